@@ -1,9 +1,21 @@
 package main
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"log"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/i18n"
+	"online-judge/internal/judge"
+	"online-judge/internal/objectstore"
 	"online-judge/internal/routes"
+	"online-judge/internal/services"
+	"online-judge/internal/store"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -13,12 +25,312 @@ func main() {
 	//	log.Fatalf("Error loading .env file")
 	//}
 
+	// Shared state lives in the store so several API replicas can run
+	// behind a load balancer (see docs/deployment.md)
+	st, err := store.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to connect to store: %v", err)
+	}
+	// Fault injection for test deployments (see docs/chaos.md)
+	chaos, err := judge.ChaosConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure chaos mode: %v", err)
+	}
+	if chaos.Enabled() {
+		log.Printf("CHAOS MODE: injecting faults %+v; never enable this in production", chaos)
+		if chaos.SlowStorage > 0 {
+			st = store.NewSlowStore(st, chaos.SlowStorage)
+		}
+	}
+	instanceID := instanceName()
+
+	authenticator, err := auth.AuthenticatorFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+
+	contestService := services.NewContestService(st)
+	seatService := services.NewSeatService(st, contestService, authenticator)
+	problemService := services.NewProblemService(st, judge.EnvAllowlistFromEnv())
+	submissionService := services.NewSubmissionService(st, contestService, problemService, seatService)
+	// An offline server judges from a contest bundle, whose test data is
+	// inlined, and never reaches object storage
+	offlineBundle := os.Getenv("OFFLINE_BUNDLE")
+	// Large test data goes straight to object storage when configured
+	var objects *objectstore.Client
+	if objectConfig, ok := objectstore.ConfigFromEnv(); ok && offlineBundle == "" {
+		objects = objectstore.New(objectConfig)
+		problemService.SetObjectStore(objects)
+	}
+	testUploadService := services.NewTestUploadService(st, problemService, objects, services.TestDataMaxBytesFromEnv())
+	submissionService.SetQueueWeights(func(problemID string) int {
+		problem, err := problemService.Get(problemID)
+		if err != nil {
+			return 1
+		}
+		return problem.QueueWeight
+	})
+	judgeClient := judge.ClientFromEnv()
+	// Regional worker pools, nearest to the submitter first
+	judgeRegion, judgeRegions, err := judge.RegionsFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure judge regions: %v", err)
+	}
+	if len(judgeRegions) > 0 {
+		judgeClient = judge.NewRegionalClient(judgeRegions)
+		submissionService.SetJudgeRegion(judgeRegion)
+		go judgeClient.RunHealthChecks(context.Background(), 10*time.Second)
+		log.Printf("Judging in regions %s, local region %q", strings.Join(judgeClient.Regions(), ", "), judgeRegion)
+	}
+	dryRunService := services.NewDryRunService(problemService, judgeClient)
+	playgroundService := services.NewPlaygroundService(services.DefaultPlaygroundConfig(), st, judgeClient)
+	printService := services.NewPrintService(st, contestService, services.PrintConfigFromEnv())
+
+	// Submission grading
+	gradingService := services.NewGradingService(problemService, submissionService, judgeClient)
+	optimizationService := services.NewOptimizationService(st, problemService)
+	gradingService.OnGraded(func(submission services.Submission, problem services.Problem) {
+		if err := optimizationService.Record(submission, problem); err != nil {
+			log.Printf("Error recording objective for submission %s: %v", submission.ID, err)
+		}
+	})
+	problemStats := services.NewProblemStatsService(st, problemService)
+	gradingService.OnGraded(func(submission services.Submission, problem services.Problem) {
+		if err := problemStats.Record(submission, problem); err != nil {
+			log.Printf("Error recording stats for submission %s: %v", submission.ID, err)
+		}
+	})
+	// Difficulty ratings estimated from the recorded stats
+	difficultyEstimator := services.NewDifficultyEstimator(st, problemService, services.DifficultyConfigFromEnv())
+	go difficultyEstimator.Run(context.Background())
+	fastestSolutions := services.NewFastestSolutionsService(st, problemService, submissionService, contestService)
+	gradingService.OnGraded(func(submission services.Submission, problem services.Problem) {
+		if err := fastestSolutions.Record(submission, problem); err != nil {
+			log.Printf("Error recording fastest solution %s: %v", submission.ID, err)
+		}
+	})
+	// Double judging on an independent worker, when configured
+	verificationConfig, verify := services.VerificationConfigFromEnv()
+	verificationService := services.NewVerificationService(st, problemService, submissionService, verificationConfig)
+	if verify {
+		gradingService.OnGraded(verificationService.Sample)
+	}
+	// Batched judgement events for external scoreboards
+	contestWebhooks := services.NewContestWebhookService(st, contestService, services.WebhookIntervalFromEnv())
+	gradingService.OnGraded(contestWebhooks.Record)
+	go contestWebhooks.Run(context.Background())
+	// Hash-chained log of every status change and verdict, for audits
+	if sink, ok := services.EventSinkFromEnv(); ok {
+		eventLog := services.NewEventLog(st, sink)
+		submissionService.OnSaved(eventLog.Record)
+		go eventLog.Run(context.Background())
+	}
+	contestPrewarmer := services.NewContestPrewarmer(contestService, problemService, services.JudgeWorkersFromEnv())
+	scoreboardService := services.NewScoreboardService(contestService, submissionService)
+	judgingLimiter := services.NewJudgingLimiter(st, services.MaxJudgingPerUserFromEnv())
+	// Fleet-wide judging slots, part of which contests may reserve
+	capacityPool := services.NewCapacityPool(st, contestService, services.JudgeCapacityFromEnv())
+	// Maintenance mode pauses dispatch while judge workers are upgraded
+	maintenanceService := services.NewMaintenanceService(st, submissionService)
+	dispatcher := services.NewSubmissionDispatcher(submissionService, gradingService, judgingLimiter, capacityPool, maintenanceService, 4)
+	go dispatcher.Run(context.Background())
+
+	watchdog := services.NewSubmissionWatchdog(st, submissionService, problemService, judgeClient, services.WatchdogConfigFromEnv())
+	go watchdog.Run(context.Background())
+	// Pipeline guarantees checked while faults are injected
+	invariantChecker := services.NewInvariantChecker(st, submissionService, services.InvariantConfigFromEnv())
+	if chaos.Enabled() {
+		gradingService.OnGraded(invariantChecker.Record)
+		go invariantChecker.Run(context.Background())
+	}
+
+	// Gradual moves of the judge workers to a new runtime directory
+	toolchainRollouts := services.NewToolchainRolloutService(st, submissionService, problemService, services.JudgeWorkersFromEnv())
+
+	// Desired judge worker count for autoscalers
+	scalingAdvisor := services.NewScalingAdvisor(submissionService, contestService, services.ScalingConfigFromEnv())
+
+	// Who may read which submission sources
+	sourceAccess := services.NewSourceAccess(submissionService, problemService, contestService)
+
+	// Anonymized read-only data for the public API
+	publicService := services.NewPublicService(st, problemService, contestService, submissionService, scoreboardService, problemStats, services.PublicConfigFromEnv())
+
+	// Cache for hot, rarely written reads such as contest lists
+	responseCache := cache.New()
+	go responseCache.RunSweeper(context.Background(), time.Minute)
+
+	// Notification channels are enabled when their configuration is present
+	var channels []services.NotificationChannel
+	if smtpConfig, ok := services.SMTPConfigFromEnv(); ok {
+		channels = append(channels, services.NewEmailChannel(smtpConfig))
+	}
+	if pushConfig, ok := services.WebPushConfigFromEnv(); ok {
+		channels = append(channels, services.NewWebPushChannel(pushConfig))
+	}
+	notificationService := services.NewNotificationService(st, channels...)
+	go notificationService.Run(context.Background())
+	preferencesService := services.NewPreferencesService(st)
+	// Rejudges recompute what is derived from verdicts once their last
+	// submission is graded again: ratings read the stats, and standings,
+	// derived on read, only need their cached copies dropped at the end
+	rejudgeReconciler := services.NewRejudgeReconciler(st, submissionService, notificationService)
+	gradingService.OnGraded(rejudgeReconciler.Record)
+	rejudgeReconciler.Register(gradingService)
+	rejudgeReconciler.Register(problemStats.Recomputer(submissionService))
+	rejudgeReconciler.Register(difficultyEstimator)
+	rejudgeReconciler.Register(services.NewRecomputer("cached standings", func(_ []string, _ []services.VerdictChange) error {
+		// Other replicas' copies expire with their TTL
+		responseCache.InvalidatePrefix(routes.ContestCachePrefix)
+		responseCache.InvalidatePrefix(routes.ProblemStatsCachePrefix)
+		responseCache.InvalidatePrefix(routes.PublicCachePrefix)
+		return nil
+	}))
+	// Solve streaks and achievements, recomputed in the background
+	achievementService := services.NewAchievementService(st, submissionService, notificationService, services.AchievementIntervalFromEnv())
+	go achievementService.Run(context.Background())
+	overrideService := services.NewOverrideService(problemService, submissionService, rejudgeReconciler)
+
+	// Submissions by email, for exam rooms without access to the web UI
+	if mailConfig, ok := services.MailIntakeConfigFromEnv(); ok {
+		var replies *services.EmailChannel
+		if smtpConfig, ok := services.SMTPConfigFromEnv(); ok {
+			replies = services.NewEmailChannel(smtpConfig)
+		}
+		go services.NewMailIntake(st, submissionService, replies, mailConfig).Run(context.Background())
+	}
+
+	backupService := services.NewBackupService(contestService, contestService.RegistrationSnapshot(), contestService.VirtualSnapshot(),
+		problemService, problemService.TestsSnapshot(), problemService.JudgeScriptsSnapshot(), problemService.UnitTestsSnapshot(),
+		submissionService, seatService, printService, contestWebhooks, capacityPool, notificationService, preferencesService)
+	contestBundler := services.NewContestBundler(contestService, problemService)
+	resolverExporter := services.NewResolverExporter(contestService, problemService, submissionService)
+	contestCloner := services.NewContestCloner(contestService, problemService, contestWebhooks)
+	// Cheating patterns flagged for contest judges
+	integrityService := services.NewIntegrityService(st, contestService, submissionService, services.IntegrityConfigFromEnv())
+	go integrityService.Run(context.Background())
+	// Data export and account deletion, carried out after a grace period
+	privacyService := services.NewPrivacyService(st, services.PrivacyConfigFromEnv(),
+		submissionService, contestService.RegistrationUserData(), contestService.VirtualUserData(),
+		problemStats.UserData(), fastestSolutions.UserData(), optimizationService.UserData(),
+		verificationService.UserData(), achievementService.UserData(), preferencesService.UserData(),
+		notificationService.UserData(), seatService.UserData(), printService.JobUserData(), printService.QuotaUserData())
+	go privacyService.Run(context.Background())
+	if offlineBundle != "" {
+		bundle, err := services.LoadBundle(offlineBundle)
+		if err != nil {
+			log.Fatalf("Failed to load offline bundle: %v", err)
+		}
+		if err := backupService.Restore(bundle); err != nil {
+			log.Fatalf("Failed to restore offline bundle: %v", err)
+		}
+		log.Printf("Judging offline from bundle %s (created %s)", offlineBundle, bundle.CreatedAt.Format(time.RFC3339))
+	}
+
+	// Contest lifecycle scheduler
+	systemTestPhase := services.NewSystemTestPhase(contestService)
+	systemTestPhase.AddJob(gradingService.RunFinalTests)
+	systemTestPhase.OnComplete(func(contest services.Contest) {
+		responseCache.InvalidatePrefix(routes.ContestCachePrefix)
+		notificationService.Broadcast(services.Notification{
+			Event: services.EventSystemTestResultsReady,
+			Title: "System test results for " + contest.Title,
+			Body:  "System testing has finished (" + string(contest.SystemTest) + ").",
+			Data:  map[string]string{"contestId": contest.ID},
+		})
+	})
+	scheduler := services.NewContestScheduler(contestService, systemTestPhase, st, instanceID, time.Second)
+	scheduler.OnTransition(func(t services.ContestTransition) {
+		log.Printf("Contest %s (%s): %s -> %s", t.Contest.ID, t.Contest.Title, t.From, t.To)
+		responseCache.InvalidatePrefix(routes.ContestCachePrefix)
+		if t.To == services.ContestRunning {
+			notificationService.Broadcast(services.Notification{
+				Event: services.EventContestStarted,
+				Title: t.Contest.Title + " has started",
+				Body:  "The contest " + t.Contest.Title + " is now running.",
+				Data:  map[string]string{"contestId": t.Contest.ID},
+			})
+		}
+	})
+	go scheduler.Run(context.Background())
+
+	// Warm the judge's test data cache before contests start
+	if objects != nil {
+		prefetcher := services.NewTestDataPrefetcher(st, contestService, problemService, judgeClient, services.PrefetchLeadFromEnv())
+		go prefetcher.Run(context.Background())
+	}
+
 	router := gin.Default()
+	// The client IP used for seat restrictions and request logs comes from
+	// X-Forwarded-For only when the connection is from a trusted proxy
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	api := router.Group("/api")
-	routes.SetupRoutes(api)
+	routes.SetupRoutes(api, routes.Dependencies{
+		Store:               st,
+		Authenticator:       authenticator,
+		ContestService:      contestService,
+		ScoreboardService:   scoreboardService,
+		PrintService:        printService,
+		SeatService:         seatService,
+		ContestPrewarmer:    contestPrewarmer,
+		ContestWebhooks:     contestWebhooks,
+		CapacityPool:        capacityPool,
+		ContestBundler:      contestBundler,
+		ResolverExporter:    resolverExporter,
+		ContestCloner:       contestCloner,
+		IntegrityService:    integrityService,
+		VerificationService: verificationService,
+		NotificationService: notificationService,
+		AchievementService:  achievementService,
+		PreferencesService:  preferencesService,
+		PrivacyService:      privacyService,
+		MaintenanceService:  maintenanceService,
+		RejudgeReconciler:   rejudgeReconciler,
+		SubmissionWatchdog:  watchdog,
+		InvariantChecker:    invariantChecker,
+		ToolchainRollouts:   toolchainRollouts,
+		ScalingAdvisor:      scalingAdvisor,
+		PublicService:       publicService,
+		BackupService:       backupService,
+		SubmissionService:   submissionService,
+		SourceAccess:        sourceAccess,
+		OverrideService:     overrideService,
+		ProblemService:      problemService,
+		TestUploadService:   testUploadService,
+		DryRunService:       dryRunService,
+		PlaygroundService:   playgroundService,
+		OptimizationService: optimizationService,
+		ProblemStats:        problemStats,
+		FastestSolutions:    fastestSolutions,
+		ResponseCache:       responseCache,
+		Locale:              i18n.DefaultLocaleFromEnv(),
+	})
 
 	// Start the server
 	if err := router.Run(":8080"); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// trustedProxies reads TRUSTED_PROXIES, a comma-separated list of proxy
+// addresses or CIDR ranges, IPv4 or IPv6. Nil trusts no proxy.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// instanceName identifies this replica, e.g. when holding the scheduler lease.
+func instanceName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + ":" + strconv.Itoa(os.Getpid())
+}
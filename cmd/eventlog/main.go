@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"online-judge/internal/services"
+	"os"
+)
+
+// eventlog checks the hash chains of a judgement event log written with
+// EVENT_LOG_FILE, and can rebuild a contest's standings from it:
+//
+//	eventlog events.jsonl
+//	eventlog -contest 3 -phase final events.jsonl
+func main() {
+	contestID := flag.String("contest", "", "contest whose standings to rebuild")
+	phase := flag.String("phase", string(services.StandingsProvisional), "standings phase, provisional or final")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open event log: %v", err)
+	}
+	defer file.Close()
+	events, err := services.ReadEventLog(file)
+	if err != nil {
+		log.Fatalf("Verification failed: %v", err)
+	}
+
+	if *contestID == "" {
+		chains := make(map[string]int)
+		for _, event := range events {
+			chains[event.ContestID]++
+		}
+		fmt.Printf("%d events in %d chains OK\n", len(events), len(chains))
+		return
+	}
+	standings, err := services.ReplayStandings(events, *contestID, services.StandingsPhase(*phase))
+	if err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(standings); err != nil {
+		log.Fatalf("Failed to write standings: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"online-judge/internal/judge"
+	"os"
+)
+
+// imagebuild builds the read-only per-language runtime directories judge
+// workers use from a declarative spec, or verifies a built one:
+//
+//	imagebuild -spec runtime.json -out /opt/judge-runtime
+//	imagebuild -verify /opt/judge-runtime/2026.10-1
+func main() {
+	specPath := flag.String("spec", "", "runtime spec to build")
+	outDir := flag.String("out", "/opt/judge-runtime", "directory that receives one subdirectory per version")
+	verifyDir := flag.String("verify", "", "built runtime version directory to verify instead of building")
+	flag.Parse()
+
+	if *verifyDir != "" {
+		manifest, err := judge.VerifyRuntime(*verifyDir)
+		if err != nil {
+			log.Fatalf("Verification failed: %v", err)
+		}
+		fmt.Printf("%s %s OK\n", manifest.Version, manifest.Digest)
+		return
+	}
+
+	if *specPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatalf("Failed to read spec: %v", err)
+	}
+	var spec judge.RuntimeSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		log.Fatalf("Failed to parse spec: %v", err)
+	}
+
+	manifest, err := judge.BuildRuntime(spec, *outDir)
+	if err != nil {
+		log.Fatalf("Build failed: %v", err)
+	}
+	fmt.Printf("%s %s (%d entries)\n", manifest.Version, manifest.Digest, len(manifest.Entries))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/judge"
+	"online-judge/internal/routes"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+
+	workDir := os.Getenv("JUDGE_WORK_DIR")
+	if workDir == "" {
+		workDir = "internal/submissions"
+	}
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		log.Fatalf("Failed to create work directory: %v", err)
+	}
+
+	// Record compiler/interpreter checksums before accepting any work
+	toolchain := judge.PinToolchains()
+	go toolchain.RunVerifier(context.Background(), time.Minute)
+
+	// Runtime directory built by cmd/imagebuild; a worker whose copy
+	// differs from its manifest must not judge
+	if runtimeDir := os.Getenv("JUDGE_RUNTIME_DIR"); runtimeDir != "" {
+		manifest, err := judge.VerifyRuntime(runtimeDir)
+		if err != nil {
+			log.Fatalf("Failed to verify runtime directory: %v", err)
+		}
+		toolchain.SetRuntime(runtimeDir, manifest)
+		log.Printf("Using runtime %s (digest %s)", manifest.Version, manifest.Digest)
+	}
+
+	// Precompiled headers and cached test data live next to the
+	// per-submission directories, whose random hex names cannot collide with
+	// "cache", "data", "binaries", "boxes" or "tools"
+	compileCache := judge.BuildCompileCache(filepath.Join(workDir, "cache"))
+
+	// Test data from object storage, kept between submissions
+	dataCache, err := judge.NewDataCache(filepath.Join(workDir, "data"), judge.DataCacheBytesFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to open test data cache: %v", err)
+	}
+
+	// Checker builds, reused across tests and submissions
+	binaryCache, err := judge.NewBinaryCache(filepath.Join(workDir, "binaries"))
+	if err != nil {
+		log.Fatalf("Failed to open binary cache: %v", err)
+	}
+
+	sandbox, err := judge.SandboxFromEnv(workDir)
+	if err != nil {
+		log.Fatalf("Invalid JUDGE_SANDBOX: %v", err)
+	}
+	log.Printf("Using the %s sandbox", sandbox.Name())
+	// Options isolate lacks fail here rather than on the first submission
+	if err := judge.CheckSandboxCapabilities(sandbox); err != nil {
+		log.Fatalf("Unsupported isolate installation: %v", err)
+	}
+
+	// Each concurrent execution runs in its own sandbox box
+	boxes, err := judge.BoxPoolFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid JUDGE_BOX_IDS: %v", err)
+	}
+
+	j := judge.New(workDir, judge.EnvAllowlistFromEnv(), toolchain, compileCache, dataCache, binaryCache, sandbox, boxes, judge.CompileLimitsFromEnv())
+
+	// Automated regression grading trades throughput for stable verdicts
+	ciMode, err := judge.CIModeFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid CI mode settings: %v", err)
+	}
+	if ciMode.Runs > 1 || len(ciMode.CPUs) > 0 {
+		log.Printf("CI mode: %d runs per execution, pinned to CPUs %v", ciMode.Runs, ciMode.CPUs)
+		ciMode.CheckHost()
+		j.SetCIMode(ciMode)
+	}
+
+	// Boxes and work directories a crash left behind, then periodically
+	// whatever a failed cleanup leaves
+	j.Reconcile()
+	go j.RunJanitor(context.Background(), judge.JanitorConfigFromEnv())
+
+	// Runtimes installed outside /usr, such as a JDK or rustup toolchain
+	mounts, err := judge.LanguageMountsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid JUDGE_LANGUAGE_MOUNTS: %v", err)
+	}
+	j.SetLanguageMounts(mounts)
+
+	// Fault injection for test deployments (see docs/chaos.md)
+	chaos, err := judge.ChaosConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid CHAOS_FAULTS: %v", err)
+	}
+	if chaos.Enabled() {
+		log.Printf("CHAOS MODE: injecting faults %+v; never enable this in production", chaos)
+		j.SetChaos(chaos)
+	}
+
+	// System calls languages' programs may not make
+	policies, guard, err := judge.SyscallPoliciesFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid JUDGE_SYSCALL_POLICIES: %v", err)
+	}
+	if len(policies) > 0 {
+		if _, err := os.Stat(guard); err != nil {
+			log.Fatalf("Syscall policies need the syscall guard: %v", err)
+		}
+		j.SetSyscallPolicies(policies, guard)
+	}
+
+	// Boxes stay initialized between executions and are emptied instead
+	j.SetWarmBoxes(judge.WarmBoxesFromEnv())
+
+	// Whoever holds the token can run code here, so there is no default
+	token := strings.TrimSpace(os.Getenv("JUDGE_TOKEN"))
+	if token == "" {
+		log.Fatalf("JUDGE_TOKEN must be set")
+	}
+
+	router := gin.Default()
+	routes.SetupJudgeRoutes(&router.RouterGroup, j, token)
+
+	// Only local callers unless JUDGE_ADDR opens the worker to the network
+	addr := os.Getenv("JUDGE_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:8081"
+	}
+
+	// Start the judge server
+	server := &http.Server{Addr: addr, Handler: router}
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.ListenAndServe() }()
+
+	// On SIGTERM, refuse new executions while the running ones finish and
+	// their responses go out, so a deploy loses no results
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer cancel()
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Judge failed to start: %v", err)
+	case <-stop.Done():
+	}
+	timeout := shutdownTimeout()
+	log.Printf("Shutting down, waiting up to %s for running executions", timeout)
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), timeout)
+	defer cancelTimeout()
+	if err := j.Shutdown(ctx); err != nil {
+		log.Printf("Executions killed at shutdown: %v", err)
+	}
+	// killed executions still answer their requests
+	ctx, cancelResponses := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelResponses()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error closing connections: %v", err)
+	}
+}
+
+// shutdownTimeout reads JUDGE_SHUTDOWN_TIMEOUT_SECONDS, how long running
+// executions may take to finish on SIGTERM, defaulting to 60 seconds.
+func shutdownTimeout() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("JUDGE_SHUTDOWN_TIMEOUT_SECONDS")); err == nil && value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return 60 * time.Second
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"online-judge/internal/judge"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// syscall-guard restricts the system calls of the program it runs, which
+// the judge starts through it for languages with a syscall policy:
+//
+//	syscall-guard -kill=ptrace,process_vm_writev -deny=clone3 -- /box/main
+//
+// It must be installed at the same path on the host and inside boxes, e.g.
+// /usr/local/bin, and built statically (CGO_ENABLED=0).
+func main() {
+	kill := flag.String("kill", "", "comma-separated system calls that kill the program")
+	deny := flag.String("deny", "", "comma-separated system calls that fail with ENOSYS")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	policy := judge.SyscallPolicy{Kill: splitList(*kill), Deny: splitList(*deny)}
+	if err := judge.InstallSyscallPolicy(policy); err != nil {
+		fmt.Fprintf(os.Stderr, "syscall-guard: %v\n", err)
+		os.Exit(2)
+	}
+	// exec right away: the Go runtime is held to the policy from here on
+	err := syscall.Exec(flag.Arg(0), flag.Args(), os.Environ())
+	fmt.Fprintf(os.Stderr, "syscall-guard: exec %s: %v\n", flag.Arg(0), err)
+	os.Exit(2)
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleJudge Role = "judge"
+	RoleAdmin Role = "admin"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID string `json:"userId"`
+	Role   Role   `json:"role"`
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// HasRole reports whether the principal has role or a more privileged one.
+func (p Principal) HasRole(role Role) bool {
+	return roleRank[p.Role] >= roleRank[role]
+}
+
+var roleRank = map[Role]int{
+	RoleUser:  1,
+	RoleJudge: 2,
+	RoleAdmin: 3,
+}
+
+type claims struct {
+	Role Role `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator verifies HS256 JWTs. Tokens are issued by the identity
+// provider that shares JWT_SECRET; the subject claim is the user ID.
+type Authenticator struct {
+	secret []byte
+}
+
+func NewAuthenticator(secret []byte) *Authenticator {
+	return &Authenticator{secret: secret}
+}
+
+// AuthenticatorFromEnv reads JWT_SECRET.
+func AuthenticatorFromEnv() (*Authenticator, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("JWT_SECRET is not set")
+	}
+	return NewAuthenticator([]byte(secret)), nil
+}
+
+func (a *Authenticator) Verify(token string) (Principal, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return a.secret, nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil || c.Subject == "" {
+		return Principal{}, ErrInvalidToken
+	}
+
+	role := c.Role
+	if _, ok := roleRank[role]; !ok {
+		role = RoleUser
+	}
+	return Principal{UserID: c.Subject, Role: role}, nil
+}
+
+// Issue signs a token for the principal. It is used by tooling and tests;
+// end-user tokens normally come from the identity provider.
+func (a *Authenticator) Issue(principal Principal, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Role: principal.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   principal.UserID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+	return token.SignedString(a.secret)
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value     any
+	expiresAt time.Time
+}
+
+// Cache is an in-memory key/value store with per-entry TTL. Keys are
+// namespaced by prefix (e.g. "contests:") so related entries can be
+// invalidated together after a write.
+type Cache struct {
+	mu      sync.RWMutex
+	entries map[string]entry
+}
+
+func New() *Cache {
+	return &Cache{entries: make(map[string]entry)}
+}
+
+func (c *Cache) Get(key string) (any, bool) {
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(e.expiresAt) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *Cache) Set(key string, value any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry{value: value, expiresAt: time.Now().Add(ttl)}
+}
+
+// InvalidatePrefix removes every entry whose key starts with prefix.
+func (c *Cache) InvalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Purge removes every entry.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]entry)
+}
+
+// Sweep drops expired entries. It is called periodically so keys that are
+// never read again do not accumulate.
+func (c *Cache) Sweep() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// RunSweeper calls Sweep every interval until ctx is cancelled.
+func (c *Cache) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Sweep()
+		}
+	}
+}
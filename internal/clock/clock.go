@@ -0,0 +1,88 @@
+// Package clock puts the current time and generated identifiers behind
+// interfaces, so code that depends on them can run against a fixed clock
+// and predictable IDs.
+package clock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// System is the wall clock.
+var System Clock = systemClock{}
+
+// Manual is a clock that only moves when told to.
+type Manual struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewManual(now time.Time) *Manual {
+	return &Manual{now: now}
+}
+
+func (c *Manual) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Manual) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *Manual) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// IDGenerator names things that need an unguessable or collision-free
+// name, such as sessions and work directories.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// RandomIDs generates hex IDs of the given number of random bytes.
+type RandomIDs int
+
+func (n RandomIDs) NewID() (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Sequence generates prefix1, prefix2, and so on.
+type Sequence struct {
+	mu     sync.Mutex
+	prefix string
+	next   int
+}
+
+func NewSequence(prefix string) *Sequence {
+	return &Sequence{prefix: prefix, next: 1}
+}
+
+func (s *Sequence) NewID() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.prefix + strconv.Itoa(s.next)
+	s.next++
+	return id, nil
+}
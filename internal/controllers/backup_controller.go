@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type BackupController struct {
+	backupService *services.BackupService
+}
+
+func NewBackupController(backupService *services.BackupService) *BackupController {
+	return &BackupController{backupService: backupService}
+}
+
+// Export returns a full snapshot as a downloadable JSON document.
+func (ctrl *BackupController) Export(c *gin.Context) {
+	snapshot, err := ctrl.backupService.Export()
+	if err != nil {
+		log.Printf("Error exporting snapshot: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export snapshot"})
+		return
+	}
+
+	filename := fmt.Sprintf("online-judge-%s.json", snapshot.CreatedAt.Format("20060102-150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// Restore replaces the server state with the uploaded snapshot.
+func (ctrl *BackupController) Restore(c *gin.Context) {
+	var snapshot services.Snapshot
+	if err := c.ShouldBindJSON(&snapshot); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ctrl.backupService.Restore(&snapshot); err != nil {
+		if errors.Is(err, services.ErrUnsupportedSnapshot) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error restoring snapshot: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type BundleController struct {
+	bundler *services.ContestBundler
+}
+
+func NewBundleController(bundler *services.ContestBundler) *BundleController {
+	return &BundleController{bundler: bundler}
+}
+
+// Export returns the contest's offline bundle as a downloadable JSON
+// document.
+func (ctrl *BundleController) Export(c *gin.Context) {
+	bundle, err := ctrl.bundler.Export(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrContestNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error exporting bundle for contest %s: %v", c.Param("id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export contest bundle"})
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("contest-%s-%s.json", c.Param("id"), bundle.CreatedAt.Format("20060102-150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.JSON(http.StatusOK, bundle)
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type ContestCloneController struct {
+	cloner *services.ContestCloner
+}
+
+func NewContestCloneController(cloner *services.ContestCloner) *ContestCloneController {
+	return &ContestCloneController{cloner: cloner}
+}
+
+// Clone copies the contest onto the schedule in the request body.
+func (ctrl *ContestCloneController) Clone(c *gin.Context) {
+	var clone services.ContestClone
+	if err := c.ShouldBindJSON(&clone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contest, err := ctrl.cloner.Clone(c.Param("id"), clone)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrContestNotFound),
+			errors.Is(err, services.ErrProblemNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidContestTimes):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error cloning contest %s: %v", c.Param("id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clone contest"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, contest)
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"time"
+)
+
+type ContestController struct {
+	contestService    *services.ContestService
+	scoreboardService *services.ScoreboardService
+}
+
+func NewContestController(contestService *services.ContestService, scoreboardService *services.ScoreboardService) *ContestController {
+	return &ContestController{contestService: contestService, scoreboardService: scoreboardService}
+}
+
+type createContestRequest struct {
+	Title      string     `json:"title" binding:"required"`
+	StartTime  time.Time  `json:"startTime" binding:"required"`
+	FreezeTime *time.Time `json:"freezeTime"`
+	EndTime    time.Time  `json:"endTime" binding:"required"`
+	Problems   []string   `json:"problems"`
+}
+
+func (ctrl *ContestController) CreateContest(c *gin.Context) {
+	var req createContestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contest, err := ctrl.contestService.Create(services.Contest{
+		Title:      req.Title,
+		StartTime:  req.StartTime,
+		FreezeTime: req.FreezeTime,
+		EndTime:    req.EndTime,
+		Problems:   req.Problems,
+	})
+	if errors.Is(err, services.ErrInvalidContestTimes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating contest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create contest"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, contest)
+}
+
+func (ctrl *ContestController) ListContests(c *gin.Context) {
+	contests, err := ctrl.contestService.List()
+	if err != nil {
+		log.Printf("Error listing contests: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list contests"})
+		return
+	}
+
+	respondList(c, "contests", contests, "startTime")
+}
+
+func (ctrl *ContestController) GetContest(c *gin.Context) {
+	contest, err := ctrl.contestService.Get(c.Param("id"))
+	if errors.Is(err, services.ErrContestNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading contest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load contest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, contest)
+}
+
+func (ctrl *ContestController) Register(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	err := ctrl.contestService.Register(c.Param("id"), principal.UserID)
+	switch {
+	case errors.Is(err, services.ErrContestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRegistrationClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error registering for contest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register"})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// Resolve lifts a finished contest's freeze.
+func (ctrl *ContestController) Resolve(c *gin.Context) {
+	contest, err := ctrl.contestService.Resolve(c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrContestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrContestNotFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error resolving contest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve contest"})
+	default:
+		c.JSON(http.StatusOK, contest)
+	}
+}
+
+// Standings returns the contest standings, frozen until the contest is
+// resolved. The phase query parameter selects provisional (default) or
+// final standings.
+func (ctrl *ContestController) Standings(c *gin.Context) {
+	ctrl.standings(c, false)
+}
+
+// LiveStandings returns the standings with the results of the frozen period,
+// for staff.
+func (ctrl *ContestController) LiveStandings(c *gin.Context) {
+	ctrl.standings(c, true)
+}
+
+func (ctrl *ContestController) standings(c *gin.Context, live bool) {
+	phase := services.StandingsPhase(c.DefaultQuery("phase", string(services.StandingsProvisional)))
+
+	standings, err := ctrl.scoreboardService.Standings(c.Param("id"), phase, live)
+	switch {
+	case errors.Is(err, services.ErrInvalidStandingsPhase):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrContestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFinalStandingsNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error computing standings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute standings"})
+	default:
+		c.JSON(http.StatusOK, standings)
+	}
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type ContestWebhookController struct {
+	webhookService *services.ContestWebhookService
+}
+
+func NewContestWebhookController(webhookService *services.ContestWebhookService) *ContestWebhookController {
+	return &ContestWebhookController{webhookService: webhookService}
+}
+
+func (ctrl *ContestWebhookController) GetWebhook(c *gin.Context) {
+	webhook, err := ctrl.webhookService.Get(c.Param("id"))
+	if err != nil {
+		respondContestWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// SetWebhook configures where the contest's judgements are sent.
+func (ctrl *ContestWebhookController) SetWebhook(c *gin.Context) {
+	var webhook services.ContestWebhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	webhook.ContestID = c.Param("id")
+
+	webhook, err := ctrl.webhookService.Set(webhook)
+	if err != nil {
+		respondContestWebhookError(c, err)
+		return
+	}
+	webhook.Secret = ""
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (ctrl *ContestWebhookController) DeleteWebhook(c *gin.Context) {
+	if err := ctrl.webhookService.Delete(c.Param("id")); err != nil {
+		respondContestWebhookError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondContestWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrContestNotFound), errors.Is(err, services.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidWebhookURL):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Contest webhook error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Contest webhook request failed"})
+	}
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"strconv"
+)
+
+type FastestController struct {
+	fastestService *services.FastestSolutionsService
+}
+
+func NewFastestController(fastestService *services.FastestSolutionsService) *FastestController {
+	return &FastestController{fastestService: fastestService}
+}
+
+type fastestSharingRequest struct {
+	ShareSource bool `json:"shareSource"`
+}
+
+// Leaderboard returns the fastest accepted solutions in the language query
+// parameter, at most limit of them.
+func (ctrl *FastestController) Leaderboard(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = n
+	}
+
+	board, err := ctrl.fastestService.Leaderboard(c.Param("id"), c.Query("language"), limit)
+	if err != nil {
+		respondFastestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"leaderboard": board})
+}
+
+// GetSharing returns whether the caller shows their sources on the
+// problem's leaderboard.
+func (ctrl *FastestController) GetSharing(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	sharing, err := ctrl.fastestService.Sharing(c.Param("id"), principal.UserID)
+	if err != nil {
+		respondFastestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sharing)
+}
+
+func (ctrl *FastestController) SetSharing(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	var req fastestSharingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sharing, err := ctrl.fastestService.SetSharing(services.FastestSharing{
+		ProblemID:   c.Param("id"),
+		UserID:      principal.UserID,
+		ShareSource: req.ShareSource,
+	})
+	if err != nil {
+		respondFastestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sharing)
+}
+
+func respondFastestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProblemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLanguageRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Fastest solutions error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Fastest solutions request failed"})
+	}
+}
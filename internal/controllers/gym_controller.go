@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+// GymController exposes finished contests as a practice archive that users
+// can browse and replay as virtual participants.
+type GymController struct {
+	contestService    *services.ContestService
+	scoreboardService *services.ScoreboardService
+}
+
+func NewGymController(contestService *services.ContestService, scoreboardService *services.ScoreboardService) *GymController {
+	return &GymController{contestService: contestService, scoreboardService: scoreboardService}
+}
+
+type gymContest struct {
+	services.Contest
+	DurationSeconds int64 `json:"durationSeconds"`
+}
+
+func newGymContest(contest services.Contest) gymContest {
+	return gymContest{Contest: contest, DurationSeconds: int64(contest.EndTime.Sub(contest.StartTime).Seconds())}
+}
+
+// ListGym lists finished contests, most recent first.
+func (ctrl *GymController) ListGym(c *gin.Context) {
+	contests, err := ctrl.contestService.List()
+	if err != nil {
+		log.Printf("Error listing gym contests: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list contests"})
+		return
+	}
+
+	gym := make([]gymContest, 0, len(contests))
+	for _, contest := range contests {
+		if contest.Status == services.ContestFinished {
+			gym = append(gym, newGymContest(contest))
+		}
+	}
+	respondList(c, "contests", gym, "-startTime")
+}
+
+func (ctrl *GymController) GetGymContest(c *gin.Context) {
+	contest, err := ctrl.finishedContest(c.Param("id"))
+	if err != nil {
+		respondGymError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newGymContest(contest))
+}
+
+// StartVirtual starts the caller's virtual participation.
+func (ctrl *GymController) StartVirtual(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	virtual, err := ctrl.contestService.StartVirtual(c.Param("id"), principal.UserID)
+	if err != nil {
+		respondGymError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, virtual)
+}
+
+// GetVirtual returns the caller's virtual participation.
+func (ctrl *GymController) GetVirtual(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	virtual, ok, err := ctrl.contestService.Virtual(c.Param("id"), principal.UserID)
+	if err != nil {
+		respondGymError(c, err)
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no virtual participation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, virtual)
+}
+
+// PracticeStandings ranks virtual participants, separately from the
+// contest's official standings.
+func (ctrl *GymController) PracticeStandings(c *gin.Context) {
+	if _, err := ctrl.finishedContest(c.Param("id")); err != nil {
+		respondGymError(c, err)
+		return
+	}
+
+	standings, err := ctrl.scoreboardService.PracticeStandings(c.Param("id"))
+	if err != nil {
+		respondGymError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
+
+// finishedContest hides contests that are not part of the gym yet.
+func (ctrl *GymController) finishedContest(id string) (services.Contest, error) {
+	contest, err := ctrl.contestService.Get(id)
+	if err != nil {
+		return services.Contest{}, err
+	}
+	if contest.Status != services.ContestFinished {
+		return services.Contest{}, services.ErrContestNotFinished
+	}
+	return contest, nil
+}
+
+func respondGymError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrContestNotFound), errors.Is(err, services.ErrContestNotFinished):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVirtualExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Gym error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gym request failed"})
+	}
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"time"
+)
+
+type IntegrityController struct {
+	integrityService *services.IntegrityService
+}
+
+func NewIntegrityController(integrityService *services.IntegrityService) *IntegrityController {
+	return &IntegrityController{integrityService: integrityService}
+}
+
+// ListFlags lists the contest's suspicious submission patterns, optionally
+// filtered by the status query parameter.
+func (ctrl *IntegrityController) ListFlags(c *gin.Context) {
+	flags, err := ctrl.integrityService.List(c.Param("id"), services.IntegrityFlagStatus(c.Query("status")))
+	if err != nil {
+		respondIntegrityError(c, err)
+		return
+	}
+
+	respondList(c, "flags", flags, "detectedAt")
+}
+
+// Analyze runs the analysis now instead of waiting for the next tick.
+func (ctrl *IntegrityController) Analyze(c *gin.Context) {
+	flags, err := ctrl.integrityService.Analyze(c.Param("id"), time.Now())
+	if err != nil {
+		respondIntegrityError(c, err)
+		return
+	}
+
+	respondList(c, "flags", flags, "detectedAt")
+}
+
+type reviewIntegrityFlagRequest struct {
+	Status services.IntegrityFlagStatus `json:"status" binding:"required"`
+	Note   string                       `json:"note"`
+}
+
+// ReviewFlag records whether a flag was confirmed or dismissed.
+func (ctrl *IntegrityController) ReviewFlag(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	var req reviewIntegrityFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	flag, err := ctrl.integrityService.Review(c.Param("id"), c.Param("flagId"), principal.UserID, req.Status, req.Note)
+	if err != nil {
+		respondIntegrityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+func respondIntegrityError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrContestNotFound), errors.Is(err, services.ErrIntegrityFlagNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReview):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Integrity review error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Integrity review request failed"})
+	}
+}
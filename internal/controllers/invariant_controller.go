@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type InvariantController struct {
+	checker *services.InvariantChecker
+}
+
+func NewInvariantController(checker *services.InvariantChecker) *InvariantController {
+	return &InvariantController{checker: checker}
+}
+
+// ListViolations returns the submissions chaos testing found lost or
+// scored twice.
+func (ctrl *InvariantController) ListViolations(c *gin.Context) {
+	violations, err := ctrl.checker.Violations()
+	if err != nil {
+		log.Printf("Invariant violations error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invariant violations"})
+		return
+	}
+
+	respondList(c, "violations", violations, "detectedAt")
+}
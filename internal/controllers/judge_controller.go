@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"io"
+	"log"
+	"net/http"
+	"online-judge/internal/clock"
+	"online-judge/internal/judge"
+)
+
+var interactiveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+type JudgeController struct {
+	judge *judge.Judge
+	// runIDs name interactive runs that came without a run ID, so they
+	// can be killed when their client goes away
+	runIDs clock.IDGenerator
+}
+
+func NewJudgeController(j *judge.Judge) *JudgeController {
+	return &JudgeController{judge: j, runIDs: clock.RandomIDs(8)}
+}
+
+func (ctrl *JudgeController) Submit(c *gin.Context) {
+	var submission judge.Submission
+	if err := c.ShouldBindJSON(&submission); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := ctrl.judge.Execute(submission)
+	if err != nil {
+		respondExecuteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// StreamSubmit executes a submission like Submit, answering with
+// newline-delimited judge.StreamEvent: the program's output as it is
+// written, then the result. Errors before any output get Submit's answers.
+func (ctrl *JudgeController) StreamSubmit(c *gin.Context) {
+	var submission judge.Submission
+	if err := c.ShouldBindJSON(&submission); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chunks := make(chan judge.OutputChunk, 16)
+	submission.Output = chunks
+	var result judge.ExecutionResult
+	var err error
+	go func() {
+		defer close(chunks)
+		result, err = ctrl.judge.Execute(submission)
+	}()
+
+	encoder := json.NewEncoder(c.Writer)
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+	}
+	for chunk := range chunks {
+		start()
+		// a client that went away is noticed when the run ends
+		encoder.Encode(judge.StreamEvent{Chunk: &chunk})
+		c.Writer.Flush()
+	}
+	if err != nil && !started {
+		respondExecuteError(c, err)
+		return
+	}
+	if errors.Is(err, judge.ErrWorkerDropped) {
+		dropConnection(c)
+		return
+	}
+	start()
+	if err != nil {
+		log.Printf("Error executing streamed submission: %v", err)
+		encoder.Encode(judge.StreamEvent{Error: err.Error()})
+		return
+	}
+	encoder.Encode(judge.StreamEvent{Result: &result})
+}
+
+// InteractiveSubmit executes a submission whose input is streamed over a
+// WebSocket while the program runs. The client sends the submission, then
+// judge.StdinMessage; the worker sends judge.StreamEvent, the output as it
+// is written and last the result or an error. A client that goes away
+// kills the run.
+func (ctrl *JudgeController) InteractiveSubmit(c *gin.Context) {
+	conn, err := interactiveUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Error upgrading interactive run: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var submission judge.Submission
+	if err := conn.ReadJSON(&submission); err != nil {
+		return
+	}
+	if submission.RunID == "" {
+		if submission.RunID, err = ctrl.runIDs.NewID(); err != nil {
+			conn.WriteJSON(judge.StreamEvent{Error: err.Error()})
+			return
+		}
+	}
+	stdin, stdinWriter := io.Pipe()
+	chunks := make(chan judge.OutputChunk, 16)
+	submission.Stdin = stdin
+	submission.Output = chunks
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			var msg judge.StdinMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				stdinWriter.CloseWithError(err)
+				select {
+				case <-done:
+				default:
+					ctrl.judge.Kill(submission.RunID)
+				}
+				return
+			}
+			if msg.EOF {
+				stdinWriter.Close()
+				continue
+			}
+			// fails once the program stopped reading
+			io.WriteString(stdinWriter, msg.Data)
+		}
+	}()
+
+	var result judge.ExecutionResult
+	go func() {
+		defer close(chunks)
+		result, err = ctrl.judge.Execute(submission)
+		stdin.Close()
+	}()
+	for chunk := range chunks {
+		// a client that went away kills the run
+		conn.WriteJSON(judge.StreamEvent{Chunk: &chunk})
+	}
+	if errors.Is(err, judge.ErrWorkerDropped) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error executing interactive submission: %v", err)
+		conn.WriteJSON(judge.StreamEvent{Error: err.Error()})
+		return
+	}
+	conn.WriteJSON(judge.StreamEvent{Result: &result})
+}
+
+// respondExecuteError answers for an execution that failed: 400 for
+// submissions the worker refuses, 503 for ones another worker may run.
+func respondExecuteError(c *gin.Context, err error) {
+	if errors.Is(err, judge.ErrUnsupportedLanguage) ||
+		errors.Is(err, judge.ErrInvalidLimits) ||
+		errors.Is(err, judge.ErrInvalidFile) ||
+		errors.Is(err, judge.ErrEnvNotAllowed) ||
+		errors.Is(err, judge.ErrInvalidDataRef) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, judge.ErrToolchainModified) || errors.Is(err, judge.ErrToolchainUnavailable) ||
+		errors.Is(err, judge.ErrDataCacheMissing) || errors.Is(err, judge.ErrDraining) ||
+		errors.Is(err, judge.ErrNetworkUnavailable) || errors.Is(err, judge.ErrInteractiveUnsupported) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, judge.ErrWorkerDropped) {
+		dropConnection(c)
+		return
+	}
+	if errors.Is(err, judge.ErrRunKilled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Error executing submission: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to execute submission"})
+}
+
+type prefetchRequest struct {
+	Refs []judge.DataRef `json:"refs" binding:"required,dive"`
+}
+
+// Prefetch starts downloading test data into the worker's cache. With
+// wait=true it answers once every download has finished, with the outcome
+// of each.
+func (ctrl *JudgeController) Prefetch(c *gin.Context) {
+	var req prefetchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wait := c.Query("wait") == "true"
+	results, err := ctrl.judge.PrefetchData(req.Refs, wait)
+	if err != nil {
+		if errors.Is(err, judge.ErrInvalidDataRef) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	if wait {
+		c.JSON(http.StatusOK, gin.H{"results": results})
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// KillRun stops the executions started with the run ID.
+func (ctrl *JudgeController) KillRun(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"killed": ctrl.judge.Kill(c.Param("id"))})
+}
+
+// Health answers 503 while the worker drains, so load balancers stop
+// sending it executions.
+func (ctrl *JudgeController) Health(c *gin.Context) {
+	status := ctrl.judge.Maintenance()
+	if status.Draining {
+		c.JSON(http.StatusServiceUnavailable, status)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (ctrl *JudgeController) Maintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, ctrl.judge.Maintenance())
+}
+
+func (ctrl *JudgeController) Drain(c *gin.Context) {
+	ctrl.judge.Drain()
+	c.JSON(http.StatusOK, ctrl.judge.Maintenance())
+}
+
+func (ctrl *JudgeController) Enable(c *gin.Context) {
+	ctrl.judge.Enable()
+	c.JSON(http.StatusOK, ctrl.judge.Maintenance())
+}
+
+type swapRuntimeRequest struct {
+	Dir string `json:"dir"`
+}
+
+// SwapRuntime switches a drained worker to another runtime directory.
+func (ctrl *JudgeController) SwapRuntime(c *gin.Context) {
+	var req swapRuntimeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := ctrl.judge.SwapRuntime(req.Dir); err != nil {
+		if errors.Is(err, judge.ErrNotDrained) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ctrl.judge.Maintenance())
+}
+
+// SelfTest runs a small program in every language.
+func (ctrl *JudgeController) SelfTest(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"results": ctrl.judge.SelfTest()})
+}
+
+func (ctrl *JudgeController) Languages(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"languages": judge.LanguageNames()})
+}
+
+func (ctrl *JudgeController) Toolchain(c *gin.Context) {
+	c.JSON(http.StatusOK, ctrl.judge.ToolchainStatus())
+}
+
+// dropConnection closes the connection without a response, as a crashed
+// worker would.
+func dropConnection(c *gin.Context) {
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	conn.Close()
+}
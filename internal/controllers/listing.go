@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/listing"
+)
+
+// respondList writes a page of items under key, following the request's
+// limit, cursor, sort and fields parameters. defaultSort applies when the
+// request does not set sort.
+func respondList[T any](c *gin.Context, key string, items []T, defaultSort string) {
+	query, err := listing.ParseQuery(c.Request.URL.Query(), defaultSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := listing.Apply(items, query)
+	if errors.Is(err, listing.ErrInvalidCursor) || errors.Is(err, listing.ErrUnknownField) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error paging %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list " + key})
+		return
+	}
+
+	body := gin.H{key: page.Items}
+	if page.NextCursor != "" {
+		body["nextCursor"] = page.NextCursor
+	}
+	c.JSON(http.StatusOK, body)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type MaintenanceController struct {
+	maintenanceService *services.MaintenanceService
+}
+
+func NewMaintenanceController(maintenanceService *services.MaintenanceService) *MaintenanceController {
+	return &MaintenanceController{maintenanceService: maintenanceService}
+}
+
+type pauseRequest struct {
+	Message string `json:"message"`
+}
+
+// GetStatus tells anyone whether judging is paused.
+func (ctrl *MaintenanceController) GetStatus(c *gin.Context) {
+	status, err := ctrl.maintenanceService.Status()
+	if err != nil {
+		respondMaintenanceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Pause stops judging; submissions keep being accepted into the queue.
+func (ctrl *MaintenanceController) Pause(c *gin.Context) {
+	var req pauseRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	principal, _ := middleware.CurrentPrincipal(c)
+	status, err := ctrl.maintenanceService.Pause(principal.UserID, req.Message)
+	if err != nil {
+		respondMaintenanceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (ctrl *MaintenanceController) Resume(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	status, err := ctrl.maintenanceService.Resume(principal.UserID)
+	if err != nil {
+		respondMaintenanceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func respondMaintenanceError(c *gin.Context, err error) {
+	log.Printf("Maintenance mode error: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Maintenance mode request failed"})
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type NotificationController struct {
+	notificationService *services.NotificationService
+}
+
+func NewNotificationController(notificationService *services.NotificationService) *NotificationController {
+	return &NotificationController{notificationService: notificationService}
+}
+
+type subscribeRequest struct {
+	Email  string                       `json:"email" binding:"omitempty,email"`
+	Push   []services.PushSubscription  `json:"push" binding:"dive"`
+	Events []services.NotificationEvent `json:"events"`
+}
+
+// subscriptionUser is the user whose subscription a request is about: the
+// caller, or the user an admin route names.
+func subscriptionUser(c *gin.Context) string {
+	if userID := c.Param("userId"); userID != "" {
+		return userID
+	}
+	principal, _ := middleware.CurrentPrincipal(c)
+	return principal.UserID
+}
+
+func (ctrl *NotificationController) GetSubscription(c *gin.Context) {
+	subscription, err := ctrl.notificationService.Subscription(subscriptionUser(c))
+	if errors.Is(err, services.ErrSubscriptionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading notification subscription: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+func (ctrl *NotificationController) Subscribe(c *gin.Context) {
+	var req subscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription := services.NotificationSubscription{
+		UserID: subscriptionUser(c),
+		Email:  req.Email,
+		Push:   req.Push,
+		Events: req.Events,
+	}
+	subscription, err := ctrl.notificationService.Subscribe(subscription)
+	if errors.Is(err, services.ErrNoNotificationTarget) || errors.Is(err, services.ErrInvalidPushEndpoint) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving notification subscription: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+type confirmEmailRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ConfirmEmail confirms the caller's address with the code mailed to it.
+func (ctrl *NotificationController) ConfirmEmail(c *gin.Context) {
+	var req confirmEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := ctrl.notificationService.ConfirmEmail(subscriptionUser(c), req.Code)
+	if err != nil {
+		respondEmailConfirmationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// ResendConfirmation mails a new confirmation code to the caller's address.
+func (ctrl *NotificationController) ResendConfirmation(c *gin.Context) {
+	if err := ctrl.notificationService.ResendConfirmation(subscriptionUser(c)); err != nil {
+		respondEmailConfirmationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func respondEmailConfirmationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidEmailCode), errors.Is(err, services.ErrNoNotificationTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailAlreadyConfirmed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrConfirmationTooSoon):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error confirming notification email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm email"})
+	}
+}
+
+func (ctrl *NotificationController) Unsubscribe(c *gin.Context) {
+	if err := ctrl.notificationService.Unsubscribe(subscriptionUser(c)); err != nil {
+		log.Printf("Error removing notification subscription: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove subscription"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
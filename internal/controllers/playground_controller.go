@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"io"
+	"log"
+	"net/http"
+	"online-judge/internal/judge"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+var playgroundUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+type PlaygroundController struct {
+	playgroundService *services.PlaygroundService
+}
+
+func NewPlaygroundController(playgroundService *services.PlaygroundService) *PlaygroundController {
+	return &PlaygroundController{playgroundService: playgroundService}
+}
+
+type createSessionRequest struct {
+	Language string `json:"language" binding:"required"`
+}
+
+type runSessionRequest struct {
+	Entry string `json:"entry" binding:"required"`
+	Stdin string `json:"stdin"`
+}
+
+// streamMessage is exchanged over the interactive run WebSocket. Clients send
+// "stdin" and "eof" messages; the server sends "output", with the stream
+// written to, and a final "exit".
+type streamMessage struct {
+	Type   string                        `json:"type"`
+	Stream string                        `json:"stream,omitempty"`
+	Data   string                        `json:"data,omitempty"`
+	Result *services.PlaygroundRunResult `json:"result,omitempty"`
+}
+
+// CreateSession starts a session owned by the caller; only they can use it.
+func (ctrl *PlaygroundController) CreateSession(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	var req createSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := ctrl.playgroundService.CreateSession(principal.UserID, req.Language)
+	if err != nil {
+		respondPlaygroundError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+func (ctrl *PlaygroundController) GetSession(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	session, err := ctrl.playgroundService.GetSession(c.Param("id"), principal.UserID)
+	if err != nil {
+		respondPlaygroundError(c, err)
+		return
+	}
+
+	files, err := ctrl.playgroundService.ListFiles(session.ID, principal.UserID)
+	if err != nil {
+		respondPlaygroundError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session": session,
+		"files":   files,
+	})
+}
+
+func (ctrl *PlaygroundController) DeleteSession(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	if err := ctrl.playgroundService.DeleteSession(c.Param("id"), principal.UserID); err != nil {
+		respondPlaygroundError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PutFile stores the raw request body as a file in the session.
+func (ctrl *PlaygroundController) PutFile(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	// the limit stops reading a larger body instead of buffering it whole
+	content, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, ctrl.playgroundService.MaxFileSize()))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondPlaygroundError(c, services.ErrFileTooLarge)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file content"})
+		return
+	}
+
+	if err := ctrl.playgroundService.WriteFile(c.Param("id"), principal.UserID, c.Param("name"), content); err != nil {
+		respondPlaygroundError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Run runs the entry file on a judge worker and answers with its result.
+func (ctrl *PlaygroundController) Run(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	var req runSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := ctrl.playgroundService.Run(c.Request.Context(), c.Param("id"), principal.UserID, req.Entry, req.Stdin)
+	if err != nil {
+		respondPlaygroundError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// StreamRun runs the entry file interactively on a judge worker, forwarding
+// stdin from the WebSocket to the program and streaming its output back.
+// Only the session's owner can start it.
+func (ctrl *PlaygroundController) StreamRun(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	process, err := ctrl.playgroundService.Start(c.Param("id"), principal.UserID, c.Query("entry"))
+	if err != nil {
+		respondPlaygroundError(c, err)
+		return
+	}
+
+	conn, err := playgroundUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Error upgrading playground stream: %v", err)
+		process.Kill()
+		for range process.Output {
+		}
+		return
+	}
+	defer conn.Close()
+
+	go func() {
+		defer process.Stdin.Close()
+		for {
+			var msg streamMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				process.Kill()
+				return
+			}
+			switch msg.Type {
+			case "stdin":
+				if _, err := io.WriteString(process.Stdin, msg.Data); err != nil {
+					return
+				}
+			case "eof":
+				return
+			}
+		}
+	}()
+
+	for chunk := range process.Output {
+		if err := conn.WriteJSON(streamMessage{Type: "output", Stream: chunk.Stream, Data: chunk.Data}); err != nil {
+			process.Kill()
+		}
+	}
+
+	conn.WriteJSON(streamMessage{Type: "exit", Result: process.Wait()})
+}
+
+func respondPlaygroundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSessionNotFound), errors.Is(err, services.ErrEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSessionExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSessionBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrQuotaExceeded), errors.Is(err, services.ErrSessionLimitHit), errors.Is(err, services.ErrUserSessionsHit):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidFileName),
+		errors.Is(err, services.ErrTooManyFiles),
+		errors.Is(err, services.ErrUnknownLanguage),
+		errors.Is(err, services.ErrReservedFile),
+		errors.Is(err, judge.ErrRejected):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, judge.ErrJudgeUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Playground error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Playground request failed"})
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type PreferencesController struct {
+	preferencesService *services.PreferencesService
+}
+
+func NewPreferencesController(preferencesService *services.PreferencesService) *PreferencesController {
+	return &PreferencesController{preferencesService: preferencesService}
+}
+
+// GetPreferences returns the caller's editor settings.
+func (ctrl *PreferencesController) GetPreferences(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	preferences, err := ctrl.preferencesService.Get(principal.UserID)
+	if err != nil {
+		respondPreferencesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// SetPreferences replaces the caller's editor settings with the request
+// body.
+func (ctrl *PreferencesController) SetPreferences(c *gin.Context) {
+	var preferences services.UserPreferences
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	principal, _ := middleware.CurrentPrincipal(c)
+	preferences.UserID = principal.UserID
+
+	preferences, err := ctrl.preferencesService.Set(preferences)
+	if err != nil {
+		respondPreferencesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+func (ctrl *PreferencesController) ResetPreferences(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	if err := ctrl.preferencesService.Reset(principal.UserID); err != nil {
+		respondPreferencesError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondPreferencesError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPreferences):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Preferences error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Preferences request failed"})
+	}
+}
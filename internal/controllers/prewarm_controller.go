@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type PrewarmController struct {
+	prewarmer *services.ContestPrewarmer
+}
+
+func NewPrewarmController(prewarmer *services.ContestPrewarmer) *PrewarmController {
+	return &PrewarmController{prewarmer: prewarmer}
+}
+
+// Prewarm prepares every judge worker for the contest and returns the
+// readiness checklist. It answers 200 even when checks fail; the report's
+// ready flag tells whether the contest can start.
+func (ctrl *PrewarmController) Prewarm(c *gin.Context) {
+	report, err := ctrl.prewarmer.Prewarm(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrContestNotFound) || errors.Is(err, services.ErrProblemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Prewarm error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Prewarm request failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
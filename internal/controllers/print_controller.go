@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type PrintController struct {
+	printService *services.PrintService
+}
+
+func NewPrintController(printService *services.PrintService) *PrintController {
+	return &PrintController{printService: printService}
+}
+
+type printRequest struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content" binding:"required"`
+}
+
+// SubmitPrint queues source or text for printing on behalf of the caller's
+// team.
+func (ctrl *PrintController) SubmitPrint(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	var req printRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := ctrl.printService.Submit(principal, c.Param("id"), req.Filename, req.Content)
+	if err != nil {
+		respondPrintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, job)
+}
+
+// ListPrints lists the contest's print jobs, optionally filtered by the
+// status query parameter.
+func (ctrl *PrintController) ListPrints(c *gin.Context) {
+	jobs, err := ctrl.printService.List(c.Param("id"), services.PrintStatus(c.Query("status")))
+	if err != nil {
+		respondPrintError(c, err)
+		return
+	}
+
+	respondList(c, "jobs", jobs, "createdAt")
+}
+
+func (ctrl *PrintController) ClaimPrint(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	job, err := ctrl.printService.Claim(c.Param("id"), c.Param("printId"), principal.UserID)
+	if err != nil {
+		respondPrintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func (ctrl *PrintController) FinishPrint(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	job, err := ctrl.printService.Done(principal, c.Param("id"), c.Param("printId"))
+	if err != nil {
+		respondPrintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func respondPrintError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPrintJobNotFound), errors.Is(err, services.ErrContestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotRegistered), errors.Is(err, services.ErrPrintJobNotClaimed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPrintJobClaimed), errors.Is(err, services.ErrPrintingUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPrintQuotaExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPrintTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Print error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Print request failed"})
+	}
+}
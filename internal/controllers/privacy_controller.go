@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type PrivacyController struct {
+	privacyService *services.PrivacyService
+}
+
+func NewPrivacyController(privacyService *services.PrivacyService) *PrivacyController {
+	return &PrivacyController{privacyService: privacyService}
+}
+
+// ExportData returns everything stored about the caller as a downloadable
+// JSON document.
+func (ctrl *PrivacyController) ExportData(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	export, err := ctrl.privacyService.Export(principal.UserID)
+	if err != nil {
+		respondPrivacyError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=online-judge-data.json")
+	c.JSON(http.StatusOK, export)
+}
+
+// RequestDeletion schedules the caller's account for deletion after the
+// grace period.
+func (ctrl *PrivacyController) RequestDeletion(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	request, err := ctrl.privacyService.RequestDeletion(principal.UserID)
+	if err != nil {
+		respondPrivacyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, request)
+}
+
+func (ctrl *PrivacyController) GetDeletion(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	request, err := ctrl.privacyService.Deletion(principal.UserID)
+	if err != nil {
+		respondPrivacyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// CancelDeletion withdraws the caller's pending deletion request.
+func (ctrl *PrivacyController) CancelDeletion(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	if err := ctrl.privacyService.CancelDeletion(principal.UserID); err != nil {
+		respondPrivacyError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondPrivacyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDeletionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeletionStarted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Privacy error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Privacy request failed"})
+	}
+}
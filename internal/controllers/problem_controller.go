@@ -0,0 +1,482 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/judge"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"slices"
+	"strconv"
+)
+
+type ProblemController struct {
+	problemService      *services.ProblemService
+	dryRunService       *services.DryRunService
+	optimizationService *services.OptimizationService
+	statsService        *services.ProblemStatsService
+}
+
+func NewProblemController(problemService *services.ProblemService, dryRunService *services.DryRunService, optimizationService *services.OptimizationService, statsService *services.ProblemStatsService) *ProblemController {
+	return &ProblemController{
+		problemService:      problemService,
+		dryRunService:       dryRunService,
+		optimizationService: optimizationService,
+		statsService:        statsService,
+	}
+}
+
+type dryRunRequest struct {
+	Language string `json:"language" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type customRunRequest struct {
+	Language string `json:"language" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	Input    string `json:"input"`
+}
+
+// customRunEvent is a line of a custom run's response, as in the
+// playground's stream: "output" with a chunk of stdout or stderr, then
+// "exit" with the result or "error".
+type customRunEvent struct {
+	Type   string                 `json:"type"`
+	Stream string                 `json:"stream,omitempty"`
+	Data   string                 `json:"data,omitempty"`
+	Result *judge.ExecutionResult `json:"result,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+type problemRequest struct {
+	Title     string   `json:"title" binding:"required"`
+	Statement string   `json:"statement"`
+	Tags      []string `json:"tags"`
+	// Difficulty is the setter's rating, shown beside the estimated one.
+	Difficulty  int               `json:"difficulty"`
+	TimeLimit   float64           `json:"timeLimit" binding:"required"`
+	MemoryLimit int               `json:"memoryLimit" binding:"required"`
+	OutputLimit int               `json:"outputLimit"`
+	Env         map[string]string `json:"env"`
+	// NetworkEnabled gives programs network access.
+	NetworkEnabled bool `json:"networkEnabled"`
+	// RandomizeTestOrder shuffles test execution order per submission.
+	RandomizeTestOrder bool                   `json:"randomizeTestOrder"`
+	Checker            *services.Checker      `json:"checker"`
+	ScoringPolicy      services.ScoringPolicy `json:"scoringPolicy"`
+	MaxScore           float64                `json:"maxScore"`
+	Optimization       *services.Optimization `json:"optimization"`
+	QueueWeight        int                    `json:"queueWeight"`
+	// Feedback is how much of failed tests contestants see.
+	Feedback services.FeedbackPolicy `json:"feedback"`
+	// SolutionVisibility is who may read other users' accepted sources.
+	SolutionVisibility services.SolutionVisibility `json:"solutionVisibility"`
+	// Templates is the starter code per language.
+	Templates map[string]services.StarterTemplate `json:"templates"`
+	// Harnesses make the problem function-only.
+	Harnesses map[string]services.StarterTemplate `json:"harnesses"`
+	// SQL seeds the database of SQL exercises.
+	SQL *services.SQLSettings `json:"sql"`
+	// Notebook picks the cells judged of notebook submissions.
+	Notebook *services.NotebookSettings `json:"notebook"`
+}
+
+func (r problemRequest) toProblem(id string) services.Problem {
+	return services.Problem{
+		ID:             id,
+		Title:          r.Title,
+		Statement:      r.Statement,
+		Tags:           r.Tags,
+		Difficulty:     r.Difficulty,
+		TimeLimit:      r.TimeLimit,
+		MemoryLimit:    r.MemoryLimit,
+		OutputLimit:    r.OutputLimit,
+		Env:            r.Env,
+		NetworkEnabled: r.NetworkEnabled,
+
+		RandomizeTestOrder: r.RandomizeTestOrder,
+		Checker:            r.Checker,
+		ScoringPolicy:      r.ScoringPolicy,
+		MaxScore:           r.MaxScore,
+		Optimization:       r.Optimization,
+		QueueWeight:        r.QueueWeight,
+		Feedback:           r.Feedback,
+		SolutionVisibility: r.SolutionVisibility,
+		Templates:          r.Templates,
+		Harnesses:          r.Harnesses,
+		SQL:                r.SQL,
+		Notebook:           r.Notebook,
+	}
+}
+
+func (ctrl *ProblemController) CreateProblem(c *gin.Context) {
+	var req problemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	problem, err := ctrl.problemService.Create(req.toProblem(""))
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, problem)
+}
+
+func (ctrl *ProblemController) UpdateProblem(c *gin.Context) {
+	var req problemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	problem, err := ctrl.problemService.Update(req.toProblem(c.Param("id")))
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, problem)
+}
+
+func (ctrl *ProblemController) ListProblems(c *gin.Context) {
+	problems, err := ctrl.problemService.List()
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	tag := c.Query("tag")
+	public := make([]services.Problem, 0, len(problems))
+	for _, problem := range problems {
+		if tag != "" && !slices.Contains(problem.Tags, tag) {
+			continue
+		}
+		public = append(public, problem.Public())
+	}
+
+	respondList(c, "problems", public, "id")
+}
+
+// CloneProblem copies a problem under a new ID, with its tests if the
+// request asks for them.
+func (ctrl *ProblemController) CloneProblem(c *gin.Context) {
+	var clone services.ProblemClone
+	if err := c.ShouldBindJSON(&clone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	problem, err := ctrl.problemService.Clone(c.Param("id"), clone)
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, problem)
+}
+
+// BulkEditProblems changes limits and tags of many problems at once.
+func (ctrl *ProblemController) BulkEditProblems(c *gin.Context) {
+	var edit services.ProblemBulkEdit
+	if err := c.ShouldBindJSON(&edit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	problems, err := ctrl.problemService.BulkEdit(edit)
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"problems": problems})
+}
+
+func (ctrl *ProblemController) GetProblem(c *gin.Context) {
+	problem, err := ctrl.problemService.Get(c.Param("id"))
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	samples, err := ctrl.problemService.SampleTests(problem.ID)
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	public := make([]services.TestCase, len(samples))
+	for i, sample := range samples {
+		public[i] = sample.Public()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"problem": problem.Public(),
+		"samples": public,
+	})
+}
+
+// GetTests returns every test with its metadata, for setters.
+func (ctrl *ProblemController) GetTests(c *gin.Context) {
+	if _, err := ctrl.problemService.Get(c.Param("id")); err != nil {
+		respondProblemError(c, err)
+		return
+	}
+	tests, err := ctrl.problemService.Tests(c.Param("id"))
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+	if tests == nil {
+		tests = []services.TestCase{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tests": tests})
+}
+
+// SetTests replaces the problem's tests with the request body.
+func (ctrl *ProblemController) SetTests(c *gin.Context) {
+	var tests []services.TestCase
+	if err := c.ShouldBindJSON(&tests); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal, _ := middleware.CurrentPrincipal(c)
+	if err := ctrl.problemService.SetTests(c.Param("id"), tests, principal.UserID); err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetTestMetadata annotates one test, identified by its 1-based index,
+// without re-uploading the test set.
+func (ctrl *ProblemController) SetTestMetadata(c *gin.Context) {
+	test, err := strconv.Atoi(c.Param("test"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "test must be a number"})
+		return
+	}
+	var metadata services.TestMetadata
+	if err := c.ShouldBindJSON(&metadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if metadata.Author == "" {
+		principal, _ := middleware.CurrentPrincipal(c)
+		metadata.Author = principal.UserID
+	}
+
+	updated, err := ctrl.problemService.SetTestMetadata(c.Param("id"), test, metadata)
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// GetJudgeScript returns the problem's judge script, for setters.
+func (ctrl *ProblemController) GetJudgeScript(c *gin.Context) {
+	script, err := ctrl.problemService.JudgeScript(c.Param("id"))
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, script)
+}
+
+// SetJudgeScript attaches a judge script that evaluates the problem's
+// submissions in place of its tests and checker.
+func (ctrl *ProblemController) SetJudgeScript(c *gin.Context) {
+	var script services.JudgeScript
+	if err := c.ShouldBindJSON(&script); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	principal, _ := middleware.CurrentPrincipal(c)
+	script.ProblemID = c.Param("id")
+	script.Author = principal.UserID
+
+	script, err := ctrl.problemService.SetJudgeScript(script)
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, script)
+}
+
+func (ctrl *ProblemController) DeleteJudgeScript(c *gin.Context) {
+	if err := ctrl.problemService.DeleteJudgeScript(c.Param("id")); err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetUnitTests returns the problem's unit test suite, for setters.
+func (ctrl *ProblemController) GetUnitTests(c *gin.Context) {
+	suite, err := ctrl.problemService.UnitTests(c.Param("id"))
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, suite)
+}
+
+// SetUnitTests attaches a unit test suite that evaluates the problem's
+// submissions in place of its tests and checker.
+func (ctrl *ProblemController) SetUnitTests(c *gin.Context) {
+	var suite services.UnitTestSuite
+	if err := c.ShouldBindJSON(&suite); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	principal, _ := middleware.CurrentPrincipal(c)
+	suite.ProblemID = c.Param("id")
+	suite.Author = principal.UserID
+
+	suite, err := ctrl.problemService.SetUnitTests(suite)
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, suite)
+}
+
+func (ctrl *ProblemController) DeleteUnitTests(c *gin.Context) {
+	if err := ctrl.problemService.DeleteUnitTests(c.Param("id")); err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DryRun runs code on the sample tests with relaxed limits and reports the
+// measured time and memory against the real limits.
+func (ctrl *ProblemController) DryRun(c *gin.Context) {
+	var req dryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := ctrl.dryRunService.Estimate(c.Request.Context(), c.Param("id"), req.Language, req.Code)
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CustomRun runs code on the user's input with the problem's limits and
+// streams newline-delimited customRunEvent, so the output shows while the
+// program runs. Errors before it starts get the usual JSON answers.
+func (ctrl *ProblemController) CustomRun(c *gin.Context) {
+	var req customRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chunks := make(chan judge.OutputChunk, 16)
+	var result judge.ExecutionResult
+	var err error
+	go func() {
+		defer close(chunks)
+		result, err = ctrl.dryRunService.CustomRun(c.Request.Context(), c.Param("id"), req.Language, req.Code, req.Input, chunks)
+	}()
+
+	encoder := json.NewEncoder(c.Writer)
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+	}
+	for chunk := range chunks {
+		start()
+		encoder.Encode(customRunEvent{Type: "output", Stream: chunk.Stream, Data: chunk.Data})
+		c.Writer.Flush()
+	}
+	if err != nil && !started {
+		respondProblemError(c, err)
+		return
+	}
+	start()
+	if err != nil {
+		log.Printf("Error in custom run: %v", err)
+		encoder.Encode(customRunEvent{Type: "error", Error: "Custom run failed"})
+		return
+	}
+	encoder.Encode(customRunEvent{Type: "exit", Result: &result})
+}
+
+// Leaderboard ranks users on an optimization problem by relative score.
+func (ctrl *ProblemController) Leaderboard(c *gin.Context) {
+	board, err := ctrl.optimizationService.Leaderboard(c.Param("id"))
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"leaderboard": board})
+}
+
+// Stats returns the problem's attempt, acceptance and verdict statistics
+// and the fastest accepted solution per language.
+func (ctrl *ProblemController) Stats(c *gin.Context) {
+	stats, err := ctrl.statsService.Stats(c.Param("id"))
+	if err != nil {
+		respondProblemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func respondProblemError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProblemNotFound),
+		errors.Is(err, services.ErrNotOptimizationProblem),
+		errors.Is(err, services.ErrTestNotFound),
+		errors.Is(err, services.ErrJudgeScriptNotFound),
+		errors.Is(err, services.ErrUnitTestsNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidLimits),
+		errors.Is(err, services.ErrInvalidScoringPolicy),
+		errors.Is(err, services.ErrInvalidFeedback),
+		errors.Is(err, services.ErrInvalidSolutionVisibility),
+		errors.Is(err, services.ErrInvalidOptimization),
+		errors.Is(err, services.ErrInvalidTemplate),
+		errors.Is(err, services.ErrInvalidHarness),
+		errors.Is(err, services.ErrInvalidJudgeScript),
+		errors.Is(err, services.ErrInvalidUnitTests),
+		errors.Is(err, services.ErrInvalidBulkEdit),
+		errors.Is(err, services.ErrNoSampleTests),
+		errors.Is(err, services.ErrCustomInputTooLarge),
+		errors.Is(err, judge.ErrRejected),
+		errors.Is(err, judge.ErrEnvNotAllowed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Problem error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Problem request failed"})
+	}
+}
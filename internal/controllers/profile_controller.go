@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type ProfileController struct {
+	achievementService *services.AchievementService
+}
+
+func NewProfileController(achievementService *services.AchievementService) *ProfileController {
+	return &ProfileController{achievementService: achievementService}
+}
+
+// GetProfile returns a user's solve streaks and achievements; "me" names
+// the caller.
+func (ctrl *ProfileController) GetProfile(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "me" {
+		principal, _ := middleware.CurrentPrincipal(c)
+		userID = principal.UserID
+	}
+
+	profile, err := ctrl.achievementService.Profile(userID)
+	if err != nil {
+		log.Printf("Error loading profile of user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
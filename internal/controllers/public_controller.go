@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+// PublicController serves the unauthenticated read-only API for community
+// tools. Nothing it returns names a user.
+type PublicController struct {
+	publicService  *services.PublicService
+	contestService *services.ContestService
+}
+
+func NewPublicController(publicService *services.PublicService, contestService *services.ContestService) *PublicController {
+	return &PublicController{publicService: publicService, contestService: contestService}
+}
+
+func (ctrl *PublicController) ListProblems(c *gin.Context) {
+	problems, err := ctrl.publicService.Problems()
+	if err != nil {
+		respondPublicError(c, err)
+		return
+	}
+
+	respondList(c, "problems", problems, "id")
+}
+
+func (ctrl *PublicController) GetProblem(c *gin.Context) {
+	problem, samples, err := ctrl.publicService.Problem(c.Param("id"))
+	if err != nil {
+		respondPublicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"problem": problem,
+		"samples": samples,
+	})
+}
+
+// ProblemStats returns the problem's statistics; the fastest solutions name
+// pseudonyms instead of users.
+func (ctrl *PublicController) ProblemStats(c *gin.Context) {
+	stats, err := ctrl.publicService.ProblemStats(c.Param("id"))
+	if err != nil {
+		respondPublicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func (ctrl *PublicController) ListContests(c *gin.Context) {
+	contests, err := ctrl.contestService.List()
+	if err != nil {
+		respondPublicError(c, err)
+		return
+	}
+
+	respondList(c, "contests", contests, "startTime")
+}
+
+// Standings returns the contest standings under pseudonyms. The phase query
+// parameter selects provisional (default) or final standings.
+func (ctrl *PublicController) Standings(c *gin.Context) {
+	phase := services.StandingsPhase(c.DefaultQuery("phase", string(services.StandingsProvisional)))
+
+	standings, err := ctrl.publicService.Standings(c.Param("id"), phase)
+	if err != nil {
+		respondPublicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
+
+// Stats returns submission counts by verdict and language.
+func (ctrl *PublicController) Stats(c *gin.Context) {
+	stats, err := ctrl.publicService.Stats()
+	if err != nil {
+		respondPublicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func respondPublicError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidStandingsPhase):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProblemNotFound), errors.Is(err, services.ErrContestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFinalStandingsNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Public API error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Public API request failed"})
+	}
+}
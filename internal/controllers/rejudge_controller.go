@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type RejudgeController struct {
+	reconciler *services.RejudgeReconciler
+}
+
+func NewRejudgeController(reconciler *services.RejudgeReconciler) *RejudgeController {
+	return &RejudgeController{reconciler: reconciler}
+}
+
+type rejudgeRequest struct {
+	SubmissionIDs []string `json:"submissionIds" binding:"required"`
+}
+
+// Rejudge queues the submissions to be graded again. The batch is
+// reconciled once all of them are graded; GET /rejudges/:id shows the
+// verdict changes then.
+func (ctrl *RejudgeController) Rejudge(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	var req rejudgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rejudge, err := ctrl.reconciler.Rejudge(principal.UserID, req.SubmissionIDs)
+	if err != nil {
+		respondRejudgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, rejudge)
+}
+
+func (ctrl *RejudgeController) GetRejudge(c *gin.Context) {
+	rejudge, err := ctrl.reconciler.Get(c.Param("id"))
+	if err != nil {
+		respondRejudgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rejudge)
+}
+
+func respondRejudgeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRejudgeNotFound), errors.Is(err, services.ErrSubmissionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoSubmissionsToRejudge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotRejudgeable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error rejudging: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rejudge"})
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type ReservationController struct {
+	capacity *services.CapacityPool
+}
+
+func NewReservationController(capacity *services.CapacityPool) *ReservationController {
+	return &ReservationController{capacity: capacity}
+}
+
+func (ctrl *ReservationController) GetReservation(c *gin.Context) {
+	reservation, err := ctrl.capacity.Reservation(c.Param("id"))
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// SetReservation reserves judging slots for the contest. Start and end
+// default to the contest's own window.
+func (ctrl *ReservationController) SetReservation(c *gin.Context) {
+	var reservation services.ContestReservation
+	if err := c.ShouldBindJSON(&reservation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reservation.ContestID = c.Param("id")
+
+	reservation, err := ctrl.capacity.Reserve(reservation)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+func (ctrl *ReservationController) DeleteReservation(c *gin.Context) {
+	if err := ctrl.capacity.DeleteReservation(c.Param("id")); err != nil {
+		respondReservationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondReservationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrContestNotFound), errors.Is(err, services.ErrReservationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReservation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCapacityExceeded), errors.Is(err, services.ErrReservationsDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Contest reservation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Contest reservation request failed"})
+	}
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type ResolverController struct {
+	exporter *services.ResolverExporter
+}
+
+func NewResolverController(exporter *services.ResolverExporter) *ResolverController {
+	return &ResolverController{exporter: exporter}
+}
+
+// Export returns the finished contest's event feed for the ICPC resolver
+// as newline-delimited JSON.
+func (ctrl *ResolverController) Export(c *gin.Context) {
+	events, err := ctrl.exporter.Export(c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrContestNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrContestNotFinished):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error exporting resolver feed for contest %s: %v", c.Param("id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export resolver feed"})
+		}
+		return
+	}
+
+	var feed bytes.Buffer
+	encoder := json.NewEncoder(&feed)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			log.Printf("Error encoding resolver feed for contest %s: %v", c.Param("id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export resolver feed"})
+			return
+		}
+	}
+	c.Header("Content-Disposition", "attachment; filename=contest-"+c.Param("id")+"-event-feed.ndjson")
+	c.Data(http.StatusOK, "application/x-ndjson", feed.Bytes())
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+	"time"
+)
+
+type ScalingController struct {
+	advisor *services.ScalingAdvisor
+}
+
+func NewScalingController(advisor *services.ScalingAdvisor) *ScalingController {
+	return &ScalingController{advisor: advisor}
+}
+
+// GetAdvice returns the desired judge worker count. It doubles as a KEDA
+// metrics-api source with valueLocation desiredWorkers.
+func (ctrl *ScalingController) GetAdvice(c *gin.Context) {
+	advice, err := ctrl.advisor.Advise(time.Now())
+	if err != nil {
+		log.Printf("Scaling advice error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute scaling advice"})
+		return
+	}
+
+	c.JSON(http.StatusOK, advice)
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type SeatController struct {
+	seatService *services.SeatService
+}
+
+func NewSeatController(seatService *services.SeatService) *SeatController {
+	return &SeatController{seatService: seatService}
+}
+
+func (ctrl *SeatController) ListSeats(c *gin.Context) {
+	seats, err := ctrl.seatService.List(c.Param("id"))
+	if err != nil {
+		respondSeatError(c, err)
+		return
+	}
+
+	respondList(c, "seats", seats, "room,seat")
+}
+
+// AssignSeat places a team at a room and seat, optionally binding it to the
+// seat's IP address.
+func (ctrl *SeatController) AssignSeat(c *gin.Context) {
+	var seat services.Seat
+	if err := c.ShouldBindJSON(&seat); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seat.ContestID = c.Param("id")
+	seat.UserID = c.Param("userId")
+
+	assigned, err := ctrl.seatService.Assign(seat)
+	if err != nil {
+		respondSeatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, assigned)
+}
+
+func (ctrl *SeatController) UnassignSeat(c *gin.Context) {
+	if err := ctrl.seatService.Unassign(c.Param("id"), c.Param("userId")); err != nil {
+		respondSeatError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Credentials returns the data for printing each team's credential sheet.
+func (ctrl *SeatController) Credentials(c *gin.Context) {
+	credentials, err := ctrl.seatService.Credentials(c.Param("id"))
+	if err != nil {
+		respondSeatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+func respondSeatError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrContestNotFound), errors.Is(err, services.ErrSeatNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSeatIP):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSeatUnavailable), errors.Is(err, services.ErrSeatIPAssigned),
+		errors.Is(err, services.ErrRegistrationClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Seat error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Seat request failed"})
+	}
+}
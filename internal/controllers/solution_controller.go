@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type SolutionController struct {
+	sourceAccess *services.SourceAccess
+}
+
+func NewSolutionController(sourceAccess *services.SourceAccess) *SolutionController {
+	return &SolutionController{sourceAccess: sourceAccess}
+}
+
+// ListSolutions lists other users' accepted submissions to the problem whose
+// sources the caller may read; it is empty until the problem's solution
+// visibility unlocks them. Sources are read through the submission source
+// endpoint.
+func (ctrl *SolutionController) ListSolutions(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	solutions, err := ctrl.sourceAccess.Solutions(principal, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrProblemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error listing solutions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list solutions"})
+		return
+	}
+
+	respondList(c, "solutions", solutions, "createdAt")
+}
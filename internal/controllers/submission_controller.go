@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/auth"
+	"online-judge/internal/highlight"
+	"online-judge/internal/i18n"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type SubmissionController struct {
+	submissionService *services.SubmissionService
+	overrideService   *services.OverrideService
+	problemService    *services.ProblemService
+	sourceAccess      *services.SourceAccess
+}
+
+func NewSubmissionController(submissionService *services.SubmissionService, overrideService *services.OverrideService, problemService *services.ProblemService, sourceAccess *services.SourceAccess) *SubmissionController {
+	return &SubmissionController{
+		submissionService: submissionService,
+		overrideService:   overrideService,
+		problemService:    problemService,
+		sourceAccess:      sourceAccess,
+	}
+}
+
+func (ctrl *SubmissionController) CreateSubmission(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	var req services.SubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ClientIP = c.ClientIP()
+
+	submission, err := ctrl.submissionService.Create(principal, req)
+	if err != nil {
+		respondSubmissionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, localizeSubmission(c, submission))
+}
+
+// ListSubmissions lists submissions filtered by the userId, contestId and
+// problemId query parameters, newest first by default. Users other than
+// judges only see their own, limited by each problem's feedback policy.
+func (ctrl *SubmissionController) ListSubmissions(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	filter := services.SubmissionFilter{
+		UserID:    c.Query("userId"),
+		ContestID: c.Query("contestId"),
+		ProblemID: c.Query("problemId"),
+	}
+	judge := principal.HasRole(auth.RoleJudge)
+	if !judge {
+		filter.UserID = principal.UserID
+	}
+
+	submissions, err := ctrl.submissionService.List(filter)
+	if err != nil {
+		respondSubmissionError(c, err)
+		return
+	}
+
+	policies := make(map[string]services.FeedbackPolicy)
+	responses := make([]submissionResponse, 0, len(submissions))
+	for _, submission := range submissions {
+		if !judge {
+			policy, ok := policies[submission.ProblemID]
+			if !ok {
+				problem, err := ctrl.problemService.Get(submission.ProblemID)
+				if err != nil && !errors.Is(err, services.ErrProblemNotFound) {
+					respondSubmissionError(c, err)
+					return
+				}
+				policy = problem.Feedback
+				policies[submission.ProblemID] = policy
+			}
+			submission = submission.ForContestant(policy)
+		}
+		responses = append(responses, localizeSubmission(c, submission))
+	}
+
+	respondList(c, "submissions", responses, "-createdAt")
+}
+
+// GetSubmission returns a submission to its owner or to judges. Owners see
+// test results only as far as the problem's feedback policy allows.
+func (ctrl *SubmissionController) GetSubmission(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	submission, err := ctrl.submissionService.Get(c.Param("id"))
+	if err != nil {
+		respondSubmissionError(c, err)
+		return
+	}
+	if !principal.HasRole(auth.RoleJudge) {
+		if submission.UserID != principal.UserID {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrSubmissionNotFound.Error()})
+			return
+		}
+		problem, err := ctrl.problemService.Get(submission.ProblemID)
+		if err != nil && !errors.Is(err, services.ErrProblemNotFound) {
+			respondSubmissionError(c, err)
+			return
+		}
+		submission = submission.ForContestant(problem.Feedback)
+	}
+
+	c.JSON(http.StatusOK, localizeSubmission(c, submission))
+}
+
+// GetSource returns a submission's source to those the source access
+// layer lets read it, either as plain text (the default) or, with
+// format=html, syntax-highlighted.
+func (ctrl *SubmissionController) GetSource(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	submission, err := ctrl.sourceAccess.Source(principal, c.Param("id"))
+	if err != nil {
+		respondSubmissionError(c, err)
+		return
+	}
+
+	switch c.DefaultQuery("format", "text") {
+	case "text":
+		c.String(http.StatusOK, "%s", submission.Source)
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(highlight.HTML(submission.Language, submission.Source)))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be text or html"})
+	}
+}
+
+// WithdrawSubmission lets the owner take back a submission that has not
+// started judging.
+func (ctrl *SubmissionController) WithdrawSubmission(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	submission, err := ctrl.submissionService.Withdraw(principal, c.Param("id"))
+	if err != nil {
+		respondSubmissionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, localizeSubmission(c, submission))
+}
+
+// CancelSubmission lets an admin take back a submission that has not
+// started judging.
+func (ctrl *SubmissionController) CancelSubmission(c *gin.Context) {
+	submission, err := ctrl.submissionService.Cancel(c.Param("id"))
+	if err != nil {
+		respondSubmissionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, localizeSubmission(c, submission))
+}
+
+// DeleteSubmission removes a submission; its source is deleted once no other
+// submission shares it.
+func (ctrl *SubmissionController) DeleteSubmission(c *gin.Context) {
+	if err := ctrl.submissionService.Delete(c.Param("id")); err != nil {
+		respondSubmissionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// OverrideVerdict records a judge's manual verdict for a submission.
+func (ctrl *SubmissionController) OverrideVerdict(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	var req services.OverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	submission, err := ctrl.overrideService.Override(c.Param("id"), principal.UserID, req)
+	if err != nil {
+		respondSubmissionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, localizeSubmission(c, submission))
+}
+
+// submissionResponse adds a description of the verdict to a submission.
+type submissionResponse struct {
+	services.Submission
+	VerdictDescription string `json:"verdictDescription,omitempty"`
+}
+
+// localizeSubmission translates the submission's hint and verdict
+// description into the request's locale.
+func localizeSubmission(c *gin.Context, submission services.Submission) submissionResponse {
+	locale := middleware.CurrentLocale(c)
+	submission.Hint = i18n.Translate(locale, submission.Hint)
+	return submissionResponse{
+		Submission:         submission,
+		VerdictDescription: i18n.Translate(locale, submission.Verdict.Description()),
+	}
+}
+
+func respondSubmissionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSubmissionNotFound), errors.Is(err, services.ErrContestNotFound),
+		errors.Is(err, services.ErrProblemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSubmissionForged), errors.Is(err, services.ErrNotRegistered),
+		errors.Is(err, services.ErrSeatIPMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrContestNotRunning), errors.Is(err, services.ErrSubmissionNotJudged),
+		errors.Is(err, services.ErrSubmissionNotQueued):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProblemNotInContest), errors.Is(err, services.ErrEmptySource),
+		errors.Is(err, services.ErrInvalidVerdict), errors.Is(err, services.ErrInvalidOverrideScore),
+		errors.Is(err, services.ErrOverrideReasonMissing), errors.Is(err, services.ErrNoTemplate),
+		errors.Is(err, services.ErrNoHarness), errors.Is(err, services.ErrHarnessSplice),
+		errors.Is(err, services.ErrInvalidNotebook):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSourceBusy):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Submission error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Submission request failed"})
+	}
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type TestUploadController struct {
+	testUploadService *services.TestUploadService
+}
+
+func NewTestUploadController(testUploadService *services.TestUploadService) *TestUploadController {
+	return &TestUploadController{testUploadService: testUploadService}
+}
+
+// CreateUpload returns presigned URLs the setter uploads a test's input
+// and output to.
+func (ctrl *TestUploadController) CreateUpload(c *gin.Context) {
+	var req services.TestUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	upload, err := ctrl.testUploadService.Create(c.Param("id"), req)
+	if err != nil {
+		respondTestUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, upload)
+}
+
+// FinalizeUpload registers an uploaded test with the problem.
+func (ctrl *TestUploadController) FinalizeUpload(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	test, err := ctrl.testUploadService.Finalize(c.Request.Context(), c.Param("id"), c.Param("uploadId"), principal.UserID)
+	if err != nil {
+		respondTestUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, test)
+}
+
+func respondTestUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProblemNotFound), errors.Is(err, services.ErrTestUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTestUploadIncomplete):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTestDataTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrObjectStorageDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Test upload error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Test upload request failed"})
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type ToolchainRolloutController struct {
+	rolloutService *services.ToolchainRolloutService
+}
+
+func NewToolchainRolloutController(rolloutService *services.ToolchainRolloutService) *ToolchainRolloutController {
+	return &ToolchainRolloutController{rolloutService: rolloutService}
+}
+
+// StartRollout moves the judge workers to another runtime directory in the
+// background; the rollout is returned at once and can be polled.
+func (ctrl *ToolchainRolloutController) StartRollout(c *gin.Context) {
+	var rollout services.ToolchainRollout
+	if err := c.ShouldBindJSON(&rollout); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	principal, _ := middleware.CurrentPrincipal(c)
+	rollout.StartedBy = principal.UserID
+
+	rollout, err := ctrl.rolloutService.Start(rollout)
+	if err != nil {
+		respondRolloutError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, rollout)
+}
+
+func (ctrl *ToolchainRolloutController) GetRollout(c *gin.Context) {
+	rollout, err := ctrl.rolloutService.Get(c.Param("id"))
+	if err != nil {
+		respondRolloutError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rollout)
+}
+
+func (ctrl *ToolchainRolloutController) ListRollouts(c *gin.Context) {
+	rollouts, err := ctrl.rolloutService.List()
+	if err != nil {
+		respondRolloutError(c, err)
+		return
+	}
+
+	respondList(c, "rollouts", rollouts, "-createdAt")
+}
+
+func respondRolloutError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRolloutNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRolloutInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Toolchain rollout error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Toolchain rollout request failed"})
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+type VerificationController struct {
+	verificationService *services.VerificationService
+}
+
+func NewVerificationController(verificationService *services.VerificationService) *VerificationController {
+	return &VerificationController{verificationService: verificationService}
+}
+
+// ListVerifications lists double-judging discrepancies, optionally filtered
+// by the status query parameter.
+func (ctrl *VerificationController) ListVerifications(c *gin.Context) {
+	verifications, err := ctrl.verificationService.List(services.VerificationStatus(c.Query("status")))
+	if err != nil {
+		respondVerificationError(c, err)
+		return
+	}
+
+	respondList(c, "verifications", verifications, "createdAt")
+}
+
+func (ctrl *VerificationController) GetVerification(c *gin.Context) {
+	verification, err := ctrl.verificationService.Get(c.Param("submissionId"))
+	if err != nil {
+		respondVerificationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+type resolveVerificationRequest struct {
+	Resolution string `json:"resolution" binding:"required"`
+}
+
+// ResolveVerification records the outcome of a manual review.
+func (ctrl *VerificationController) ResolveVerification(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	var req resolveVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	verification, err := ctrl.verificationService.Resolve(c.Param("submissionId"), principal.UserID, req.Resolution)
+	if err != nil {
+		respondVerificationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+func respondVerificationError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrVerificationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Verification error: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Verification request failed"})
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/services"
+)
+
+type WatchdogController struct {
+	watchdog *services.SubmissionWatchdog
+}
+
+func NewWatchdogController(watchdog *services.SubmissionWatchdog) *WatchdogController {
+	return &WatchdogController{watchdog: watchdog}
+}
+
+// GetStats returns how many stuck submissions the watchdog found and what
+// became of them, for alerting.
+func (ctrl *WatchdogController) GetStats(c *gin.Context) {
+	stats, err := ctrl.watchdog.Stats()
+	if err != nil {
+		log.Printf("Watchdog stats error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load watchdog stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
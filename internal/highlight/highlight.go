@@ -0,0 +1,220 @@
+// Package highlight renders source code as HTML with syntax classes, so
+// frontends and admin tools can show submissions without bundling their
+// own highlighter.
+package highlight
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token classes used as CSS class names in the generated HTML.
+const (
+	ClassKeyword      = "kw"
+	ClassString       = "str"
+	ClassComment      = "com"
+	ClassNumber       = "num"
+	ClassPreprocessor = "pre"
+)
+
+type lexer struct {
+	keywords      map[string]bool
+	lineComment   string
+	blockComment  [2]string
+	tripleQuotes  bool
+	preprocessor  bool
+	stringEscapes bool
+}
+
+func words(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(list) {
+		set[w] = true
+	}
+	return set
+}
+
+const cKeywords = `auto break case char const continue default do double else enum extern
+float for goto if inline int long register restrict return short signed sizeof static
+struct switch typedef union unsigned void volatile while _Bool bool true false NULL`
+
+var lexers = map[string]lexer{
+	"c": {
+		keywords:      words(cKeywords),
+		lineComment:   "//",
+		blockComment:  [2]string{"/*", "*/"},
+		preprocessor:  true,
+		stringEscapes: true,
+	},
+	"cpp": {
+		keywords: words(cKeywords + ` alignas alignof and asm catch class constexpr const_cast
+decltype delete dynamic_cast explicit export friend mutable namespace new noexcept not
+nullptr operator or private protected public reinterpret_cast static_assert static_cast
+template this thread_local throw try typeid typename using virtual xor`),
+		lineComment:   "//",
+		blockComment:  [2]string{"/*", "*/"},
+		preprocessor:  true,
+		stringEscapes: true,
+	},
+	"java": {
+		keywords: words(`abstract assert boolean break byte case catch char class const continue
+default do double else enum extends final finally float for goto if implements import
+instanceof int interface long native new package private protected public return short
+static strictfp super switch synchronized this throw throws transient try var void
+volatile while true false null record`),
+		lineComment:   "//",
+		blockComment:  [2]string{"/*", "*/"},
+		stringEscapes: true,
+	},
+	"python": {
+		keywords: words(`False None True and as assert async await break class continue def del
+elif else except finally for from global if import in is lambda nonlocal not or pass
+raise return try while with yield match case`),
+		lineComment:   "#",
+		tripleQuotes:  true,
+		stringEscapes: true,
+	},
+}
+
+// aliases maps common language names and file extensions onto lexers.
+var aliases = map[string]string{
+	"c": "c", "h": "c", "c11": "c", "c17": "c",
+	"cpp": "cpp", "c++": "cpp", "cc": "cpp", "cxx": "cpp", "hpp": "cpp", "cpp17": "cpp", "cpp20": "cpp",
+	"java":   "java",
+	"python": "python", "python3": "python", "py": "python",
+}
+
+// Lookup maps a language name, alias or file extension to a supported
+// lexer name.
+func Lookup(language string) (string, bool) {
+	name, ok := aliases[strings.ToLower(strings.TrimPrefix(language, "."))]
+	return name, ok
+}
+
+// HTML renders source as a <pre><code> block. Unknown languages are escaped
+// without highlighting.
+func HTML(language, source string) string {
+	var b strings.Builder
+	name, ok := Lookup(language)
+	b.WriteString(`<pre class="highlight`)
+	if ok {
+		b.WriteString(" language-" + name)
+	}
+	b.WriteString(`"><code>`)
+	if ok {
+		lexers[name].render(&b, source)
+	} else {
+		b.WriteString(html.EscapeString(source))
+	}
+	b.WriteString("</code></pre>")
+	return b.String()
+}
+
+func (l lexer) render(b *strings.Builder, src string) {
+	lineStart := true
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		c := src[i]
+
+		switch {
+		case l.preprocessor && lineStart && c == '#':
+			n := lineEnd(rest)
+			span(b, ClassPreprocessor, rest[:n])
+			i += n
+		case l.lineComment != "" && strings.HasPrefix(rest, l.lineComment):
+			n := lineEnd(rest)
+			span(b, ClassComment, rest[:n])
+			i += n
+		case l.blockComment[0] != "" && strings.HasPrefix(rest, l.blockComment[0]):
+			n := len(rest)
+			if end := strings.Index(rest[len(l.blockComment[0]):], l.blockComment[1]); end >= 0 {
+				n = len(l.blockComment[0]) + end + len(l.blockComment[1])
+			}
+			span(b, ClassComment, rest[:n])
+			i += n
+		case l.tripleQuotes && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)):
+			n := len(rest)
+			if end := strings.Index(rest[3:], rest[:3]); end >= 0 {
+				n = 3 + end + 3
+			}
+			span(b, ClassString, rest[:n])
+			i += n
+		case c == '"' || c == '\'':
+			n := l.stringEnd(rest)
+			span(b, ClassString, rest[:n])
+			i += n
+		case isDigit(c):
+			n := 1
+			for n < len(rest) && (isIdent(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			span(b, ClassNumber, rest[:n])
+			i += n
+		case isIdentStart(c):
+			n := 1
+			for n < len(rest) && isIdent(rest[n]) {
+				n++
+			}
+			if l.keywords[rest[:n]] {
+				span(b, ClassKeyword, rest[:n])
+			} else {
+				b.WriteString(html.EscapeString(rest[:n]))
+			}
+			i += n
+		default:
+			_, n := utf8.DecodeRuneInString(rest)
+			b.WriteString(html.EscapeString(rest[:n]))
+			i += n
+		}
+
+		if c == '\n' {
+			lineStart = true
+		} else if !unicode.IsSpace(rune(c)) {
+			lineStart = false
+		}
+	}
+}
+
+// stringEnd returns the length of the string literal at the start of s,
+// stopping at the end of the line for unterminated literals.
+func (l lexer) stringEnd(s string) int {
+	quote := s[0]
+	for n := 1; n < len(s); n++ {
+		switch {
+		case s[n] == '\\' && l.stringEscapes:
+			n++
+		case s[n] == quote:
+			return n + 1
+		case s[n] == '\n':
+			return n
+		}
+	}
+	return len(s)
+}
+
+func lineEnd(s string) int {
+	if n := strings.IndexByte(s, '\n'); n >= 0 {
+		return n
+	}
+	return len(s)
+}
+
+func span(b *strings.Builder, class, text string) {
+	b.WriteString(`<span class="` + class + `">`)
+	b.WriteString(html.EscapeString(text))
+	b.WriteString("</span>")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdent(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
@@ -0,0 +1,78 @@
+package i18n
+
+var spanish = catalog{
+	// verdict descriptions
+	"The program passed every test.":                               "El programa superó todas las pruebas.",
+	"The program earned part of the score.":                        "El programa obtuvo parte de la puntuación.",
+	"The program printed a wrong answer.":                          "El programa imprimió una respuesta incorrecta.",
+	"The program ran longer than the time limit.":                  "El programa superó el límite de tiempo.",
+	"The program used more memory than the memory limit.":          "El programa usó más memoria que el límite permitido.",
+	"The program crashed or exited with an error.":                 "El programa falló o terminó con un error.",
+	"The program did not compile.":                                 "El programa no compiló.",
+	"The judge failed to grade the program; it will be looked at.": "El juez no pudo evaluar el programa; se revisará.",
+	"The program made a system call that is not allowed.":          "El programa hizo una llamada al sistema no permitida.",
+
+	// compiler and runtime hints
+	"The linker could not find a main function. Every C/C++ program needs `int main()`; check its spelling and that it is not inside a class or namespace.":   "El enlazador no encontró la función main. Todo programa en C/C++ necesita `int main()`; revisa cómo está escrita y que no esté dentro de una clase o un namespace.",
+	"The public class must be named Main, because the source is saved as Main.java.":                                                                          "La clase pública debe llamarse Main, porque el código se guarda como Main.java.",
+	"A name is used before it is declared. Check for typos, missing #include or import lines, and variables declared inside a different block.":               "Se usa un nombre antes de declararlo. Revisa errores de escritura, líneas #include o import que falten y variables declaradas en otro bloque.",
+	"A statement is missing its semicolon. The error usually points at the line after the one missing it.":                                                    "A una instrucción le falta el punto y coma. El error suele señalar la línea siguiente a la que lo necesita.",
+	"Python uses indentation to group statements. Indent each block consistently and do not mix tabs and spaces.":                                             "Python agrupa las instrucciones por su sangría. Usa la misma sangría en todo el bloque y no mezcles tabulaciones y espacios.",
+	"Python could not parse the program. Look for missing colons after if/for/def, unbalanced brackets or Python 2 syntax such as print without parentheses.": "Python no pudo interpretar el programa. Busca dos puntos que falten después de if/for/def, paréntesis sin cerrar o sintaxis de Python 2 como print sin paréntesis.",
+	"A name is used before it is defined. Check for typos and for variables defined only inside a branch that did not run.":                                   "Se usa un nombre antes de definirlo. Revisa errores de escritura y variables definidas solo en una rama que no se ejecutó.",
+	"The program imports a module that is not installed on the judge. Only the standard library is available.":                                                "El programa importa un módulo que no está instalado en el juez. Solo está disponible la biblioteca estándar.",
+	"The recursion went too deep. Check the base case, or rewrite the recursion as a loop.":                                                                   "La recursión es demasiado profunda. Revisa el caso base o reescribe la recursión como un bucle.",
+	"The program read or wrote past the end of a list or array. Check loop bounds and the sizes given in the input.":                                          "El programa leyó o escribió fuera de los límites de una lista o un arreglo. Revisa los límites de los bucles y los tamaños de la entrada.",
+	"The program stopped with an uncaught exception; the message above names it and the line it happened on.":                                                 "El programa terminó con una excepción no capturada; el mensaje indica cuál fue y en qué línea ocurrió.",
+	"The program crashed with a segmentation fault, usually from an array index out of bounds, a null or dangling pointer, or very deep recursion.":           "El programa falló con un error de segmentación, normalmente por un índice fuera de rango, un puntero nulo o inválido, o una recursión muy profunda.",
+	"The program exited with a non-zero exit code. In C/C++ main must return 0; in other languages this usually means an error was raised.":                   "El programa terminó con un código de salida distinto de cero. En C/C++ main debe devolver 0; en otros lenguajes suele indicar que se produjo un error.",
+
+	// errors
+	"Missing bearer token":                                      "Falta el token de acceso",
+	"Insufficient permissions":                                  "Permisos insuficientes",
+	"invalid or expired token":                                  "Token inválido o caducado",
+	"contest not found":                                         "Concurso no encontrado",
+	"contest registration is closed":                            "La inscripción al concurso está cerrada",
+	"contest has not finished yet":                              "El concurso aún no ha terminado",
+	"virtual participation already started":                     "La participación virtual ya comenzó",
+	"no virtual participation":                                  "No hay participación virtual",
+	"submission not found":                                      "Envío no encontrado",
+	"submission user does not match the authenticated user":     "El usuario del envío no coincide con el usuario autenticado",
+	"contest is not accepting submissions":                      "El concurso no acepta envíos",
+	"user is not registered for the contest":                    "El usuario no está inscrito en el concurso",
+	"problem is not part of the contest":                        "El problema no forma parte del concurso",
+	"source code is empty":                                      "El código fuente está vacío",
+	"submission is no longer queued":                            "El envío ya no está en cola",
+	"source is being updated, try again":                        "El código fuente se está actualizando, inténtalo de nuevo",
+	"submissions for this team are only accepted from its seat": "Los envíos de este equipo solo se aceptan desde su puesto",
+	"format must be text or html":                               "El formato debe ser text o html",
+	"problem not found":                                         "Problema no encontrado",
+	"problem has no sample tests":                               "El problema no tiene pruebas de ejemplo",
+	"problem is not an optimization problem":                    "El problema no es de optimización",
+	"standings phase must be provisional or final":              "La fase de la clasificación debe ser provisional o final",
+	"final standings are available once system testing is done": "La clasificación final estará disponible cuando terminen las pruebas del sistema",
+	"print job not found":                                       "Trabajo de impresión no encontrado",
+	"print quota exceeded":                                      "Cuota de impresión agotada",
+	"print job is too large":                                    "El trabajo de impresión es demasiado grande",
+	"printing is only available while the contest is running":   "La impresión solo está disponible durante el concurso",
+	"playground session not found":                              "Sesión de pruebas no encontrada",
+	"playground session expired":                                "La sesión de pruebas caducó",
+	"playground run quota exceeded":                             "Cuota de ejecuciones de la sesión de pruebas agotada",
+	"invalid file name":                                         "Nombre de archivo no válido",
+	"file exceeds size limit":                                   "El archivo supera el tamaño máximo",
+	"too many files in session":                                 "Demasiados archivos en la sesión",
+	"unsupported language":                                      "Lenguaje no soportado",
+	"too many active playground sessions":                       "Demasiadas sesiones de pruebas activas",
+	"playground session is already running a program":           "La sesión de pruebas ya está ejecutando un programa",
+	"notification subscription not found":                       "Suscripción de notificaciones no encontrada",
+	"subscription has no email or push endpoint":                "La suscripción no tiene correo ni destino push",
+	"Submission request failed":                                 "La solicitud de envío falló",
+	"Failed to list contests":                                   "No se pudieron listar los concursos",
+	"Failed to load contest":                                    "No se pudo cargar el concurso",
+	"Failed to register":                                        "No se pudo completar la inscripción",
+	"Failed to compute standings":                               "No se pudo calcular la clasificación",
+	"Playground request failed":                                 "La solicitud a la sesión de pruebas falló",
+	"Problem request failed":                                    "La solicitud del problema falló",
+	"Print request failed":                                      "La solicitud de impresión falló",
+	"Gym request failed":                                        "La solicitud al gimnasio falló",
+}
@@ -0,0 +1,99 @@
+// Package i18n translates user-facing API messages. Messages are written in
+// English in the code and used as catalog keys, so a message without a
+// translation is served in English unchanged.
+package i18n
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// English is the language messages are written in.
+const English = "en"
+
+type catalog map[string]string
+
+var catalogs = map[string]catalog{
+	"es": spanish,
+}
+
+// Supported returns the locales messages can be served in.
+func Supported() []string {
+	locales := []string{English}
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+func supported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok || locale == English
+}
+
+// DefaultLocaleFromEnv returns LOCALE, the locale used when a request's
+// Accept-Language matches no supported locale. It defaults to English.
+func DefaultLocaleFromEnv() string {
+	locale := strings.ToLower(os.Getenv("LOCALE"))
+	if locale == "" {
+		return English
+	}
+	if !supported(locale) {
+		log.Printf("Unsupported LOCALE %q, using %s", locale, English)
+		return English
+	}
+	return locale
+}
+
+// Negotiate picks the supported locale the client prefers most according to
+// an Accept-Language header. Regional tags match their base language, so
+// es-MX is served Spanish.
+func Negotiate(acceptLanguage, fallback string) string {
+	type preference struct {
+		tag     string
+		quality float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if tag == "" || quality <= 0 {
+			continue
+		}
+		preferences = append(preferences, preference{tag: strings.ToLower(tag), quality: quality})
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].quality > preferences[j].quality })
+
+	for _, preference := range preferences {
+		if preference.tag == "*" {
+			return fallback
+		}
+		if supported(preference.tag) {
+			return preference.tag
+		}
+		if base, _, ok := strings.Cut(preference.tag, "-"); ok && supported(base) {
+			return base
+		}
+	}
+	return fallback
+}
+
+// Translate returns message in locale, or message itself when the locale
+// has no translation for it.
+func Translate(locale, message string) string {
+	if translated, ok := catalogs[locale][message]; ok {
+		return translated
+	}
+	return message
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package judge
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// maxPinnedCPU bounds the cores a run can be pinned to, the size of the
+// affinity mask passed to the kernel.
+const maxPinnedCPU = 1024
+
+const cpuPinningSupported = true
+
+// runPinned calls run on an OS thread pinned to cpu. Processes started by
+// run inherit the thread's affinity, sandboxes included. The thread is
+// never handed back to the Go scheduler: a goroutine that exits while
+// locked to its thread takes the thread with it.
+func runPinned(cpu int, run func()) error {
+	errs := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		var mask [maxPinnedCPU / 64]uint64
+		mask[cpu/64] = 1 << (cpu % 64)
+		// pid 0 is the calling thread
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+		if errno != 0 {
+			errs <- errno
+			return
+		}
+		run()
+		errs <- nil
+	}()
+	return <-errs
+}
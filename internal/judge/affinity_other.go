@@ -0,0 +1,14 @@
+//go:build !linux
+
+package judge
+
+const maxPinnedCPU = 1024
+
+// cpuPinningSupported is false where the judge cannot set thread affinity;
+// CIModeFromEnv then rejects JUDGE_CI_CPUS.
+const cpuPinningSupported = false
+
+func runPinned(cpu int, run func()) error {
+	run()
+	return nil
+}
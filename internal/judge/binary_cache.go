@@ -0,0 +1,137 @@
+package judge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxCachedBinaries bounds the binary cache; checkers change rarely, so the
+// least recently used ones are dropped beyond this.
+const maxCachedBinaries = 256
+
+// BinaryCache keeps the build output of programs that run many times with
+// the same source, such as checkers, so they compile once per source
+// version on each worker instead of once per test. Entries are directories
+// named by a hash of the language, compile command and source.
+type BinaryCache struct {
+	dir string
+}
+
+func NewBinaryCache(dir string) (*BinaryCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &BinaryCache{dir: dir}, nil
+}
+
+func (c *BinaryCache) key(compileCmd []string, lang Language, code string) string {
+	hash := sha256.New()
+	io.WriteString(hash, lang.Name+"\x00"+strings.Join(compileCmd, "\x00")+"\x00"+code)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// restore copies a cached build into dir and reports whether there was one.
+func (c *BinaryCache) restore(key, dir string) bool {
+	entry := filepath.Join(c.dir, key)
+	files, err := os.ReadDir(entry)
+	if err != nil {
+		return false
+	}
+	for _, file := range files {
+		if err := copyFile(filepath.Join(entry, file.Name()), filepath.Join(dir, file.Name())); err != nil {
+			log.Printf("Error restoring cached binary %s: %v", key, err)
+			return false
+		}
+	}
+	now := time.Now()
+	os.Chtimes(entry, now, now)
+	return true
+}
+
+// save copies the files compilation added to dir into the cache. Two
+// workers compiling the same source race harmlessly: the first rename wins.
+func (c *BinaryCache) save(key, dir string, before map[string]bool) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	tmp, err := os.MkdirTemp(c.dir, ".build-*")
+	if err != nil {
+		log.Printf("Error caching binary %s: %v", key, err)
+		return
+	}
+	for _, file := range files {
+		if before[file.Name()] || file.IsDir() {
+			continue
+		}
+		if err := copyFile(filepath.Join(dir, file.Name()), filepath.Join(tmp, file.Name())); err != nil {
+			log.Printf("Error caching binary %s: %v", key, err)
+			os.RemoveAll(tmp)
+			return
+		}
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, key)); err != nil {
+		os.RemoveAll(tmp)
+		return
+	}
+	c.evict()
+}
+
+func (c *BinaryCache) evict() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil || len(entries) <= maxCachedBinaries {
+		return
+	}
+	type cached struct {
+		name    string
+		modTime time.Time
+	}
+	var all []cached
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !strings.HasPrefix(entry.Name(), ".") {
+			all = append(all, cached{name: entry.Name(), modTime: info.ModTime()})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].modTime.Before(all[j].modTime) })
+	for len(all) > maxCachedBinaries {
+		os.RemoveAll(filepath.Join(c.dir, all[0].name))
+		all = all[1:]
+	}
+}
+
+func listFiles(dir string) map[string]bool {
+	names := make(map[string]bool)
+	files, _ := os.ReadDir(dir)
+	for _, file := range files {
+		names[file.Name()] = true
+	}
+	return names
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,45 @@
+package judge
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxReturnedFiles caps the files a run sends back with ReturnFiles.
+const maxReturnedFiles = 64
+
+// boxFiles returns the files left in boxDir for Submission.ReturnFiles:
+// regular files of up to limitKB, at most maxReturnedFiles of them in name
+// order. Hidden files, among them isolate's redirections of the standard
+// streams, are left out.
+func boxFiles(boxDir string, limitKB int) (map[string]string, error) {
+	entries, err := os.ReadDir(boxDir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if len(files) == maxReturnedFiles {
+			break
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() || info.Size() > int64(limitKB)*1024 {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(boxDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files, nil
+}
@@ -0,0 +1,79 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidBoxRange = errors.New("box range must look like 0-99 with first <= last")
+
+// BoxPool hands out sandbox box IDs so concurrent executions never share a
+// sandbox. Each ID is leased by at most one execution at a time.
+type BoxPool struct {
+	free chan int
+}
+
+// NewBoxPool returns a pool of the box IDs first through last.
+func NewBoxPool(first, last int) (*BoxPool, error) {
+	if first < 0 || last < first {
+		return nil, ErrInvalidBoxRange
+	}
+	pool := &BoxPool{free: make(chan int, last-first+1)}
+	for id := first; id <= last; id++ {
+		pool.free <- id
+	}
+	return pool, nil
+}
+
+// BoxPoolFromEnv reads JUDGE_BOX_IDS, an inclusive range such as 0-99,
+// defaulting to 0-99. The range must not overlap with other judges or
+// isolate users on the same machine.
+func BoxPoolFromEnv() (*BoxPool, error) {
+	value := strings.TrimSpace(os.Getenv("JUDGE_BOX_IDS"))
+	if value == "" {
+		return NewBoxPool(0, 99)
+	}
+	firstText, lastText, ok := strings.Cut(value, "-")
+	if !ok {
+		lastText = firstText
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(firstText))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidBoxRange, value)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(lastText))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidBoxRange, value)
+	}
+	return NewBoxPool(first, last)
+}
+
+// Acquire leases a free box ID, waiting for one while every box is in use.
+// The returned function releases the lease and must be called exactly once.
+func (p *BoxPool) Acquire(ctx context.Context) (string, func(), error) {
+	select {
+	case id := <-p.free:
+		return strconv.Itoa(id), func() { p.free <- id }, nil
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+}
+
+// TryAcquire leases a free box ID if there is one, without waiting.
+func (p *BoxPool) TryAcquire() (string, func(), bool) {
+	select {
+	case id := <-p.free:
+		return strconv.Itoa(id), func() { p.free <- id }, true
+	default:
+		return "", nil, false
+	}
+}
+
+// Size is the number of boxes in the pool.
+func (p *BoxPool) Size() int {
+	return cap(p.free)
+}
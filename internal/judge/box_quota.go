@@ -0,0 +1,93 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidBoxQuota = errors.New("box quota must be a positive number")
+
+// defaultQuotaInodes is the file quota of boxes whose disk quota sets none.
+const defaultQuotaInodes = 1024
+
+// edquotMessage is strerror(EDQUOT), which runtimes print when a write
+// fails on the quota.
+const edquotMessage = "Disk quota exceeded"
+
+// BoxQuota limits the disk space and files of a box, its /tmp included, so
+// a program writing huge temporary files fails in its box instead of
+// filling the disk isolate keeps boxes on. Only isolate enforces it, and
+// only on a filesystem mounted with user quotas.
+type BoxQuota struct {
+	Blocks int // kilobytes; zero for no quota
+	Inodes int
+}
+
+// BoxQuotaFromEnv reads JUDGE_BOX_QUOTA_KB and JUDGE_BOX_QUOTA_INODES, the
+// latter defaulting to 1024 when a disk quota is set.
+func BoxQuotaFromEnv() (BoxQuota, error) {
+	var quota BoxQuota
+	for _, setting := range []struct {
+		name  string
+		value *int
+	}{{"JUDGE_BOX_QUOTA_KB", &quota.Blocks}, {"JUDGE_BOX_QUOTA_INODES", &quota.Inodes}} {
+		value := strings.TrimSpace(os.Getenv(setting.name))
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return BoxQuota{}, fmt.Errorf("%w: %s %q", ErrInvalidBoxQuota, setting.name, value)
+		}
+		*setting.value = n
+	}
+	if quota.Blocks == 0 {
+		return BoxQuota{}, nil
+	}
+	if quota.Inodes == 0 {
+		quota.Inodes = defaultQuotaInodes
+	}
+	return quota, nil
+}
+
+// arg is isolate's --init option setting the quota.
+func (q BoxQuota) arg() string {
+	return "--quota=" + strconv.Itoa(q.Blocks) + "," + strconv.Itoa(q.Inodes)
+}
+
+// reached tells whether a run that wrote kilobytes in files ran into the
+// quota. The filesystem's own blocks and the box directories count
+// against it too, so the last few percent are as good as full.
+func (q BoxQuota) reached(kilobytes, files int) bool {
+	slack := max(q.Blocks/100, 16)
+	return kilobytes >= q.Blocks-slack || files >= q.Inodes-2
+}
+
+// writtenUsage sums the files under dirs that are not in skip, the names
+// the judge copied into the top level of the first one.
+func writtenUsage(dirs []string, skip map[string]bool) (kilobytes, files int) {
+	for i, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || path == dir {
+				return nil
+			}
+			if i == 0 && filepath.Dir(path) == dir && skip[entry.Name()] {
+				if entry.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			files++
+			if info, err := entry.Info(); err == nil && entry.Type().IsRegular() {
+				kilobytes += int((info.Size() + 1023) / 1024)
+			}
+			return nil
+		})
+	}
+	return kilobytes, files
+}
@@ -0,0 +1,115 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidChaos = errors.New("invalid chaos faults")
+	// ErrWorkerDropped is returned by Execute when chaos mode drops the
+	// worker after a run; the controller then closes the connection without
+	// answering, as a worker that crashed would.
+	ErrWorkerDropped = errors.New("chaos: worker dropped")
+	errChaos         = errors.New("chaos: injected fault")
+)
+
+// ChaosConfig injects faults into judging to test that the API's queue,
+// retries and watchdog lose no submission and score none twice. Rates are
+// the chance of a fault per execution. It is for test deployments only.
+type ChaosConfig struct {
+	// DropWorker drops the worker's connection after a run, losing its
+	// result.
+	DropWorker float64
+	// CorruptMeta fails runs as if isolate's meta file could not be
+	// parsed.
+	CorruptMeta float64
+	// BoxFailure fails box setup as isolate --init does when its retries
+	// are exhausted.
+	BoxFailure float64
+	// SlowStorage delays every store call of the API by up to this long.
+	SlowStorage time.Duration
+}
+
+// Enabled reports whether any fault is injected.
+func (c ChaosConfig) Enabled() bool {
+	return c != ChaosConfig{}
+}
+
+// ChaosConfigFromEnv reads CHAOS_FAULTS, comma-separated fault=value pairs:
+// drop_worker, corrupt_meta and box_failure take rates between 0 and 1,
+// slow_storage a duration such as 200ms. Empty injects nothing.
+func ChaosConfigFromEnv() (ChaosConfig, error) {
+	var config ChaosConfig
+	value := strings.TrimSpace(os.Getenv("CHAOS_FAULTS"))
+	if value == "" {
+		return config, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		name, setting, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		var rate *float64
+		switch name {
+		case "drop_worker":
+			rate = &config.DropWorker
+		case "corrupt_meta":
+			rate = &config.CorruptMeta
+		case "box_failure":
+			rate = &config.BoxFailure
+		case "slow_storage":
+			delay, err := time.ParseDuration(setting)
+			if err != nil || delay < 0 {
+				return ChaosConfig{}, fmt.Errorf("%w: slow_storage %q", ErrInvalidChaos, setting)
+			}
+			config.SlowStorage = delay
+			continue
+		default:
+			return ChaosConfig{}, fmt.Errorf("%w: unknown fault %q", ErrInvalidChaos, name)
+		}
+		n, err := strconv.ParseFloat(setting, 64)
+		if err != nil || n < 0 || n > 1 {
+			return ChaosConfig{}, fmt.Errorf("%w: %s rate %q", ErrInvalidChaos, name, setting)
+		}
+		*rate = n
+	}
+	return config, nil
+}
+
+func chaosHit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// SetChaos injects the configured faults into the worker's executions. It
+// must be called before executions start.
+func (j *Judge) SetChaos(config ChaosConfig) {
+	j.chaos = config
+	if config.CorruptMeta > 0 || config.BoxFailure > 0 {
+		j.sandbox = &chaosSandbox{Sandbox: j.sandbox, config: config}
+	}
+}
+
+// chaosSandbox fails box setup and runs of the sandbox it wraps.
+type chaosSandbox struct {
+	Sandbox
+	config ChaosConfig
+}
+
+func (s *chaosSandbox) Init(boxID string) error {
+	if chaosHit(s.config.BoxFailure) {
+		return fmt.Errorf("%w: box %s: %v", ErrBoxInit, boxID, errChaos)
+	}
+	return s.Sandbox.Init(boxID)
+}
+
+func (s *chaosSandbox) Run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error) {
+	result, err := s.Sandbox.Run(ctx, boxID, lang, dir, submission)
+	if err == nil && chaosHit(s.config.CorruptMeta) {
+		return ExecutionResult{}, fmt.Errorf("parse meta: %w", errChaos)
+	}
+	return result, err
+}
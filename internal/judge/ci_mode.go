@@ -0,0 +1,119 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCIMode = errors.New("invalid CI mode settings")
+
+// defaultCIRuns is how often CI mode runs each program unless configured.
+const defaultCIRuns = 3
+
+// CIMode tunes a worker for automated regression grading, where a verdict
+// must not flip between gradings of the same program: every run is pinned
+// to a CPU of its own and repeated, and the median run is reported.
+type CIMode struct {
+	// CPUs are the cores runs are pinned to, box ID modulo their count, so
+	// a pool with no more boxes than CPUs gives every box its own core.
+	// Empty leaves scheduling to the kernel. The docker and gvisor
+	// backends are not pinned: their containers are started by the daemon.
+	CPUs []int
+	// Runs is how many times each program runs; zero or one runs it once.
+	Runs int
+}
+
+// CIModeFromEnv reads JUDGE_CI_MODE, true to enable CI mode, then
+// JUDGE_CI_CPUS, a comma-separated list of cores, and JUDGE_CI_RUNS,
+// defaulting to 3. Disabled, it returns the zero CIMode.
+func CIModeFromEnv() (CIMode, error) {
+	value := os.Getenv("JUDGE_CI_MODE")
+	enabled, err := strconv.ParseBool(value)
+	if value != "" && err != nil {
+		return CIMode{}, fmt.Errorf("%w: JUDGE_CI_MODE %q", ErrInvalidCIMode, value)
+	}
+	if !enabled {
+		return CIMode{}, nil
+	}
+
+	mode := CIMode{Runs: defaultCIRuns}
+	if value := strings.TrimSpace(os.Getenv("JUDGE_CI_RUNS")); value != "" {
+		runs, err := strconv.Atoi(value)
+		if err != nil || runs < 1 {
+			return CIMode{}, fmt.Errorf("%w: JUDGE_CI_RUNS %q", ErrInvalidCIMode, value)
+		}
+		mode.Runs = runs
+	}
+	for _, field := range strings.Split(os.Getenv("JUDGE_CI_CPUS"), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		cpu, err := strconv.Atoi(field)
+		if err != nil || cpu < 0 || cpu >= maxPinnedCPU {
+			return CIMode{}, fmt.Errorf("%w: JUDGE_CI_CPUS %q", ErrInvalidCIMode, field)
+		}
+		mode.CPUs = append(mode.CPUs, cpu)
+	}
+	if len(mode.CPUs) > 0 && !cpuPinningSupported {
+		return CIMode{}, fmt.Errorf("%w: CPU pinning needs Linux", ErrInvalidCIMode)
+	}
+	return mode, nil
+}
+
+// cpu returns the core runs in the box are pinned to, if any.
+func (m CIMode) cpu(boxID string) (int, bool) {
+	if len(m.CPUs) == 0 {
+		return 0, false
+	}
+	id, _ := strconv.Atoi(boxID)
+	return m.CPUs[id%len(m.CPUs)], true
+}
+
+// CheckHost logs what makes timings vary on the host despite pinning:
+// turbo boost and frequency governors other than performance. It only
+// warns; CI machines are often VMs without a say over either.
+func (m CIMode) CheckHost() {
+	if readSysfs("/sys/devices/system/cpu/intel_pstate/no_turbo") == "0" ||
+		readSysfs("/sys/devices/system/cpu/cpufreq/boost") == "1" {
+		log.Printf("CI mode: turbo boost is enabled, run times will vary with the CPU temperature")
+	}
+	for _, cpu := range m.CPUs {
+		path := filepath.Join("/sys/devices/system/cpu", "cpu"+strconv.Itoa(cpu), "cpufreq/scaling_governor")
+		if governor := readSysfs(path); governor != "" && governor != "performance" {
+			log.Printf("CI mode: CPU %d uses the %s frequency governor rather than performance", cpu, governor)
+		}
+	}
+}
+
+// readSysfs returns a sysfs value, or "" where the file does not exist.
+func readSysfs(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// medianRun reports the run with the median CPU time, with the median
+// peak memory of all runs, so one slow or noisy run does not decide the
+// verdict.
+func medianRun(results []ExecutionResult) ExecutionResult {
+	byTime := append([]ExecutionResult(nil), results...)
+	sort.SliceStable(byTime, func(a, b int) bool { return byTime[a].Time < byTime[b].Time })
+	memories := make([]int, len(results))
+	for i, result := range results {
+		memories[i] = result.Memory
+	}
+	sort.Ints(memories)
+
+	median := byTime[(len(byTime)-1)/2]
+	median.Memory = memories[(len(memories)-1)/2]
+	median.Runs = len(results)
+	return median
+}
@@ -0,0 +1,408 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrRejected is returned when the judge refuses a submission as invalid,
+// e.g. for an unsupported language.
+var ErrRejected = errors.New("judge rejected submission")
+
+// Client calls a judge worker's HTTP API.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	// regions are set by NewRegionalClient; see regions.go.
+	regions []*regionPool
+}
+
+// NewClient returns a client for the worker at baseURL that authenticates
+// with JUDGE_TOKEN, the bearer token workers are started with.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    baseURL,
+		token:      strings.TrimSpace(os.Getenv("JUDGE_TOKEN")),
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// authorize adds the worker token to a request's headers.
+func (c *Client) authorize(header http.Header) {
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// ClientFromEnv reads JUDGE_URL, defaulting to a judge on localhost.
+func ClientFromEnv() *Client {
+	url := os.Getenv("JUDGE_URL")
+	if url == "" {
+		url = "http://localhost:8081"
+	}
+	return NewClient(url)
+}
+
+// drainRetries and drainRetryDelay bound how long Execute waits for another
+// worker when the one behind JUDGE_URL is draining.
+const (
+	drainRetries    = 5
+	drainRetryDelay = time.Second
+)
+
+func (c *Client) Execute(ctx context.Context, submission Submission) (ExecutionResult, error) {
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	if len(c.regions) > 0 {
+		return c.executeRegions(ctx, submission.Region, body)
+	}
+	return c.executeRetrying(ctx, c.baseURL, body)
+}
+
+func (c *Client) executeRetrying(ctx context.Context, baseURL string, body []byte) (ExecutionResult, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := c.execute(ctx, baseURL, body)
+		if !errors.Is(err, ErrDraining) || attempt == drainRetries {
+			return result, err
+		}
+		select {
+		case <-ctx.Done():
+			return ExecutionResult{}, ctx.Err()
+		case <-time.After(drainRetryDelay):
+		}
+	}
+}
+
+func (c *Client) execute(ctx context.Context, baseURL string, body []byte) (ExecutionResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/submit", bytes.NewReader(body))
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ExecutionResult{}, err
+		}
+		return ExecutionResult{}, fmt.Errorf("%w: %v", ErrJudgeUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ExecutionResult{}, executeError(resp)
+	}
+
+	var result ExecutionResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ExecutionResult{}, err
+	}
+	return result, nil
+}
+
+// executeError is the error of an execution the worker did not answer
+// with 200.
+func executeError(resp *http.Response) error {
+	var errBody struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&errBody)
+	if resp.StatusCode == http.StatusBadRequest {
+		return fmt.Errorf("%w: %s", ErrRejected, errBody.Error)
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable && errBody.Error == ErrDraining.Error() {
+		return ErrDraining
+	}
+	if unavailableStatus(resp.StatusCode) {
+		return fmt.Errorf("%w: judge returned %d: %s", ErrJudgeUnavailable, resp.StatusCode, errBody.Error)
+	}
+	return fmt.Errorf("judge returned %d: %s", resp.StatusCode, errBody.Error)
+}
+
+// ExecuteStream is Execute for custom runs: the program's stdout and
+// stderr are sent to out as the worker streams them, before the result is
+// returned. It is not retried, since output already sent cannot be taken
+// back, and a regional client only tries the first region it would. out
+// is not closed.
+func (c *Client) ExecuteStream(ctx context.Context, submission Submission, out chan<- OutputChunk) (ExecutionResult, error) {
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	baseURL := c.baseURL
+	if len(c.regions) > 0 {
+		baseURL = c.route(submission.Region)[0].URL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/submit/stream", bytes.NewReader(body))
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ExecutionResult{}, err
+		}
+		return ExecutionResult{}, fmt.Errorf("%w: %v", ErrJudgeUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ExecutionResult{}, executeError(resp)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event StreamEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return ExecutionResult{}, fmt.Errorf("%w: stream ended without a result", ErrJudgeUnavailable)
+			}
+			return ExecutionResult{}, err
+		}
+		switch {
+		case event.Chunk != nil:
+			select {
+			case out <- *event.Chunk:
+			case <-ctx.Done():
+				return ExecutionResult{}, ctx.Err()
+			}
+		case event.Result != nil:
+			return *event.Result, nil
+		default:
+			return ExecutionResult{}, fmt.Errorf("judge failed during the run: %s", event.Error)
+		}
+	}
+}
+
+// ExecuteInteractive runs a submission whose program reads stdin while it
+// runs, for playground sessions, over a WebSocket to the worker: what is
+// read from stdin goes to the program as it comes, and its stdout and
+// stderr are sent to out as in ExecuteStream. The submission's
+// WallTimeLimit bounds how long the program may wait for input. Like
+// ExecuteStream it is not retried; out is not closed, and stdin is read
+// until it ends or the run does, so callers close it afterwards.
+func (c *Client) ExecuteInteractive(ctx context.Context, submission Submission, stdin io.Reader, out chan<- OutputChunk) (ExecutionResult, error) {
+	baseURL := c.baseURL
+	if len(c.regions) > 0 {
+		baseURL = c.route(submission.Region)[0].URL
+	}
+	header := make(http.Header)
+	c.authorize(header)
+	// http becomes ws and https wss
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, "ws"+strings.TrimPrefix(baseURL, "http")+"/submit/interactive", header)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ExecutionResult{}, err
+		}
+		return ExecutionResult{}, fmt.Errorf("%w: %v", ErrJudgeUnavailable, err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.WriteJSON(submission); err != nil {
+		return ExecutionResult{}, err
+	}
+	// the only writer from here on
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 {
+				if conn.WriteJSON(StdinMessage{Data: string(buf[:n])}) != nil {
+					return
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				conn.WriteJSON(StdinMessage{EOF: true})
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		var event StreamEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			if ctx.Err() != nil {
+				return ExecutionResult{}, ctx.Err()
+			}
+			return ExecutionResult{}, fmt.Errorf("%w: stream ended without a result: %v", ErrJudgeUnavailable, err)
+		}
+		switch {
+		case event.Chunk != nil:
+			select {
+			case out <- *event.Chunk:
+			case <-ctx.Done():
+				return ExecutionResult{}, ctx.Err()
+			}
+		case event.Result != nil:
+			return *event.Result, nil
+		default:
+			return ExecutionResult{}, fmt.Errorf("judge failed during the run: %s", event.Error)
+		}
+	}
+}
+
+// Prefetch asks the worker to download test data into its cache ahead of
+// the submissions that need it. A regional client asks every region.
+func (c *Client) Prefetch(ctx context.Context, refs []DataRef) error {
+	var errs []error
+	for _, client := range c.perRegion() {
+		if err := client.post(ctx, client.httpClient, "/prefetch", map[string][]DataRef{"refs": refs}, http.StatusAccepted, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PrefetchAndWait downloads test data like Prefetch but returns once the
+// worker has it, with the outcome per ref. Large downloads can take long,
+// so only ctx bounds the call.
+func (c *Client) PrefetchAndWait(ctx context.Context, refs []DataRef) ([]PrefetchResult, error) {
+	var resp struct {
+		Results []PrefetchResult `json:"results"`
+	}
+	err := c.post(ctx, &http.Client{}, "/prefetch?wait=true", map[string][]DataRef{"refs": refs}, http.StatusOK, &resp)
+	return resp.Results, err
+}
+
+// Kill stops the worker's executions with the run ID and reports how many
+// there were. A regional client kills them in every region, since the run
+// may have failed over.
+func (c *Client) Kill(ctx context.Context, runID string) (int, error) {
+	killed := 0
+	var errs []error
+	for _, client := range c.perRegion() {
+		var resp struct {
+			Killed int `json:"killed"`
+		}
+		if err := client.post(ctx, client.httpClient, "/runs/"+url.PathEscape(runID)+"/kill", struct{}{}, http.StatusOK, &resp); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		killed += resp.Killed
+	}
+	return killed, errors.Join(errs...)
+}
+
+// perRegion returns a client per region, or c itself without regions.
+func (c *Client) perRegion() []*Client {
+	if len(c.regions) == 0 {
+		return []*Client{c}
+	}
+	clients := make([]*Client, len(c.regions))
+	for i, pool := range c.regions {
+		clients[i] = &Client{baseURL: pool.URL, httpClient: c.httpClient}
+	}
+	return clients
+}
+
+// Toolchain returns the worker's toolchain pins and any drift from them.
+func (c *Client) Toolchain(ctx context.Context) (ToolchainStatus, error) {
+	var status ToolchainStatus
+	err := c.get(ctx, "/toolchain", &status)
+	return status, err
+}
+
+// Maintenance returns whether the worker is draining and how many
+// executions it is running.
+func (c *Client) Maintenance(ctx context.Context) (MaintenanceStatus, error) {
+	var status MaintenanceStatus
+	err := c.get(ctx, "/maintenance", &status)
+	return status, err
+}
+
+// Drain stops the worker from taking new executions.
+func (c *Client) Drain(ctx context.Context) error {
+	return c.post(ctx, c.httpClient, "/maintenance/drain", struct{}{}, http.StatusOK, nil)
+}
+
+// Enable lets a drained worker take executions again.
+func (c *Client) Enable(ctx context.Context) error {
+	return c.post(ctx, c.httpClient, "/maintenance/enable", struct{}{}, http.StatusOK, nil)
+}
+
+// SwapRuntime switches a drained, idle worker to another runtime
+// directory; an empty dir clears it.
+func (c *Client) SwapRuntime(ctx context.Context, dir string) (MaintenanceStatus, error) {
+	var status MaintenanceStatus
+	err := c.post(ctx, c.httpClient, "/maintenance/runtime", map[string]string{"dir": dir}, http.StatusOK, &status)
+	return status, err
+}
+
+// SelfTest runs a small program in every language on the worker.
+func (c *Client) SelfTest(ctx context.Context) ([]SelfTestResult, error) {
+	var resp struct {
+		Results []SelfTestResult `json:"results"`
+	}
+	err := c.post(ctx, c.httpClient, "/maintenance/self-test", struct{}{}, http.StatusOK, &resp)
+	return resp.Results, err
+}
+
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	c.authorize(req.Header)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("judge returned %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) post(ctx context.Context, httpClient *http.Client, path string, payload any, wantStatus int, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req.Header)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errBody)
+		return fmt.Errorf("judge returned %d: %s", resp.StatusCode, errBody.Error)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
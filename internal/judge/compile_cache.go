@@ -0,0 +1,95 @@
+package judge
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// CompileCache holds per-worker precompiled headers. Each language with
+// precompiled headers gets its own directory, which is put first on the
+// include path when compiling so the compiler picks up the .gch files.
+type CompileCache struct {
+	dir   string
+	ready map[string]bool // language name -> headers built
+}
+
+// BuildCompileCache precompiles every language's headers into dir and runs
+// the languages' warmup commands. Failures are logged and only cost speed:
+// compilation falls back to the plain headers.
+func BuildCompileCache(dir string) *CompileCache {
+	c := &CompileCache{dir: dir, ready: make(map[string]bool)}
+	for _, name := range LanguageNames() {
+		lang, _ := LookupLanguage(name)
+		if len(lang.PrecompiledHeaders) > 0 {
+			start := time.Now()
+			if err := c.precompile(lang); err != nil {
+				log.Printf("Precompiling headers for %s failed: %v", name, err)
+			} else {
+				c.ready[name] = true
+				log.Printf("Precompiled headers for %s in %s", name, time.Since(start).Round(time.Millisecond))
+			}
+		}
+		if len(lang.WarmupCmd) > 0 {
+			if output, err := exec.Command(lang.WarmupCmd[0], lang.WarmupCmd[1:]...).CombinedOutput(); err != nil {
+				log.Printf("Warming up %s failed: %v: %s", name, err, bytes.TrimSpace(output))
+			}
+		}
+	}
+	return c
+}
+
+// precompile builds <dir>/<lang>/<header>.gch from a wrapper that includes
+// the real header, which is what the compiler loads in its place.
+func (c *CompileCache) precompile(lang Language) error {
+	langDir := filepath.Join(c.dir, lang.Name)
+	if err := os.RemoveAll(langDir); err != nil {
+		return err
+	}
+	for _, header := range lang.PrecompiledHeaders {
+		out := filepath.Join(langDir, header+".gch")
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return err
+		}
+		wrapper := filepath.Join(langDir, "pch-wrapper.h")
+		if err := os.WriteFile(wrapper, []byte("#include <"+header+">\n"), 0o644); err != nil {
+			return err
+		}
+
+		args := append(append([]string{}, lang.PrecompileCmd[1:]...), "-o", out, wrapper)
+		output, err := exec.Command(lang.PrecompileCmd[0], args...).CombinedOutput()
+		os.Remove(wrapper)
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", header, err, bytes.TrimSpace(output))
+		}
+	}
+	return nil
+}
+
+// compileCmd returns lang's compile command, with the cache directory added
+// to the include path when headers for lang were built.
+func (c *CompileCache) compileCmd(lang Language) []string {
+	if c == nil || !c.ready[lang.Name] {
+		return lang.CompileCmd
+	}
+	abs, err := filepath.Abs(filepath.Join(c.dir, lang.Name))
+	if err != nil {
+		return lang.CompileCmd
+	}
+	cmd := []string{lang.CompileCmd[0], "-I", abs}
+	return append(cmd, lang.CompileCmd[1:]...)
+}
+
+// includeDirs lists the directories the sandboxed compiler must see for
+// compileCmd's include path.
+func (c *CompileCache) includeDirs(lang Language) []string {
+	cmd := c.compileCmd(lang)
+	if len(cmd) > 2 && cmd[1] == "-I" {
+		return []string{cmd[2]}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// ErrCompileTimeLimitExceeded is returned by Sandbox.Compile, together with
+// the compiler's output so far, when the compiler ran out of time.
+var ErrCompileTimeLimitExceeded = errors.New("compilation exceeded the time limit")
+
+// CompileLimits bound the sandboxed compiler, independently of the limits
+// of the program it builds.
+type CompileLimits struct {
+	// TimeLimit is the compiler's CPU time in seconds.
+	TimeLimit float64
+	// WallTimeLimit stops a compiler that hangs without using CPU time,
+	// in seconds; zero means WallTimeLimit(TimeLimit).
+	WallTimeLimit float64
+	// MemoryLimit is in kilobytes.
+	MemoryLimit int
+	// FileSizeLimit caps each file the compiler writes, the build and its
+	// messages, in kilobytes; zero leaves it unlimited. OutputLimit caps
+	// the messages returned, in kilobytes. Sandboxes that redirect the
+	// compiler's output to a file in the box, like isolate, apply them.
+	FileSizeLimit int
+	OutputLimit   int
+}
+
+// CompileLimitsFromEnv reads JUDGE_COMPILE_TIME_SECONDS,
+// JUDGE_COMPILE_WALL_SECONDS, JUDGE_COMPILE_MEMORY_KB, JUDGE_COMPILE_FILE_KB
+// and JUDGE_COMPILE_OUTPUT_KB, defaulting to 10 seconds of CPU time, twice
+// that and a second of wall time, 1 GiB, files of 256 MiB and 64 KiB of
+// messages.
+func CompileLimitsFromEnv() CompileLimits {
+	limits := CompileLimits{TimeLimit: 10, MemoryLimit: 1 << 20, FileSizeLimit: 256 << 10, OutputLimit: 64}
+	if value := os.Getenv("JUDGE_COMPILE_TIME_SECONDS"); value != "" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			log.Printf("Invalid JUDGE_COMPILE_TIME_SECONDS %q, using %g", value, limits.TimeLimit)
+		} else {
+			limits.TimeLimit = seconds
+		}
+	}
+	if value := os.Getenv("JUDGE_COMPILE_WALL_SECONDS"); value != "" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			log.Printf("Invalid JUDGE_COMPILE_WALL_SECONDS %q, using %g", value, limits.wallTime())
+		} else {
+			limits.WallTimeLimit = seconds
+		}
+	}
+	if value := os.Getenv("JUDGE_COMPILE_MEMORY_KB"); value != "" {
+		kb, err := strconv.Atoi(value)
+		if err != nil || kb <= 0 {
+			log.Printf("Invalid JUDGE_COMPILE_MEMORY_KB %q, using %d", value, limits.MemoryLimit)
+		} else {
+			limits.MemoryLimit = kb
+		}
+	}
+	if value := os.Getenv("JUDGE_COMPILE_FILE_KB"); value != "" {
+		kb, err := strconv.Atoi(value)
+		if err != nil || kb <= 0 {
+			log.Printf("Invalid JUDGE_COMPILE_FILE_KB %q, using %d", value, limits.FileSizeLimit)
+		} else {
+			limits.FileSizeLimit = kb
+		}
+	}
+	if value := os.Getenv("JUDGE_COMPILE_OUTPUT_KB"); value != "" {
+		kb, err := strconv.Atoi(value)
+		if err != nil || kb <= 0 {
+			log.Printf("Invalid JUDGE_COMPILE_OUTPUT_KB %q, using %d", value, limits.OutputLimit)
+		} else {
+			limits.OutputLimit = kb
+		}
+	}
+	return limits
+}
+
+func (l CompileLimits) wallTime() float64 {
+	if l.WallTimeLimit > 0 {
+		return l.WallTimeLimit
+	}
+	return WallTimeLimit(l.TimeLimit)
+}
+
+// timedOut is what Compile returns for a compiler that was stopped.
+func (l CompileLimits) timedOut(output string) (string, bool, error) {
+	return output + fmt.Sprintf("\ncompilation exceeded the time limit of %gs (%gs of wall time)\n", l.TimeLimit, l.wallTime()), false, ErrCompileTimeLimitExceeded
+}
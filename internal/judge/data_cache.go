@@ -0,0 +1,252 @@
+package judge
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidDataRef   = errors.New("invalid test data reference")
+	ErrDataCacheMissing = errors.New("judge has no test data cache")
+)
+
+// DataRef points a worker at test data in object storage. URL is a
+// presigned download link; Version identifies the problem's test data, so
+// a changed test set never hits stale cache entries.
+type DataRef struct {
+	Version string `json:"version" binding:"required"`
+	Name    string `json:"name" binding:"required"`
+	URL     string `json:"url" binding:"required"`
+	Size    int64  `json:"size"`
+}
+
+func (r DataRef) valid() bool {
+	return safeName(r.Version) && safeName(r.Name) && r.URL != ""
+}
+
+func safeName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+type dataEntry struct {
+	path string
+	size int64
+}
+
+// DataCache keeps downloaded test data on the worker's disk under
+// <dir>/<version>/<name> and evicts the least recently used files once the
+// total size passes maxBytes.
+type DataCache struct {
+	dir        string
+	maxBytes   int64
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	lru      *list.List // of *dataEntry, most recently used first
+	entries  map[string]*list.Element
+	size     int64
+	fetching map[string]chan struct{}
+}
+
+// DataCacheBytesFromEnv reads JUDGE_DATA_CACHE_BYTES, the size limit of the
+// test data cache, defaulting to 10 GiB.
+func DataCacheBytesFromEnv() int64 {
+	value := os.Getenv("JUDGE_DATA_CACHE_BYTES")
+	if value == "" {
+		return 10 << 30
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid JUDGE_DATA_CACHE_BYTES %q, using 10 GiB", value)
+		return 10 << 30
+	}
+	return n
+}
+
+// NewDataCache opens the cache in dir, picking up files kept from earlier
+// runs with their modification time as last use.
+func NewDataCache(dir string, maxBytes int64) (*DataCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &DataCache{
+		dir:        dir,
+		maxBytes:   maxBytes,
+		httpClient: &http.Client{Timeout: 30 * time.Minute},
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		fetching:   make(map[string]chan struct{}),
+	}
+
+	type found struct {
+		entry   *dataEntry
+		modTime time.Time
+	}
+	var files []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if filepath.Base(path)[0] == '.' {
+			// a download interrupted by a crash
+			return os.Remove(path)
+		}
+		files = append(files, found{entry: &dataEntry{path: path, size: info.Size()}, modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, file := range files {
+		c.entries[file.entry.path] = c.lru.PushBack(file.entry)
+		c.size += file.entry.size
+	}
+	c.mu.Lock()
+	c.evict(nil)
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Read returns the referenced data, downloading it on a cache miss.
+func (c *DataCache) Read(ref DataRef) (string, error) {
+	path, err := c.fetch(ref)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// Prefetch downloads refs in the background, one at a time.
+func (c *DataCache) Prefetch(refs []DataRef) {
+	go func() {
+		for _, ref := range refs {
+			if _, err := c.fetch(ref); err != nil {
+				log.Printf("Error prefetching test data %s/%s: %v", ref.Version, ref.Name, err)
+			}
+		}
+	}()
+}
+
+// fetch returns the path of the cached file, downloading it once even when
+// several submissions ask for it at the same time.
+func (c *DataCache) fetch(ref DataRef) (string, error) {
+	if !ref.valid() {
+		return "", ErrInvalidDataRef
+	}
+	path := filepath.Join(c.dir, ref.Version, ref.Name)
+	for {
+		c.mu.Lock()
+		if element, ok := c.entries[path]; ok {
+			c.lru.MoveToFront(element)
+			c.mu.Unlock()
+			now := time.Now()
+			os.Chtimes(path, now, now)
+			return path, nil
+		}
+		if wait, ok := c.fetching[path]; ok {
+			c.mu.Unlock()
+			<-wait
+			continue
+		}
+		done := make(chan struct{})
+		c.fetching[path] = done
+		c.mu.Unlock()
+
+		size, err := c.download(ref, path)
+
+		c.mu.Lock()
+		delete(c.fetching, path)
+		close(done)
+		if err != nil {
+			c.mu.Unlock()
+			return "", err
+		}
+		element := c.lru.PushFront(&dataEntry{path: path, size: size})
+		c.entries[path] = element
+		c.size += size
+		c.evict(element)
+		c.mu.Unlock()
+		return path, nil
+	}
+}
+
+func (c *DataCache) download(ref DataRef, path string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.httpClient.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("download test data %s/%s: %s", ref.Version, ref.Name, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && ref.Size > 0 && size != ref.Size {
+		err = fmt.Errorf("download test data %s/%s: got %d bytes, expected %d", ref.Version, ref.Name, size, ref.Size)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return size, os.Rename(tmp.Name(), path)
+}
+
+// evict drops least recently used files until the cache fits, never
+// dropping keep. c.mu must be held.
+func (c *DataCache) evict(keep *list.Element) {
+	for c.size > c.maxBytes {
+		oldest := c.lru.Back()
+		if oldest == nil || oldest == keep {
+			return
+		}
+		entry := oldest.Value.(*dataEntry)
+		if err := os.Remove(entry.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Error evicting test data %s: %v", entry.path, err)
+		}
+		c.lru.Remove(oldest)
+		delete(c.entries, entry.path)
+		c.size -= entry.size
+	}
+}
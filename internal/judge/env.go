@@ -0,0 +1,92 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var ErrEnvNotAllowed = errors.New("environment variable not allowed")
+
+// DefaultEnvAllowlist lists the variables problems may expose to programs
+// when JUDGE_ENV_ALLOWLIST is not set.
+var DefaultEnvAllowlist = []string{"SEED", "TEST_INDEX", "TEST_COUNT"}
+
+var envNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// forbiddenEnv can never be injected, even if an operator allowlists it,
+// because it changes how binaries are loaded or located.
+var forbiddenEnv = map[string]bool{
+	"PATH":              true,
+	"HOME":              true,
+	"SHELL":             true,
+	"IFS":               true,
+	"ENV":               true,
+	"BASH_ENV":          true,
+	"PYTHONPATH":        true,
+	"PYTHONSTARTUP":     true,
+	"CLASSPATH":         true,
+	"JAVA_TOOL_OPTIONS": true,
+	"_JAVA_OPTIONS":     true,
+}
+
+func isForbiddenEnv(name string) bool {
+	return forbiddenEnv[name] || strings.HasPrefix(name, "LD_") || strings.HasPrefix(name, "DYLD_")
+}
+
+// EnvAllowlist decides which environment variables may be passed into the
+// sandbox.
+type EnvAllowlist struct {
+	allowed map[string]bool
+}
+
+func NewEnvAllowlist(names []string) *EnvAllowlist {
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" && !isForbiddenEnv(name) {
+			allowed[name] = true
+		}
+	}
+	return &EnvAllowlist{allowed: allowed}
+}
+
+// EnvAllowlistFromEnv reads a comma-separated JUDGE_ENV_ALLOWLIST.
+func EnvAllowlistFromEnv() *EnvAllowlist {
+	if value := os.Getenv("JUDGE_ENV_ALLOWLIST"); value != "" {
+		return NewEnvAllowlist(strings.Split(value, ","))
+	}
+	return NewEnvAllowlist(DefaultEnvAllowlist)
+}
+
+// Validate returns an error naming the first variable that may not be
+// injected.
+func (a *EnvAllowlist) Validate(env map[string]string) error {
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) || isForbiddenEnv(name) || !a.allowed[name] {
+			return fmt.Errorf("%w: %q", ErrEnvNotAllowed, name)
+		}
+		if strings.ContainsAny(value, "\x00\n") {
+			return fmt.Errorf("%w: %q has an invalid value", ErrEnvNotAllowed, name)
+		}
+	}
+	return nil
+}
+
+// languageEnv returns the language's variables overridden by env, the
+// problem's validated ones.
+func languageEnv(lang Language, env map[string]string) map[string]string {
+	if len(lang.Env) == 0 {
+		return env
+	}
+	merged := make(map[string]string, len(lang.Env)+len(env))
+	for name, value := range lang.Env {
+		merged[name] = value
+	}
+	for name, value := range env {
+		merged[name] = value
+	}
+	return merged
+}
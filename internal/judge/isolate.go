@@ -0,0 +1,590 @@
+package judge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// isolateMeta holds the fields of an isolate --meta file that the judge uses.
+type isolateMeta struct {
+	Time     float64
+	WallTime float64
+	MaxRSS   int
+	// CgMem and OOMKilled are only reported when the box runs in a
+	// control group.
+	CgMem     int
+	OOMKilled bool
+	ExitCode  int
+	ExitSig   int
+	Status    string
+	Message   string
+	Killed    bool
+}
+
+func parseIsolateMeta(r io.Reader) (isolateMeta, error) {
+	var meta isolateMeta
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "time":
+			meta.Time, _ = strconv.ParseFloat(value, 64)
+		case "time-wall":
+			meta.WallTime, _ = strconv.ParseFloat(value, 64)
+		case "max-rss":
+			meta.MaxRSS, _ = strconv.Atoi(value)
+		case "cg-mem":
+			meta.CgMem, _ = strconv.Atoi(value)
+		case "cg-oom-killed":
+			meta.OOMKilled = value == "1"
+		case "exitcode":
+			meta.ExitCode, _ = strconv.Atoi(value)
+		case "exitsig":
+			meta.ExitSig, _ = strconv.Atoi(value)
+		case "status":
+			meta.Status = value
+		case "message":
+			meta.Message = value
+		case "killed":
+			meta.Killed = value == "1"
+		}
+	}
+	return meta, scanner.Err()
+}
+
+// IsolateSandbox runs programs in isolate boxes: separate namespaces and
+// user, with isolate enforcing the time, memory and process limits.
+type IsolateSandbox struct {
+	tools *toolboxes
+	// cgroups puts boxes in control groups, so memory is limited and
+	// measured for the whole box, arena allocations of the JVM and CPython
+	// included, rather than as each process's address space.
+	cgroups bool
+	quota   BoxQuota
+	retries InitRetryPolicy
+	// noNetwork is set when isolate cannot share the network; see
+	// CheckCapabilities.
+	noNetwork bool
+	mu        sync.Mutex
+	dirs      map[string]string // box ID -> box directory, while initialized
+}
+
+// NewIsolateSandbox keeps the toolboxes of languages with Tools in
+// workDir/tools, a name the random per-submission directories cannot take.
+// cgroups needs isolate set up for control groups (isolate-cg-keeper on
+// cgroups v2), and quota the box root on a filesystem with user quotas.
+func NewIsolateSandbox(workDir string, cgroups bool, quota BoxQuota, retries InitRetryPolicy) *IsolateSandbox {
+	return &IsolateSandbox{tools: newToolboxes(filepath.Join(workDir, "tools")), cgroups: cgroups, quota: quota, retries: retries, dirs: make(map[string]string)}
+}
+
+// IsolateCgroupsFromEnv reads JUDGE_ISOLATE_CGROUPS, true to run boxes in
+// control groups.
+func IsolateCgroupsFromEnv() bool {
+	value := os.Getenv("JUDGE_ISOLATE_CGROUPS")
+	cgroups, err := strconv.ParseBool(value)
+	if value != "" && err != nil {
+		log.Printf("Invalid JUDGE_ISOLATE_CGROUPS %q, not using control groups", value)
+	}
+	return cgroups
+}
+
+// box returns the arguments selecting the box, which every isolate
+// invocation for it must repeat.
+func (s *IsolateSandbox) box(boxID string, args ...string) []string {
+	box := []string{"--box-id=" + boxID}
+	if s.cgroups {
+		box = append(box, "--cg")
+	}
+	return append(box, args...)
+}
+
+// mountArgs shows the language's extra directories at their host paths;
+// "maybe" skips those a host does not have.
+func mountArgs(lang Language) []string {
+	args := make([]string, 0, len(lang.Mounts))
+	for _, mount := range lang.Mounts {
+		args = append(args, "--dir="+mount+":maybe")
+	}
+	return args
+}
+
+// memoryLimit limits the box as a whole with control groups, and each
+// process's address space without.
+func (s *IsolateSandbox) memoryLimit(kilobytes int) string {
+	if s.cgroups {
+		return "--cg-mem=" + strconv.Itoa(kilobytes)
+	}
+	return "--mem=" + strconv.Itoa(kilobytes)
+}
+
+func (s *IsolateSandbox) Name() string {
+	return "isolate"
+}
+
+// Init sets up the box, retrying with backoff when isolate fails, e.g.
+// with "Box busy" after a crashed run. Every attempt cleans the box up
+// first; once the retries are exhausted it returns ErrBoxInit.
+func (s *IsolateSandbox) Init(boxID string) error {
+	args := s.box(boxID, "--init")
+	if s.quota.Blocks > 0 {
+		args = append(args, s.quota.arg())
+	}
+	for retry := 0; ; retry++ {
+		// a previous crash may have left the box initialized
+		exec.Command("isolate", s.box(boxID, "--cleanup")...).Run()
+
+		initOut, err := exec.Command("isolate", args...).Output()
+		if err == nil {
+			s.mu.Lock()
+			s.dirs[boxID] = filepath.Join(strings.TrimSpace(string(initOut)), "box")
+			s.mu.Unlock()
+			return nil
+		}
+		err = commandError(err)
+		if retry == s.retries.Retries {
+			return fmt.Errorf("%w: isolate init of box %s failed after %d retries: %v", ErrBoxInit, boxID, retry, err)
+		}
+		delay := s.retries.delay(retry + 1)
+		log.Printf("isolate init of box %s failed, retrying in %s: %v", boxID, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+func (s *IsolateSandbox) Cleanup(boxID string) error {
+	s.mu.Lock()
+	delete(s.dirs, boxID)
+	s.mu.Unlock()
+	return exec.Command("isolate", s.box(boxID, "--cleanup")...).Run()
+}
+
+// Recycle empties the box and its /tmp, keeping it initialized. The run's
+// processes are gone once isolate --run returns, and with the files the
+// quota's usage.
+func (s *IsolateSandbox) Recycle(boxID string) error {
+	boxDir, err := s.boxDir(boxID)
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{boxDir, filepath.Join(filepath.Dir(boxDir), "tmp")} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *IsolateSandbox) boxDir(boxID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir, ok := s.dirs[boxID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrBoxNotInitialized, boxID)
+	}
+	return dir, nil
+}
+
+// Run classifies the outcome from isolate's meta file. Cancelling ctx kills
+// the isolate keeper, and with it every process in the box; writing past
+// the output limit gets the program SIGXFSZ.
+func (s *IsolateSandbox) Run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error) {
+	if submission.NetworkEnabled && s.noNetwork {
+		return ExecutionResult{}, ErrNetworkUnavailable
+	}
+	boxDir, err := s.boxDir(boxID)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	if err := copyDir(dir, boxDir); err != nil {
+		return ExecutionResult{}, fmt.Errorf("copy into box: %w", err)
+	}
+
+	// isolate opens the redirections inside the box, so output is counted
+	// against --fsize and the quota and never passes through the judge
+	if err := os.WriteFile(filepath.Join(boxDir, isolateStdin), []byte(submission.Input), 0o644); err != nil {
+		return ExecutionResult{}, fmt.Errorf("write input: %w", err)
+	}
+
+	metaPath := filepath.Join(dir, "meta")
+	args := s.box(boxID,
+		"--meta="+metaPath,
+		"--time="+formatSeconds(submission.TimeLimit),
+		"--wall-time="+formatSeconds(submission.wallTime()),
+		"--extra-time=0.5",
+		s.memoryLimit(submission.MemoryLimit),
+		"--processes="+strconv.Itoa(submission.MaxProcesses),
+		"--fsize="+strconv.Itoa(submission.OutputLimit),
+		"--stdout="+isolateStdout,
+		"--stderr="+isolateStderr,
+	)
+	// without --stdin, the program reads isolate's own
+	if submission.Stdin == nil {
+		args = append(args, "--stdin="+isolateStdin)
+	}
+	if submission.NetworkEnabled {
+		args = append(args, "--share-net")
+	}
+	args = append(args, mountArgs(lang)...)
+	if len(lang.Tools) > 0 {
+		mounts, err := s.tools.mounts(lang)
+		if err != nil {
+			return ExecutionResult{}, err
+		}
+		for path, source := range mounts {
+			args = append(args, "--dir="+path+"="+source)
+		}
+		args = append(args, "--env=PATH=/usr/bin:/bin")
+	}
+	for name, value := range submission.Env {
+		args = append(args, "--env="+name+"="+value)
+	}
+	args = append(args, "--run", "--")
+	args = append(args, lang.RunCmd...)
+
+	// isolate's own messages; the program's go to files in the box
+	var isolateErr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "isolate", args...)
+	cmd.Stderr = &isolateErr
+	if submission.Stdin != nil {
+		if err := feedStdin(cmd, submission.Stdin); err != nil {
+			return ExecutionResult{}, err
+		}
+	}
+
+	// streamed runs tail the files, which a previous run in the box must
+	// not have left behind
+	stdoutPath := filepath.Join(boxDir, isolateStdout)
+	stderrPath := filepath.Join(boxDir, isolateStderr)
+	os.Remove(stdoutPath)
+	os.Remove(stderrPath)
+	finishStream := streamOutput(ctx, submission.Output,
+		fileOutput(stdoutPath, submission.OutputLimit), fileOutput(stderrPath, submission.OutputLimit))
+
+	var sampler *usageSampler
+	if submission.Timeline && s.cgroups {
+		if cgroupDir, err := isolateCgroupDir(boxID); err == nil {
+			sampler = sampleUsage(cgroupUsage(cgroupDir))
+		} else {
+			log.Printf("Usage timeline unavailable: %v", err)
+		}
+	}
+	runErr := cmd.Run()
+	finishStream()
+	var timeline []UsageSample
+	if sampler != nil {
+		timeline = sampler.Stop()
+	}
+	var exitErr *exec.ExitError
+	if runErr != nil && !(errors.As(runErr, &exitErr) && exitErr.ExitCode() == 1) {
+		return ExecutionResult{}, fmt.Errorf("isolate run: %w: %s", runErr, isolateErr.String())
+	}
+
+	metaFile, err := os.Open(metaPath)
+	if err != nil {
+		return ExecutionResult{}, fmt.Errorf("read meta: %w", err)
+	}
+	defer metaFile.Close()
+	meta, err := parseIsolateMeta(metaFile)
+	if err != nil {
+		return ExecutionResult{}, fmt.Errorf("parse meta: %w", err)
+	}
+	stdout, err := readOutput(stdoutPath, submission.OutputLimit)
+	if err != nil {
+		return ExecutionResult{}, fmt.Errorf("read stdout: %w", err)
+	}
+	stderr, err := readOutput(stderrPath, submission.OutputLimit)
+	if err != nil {
+		return ExecutionResult{}, fmt.Errorf("read stderr: %w", err)
+	}
+
+	result := ExecutionResult{
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Time:     meta.Time,
+		WallTime: meta.WallTime,
+		Memory:   meta.peakMemory(),
+		ExitCode: meta.ExitCode,
+		Message:  meta.Message,
+		Status:   classify(meta, submission.MemoryLimit),
+		Timeline: timeline,
+	}
+	switch result.Status {
+	case StatusOutputLimitExceeded:
+		result.Message = outputLimitMessage(submission.OutputLimit)
+	case StatusSecurityViolation:
+		result.Message = securityViolationMessage
+	}
+	if s.quota.Blocks > 0 && result.Status != StatusOK && s.quotaReached(boxDir, dir, result.Stderr) {
+		result.Status = StatusDiskQuotaExceeded
+		result.Message = fmt.Sprintf("files exceeded the disk quota of %d KB and %d files", s.quota.Blocks, s.quota.Inodes)
+	}
+	if submission.ReturnFiles {
+		if result.Files, err = boxFiles(boxDir, submission.OutputLimit); err != nil {
+			return ExecutionResult{}, fmt.Errorf("read box files: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// quotaReached tells whether a failed run ran into the box's quota: writes
+// then fail with EDQUOT, which programs ignoring the error do not report.
+func (s *IsolateSandbox) quotaReached(boxDir, dir, stderr string) bool {
+	if strings.Contains(stderr, edquotMessage) {
+		return true
+	}
+	// isolate mounts the box's /tmp from next to the box directory
+	dirs := []string{boxDir, filepath.Join(filepath.Dir(boxDir), "tmp")}
+	skip := listFiles(dir)
+	skip[isolateStdin] = true
+	return s.quota.reached(writtenUsage(dirs, skip))
+}
+
+// Files in the box isolate redirects the program's standard streams to.
+const (
+	isolateStdin  = ".stdin"
+	isolateStdout = ".stdout"
+	isolateStderr = ".stderr"
+)
+
+// readOutput reads what the program wrote to path, up to limitKB. --fsize
+// keeps the file within the limit; the read is bounded all the same.
+func readOutput(path string, limitKB int) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// isolate failed before opening it
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if limitKB > 0 {
+		r = io.LimitReader(f, int64(limitKB)*1024)
+	}
+	data, err := io.ReadAll(r)
+	return string(data), err
+}
+
+// compilerOutput reads the compiler's messages from path, keeping the
+// first limitKB and noting that the rest was cut.
+func compilerOutput(path string, limitKB int) (string, error) {
+	output, err := readOutput(path, limitKB)
+	if err != nil || limitKB <= 0 {
+		return output, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return output, nil
+		}
+		return "", err
+	}
+	if info.Size() > int64(limitKB)*1024 {
+		output += fmt.Sprintf("\n... compiler output truncated to %d KB\n", limitKB)
+	}
+	return output, nil
+}
+
+// isolateCgroupRoot names the file where isolate-cg-keeper records the
+// control group it manages, isolate's default cg_root.
+const isolateCgroupRoot = "/run/isolate/cgroup"
+
+// isolateCgroupDir returns the control group of a box on cgroups v2.
+func isolateCgroupDir(boxID string) (string, error) {
+	root, err := os.ReadFile(isolateCgroupRoot)
+	if err != nil {
+		return "", fmt.Errorf("find isolate control group: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(string(root)), "box-"+boxID), nil
+}
+
+// Compile gives the compiler the host's toolchain read-only plus
+// includeDirs.
+func (s *IsolateSandbox) Compile(ctx context.Context, boxID string, lang Language, dir string, compileCmd []string, includeDirs []string, limits CompileLimits) (output string, ok bool, err error) {
+	// isolate does not search PATH for the program it runs
+	compiler, err := exec.LookPath(compileCmd[0])
+	if err != nil {
+		return "", false, fmt.Errorf("find compiler: %w", err)
+	}
+
+	boxDir, err := s.boxDir(boxID)
+	if err != nil {
+		return "", false, err
+	}
+	if err := copyDir(dir, boxDir); err != nil {
+		return "", false, fmt.Errorf("copy into box: %w", err)
+	}
+
+	// the meta file is kept out of dir, whose new files are the build
+	metaFile, err := os.CreateTemp("", "compile-meta-")
+	if err != nil {
+		return "", false, err
+	}
+	metaFile.Close()
+	defer os.Remove(metaFile.Name())
+
+	args := s.box(boxID,
+		"--meta="+metaFile.Name(),
+		"--time="+formatSeconds(limits.TimeLimit),
+		"--wall-time="+formatSeconds(limits.wallTime()),
+		s.memoryLimit(limits.MemoryLimit),
+		// compiler drivers fork and javac starts many JVM threads
+		"--processes",
+		"--env=PATH=/usr/local/bin:/usr/bin:/bin",
+		// as in Run, isolate opens the redirection inside the box, so the
+		// messages are bounded by --fsize and never pass through the judge
+		"--stdout="+isolateStdout,
+		"--stderr-to-stdout",
+	)
+	if limits.FileSizeLimit > 0 {
+		args = append(args, "--fsize="+strconv.Itoa(limits.FileSizeLimit))
+	}
+	for _, includeDir := range includeDirs {
+		args = append(args, "--dir="+includeDir)
+	}
+	args = append(args, mountArgs(lang)...)
+	args = append(args, "--run", "--", compiler)
+	args = append(args, compileCmd[1:]...)
+
+	// isolate's own messages; the compiler's go to a file in the box
+	var isolateErr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "isolate", args...)
+	cmd.Stderr = &isolateErr
+
+	outputPath := filepath.Join(boxDir, isolateStdout)
+	os.Remove(outputPath)
+	runErr := cmd.Run()
+	var exitErr *exec.ExitError
+	if runErr != nil && !(errors.As(runErr, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", false, fmt.Errorf("isolate run: %w: %s", runErr, isolateErr.String())
+	}
+
+	metaReader, err := os.Open(metaFile.Name())
+	if err != nil {
+		return "", false, fmt.Errorf("read meta: %w", err)
+	}
+	defer metaReader.Close()
+	meta, err := parseIsolateMeta(metaReader)
+	if err != nil {
+		return "", false, fmt.Errorf("parse meta: %w", err)
+	}
+
+	output, err = compilerOutput(outputPath, limits.OutputLimit)
+	if err != nil {
+		return "", false, fmt.Errorf("read compiler output: %w", err)
+	}
+	// the messages are not part of the build
+	if err := os.Remove(outputPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", false, err
+	}
+	switch meta.Status {
+	case "":
+	case "TO":
+		return limits.timedOut(output)
+	case "XX":
+		return "", false, fmt.Errorf("isolate internal error: %s", meta.Message)
+	case "SG":
+		if meta.ExitSig == sigxfsz {
+			output += fmt.Sprintf("\ncompiler wrote a file larger than the limit of %d KB\n", limits.FileSizeLimit)
+			return output, false, nil
+		}
+		fallthrough
+	default:
+		if meta.Message != "" {
+			output += "\n" + meta.Message + "\n"
+		}
+		return output, false, nil
+	}
+
+	if err := copyDir(boxDir, dir); err != nil {
+		return "", false, fmt.Errorf("copy out of box: %w", err)
+	}
+	return output, true, nil
+}
+
+// WallTimeLimit is the wall-clock time a run with the given CPU time limit
+// may take before the sandbox stops it, leaving room for waiting on I/O.
+func WallTimeLimit(timeLimit float64) float64 {
+	return timeLimit*2 + 1
+}
+
+// peakMemory is the program's memory high-water mark in kilobytes. The
+// control group's figure also counts child processes and page cache, so it
+// wins when present.
+func (m isolateMeta) peakMemory() int {
+	return max(m.MaxRSS, m.CgMem)
+}
+
+func classify(meta isolateMeta, memoryLimit int) Status {
+	switch meta.Status {
+	case "":
+		return StatusOK
+	case "TO":
+		return StatusTimeLimitExceeded
+	case "XX":
+		return StatusInternalError
+	case "SG":
+		if meta.ExitSig == sigxfsz {
+			// a file written past --fsize
+			return StatusOutputLimitExceeded
+		}
+		if meta.ExitSig == sigsys {
+			return StatusSecurityViolation
+		}
+	}
+	if meta.OOMKilled || memoryLimit > 0 && meta.peakMemory() >= memoryLimit {
+		return StatusMemoryLimitExceeded
+	}
+	return StatusRuntimeError
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+// copyDir copies the regular files of src into dst, preserving permissions
+// so compiled binaries stay executable.
+func copyDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), data, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrIsolateUnsupported is returned at startup when the installed
+	// isolate lacks an option the worker needs.
+	ErrIsolateUnsupported = errors.New("installed isolate is missing required options")
+	// ErrNetworkUnavailable is returned for executions with network access
+	// on a worker whose isolate cannot share the network.
+	ErrNetworkUnavailable = errors.New("this worker cannot give programs network access")
+)
+
+// isolateRequiredFlags are the options every execution uses.
+var isolateRequiredFlags = []string{
+	"--box-id", "--init", "--cleanup", "--run", "--meta", "--time", "--wall-time",
+	"--extra-time", "--mem", "--processes", "--fsize", "--dir", "--env", "--stderr-to-stdout",
+}
+
+var isolateFlagPattern = regexp.MustCompile(`--[a-z][a-z-]*`)
+
+// IsolateCapabilities is what the installed isolate supports, as read from
+// its --version and --help output.
+type IsolateCapabilities struct {
+	Version string
+	Flags   map[string]bool
+}
+
+// ProbeIsolate runs isolate --version and --help.
+func ProbeIsolate() (IsolateCapabilities, error) {
+	version, err := exec.Command("isolate", "--version").CombinedOutput()
+	if err != nil && len(version) == 0 {
+		return IsolateCapabilities{}, fmt.Errorf("isolate --version: %w", err)
+	}
+	// older versions exit non-zero after printing the usage
+	help, err := exec.Command("isolate", "--help").CombinedOutput()
+	if err != nil && len(help) == 0 {
+		return IsolateCapabilities{}, fmt.Errorf("isolate --help: %w", err)
+	}
+	capabilities := IsolateCapabilities{Flags: make(map[string]bool)}
+	capabilities.Version, _, _ = strings.Cut(strings.TrimSpace(string(version)), "\n")
+	for _, flag := range isolateFlagPattern.FindAllString(string(help), -1) {
+		capabilities.Flags[flag] = true
+	}
+	return capabilities, nil
+}
+
+func (c IsolateCapabilities) missing(flags ...string) []string {
+	var missing []string
+	for _, flag := range flags {
+		if !c.Flags[flag] {
+			missing = append(missing, flag)
+		}
+	}
+	return missing
+}
+
+// CheckCapabilities makes sure isolate supports what the sandbox is
+// configured for. Missing options the sandbox cannot work without, control
+// groups and disk quotas included when they are enabled, are an error;
+// without --share-net, executions that need the network are refused.
+func (s *IsolateSandbox) CheckCapabilities(capabilities IsolateCapabilities) error {
+	required := append([]string{}, isolateRequiredFlags...)
+	if s.cgroups {
+		required = append(required, "--cg", "--cg-mem")
+	}
+	if s.quota.Blocks > 0 {
+		required = append(required, "--quota")
+	}
+	if missing := capabilities.missing(required...); len(missing) > 0 {
+		return fmt.Errorf("%w: %s lacks %s", ErrIsolateUnsupported, capabilities.Version, strings.Join(missing, ", "))
+	}
+	if len(capabilities.missing("--share-net")) > 0 {
+		log.Printf("isolate has no --share-net; problems with network access will not be judged")
+		s.noNetwork = true
+	}
+	return nil
+}
+
+// CheckSandboxCapabilities probes isolate when the sandbox uses it, for
+// every language or some, logs what it supports and checks it against
+// the configuration.
+func CheckSandboxCapabilities(sandbox Sandbox) error {
+	var isolates []*IsolateSandbox
+	switch sandbox := sandbox.(type) {
+	case *LanguageSandbox:
+		if isolate, ok := isolateOf(sandbox.fallback); ok {
+			isolates = append(isolates, isolate)
+		}
+		for _, backend := range sandbox.byLanguage {
+			if isolate, ok := isolateOf(backend); ok {
+				isolates = append(isolates, isolate)
+			}
+		}
+	default:
+		if isolate, ok := isolateOf(sandbox); ok {
+			isolates = append(isolates, isolate)
+		}
+	}
+	if len(isolates) == 0 {
+		return nil
+	}
+
+	capabilities, err := ProbeIsolate()
+	if err != nil {
+		return err
+	}
+	flags := make([]string, 0, len(capabilities.Flags))
+	for flag := range capabilities.Flags {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	log.Printf("Found %s supporting %s", capabilities.Version, strings.Join(flags, " "))
+	for _, isolate := range isolates {
+		if err := isolate.CheckCapabilities(capabilities); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isolateOf returns the isolate backend a sandbox is or runs programs in.
+func isolateOf(sandbox Sandbox) (*IsolateSandbox, bool) {
+	if wasm, ok := sandbox.(*WasmSandbox); ok {
+		sandbox = wasm.Sandbox
+	}
+	isolate, ok := sandbox.(*IsolateSandbox)
+	return isolate, ok
+}
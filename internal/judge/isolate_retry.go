@@ -0,0 +1,66 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBoxInit is returned when a box could not be set up even after
+	// retrying; Execute reports it as StatusInternalError.
+	ErrBoxInit            = errors.New("box could not be initialized")
+	ErrInvalidRetryPolicy = errors.New("invalid isolate init retry policy")
+)
+
+var defaultInitRetryPolicy = InitRetryPolicy{Retries: 3, Backoff: 100 * time.Millisecond}
+
+// InitRetryPolicy is how often isolate --init is retried, e.g. when a box
+// is still busy after a crashed run, and how long to wait before the first
+// retry; the wait doubles with every retry.
+type InitRetryPolicy struct {
+	Retries int
+	Backoff time.Duration
+}
+
+// InitRetryPolicyFromEnv reads JUDGE_ISOLATE_INIT_RETRIES and
+// JUDGE_ISOLATE_INIT_BACKOFF_MS, defaulting to 3 retries after 100ms.
+func InitRetryPolicyFromEnv() (InitRetryPolicy, error) {
+	policy := defaultInitRetryPolicy
+	if value := strings.TrimSpace(os.Getenv("JUDGE_ISOLATE_INIT_RETRIES")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return InitRetryPolicy{}, fmt.Errorf("%w: JUDGE_ISOLATE_INIT_RETRIES %q", ErrInvalidRetryPolicy, value)
+		}
+		policy.Retries = n
+	}
+	if value := strings.TrimSpace(os.Getenv("JUDGE_ISOLATE_INIT_BACKOFF_MS")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return InitRetryPolicy{}, fmt.Errorf("%w: JUDGE_ISOLATE_INIT_BACKOFF_MS %q", ErrInvalidRetryPolicy, value)
+		}
+		policy.Backoff = time.Duration(n) * time.Millisecond
+	}
+	return policy, nil
+}
+
+// delay is the wait before the retry-th retry, counting from one.
+func (p InitRetryPolicy) delay(retry int) time.Duration {
+	return p.Backoff << (retry - 1)
+}
+
+// commandError adds what a failed command wrote to stderr, such as
+// isolate's "Box busy", to its exit error.
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if stderr := strings.TrimSpace(string(exitErr.Stderr)); stderr != "" {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+	}
+	return err
+}
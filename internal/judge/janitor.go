@@ -0,0 +1,132 @@
+package judge
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// workDirShared are the work directory's entries that outlive executions;
+// everything else in it is a per-execution directory.
+var workDirShared = map[string]bool{
+	"cache":    true,
+	"data":     true,
+	"binaries": true,
+	"boxes":    true,
+	"tools":    true,
+}
+
+// JanitorConfig says how often the janitor sweeps and how old a leftover
+// per-execution directory must be before it is removed.
+type JanitorConfig struct {
+	Interval time.Duration
+	MaxAge   time.Duration
+}
+
+// JanitorConfigFromEnv reads JUDGE_JANITOR_INTERVAL_MINUTES and
+// JUDGE_JANITOR_MAX_AGE_MINUTES, defaulting to 10 and 60 minutes.
+func JanitorConfigFromEnv() JanitorConfig {
+	minutes := func(name string, fallback int) time.Duration {
+		value := os.Getenv(name)
+		if value == "" {
+			return time.Duration(fallback) * time.Minute
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			log.Printf("Invalid %s %q, using %d", name, value, fallback)
+			return time.Duration(fallback) * time.Minute
+		}
+		return time.Duration(n) * time.Minute
+	}
+	return JanitorConfig{
+		Interval: minutes("JUDGE_JANITOR_INTERVAL_MINUTES", 10),
+		MaxAge:   minutes("JUDGE_JANITOR_MAX_AGE_MINUTES", 60),
+	}
+}
+
+// Reconcile cleans up after a worker that crashed mid-run: it cleans every
+// box of the pool and removes every per-execution directory. It must run
+// before the worker takes executions.
+func (j *Judge) Reconcile() {
+	boxes, dirs := j.sweep(0)
+	log.Printf("Reconciled %d sandbox boxes and removed %d leftover work directories", boxes, dirs)
+}
+
+// RunJanitor sweeps every interval until ctx is cancelled, cleaning boxes
+// that are not leased and removing per-execution directories older than
+// MaxAge that no execution uses, such as those a failed removal left.
+func (j *Judge) RunJanitor(ctx context.Context, config JanitorConfig) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, dirs := j.sweep(config.MaxAge); dirs > 0 {
+				log.Printf("Janitor removed %d leftover work directories", dirs)
+			}
+		}
+	}
+}
+
+// sweep cleans the free boxes and removes unused per-execution directories
+// last modified more than maxAge ago, returning how many of each. With a
+// maxAge, as the janitor sweeps, warm boxes are left as they are.
+func (j *Judge) sweep(maxAge time.Duration) (boxes, dirs int) {
+	// Leased boxes are skipped; a released one goes to the back of the
+	// pool, so each free box is seen once
+	for i := 0; i < j.boxes.Size(); i++ {
+		boxID, release, ok := j.boxes.TryAcquire()
+		if !ok {
+			break
+		}
+		// warm boxes were emptied when released; periodic sweeps keep them
+		if maxAge > 0 && j.isWarm(boxID) {
+			release()
+			continue
+		}
+		j.forgetWarm(boxID)
+		if err := j.sandbox.Cleanup(boxID); err != nil {
+			log.Printf("Error cleaning up box %s: %v", boxID, err)
+		} else {
+			boxes++
+		}
+		release()
+	}
+
+	entries, err := os.ReadDir(j.workDir)
+	if err != nil {
+		log.Printf("Error listing work directory: %v", err)
+		return boxes, dirs
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		path := filepath.Join(j.workDir, entry.Name())
+		if workDirShared[entry.Name()] {
+			continue
+		}
+		if _, used := j.workDirs.Load(path); used {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			log.Printf("Error removing leftover work directory %s: %v", path, err)
+			continue
+		}
+		dirs++
+	}
+	return boxes, dirs
+}
+
+// removeWorkDir removes a per-execution directory once it is done with.
+func (j *Judge) removeWorkDir(dir string) {
+	os.RemoveAll(dir)
+	j.workDirs.Delete(dir)
+}
@@ -0,0 +1,440 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"online-judge/internal/clock"
+	"online-judge/pkg/verdict"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	ErrInvalidLimits       = errors.New("time and memory limits must be positive")
+	ErrInvalidFile         = errors.New("invalid extra file name")
+)
+
+// Status and its values are defined in pkg/verdict, for embedders.
+type Status = verdict.Status
+
+const (
+	StatusOK                       = verdict.StatusOK
+	StatusCompileError             = verdict.StatusCompileError
+	StatusCompileTimeLimitExceeded = verdict.StatusCompileTimeLimitExceeded
+	StatusRuntimeError             = verdict.StatusRuntimeError
+	StatusTimeLimitExceeded        = verdict.StatusTimeLimitExceeded
+	StatusMemoryLimitExceeded      = verdict.StatusMemoryLimitExceeded
+	StatusOutputLimitExceeded      = verdict.StatusOutputLimitExceeded
+	StatusDiskQuotaExceeded        = verdict.StatusDiskQuotaExceeded
+	StatusInternalError            = verdict.StatusInternalError
+	StatusSecurityViolation        = verdict.StatusSecurityViolation
+)
+
+// Submission is a single program run requested from the judge.
+type Submission struct {
+	Language    string  `json:"language" binding:"required"`
+	Code        string  `json:"code" binding:"required"`
+	Input       string  `json:"input"`
+	TimeLimit   float64 `json:"timeLimit"`   // seconds of CPU time
+	MemoryLimit int     `json:"memoryLimit"` // kilobytes
+	// WallTimeLimit stops a program that waits without using CPU time, in
+	// seconds; zero means WallTimeLimit(TimeLimit). At most
+	// MaxWallTimeLimit.
+	WallTimeLimit float64 `json:"wallTimeLimit,omitempty"`
+	// OutputLimit caps what the program writes to stdout, to stderr and to
+	// each file, in kilobytes.
+	OutputLimit int               `json:"outputLimit,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	// Files are extra files placed next to the program, e.g. the input,
+	// answer and output files read by a checker.
+	Files map[string]string `json:"files,omitempty"`
+	// InputRef replaces Input with test data the worker fetches from
+	// object storage through its data cache.
+	InputRef *DataRef `json:"inputRef,omitempty"`
+	// CacheBinary keeps the build for later runs of the same source, for
+	// checkers and other programs run on every test.
+	CacheBinary bool `json:"cacheBinary,omitempty"`
+	// MaxProcesses lets the program start that many processes and threads,
+	// e.g. for judge scripts that run compilers; zero takes the language's
+	// limit, so a fork bomb stops at the first fork.
+	MaxProcesses int `json:"maxProcesses,omitempty"`
+	// NetworkEnabled lets the program use the network: isolate boxes and
+	// nsjail jails share the host's. Docker containers keep their
+	// configured network, where loopback works either way.
+	NetworkEnabled bool `json:"networkEnabled,omitempty"`
+	// RunID names the run so it can be killed while it executes.
+	RunID string `json:"runId,omitempty"`
+	// Timeline samples the program's CPU time and memory while it runs,
+	// on the isolate backend with control groups and the process backend.
+	Timeline bool `json:"timeline,omitempty"`
+	// Region is the judge region a regional Client tries first; it is not
+	// sent to the worker.
+	Region string `json:"-"`
+	// ReturnFiles sends back the files the program left next to it, in
+	// ExecutionResult.Files, for playground sessions that keep them
+	// between runs.
+	ReturnFiles bool `json:"returnFiles,omitempty"`
+	// Output receives the program's stdout and stderr while it runs, on
+	// the isolate and process backends; see Client.ExecuteStream. The
+	// caller closes it once Execute returned.
+	Output chan<- OutputChunk `json:"-"`
+	// Stdin replaces Input with what the program reads while it runs, for
+	// interactive runs on the isolate and process backends; see
+	// Client.ExecuteInteractive. The caller closes it once Execute
+	// returned.
+	Stdin io.Reader `json:"-"`
+}
+
+// wallTime is the run's wall-clock limit in seconds.
+func (s Submission) wallTime() float64 {
+	if s.WallTimeLimit > 0 {
+		return s.WallTimeLimit
+	}
+	return WallTimeLimit(s.TimeLimit)
+}
+
+type ExecutionResult struct {
+	Status        Status  `json:"status"`
+	Stdout        string  `json:"stdout"`
+	Stderr        string  `json:"stderr"`
+	CompileOutput string  `json:"compileOutput,omitempty"`
+	CompileTime   float64 `json:"compileTime,omitempty"` // seconds of wall time
+	Time          float64 `json:"time"`
+	WallTime      float64 `json:"wallTime"`
+	Memory        int     `json:"memory"` // peak, in kilobytes
+	ExitCode      int     `json:"exitCode"`
+	Message       string  `json:"message,omitempty"`
+	// Timeline is the usage sampled during the run when requested, at most
+	// timelineSamples points.
+	Timeline []UsageSample `json:"timeline,omitempty"`
+	// Runs is how many runs the result is the median of, in CI mode.
+	Runs int `json:"runs,omitempty"`
+	// Files are the files the program left when ReturnFiles was set, each
+	// at most OutputLimit.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+const (
+	DefaultTimeLimit   = 2.0
+	DefaultMemoryLimit = 256 * 1024
+	DefaultOutputLimit = 64 * 1024
+	// DefaultMaxProcesses is the process limit of languages that set none.
+	DefaultMaxProcesses = 1
+	// MaxWallTimeLimit caps Submission.WallTimeLimit, in seconds, so an
+	// interactive run cannot hold a box for long.
+	MaxWallTimeLimit = 300
+)
+
+type Judge struct {
+	workDir       string
+	envAllowlist  *EnvAllowlist
+	toolchain     *ToolchainPins
+	compileCache  *CompileCache
+	dataCache     *DataCache
+	binaryCache   *BinaryCache
+	sandbox       Sandbox
+	boxes         *BoxPool
+	compileLimits CompileLimits
+	ciMode        CIMode
+	mounts        LanguageMounts
+	// workDirs holds the per-execution directories in use, which the
+	// janitor leaves alone
+	workDirs sync.Map
+	ids      clock.IDGenerator
+	runs     activeRuns
+	// draining and active implement maintenance; see maintenance.go
+	draining atomic.Bool
+	active   atomic.Int64
+	// chaos injects faults in test deployments; see chaos.go
+	chaos ChaosConfig
+	// warm is nil unless boxes are kept initialized; see warm_boxes.go
+	warm *warmBoxes
+	// syscalls restrict what programs may call; see syscall_policy.go
+	syscalls     SyscallPolicies
+	syscallGuard string
+}
+
+func New(workDir string, envAllowlist *EnvAllowlist, toolchain *ToolchainPins, compileCache *CompileCache, dataCache *DataCache, binaryCache *BinaryCache, sandbox Sandbox, boxes *BoxPool, compileLimits CompileLimits) *Judge {
+	return &Judge{
+		workDir:       workDir,
+		envAllowlist:  envAllowlist,
+		toolchain:     toolchain,
+		compileCache:  compileCache,
+		dataCache:     dataCache,
+		binaryCache:   binaryCache,
+		sandbox:       sandbox,
+		boxes:         boxes,
+		compileLimits: compileLimits,
+		ids:           clock.RandomIDs(8),
+	}
+}
+
+// SetIDGenerator replaces the random names of per-submission directories,
+// e.g. with a clock.Sequence in tests. Names must not collide with the
+// caches kept in the work directory.
+func (j *Judge) SetIDGenerator(ids clock.IDGenerator) {
+	j.ids = ids
+}
+
+// SetCIMode pins and repeats runs for reproducible verdicts; see CIMode.
+func (j *Judge) SetCIMode(mode CIMode) {
+	j.ciMode = mode
+}
+
+// SetLanguageMounts adds host directories to languages' boxes; see
+// LanguageMountsFromEnv.
+func (j *Judge) SetLanguageMounts(mounts LanguageMounts) {
+	j.mounts = mounts
+}
+
+// PrefetchResult reports whether one piece of test data is in a worker's
+// data cache.
+type PrefetchResult struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PrefetchData downloads test data into the data cache so the first
+// submissions of a contest do not wait for it. Without wait the downloads
+// run in the background and no results are returned.
+func (j *Judge) PrefetchData(refs []DataRef, wait bool) ([]PrefetchResult, error) {
+	if j.dataCache == nil {
+		return nil, ErrDataCacheMissing
+	}
+	for _, ref := range refs {
+		if !ref.valid() {
+			return nil, ErrInvalidDataRef
+		}
+	}
+	if !wait {
+		j.dataCache.Prefetch(refs)
+		return nil, nil
+	}
+	results := make([]PrefetchResult, 0, len(refs))
+	for _, ref := range refs {
+		result := PrefetchResult{Version: ref.Version, Name: ref.Name}
+		if _, err := j.dataCache.fetch(ref); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Execute compiles the submission if needed and runs it once against its
+// input. A draining worker refuses new executions.
+func (j *Judge) Execute(submission Submission) (ExecutionResult, error) {
+	// Counted before the check so a drained worker never looks idle while
+	// an execution is still getting in
+	j.active.Add(1)
+	defer j.active.Add(-1)
+	if j.draining.Load() {
+		return ExecutionResult{}, ErrDraining
+	}
+	result, err := j.execute(submission)
+	if chaosHit(j.chaos.DropWorker) {
+		return ExecutionResult{}, ErrWorkerDropped
+	}
+	if errors.Is(err, ErrBoxInit) {
+		log.Printf("Error executing submission: %v", err)
+		return ExecutionResult{Status: StatusInternalError, Message: "The sandbox could not be set up."}, nil
+	}
+	return result, err
+}
+
+func (j *Judge) execute(submission Submission) (ExecutionResult, error) {
+	lang, ok := LookupLanguage(submission.Language)
+	if !ok {
+		return ExecutionResult{}, ErrUnsupportedLanguage
+	}
+	lang = j.mounts.apply(lang)
+	if submission.TimeLimit == 0 {
+		submission.TimeLimit = DefaultTimeLimit
+	}
+	if submission.MemoryLimit == 0 {
+		submission.MemoryLimit = DefaultMemoryLimit
+	}
+	if submission.OutputLimit == 0 {
+		submission.OutputLimit = DefaultOutputLimit
+	}
+	if submission.MaxProcesses == 0 {
+		submission.MaxProcesses = lang.MaxProcesses
+	}
+	if submission.MaxProcesses == 0 {
+		submission.MaxProcesses = DefaultMaxProcesses
+	}
+	if submission.TimeLimit < 0 || submission.MemoryLimit < 0 || submission.OutputLimit < 0 || submission.MaxProcesses < 0 ||
+		submission.WallTimeLimit < 0 || submission.WallTimeLimit > MaxWallTimeLimit {
+		return ExecutionResult{}, ErrInvalidLimits
+	}
+	if err := j.envAllowlist.Validate(submission.Env); err != nil {
+		return ExecutionResult{}, err
+	}
+	submission.Env = languageEnv(lang, submission.Env)
+	if err := j.toolchain.Check(lang); err != nil {
+		return ExecutionResult{}, err
+	}
+	lang = j.guardRun(lang)
+
+	for name := range submission.Files {
+		if !validExtraFile(name, lang) {
+			return ExecutionResult{}, fmt.Errorf("%w: %q", ErrInvalidFile, name)
+		}
+	}
+
+	if submission.InputRef != nil {
+		if j.dataCache == nil {
+			return ExecutionResult{}, ErrDataCacheMissing
+		}
+		input, err := j.dataCache.Read(*submission.InputRef)
+		if err != nil {
+			return ExecutionResult{}, err
+		}
+		submission.Input = input
+	}
+
+	dir, err := j.prepareWorkDir(lang, submission.Code, submission.Files)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	defer j.removeWorkDir(dir)
+
+	ctx, done := j.runs.start(submission.RunID)
+	defer done()
+
+	// The compiler runs in the same box as the program, one after the other
+	boxID, release, err := j.boxes.Acquire(ctx)
+	if err != nil {
+		return ExecutionResult{}, ErrRunKilled
+	}
+	defer release()
+	if err := j.initBox(boxID); err != nil {
+		return ExecutionResult{}, err
+	}
+	defer j.releaseBox(boxID)
+
+	compileTime := 0.0
+	if len(lang.CompileCmd) > 0 {
+		compileCmd := j.compileCache.compileCmd(lang)
+		cached := submission.CacheBinary && j.binaryCache != nil
+		key := ""
+		if cached {
+			key = j.binaryCache.key(compileCmd, lang, submission.Code)
+		}
+		if !cached || !j.binaryCache.restore(key, dir) {
+			before := listFiles(dir)
+			start := time.Now()
+			output, ok, err := j.sandbox.Compile(ctx, boxID, lang, dir, compileCmd, j.compileCache.includeDirs(lang), j.compileLimits)
+			compileTime = time.Since(start).Seconds()
+			if ctx.Err() != nil {
+				return ExecutionResult{}, ErrRunKilled
+			}
+			if errors.Is(err, ErrCompileTimeLimitExceeded) {
+				return ExecutionResult{Status: StatusCompileTimeLimitExceeded, CompileOutput: output, CompileTime: compileTime}, nil
+			}
+			if err != nil {
+				return ExecutionResult{}, fmt.Errorf("compile: %w", err)
+			}
+			if !ok {
+				return ExecutionResult{Status: StatusCompileError, CompileOutput: output, CompileTime: compileTime}, nil
+			}
+			if cached {
+				j.binaryCache.save(key, dir, before)
+			}
+		}
+	}
+
+	result, err := j.run(ctx, boxID, lang, dir, submission)
+	if ctx.Err() != nil {
+		return ExecutionResult{}, ErrRunKilled
+	}
+	result.CompileTime = compileTime
+	return result, err
+}
+
+// run runs the program in the box once, or in CI mode as often as
+// configured on the box's CPU, reporting the median run. Interactive runs,
+// whose input cannot be replayed, run once.
+func (j *Judge) run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error) {
+	if submission.Stdin != nil || j.ciMode.Runs <= 1 && len(j.ciMode.CPUs) == 0 {
+		return j.sandbox.Run(ctx, boxID, lang, dir, submission)
+	}
+	results := make([]ExecutionResult, 0, max(j.ciMode.Runs, 1))
+	for len(results) < cap(results) {
+		var result ExecutionResult
+		var err error
+		run := func() { result, err = j.sandbox.Run(ctx, boxID, lang, dir, submission) }
+		if cpu, ok := j.ciMode.cpu(boxID); ok {
+			if pinErr := runPinned(cpu, run); pinErr != nil {
+				return ExecutionResult{}, fmt.Errorf("pin to CPU %d: %w", cpu, pinErr)
+			}
+		} else {
+			run()
+		}
+		if err != nil || ctx.Err() != nil {
+			return result, err
+		}
+		results = append(results, result)
+	}
+	return medianRun(results), nil
+}
+
+func (j *Judge) prepareWorkDir(lang Language, code string, files map[string]string) (string, error) {
+	id, err := j.ids.NewID()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(j.workDir, id)
+	j.workDirs.Store(dir, struct{}{})
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		j.workDirs.Delete(dir)
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, lang.SourceFile), []byte(code), 0o644); err != nil {
+		j.removeWorkDir(dir)
+		return "", err
+	}
+	for name, content := range lang.Files {
+		if _, ok := files[name]; ok {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			j.removeWorkDir(dir)
+			return "", err
+		}
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			j.removeWorkDir(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// validExtraFile rejects names that escape the work directory or collide
+// with files the judge writes itself.
+func validExtraFile(name string, lang Language) bool {
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return false
+	}
+	return name != lang.SourceFile && name != "meta"
+}
+
+// Kill stops every execution with the run ID, killing its compiler or its
+// sandbox box, and reports how many there were.
+func (j *Judge) Kill(runID string) int {
+	return j.runs.kill(runID)
+}
+
+func (j *Judge) ToolchainStatus() ToolchainStatus {
+	return j.toolchain.Status()
+}
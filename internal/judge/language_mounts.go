@@ -0,0 +1,48 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidLanguageMounts = errors.New("invalid language mounts")
+
+// LanguageMounts are host directories bound read-only into the box for
+// one language's compiles and runs, on top of Language.Mounts.
+type LanguageMounts map[string][]string
+
+// LanguageMountsFromEnv reads JUDGE_LANGUAGE_MOUNTS, comma-separated
+// language=dir:dir pairs such as "java=/usr/lib/jvm,rust=/opt/rust", for
+// runtimes installed outside the directories a box shows by default.
+// Directories must be absolute; a language may be named more than once.
+func LanguageMountsFromEnv() (LanguageMounts, error) {
+	value := strings.TrimSpace(os.Getenv("JUDGE_LANGUAGE_MOUNTS"))
+	if value == "" {
+		return nil, nil
+	}
+	mounts := make(LanguageMounts)
+	for _, pair := range strings.Split(value, ",") {
+		lang, dirs, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if _, known := LookupLanguage(lang); !ok || !known {
+			return nil, fmt.Errorf("%w: %q is not language=dir for a known language", ErrInvalidLanguageMounts, pair)
+		}
+		for _, dir := range strings.Split(dirs, ":") {
+			if !filepath.IsAbs(dir) || filepath.Clean(dir) == "/" {
+				return nil, fmt.Errorf("%w: %q is not an absolute directory", ErrInvalidLanguageMounts, dir)
+			}
+			mounts[lang] = append(mounts[lang], filepath.Clean(dir))
+		}
+	}
+	return mounts, nil
+}
+
+// apply returns lang with the configured directories added to its own.
+func (m LanguageMounts) apply(lang Language) Language {
+	if len(m[lang.Name]) > 0 {
+		lang.Mounts = append(append([]string{}, lang.Mounts...), m[lang.Name]...)
+	}
+	return lang
+}
@@ -0,0 +1,14 @@
+package judge
+
+import "online-judge/pkg/languages"
+
+// Language and the registry are defined in pkg/languages, for embedders.
+type Language = languages.Language
+
+func LookupLanguage(name string) (Language, bool) {
+	return languages.Lookup(name)
+}
+
+func LanguageNames() []string {
+	return languages.Names()
+}
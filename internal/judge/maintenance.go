@@ -0,0 +1,190 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDraining   = errors.New("worker is draining")
+	ErrNotDrained = errors.New("worker must be drained and idle first")
+)
+
+// MaintenanceStatus tells whether a worker takes new executions and how
+// many are still running.
+type MaintenanceStatus struct {
+	Draining bool            `json:"draining"`
+	Active   int64           `json:"active"`
+	Runtime  *RuntimeVersion `json:"runtime,omitempty"`
+	// WarmBoxes is set when boxes are kept initialized between executions.
+	WarmBoxes *WarmPoolStatus `json:"warmBoxes,omitempty"`
+}
+
+// Drain stops the worker from taking new executions; running ones finish.
+func (j *Judge) Drain() {
+	j.draining.Store(true)
+}
+
+// Enable lets a drained worker take executions again.
+func (j *Judge) Enable() {
+	j.draining.Store(false)
+}
+
+func (j *Judge) Maintenance() MaintenanceStatus {
+	return MaintenanceStatus{
+		Draining:  j.draining.Load(),
+		Active:    j.active.Load(),
+		Runtime:   j.toolchain.Status().Runtime,
+		WarmBoxes: j.WarmPool(),
+	}
+}
+
+// Shutdown drains the worker and waits for running executions to finish,
+// so they still return a result. Those still running when ctx is done are
+// killed and return ErrRunKilled, which the API treats as a failed
+// attempt. Free boxes are cleaned up afterwards. It returns ctx's error if
+// executions had to be killed.
+func (j *Judge) Shutdown(ctx context.Context) error {
+	j.Drain()
+	err := j.waitIdle(ctx)
+	if err != nil {
+		log.Printf("Killing %d runs still going at shutdown", j.runs.killAll())
+		// killed runs clean up their boxes on the way out
+		grace, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		j.waitIdle(grace)
+	}
+	j.sweep(0)
+	return err
+}
+
+// waitIdle waits until no execution is running or ctx is done.
+func (j *Judge) waitIdle(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for j.active.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// SwapRuntime switches a drained, idle worker to another runtime
+// directory built by cmd/imagebuild after verifying it. An empty dir
+// clears the runtime.
+func (j *Judge) SwapRuntime(dir string) (*RuntimeVersion, error) {
+	if !j.draining.Load() || j.active.Load() > 0 {
+		return nil, ErrNotDrained
+	}
+	if dir == "" {
+		j.toolchain.clearRuntime()
+		return nil, nil
+	}
+	manifest, err := VerifyRuntime(dir)
+	if err != nil {
+		return nil, err
+	}
+	j.toolchain.SetRuntime(dir, manifest)
+	return j.toolchain.Status().Runtime, nil
+}
+
+// SelfTestResult is the outcome of the self-test program of one language.
+type SelfTestResult struct {
+	Language string `json:"language"`
+	OK       bool   `json:"ok"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// selfTests are programs that print twice the number they read, one per
+// language.
+var selfTests = map[string]string{
+	"bash":   "read n\necho $((2 * n))\n",
+	"c":      "#include <stdio.h>\nint main(void) { long n; scanf(\"%ld\", &n); printf(\"%ld\\n\", 2 * n); return 0; }\n",
+	"cpp":    "#include <iostream>\nint main() { long n; std::cin >> n; std::cout << 2 * n << std::endl; }\n",
+	"java":   "import java.util.Scanner;\npublic class Main { public static void main(String[] args) { System.out.println(2 * new Scanner(System.in).nextLong()); } }\n",
+	"python": "print(2 * int(input()))\n",
+	"sql":    ".mode list\nSELECT 2 * n FROM input;\n",
+	"c-wasm": "#include <stdio.h>\nint main(void) { long n; scanf(\"%ld\", &n); printf(\"%ld\\n\", 2 * n); return 0; }\n",
+	"wat":    watSelfTest,
+}
+
+// watSelfTest reads the number through WASI's fd_read into bytes 64 to 95
+// and writes its double, built backwards from byte 127, with fd_write.
+const watSelfTest = `(module
+  (import "wasi_snapshot_preview1" "fd_read" (func $fd_read (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+  (memory (export "memory") 1)
+  (func (export "_start")
+    (local $i i32) (local $end i32) (local $n i64) (local $p i32)
+    (i32.store (i32.const 0) (i32.const 64))
+    (i32.store (i32.const 4) (i32.const 32))
+    (drop (call $fd_read (i32.const 0) (i32.const 0) (i32.const 1) (i32.const 8)))
+    (local.set $i (i32.const 64))
+    (local.set $end (i32.add (i32.const 64) (i32.load (i32.const 8))))
+    (block $done
+      (loop $digits
+        (br_if $done (i32.ge_u (local.get $i) (local.get $end)))
+        (br_if $done (i32.gt_u (i32.sub (i32.load8_u (local.get $i)) (i32.const 48)) (i32.const 9)))
+        (local.set $n (i64.add (i64.mul (local.get $n) (i64.const 10))
+          (i64.extend_i32_u (i32.sub (i32.load8_u (local.get $i)) (i32.const 48)))))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $digits)))
+    (local.set $n (i64.mul (local.get $n) (i64.const 2)))
+    (local.set $p (i32.const 127))
+    (i32.store8 (local.get $p) (i32.const 10))
+    (loop $out
+      (local.set $p (i32.sub (local.get $p) (i32.const 1)))
+      (i32.store8 (local.get $p) (i32.add (i32.const 48) (i32.wrap_i64 (i64.rem_u (local.get $n) (i64.const 10)))))
+      (local.set $n (i64.div_u (local.get $n) (i64.const 10)))
+      (br_if $out (i64.ne (local.get $n) (i64.const 0))))
+    (i32.store (i32.const 16) (local.get $p))
+    (i32.store (i32.const 20) (i32.sub (i32.const 128) (local.get $p)))
+    (drop (call $fd_write (i32.const 1) (i32.const 16) (i32.const 1) (i32.const 24)))))
+`
+
+// selfTestInputs replace the self-test input "21" for languages that
+// cannot read it as is.
+var selfTestInputs = map[string]string{
+	"sql": "CREATE TABLE input (n INTEGER); INSERT INTO input VALUES (21);\n",
+}
+
+// SelfTest compiles and runs a small program in every language, even while
+// the worker is draining, and reports which ones work.
+func (j *Judge) SelfTest() []SelfTestResult {
+	names := LanguageNames()
+	results := make([]SelfTestResult, 0, len(names))
+	for _, name := range names {
+		result := SelfTestResult{Language: name}
+		source, ok := selfTests[name]
+		if !ok {
+			result.Detail = "no self-test program"
+			results = append(results, result)
+			continue
+		}
+		input, ok := selfTestInputs[name]
+		if !ok {
+			input = "21\n"
+		}
+		j.active.Add(1)
+		run, err := j.execute(Submission{Language: name, Code: source, Input: input})
+		j.active.Add(-1)
+		switch {
+		case err != nil:
+			result.Detail = err.Error()
+		case run.Status != StatusOK:
+			result.Detail = string(run.Status) + " " + strings.TrimSpace(run.CompileOutput+run.Stderr)
+		case strings.TrimSpace(run.Stdout) != "42":
+			result.Detail = "unexpected output " + strings.TrimSpace(run.Stdout)
+		default:
+			result.OK = true
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,72 @@
+package judge
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+)
+
+const (
+	// sigxfsz is the signal a program gets for writing past its file size
+	// limit, 25 on every architecture the judge runs on.
+	sigxfsz = 25
+	// sigxfszExit is the exit code jails and container runtimes report for
+	// a program killed by it.
+	sigxfszExit = 128 + sigxfsz
+)
+
+// outputBuffer keeps up to limit bytes of a program's output. Writing more
+// marks it exceeded and calls kill, so a runaway program is stopped rather
+// than buffered into the judge's memory. A limit of zero keeps everything.
+type outputBuffer struct {
+	// mu guards buf, which streamed runs read while it is written
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	limit    int
+	kill     func()
+	exceeded bool
+}
+
+func newOutputBuffer(limitKB int, kill func()) *outputBuffer {
+	return &outputBuffer{limit: limitKB * 1024, kill: kill}
+}
+
+// Write always reports success, so the copy from the program's pipe keeps
+// draining it until the kill lands.
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit == 0 {
+		return b.buf.Write(p)
+	}
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:max(room, 0)])
+		if !b.exceeded {
+			b.exceeded = true
+			b.kill()
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *outputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// output is the buffer as an outputSource.
+func (b *outputBuffer) output(offset int64, n int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if offset >= int64(b.buf.Len()) {
+		return nil, nil
+	}
+	data := b.buf.Bytes()[offset:]
+	return append([]byte(nil), data[:min(n, len(data))]...), nil
+}
+
+func outputLimitMessage(limitKB int) string {
+	return "output exceeded the limit of " + strconv.Itoa(limitKB) + " KB"
+}
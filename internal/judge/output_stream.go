@@ -0,0 +1,170 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+	// tailInterval is how often a running program's output is checked for
+	// what it wrote since.
+	tailInterval = 50 * time.Millisecond
+	// chunkBytes caps a chunk, so a burst of output arrives in several.
+	chunkBytes = 16 * 1024
+)
+
+// OutputChunk is output a program wrote while it ran, sent to
+// Submission.Output as it is produced.
+type OutputChunk struct {
+	Stream string `json:"stream"` // stdout or stderr
+	Data   string `json:"data"`
+}
+
+// StreamEvent is a line of the newline-delimited JSON a worker answers
+// streamed executions with: a chunk of output, and last the result or an
+// error.
+type StreamEvent struct {
+	Chunk  *OutputChunk     `json:"chunk,omitempty"`
+	Result *ExecutionResult `json:"result,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// StdinMessage is what a client sends over an interactive run's WebSocket
+// after the submission: input for the program, or EOF to close its stdin.
+type StdinMessage struct {
+	Data string `json:"data,omitempty"`
+	EOF  bool   `json:"eof,omitempty"`
+}
+
+// feedStdin copies stdin to cmd's standard input while it runs, through a
+// pipe Wait closes, so a user who never types does not keep Wait waiting.
+// It must be called before cmd starts.
+func feedStdin(cmd *exec.Cmd, stdin io.Reader) error {
+	pipe, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		io.Copy(pipe, stdin)
+		pipe.Close()
+	}()
+	return nil
+}
+
+// outputSource returns up to n bytes of a program's output from offset on,
+// and nothing at its end so far.
+type outputSource func(offset int64, n int) ([]byte, error)
+
+// fileOutput reads output a program writes to the file at path, up to
+// limitKB as the result holds; zero is no limit. A file the program has
+// not created yet is empty.
+func fileOutput(path string, limitKB int) outputSource {
+	limit := int64(limitKB) * 1024
+	return func(offset int64, n int) ([]byte, error) {
+		if limit > 0 {
+			n = int(min(int64(n), limit-offset))
+		}
+		if n <= 0 {
+			return nil, nil
+		}
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		buf := make([]byte, n)
+		read, err := file.ReadAt(buf, offset)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		return buf[:read], err
+	}
+}
+
+// streamOutput sends the program's stdout and stderr to out in chunks
+// while it runs. The returned function, called once the program exited,
+// sends what is left and waits for it, so every chunk arrives before the
+// result. Without out it does nothing.
+func streamOutput(ctx context.Context, out chan<- OutputChunk, stdout, stderr outputSource) func() {
+	if out == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	tail := func(stream string, source outputSource) {
+		defer wg.Done()
+		tailOutput(ctx, out, stream, source, stop)
+	}
+	wg.Add(2)
+	go tail(streamStdout, stdout)
+	go tail(streamStderr, stderr)
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// tailOutput sends what source holds beyond what it sent every
+// tailInterval until stop is closed, then once more. It gives up when ctx
+// is done, so a reader that went away cannot hold up the run.
+func tailOutput(ctx context.Context, out chan<- OutputChunk, stream string, source outputSource, stop <-chan struct{}) {
+	ticker := time.NewTicker(tailInterval)
+	defer ticker.Stop()
+	var offset int64
+	for {
+		stopped := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			stopped = true
+		case <-ticker.C:
+		}
+		for {
+			data, err := source(offset, chunkBytes)
+			if err != nil {
+				return
+			}
+			if !stopped {
+				// the next read completes a character split across writes
+				data = completeRunes(data)
+			}
+			if len(data) == 0 {
+				break
+			}
+			offset += int64(len(data))
+			select {
+			case out <- OutputChunk{Stream: stream, Data: string(data)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if stopped {
+			return
+		}
+	}
+}
+
+// completeRunes drops an incomplete UTF-8 sequence at the end of data.
+func completeRunes(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
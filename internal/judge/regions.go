@@ -0,0 +1,173 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrInvalidRegions = errors.New("invalid judge regions")
+	// ErrJudgeUnavailable is returned when a worker pool cannot be reached
+	// or has no worker to take the execution.
+	ErrJudgeUnavailable = errors.New("judge unavailable")
+)
+
+// regionCooldown is how long a region that failed is tried only after the
+// others, unless a health check finds it up sooner.
+const regionCooldown = 30 * time.Second
+
+// Region is a pool of judge workers behind one URL, usually a load
+// balancer in one data centre.
+type Region struct {
+	Name string
+	URL  string
+}
+
+type regionPool struct {
+	Region
+	// downSince is when the pool last failed, in Unix nanoseconds, or
+	// zero once it worked again.
+	downSince atomic.Int64
+}
+
+func (p *regionPool) up(now time.Time) bool {
+	since := p.downSince.Load()
+	return since == 0 || now.Sub(time.Unix(0, since)) > regionCooldown
+}
+
+// RegionsFromEnv reads JUDGE_REGIONS, comma-separated name=url pairs in
+// failover order, and JUDGE_REGION, the region this coordinator runs in,
+// which is moved to the front. It returns no regions when JUDGE_REGIONS is
+// empty.
+func RegionsFromEnv() (local string, regions []Region, err error) {
+	value := strings.TrimSpace(os.Getenv("JUDGE_REGIONS"))
+	if value == "" {
+		return "", nil, nil
+	}
+	local = os.Getenv("JUDGE_REGION")
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		name, url, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		name, url = strings.TrimSpace(name), strings.TrimSpace(url)
+		if !ok || name == "" || url == "" {
+			return "", nil, fmt.Errorf("%w: %q is not name=url", ErrInvalidRegions, entry)
+		}
+		if seen[name] {
+			return "", nil, fmt.Errorf("%w: region %s is listed twice", ErrInvalidRegions, name)
+		}
+		seen[name] = true
+		region := Region{Name: name, URL: url}
+		if name == local {
+			regions = append([]Region{region}, regions...)
+		} else {
+			regions = append(regions, region)
+		}
+	}
+	if local != "" && !seen[local] {
+		return "", nil, fmt.Errorf("%w: JUDGE_REGION %s is not in JUDGE_REGIONS", ErrInvalidRegions, local)
+	}
+	return local, regions, nil
+}
+
+// NewRegionalClient returns a client that executes in the first healthy
+// region, the submission's own first, and fails over to the next when a
+// region is unreachable or draining. Calls other than Execute, Kill and
+// Prefetch go to the first region.
+func NewRegionalClient(regions []Region) *Client {
+	c := NewClient(regions[0].URL)
+	for _, region := range regions {
+		c.regions = append(c.regions, &regionPool{Region: region})
+	}
+	return c
+}
+
+// Regions returns the client's region names in failover order.
+func (c *Client) Regions() []string {
+	names := make([]string, len(c.regions))
+	for i, pool := range c.regions {
+		names[i] = pool.Name
+	}
+	return names
+}
+
+// route orders the regions for an execution: regions that are up, the
+// preferred one first, then those that failed recently as a last resort.
+func (c *Client) route(preferred string) []*regionPool {
+	now := time.Now()
+	var up, down []*regionPool
+	for _, pool := range c.regions {
+		switch {
+		case !pool.up(now):
+			down = append(down, pool)
+		case pool.Name == preferred:
+			up = append([]*regionPool{pool}, up...)
+		default:
+			up = append(up, pool)
+		}
+	}
+	return append(up, down...)
+}
+
+// executeRegions runs the execution in the first region that takes it.
+func (c *Client) executeRegions(ctx context.Context, preferred string, body []byte) (ExecutionResult, error) {
+	var err error
+	for _, pool := range c.route(preferred) {
+		var result ExecutionResult
+		result, err = c.executeRetrying(ctx, pool.URL, body)
+		if err == nil {
+			pool.downSince.Store(0)
+			return result, nil
+		}
+		if ctx.Err() != nil || !(errors.Is(err, ErrJudgeUnavailable) || errors.Is(err, ErrDraining)) {
+			return result, err
+		}
+		log.Printf("Judge region %s failed, failing over: %v", pool.Name, err)
+		pool.downSince.Store(time.Now().UnixNano())
+	}
+	return ExecutionResult{}, err
+}
+
+// RunHealthChecks probes every region's /health each interval until ctx
+// is cancelled, so executions skip a region that went down before one of
+// them fails there, and return to it once it is back.
+func (c *Client) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, pool := range c.regions {
+			checkCtx, cancel := context.WithTimeout(ctx, interval/2)
+			err := NewClient(pool.URL).get(checkCtx, "/health", &MaintenanceStatus{})
+			cancel()
+			wasUp := pool.downSince.Load() == 0
+			if err != nil {
+				if wasUp {
+					log.Printf("Judge region %s is down: %v", pool.Name, err)
+				}
+				pool.downSince.Store(time.Now().UnixNano())
+				continue
+			}
+			if !wasUp {
+				log.Printf("Judge region %s is back up", pool.Name)
+			}
+			pool.downSince.Store(0)
+		}
+	}
+}
+
+// unavailableStatus reports whether a worker pool's response means it had
+// no worker for the request, as load balancers answer.
+func unavailableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
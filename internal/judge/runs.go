@@ -0,0 +1,82 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrRunKilled is returned by Execute when the run was killed on request.
+var ErrRunKilled = errors.New("run was killed")
+
+// activeRuns tracks executions by their RunID so they can be killed, e.g.
+// by the API's watchdog when a submission has been running for too long.
+type activeRuns struct {
+	mu   sync.Mutex
+	runs map[string][]*activeRun
+}
+
+type activeRun struct {
+	cancel context.CancelFunc
+}
+
+// start registers a run. The returned context is cancelled when the run is
+// killed; done must be called once the run is over. Runs without an ID
+// can only be killed by killAll.
+func (a *activeRuns) start(id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &activeRun{cancel: cancel}
+
+	a.mu.Lock()
+	if a.runs == nil {
+		a.runs = make(map[string][]*activeRun)
+	}
+	a.runs[id] = append(a.runs[id], run)
+	a.mu.Unlock()
+
+	return ctx, func() {
+		cancel()
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		runs := a.runs[id]
+		for i, other := range runs {
+			if other == run {
+				runs = append(runs[:i], runs[i+1:]...)
+				break
+			}
+		}
+		if len(runs) == 0 {
+			delete(a.runs, id)
+		} else {
+			a.runs[id] = runs
+		}
+	}
+}
+
+// kill cancels every run with the ID and reports how many there were.
+func (a *activeRuns) kill(id string) int {
+	if id == "" {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	runs := a.runs[id]
+	for _, run := range runs {
+		run.cancel()
+	}
+	return len(runs)
+}
+
+// killAll cancels every run and reports how many there were.
+func (a *activeRuns) killAll() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	killed := 0
+	for _, runs := range a.runs {
+		for _, run := range runs {
+			run.cancel()
+		}
+		killed += len(runs)
+	}
+	return killed
+}
@@ -0,0 +1,307 @@
+package judge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRuntimeSpec = errors.New("invalid runtime spec")
+	ErrRuntimeExists      = errors.New("runtime version already built")
+	ErrRuntimeMismatch    = errors.New("runtime directory does not match its manifest")
+)
+
+// RuntimeSpec declares the read-only runtime directories workers judge
+// with: for each language, the files and directories of the build host that
+// its toolchain needs. Building a spec in a pinned base image gives every
+// worker byte-identical toolchains.
+type RuntimeSpec struct {
+	// Version names the build. A version is built once and never changed;
+	// a new toolchain gets a new version.
+	Version   string            `json:"version"`
+	Languages []RuntimeLanguage `json:"languages"`
+}
+
+// RuntimeLanguage lists absolute paths copied into the language's
+// directory under the same path. A symlink is copied together with the
+// file or directory it points to.
+type RuntimeLanguage struct {
+	Name  string   `json:"name"`
+	Paths []string `json:"paths"`
+}
+
+// RuntimeManifest describes a built runtime; it is written next to the
+// language directories as manifest.json.
+type RuntimeManifest struct {
+	Version string `json:"version"`
+	// Digest covers every entry, so two workers run the same toolchains
+	// exactly when their digests match.
+	Digest  string         `json:"digest"`
+	Entries []RuntimeEntry `json:"entries"`
+}
+
+// RuntimeEntry is one file, directory or symlink of a runtime, by its path
+// relative to the version directory.
+type RuntimeEntry struct {
+	Path   string      `json:"path"`
+	Mode   fs.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256,omitempty"`
+	Link   string      `json:"link,omitempty"`
+}
+
+const (
+	runtimeManifestFile = "manifest.json"
+	runtimeDirPerm      = 0o555
+)
+
+// runtimeModTime is given to every built file so copies of a runtime do not
+// differ by when they were made.
+var runtimeModTime = time.Unix(0, 0)
+
+func (s RuntimeSpec) validate() error {
+	if s.Version == "" || s.Version != filepath.Base(s.Version) || strings.HasPrefix(s.Version, ".") {
+		return fmt.Errorf("%w: version %q must be a plain directory name", ErrInvalidRuntimeSpec, s.Version)
+	}
+	seen := make(map[string]bool, len(s.Languages))
+	for _, lang := range s.Languages {
+		if _, ok := LookupLanguage(lang.Name); !ok || seen[lang.Name] {
+			return fmt.Errorf("%w: language %q is unknown or listed twice", ErrInvalidRuntimeSpec, lang.Name)
+		}
+		seen[lang.Name] = true
+		for _, path := range lang.Paths {
+			if !filepath.IsAbs(path) {
+				return fmt.Errorf("%w: path %q is not absolute", ErrInvalidRuntimeSpec, path)
+			}
+		}
+	}
+	return nil
+}
+
+// BuildRuntime copies the spec's paths into outDir/<version>/<language>,
+// makes the result read-only and writes its manifest.
+func BuildRuntime(spec RuntimeSpec, outDir string) (RuntimeManifest, error) {
+	if err := spec.validate(); err != nil {
+		return RuntimeManifest{}, err
+	}
+	root := filepath.Join(outDir, spec.Version)
+	if _, err := os.Lstat(root); err == nil {
+		return RuntimeManifest{}, fmt.Errorf("%w: %s", ErrRuntimeExists, root)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return RuntimeManifest{}, err
+	}
+
+	for _, lang := range spec.Languages {
+		for _, path := range lang.Paths {
+			if err := copyRuntimePath(path, filepath.Join(root, lang.Name)); err != nil {
+				return RuntimeManifest{}, fmt.Errorf("copy %s for %s: %w", path, lang.Name, err)
+			}
+		}
+	}
+
+	entries, err := scanRuntime(root)
+	if err != nil {
+		return RuntimeManifest{}, err
+	}
+	manifest := RuntimeManifest{Version: spec.Version, Digest: runtimeDigest(entries), Entries: entries}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return RuntimeManifest{}, err
+	}
+	if err := os.WriteFile(filepath.Join(root, runtimeManifestFile), data, 0o444); err != nil {
+		return RuntimeManifest{}, err
+	}
+	return manifest, freezeRuntime(root)
+}
+
+// copyRuntimePath copies path, and what it links to, under dest.
+func copyRuntimePath(path, dest string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if err := copyRuntimeEntry(path, dest, info); err != nil {
+			return err
+		}
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+		return copyRuntimePath(target, dest)
+	}
+	if err := os.MkdirAll(filepath.Join(dest, filepath.Dir(path)), 0o755); err != nil {
+		return err
+	}
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return copyRuntimeEntry(p, dest, info)
+	})
+}
+
+func copyRuntimeEntry(path, dest string, info fs.FileInfo) error {
+	target := filepath.Join(dest, path)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		return os.MkdirAll(target, 0o755)
+	case info.Mode()&fs.ModeSymlink != 0:
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if existing, err := os.Readlink(target); err == nil && existing == link {
+			return nil
+		}
+		return os.Symlink(link, target)
+	case info.Mode().IsRegular():
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm()|0o200)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		// Set explicitly, as the umask may differ between build hosts
+		return os.Chmod(target, info.Mode().Perm()|0o200)
+	}
+	// Devices, sockets and pipes have no place in a toolchain
+	return nil
+}
+
+// scanRuntime lists every entry under root except the manifest, in path
+// order.
+func scanRuntime(root string) ([]RuntimeEntry, error) {
+	var entries []RuntimeEntry
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == runtimeManifestFile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		// Write bits are dropped when the runtime is frozen, so they are
+		// left out to give the same entries before and after
+		entry := RuntimeEntry{Path: filepath.ToSlash(rel), Mode: info.Mode() &^ 0o222}
+		switch {
+		case info.IsDir():
+			entry.Mode = fs.ModeDir | runtimeDirPerm
+		case info.Mode()&fs.ModeSymlink != 0:
+			if entry.Link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if entry.SHA256, err = hashFile(path); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, err
+}
+
+func runtimeDigest(entries []RuntimeEntry) string {
+	h := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintf(h, "%s\x00%o\x00%s\x00%s\n", entry.Path, uint32(entry.Mode), entry.SHA256, entry.Link)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// freezeRuntime drops write permission and resets modification times,
+// children before their directories.
+func freezeRuntime(root string) error {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink == 0 {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		info, err := os.Lstat(paths[i])
+		if err != nil {
+			return err
+		}
+		perm := info.Mode().Perm() &^ 0o222
+		if info.IsDir() {
+			perm = runtimeDirPerm
+		}
+		if err := os.Chmod(paths[i], perm); err != nil {
+			return err
+		}
+		if err := os.Chtimes(paths[i], runtimeModTime, runtimeModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyRuntime checks a built runtime directory, such as
+// /opt/judge-runtime/2026.10-1, against its manifest and returns the
+// manifest if every entry matches.
+func VerifyRuntime(dir string) (RuntimeManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, runtimeManifestFile))
+	if err != nil {
+		return RuntimeManifest{}, err
+	}
+	var manifest RuntimeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return RuntimeManifest{}, fmt.Errorf("%w: %v", ErrRuntimeMismatch, err)
+	}
+	if runtimeDigest(manifest.Entries) != manifest.Digest {
+		return RuntimeManifest{}, fmt.Errorf("%w: manifest digest is wrong", ErrRuntimeMismatch)
+	}
+
+	entries, err := scanRuntime(dir)
+	if err != nil {
+		return RuntimeManifest{}, err
+	}
+	if digest := runtimeDigest(entries); digest != manifest.Digest {
+		return RuntimeManifest{}, fmt.Errorf("%w: contents have digest %s, manifest says %s", ErrRuntimeMismatch, digest, manifest.Digest)
+	}
+	return manifest, nil
+}
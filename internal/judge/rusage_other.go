@@ -0,0 +1,10 @@
+//go:build !unix
+
+package judge
+
+import "os"
+
+// peakRSS is unknown where the system does not report resource usage.
+func peakRSS(state *os.ProcessState) int {
+	return 0
+}
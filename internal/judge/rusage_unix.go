@@ -0,0 +1,22 @@
+//go:build unix
+
+package judge
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakRSS is the process's memory high-water mark in kilobytes.
+func peakRSS(state *os.ProcessState) int {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// macOS reports bytes, other systems kilobytes
+	if runtime.GOOS == "darwin" {
+		return int(usage.Maxrss / 1024)
+	}
+	return int(usage.Maxrss)
+}
@@ -0,0 +1,146 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+)
+
+var (
+	ErrUnknownSandbox    = errors.New("unknown sandbox backend")
+	ErrBoxNotInitialized = errors.New("sandbox box is not initialized")
+	ErrBoxInUse          = errors.New("sandbox box is in use by another backend")
+	// ErrInteractiveUnsupported is returned for interactive runs by
+	// backends that only take the input as a whole.
+	ErrInteractiveUnsupported = errors.New("sandbox backend does not support interactive runs")
+)
+
+// sandboxProcesses caps processes and threads where backends need a limit
+// and none is set, as for compilers: drivers fork and the JVM alone starts
+// a few dozen threads.
+const sandboxProcesses = 64
+
+// Sandbox is a backend that compiles and runs untrusted programs. An
+// execution leases a box ID from the BoxPool, initializes the box, compiles
+// and runs in it, and cleans it up; backends may be called for different
+// boxes concurrently.
+type Sandbox interface {
+	Name() string
+	// Init prepares an empty box, discarding whatever an earlier crash left
+	// in it.
+	Init(boxID string) error
+	// Compile copies the files of dir into the box, runs compileCmd, which
+	// builds lang, there within limits and copies what the compiler
+	// produced back into dir.
+	// ok reports whether compilation succeeded; output holds the compiler's
+	// messages either way. err is only for failures of the sandbox itself,
+	// or ErrCompileTimeLimitExceeded when the compiler was stopped.
+	Compile(ctx context.Context, boxID string, lang Language, dir string, compileCmd []string, includeDirs []string, limits CompileLimits) (output string, ok bool, err error)
+	// Run copies the files of dir into the box and runs lang's RunCmd on
+	// the submission's input within its limits.
+	Run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error)
+	// Cleanup removes the box and everything still running in it.
+	Cleanup(boxID string) error
+}
+
+// SandboxFromEnv picks the backend named by JUDGE_SANDBOX, and by
+// JUDGE_SANDBOX_LANGUAGES, comma-separated language=backend pairs, for the
+// languages that need another one. Backends are "isolate", the default,
+// "nsjail" where isolate's setuid helper is not acceptable, "docker" where
+// isolate cannot be installed, "gvisor" for languages that need network or
+// many system calls, "wasm" for languages that build WebAssembly modules,
+// run under wasmtime in the backend named by JUDGE_WASM_SANDBOX, or
+// "process" for machines without isolate such as CI and development
+// laptops, and the default on macOS and Windows, where isolate does not
+// run. The process backend does not contain programs and must never judge
+// untrusted code.
+func SandboxFromEnv(workDir string) (Sandbox, error) {
+	backends := make(map[string]Sandbox)
+	backend := func(name string) (Sandbox, error) {
+		if sandbox, ok := backends[name]; ok {
+			return sandbox, nil
+		}
+		sandbox, err := NewSandbox(name, workDir)
+		if err != nil {
+			return nil, err
+		}
+		backends[name] = sandbox
+		return sandbox, nil
+	}
+
+	fallback, err := backend(os.Getenv("JUDGE_SANDBOX"))
+	if err != nil {
+		return nil, err
+	}
+	value := strings.TrimSpace(os.Getenv("JUDGE_SANDBOX_LANGUAGES"))
+	if value == "" {
+		return fallback, nil
+	}
+	byLanguage := make(map[string]Sandbox)
+	for _, pair := range strings.Split(value, ",") {
+		lang, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if _, known := LookupLanguage(lang); !ok || !known {
+			return nil, fmt.Errorf("%w: %q is not language=backend for a known language", ErrUnknownSandbox, pair)
+		}
+		sandbox, err := backend(name)
+		if err != nil {
+			return nil, err
+		}
+		byLanguage[lang] = sandbox
+	}
+	return NewLanguageSandbox(fallback, byLanguage), nil
+}
+
+// NewSandbox returns the backend named as in JUDGE_SANDBOX, with its
+// settings read from the environment.
+func NewSandbox(name, workDir string) (Sandbox, error) {
+	if name == "" && runtime.GOOS != "linux" {
+		name = "process"
+	}
+	switch name {
+	case "", "isolate":
+		quota, err := BoxQuotaFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		retries, err := InitRetryPolicyFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return NewIsolateSandbox(workDir, IsolateCgroupsFromEnv(), quota, retries), nil
+	case "nsjail":
+		return NewNsjailSandbox(workDir), nil
+	case "docker", "gvisor":
+		images, err := DockerImagesFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if name == "gvisor" {
+			return NewGVisorSandbox(workDir, images), nil
+		}
+		return NewDockerSandbox(workDir, images), nil
+	case "wasm":
+		config, err := WasmConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		innerName := os.Getenv("JUDGE_WASM_SANDBOX")
+		if innerName == "wasm" {
+			return nil, fmt.Errorf("%w: JUDGE_WASM_SANDBOX cannot be wasm", ErrUnknownSandbox)
+		}
+		inner, err := NewSandbox(innerName, workDir)
+		if err != nil {
+			return nil, err
+		}
+		return NewWasmSandbox(inner, config), nil
+	case "process":
+		log.Printf("WARNING: the process sandbox does not contain programs; it is for development only and must never judge untrusted code")
+		return NewProcessSandbox(workDir), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSandbox, name)
+	}
+}
@@ -0,0 +1,302 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDockerImages = errors.New("docker images must look like c=gcc:12,python=python:3.11")
+
+// defaultDockerImages provide each language's toolchain. Any image works as
+// long as its PATH holds the commands in languages.go.
+var defaultDockerImages = map[string]string{
+	"c":      "gcc:12",
+	"cpp":    "gcc:12",
+	"java":   "eclipse-temurin:17-jdk",
+	"python": "python:3.11-slim",
+}
+
+// DockerImagesFromEnv reads JUDGE_DOCKER_IMAGES, comma-separated
+// language=image pairs overriding the default images.
+func DockerImagesFromEnv() (map[string]string, error) {
+	images := make(map[string]string, len(defaultDockerImages))
+	for lang, image := range defaultDockerImages {
+		images[lang] = image
+	}
+	value := strings.TrimSpace(os.Getenv("JUDGE_DOCKER_IMAGES"))
+	if value == "" {
+		return images, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		lang, image, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || image == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidDockerImages, pair)
+		}
+		if _, known := LookupLanguage(lang); !known {
+			return nil, fmt.Errorf("%w: unknown language %q", ErrInvalidDockerImages, lang)
+		}
+		images[lang] = image
+	}
+	return images, nil
+}
+
+// DockerSandbox runs every compilation and run in a fresh container of the
+// language's image, with the box directory mounted at /box. The container
+// has no network unless configured, a read-only root, no capabilities and one CPU, and its
+// control group enforces the memory and process limits. Docker reports
+// neither CPU time nor peak memory: runs are stopped at the wall time limit
+// and report wall time, which includes the container's start, as their
+// time.
+type DockerSandbox struct {
+	name   string
+	dir    string
+	images map[string]string
+	// runtime is the OCI runtime containers run with, the daemon's default
+	// when empty; network is the container network, "none" for most.
+	runtime string
+	network string
+}
+
+// NewDockerSandbox keeps its boxes in workDir/boxes, a name the random
+// per-submission directories cannot take.
+func NewDockerSandbox(workDir string, images map[string]string) *DockerSandbox {
+	return &DockerSandbox{name: "docker", dir: filepath.Join(workDir, "boxes"), images: images, network: "none"}
+}
+
+// NewGVisorSandbox runs the containers under gVisor's runsc runtime, whose
+// user-space kernel serves the programs' system calls. That keeps the
+// host kernel out of reach of languages that need many system calls, and
+// makes it acceptable to give containers network, which the docker network
+// named by JUDGE_GVISOR_NETWORK does; the default is none.
+func NewGVisorSandbox(workDir string, images map[string]string) *DockerSandbox {
+	network := os.Getenv("JUDGE_GVISOR_NETWORK")
+	if network == "" {
+		network = "none"
+	}
+	return &DockerSandbox{name: "gvisor", dir: filepath.Join(workDir, "boxes"), images: images, runtime: "runsc", network: network}
+}
+
+func (s *DockerSandbox) Name() string {
+	return s.name
+}
+
+func (s *DockerSandbox) boxDir(boxID string) string {
+	return filepath.Join(s.dir, boxID)
+}
+
+func containerName(boxID string) string {
+	return "judge-box-" + boxID
+}
+
+func (s *DockerSandbox) Init(boxID string) error {
+	// a previous crash may have left the container behind
+	exec.Command("docker", "rm", "--force", containerName(boxID)).Run()
+
+	if err := os.RemoveAll(s.boxDir(boxID)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.boxDir(boxID), 0o755); err != nil {
+		return err
+	}
+	// the container runs as nobody and writes its build into the box
+	return os.Chmod(s.boxDir(boxID), 0o777)
+}
+
+func (s *DockerSandbox) Cleanup(boxID string) error {
+	exec.Command("docker", "rm", "--force", containerName(boxID)).Run()
+	return os.RemoveAll(s.boxDir(boxID))
+}
+
+// dockerRun is one finished container.
+type dockerRun struct {
+	stdout, stderr string
+	wallTime       float64
+	exitCode       int
+	oomKilled      bool
+	timedOut       bool
+	outputExceeded bool
+}
+
+// run starts cmd in a container of image for box boxID and waits for it,
+// killing it after wallTime or once stdout or stderr pass outputLimit
+// kilobytes, zero for no limit.
+func (s *DockerSandbox) run(ctx context.Context, boxID, image string, cmd []string, flags []string, stdin string, wallTime float64, outputLimit int) (dockerRun, error) {
+	name := containerName(boxID)
+	args := []string{
+		"run", "--name=" + name, "--interactive",
+		"--network=" + s.network, "--read-only", "--tmpfs=/tmp",
+		"--cap-drop=ALL", "--security-opt=no-new-privileges",
+		"--user=65534:65534", "--cpus=1",
+		"--volume=" + s.boxDir(boxID) + ":/box", "--workdir=/box",
+	}
+	if s.runtime != "" {
+		args = append(args, "--runtime="+s.runtime)
+	}
+	args = append(args, flags...)
+	args = append(args, image)
+	args = append(args, containerCmd(cmd)...)
+	// the container outlives a killed client, so it is removed in any case
+	defer exec.Command("docker", "rm", "--force", name).Run()
+
+	runCtx, cancel := context.WithTimeout(ctx, secondsDuration(wallTime))
+	defer cancel()
+	stdout := newOutputBuffer(outputLimit, cancel)
+	stderr := newOutputBuffer(outputLimit, cancel)
+	client := exec.CommandContext(runCtx, "docker", args...)
+	client.Stdin = strings.NewReader(stdin)
+	client.Stdout = stdout
+	client.Stderr = stderr
+
+	start := time.Now()
+	err := client.Run()
+	result := dockerRun{
+		stdout:   stdout.String(),
+		stderr:   stderr.String(),
+		wallTime: time.Since(start).Seconds(),
+		timedOut: errors.Is(runCtx.Err(), context.DeadlineExceeded),
+		// the client was killed; the deferred removal stops the container
+		outputExceeded: stdout.exceeded || stderr.exceeded,
+	}
+	if ctx.Err() != nil || result.timedOut || result.outputExceeded {
+		return result, nil
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return dockerRun{}, fmt.Errorf("docker run: %w", err)
+	}
+
+	state, err := exec.Command("docker", "inspect", "--format={{.State.ExitCode}} {{.State.OOMKilled}}", name).Output()
+	if err != nil {
+		// the container never started, e.g. a missing image
+		return dockerRun{}, fmt.Errorf("docker run: %s", strings.TrimSpace(result.stderr))
+	}
+	exitCode, oomKilled, _ := strings.Cut(strings.TrimSpace(string(state)), " ")
+	result.exitCode, _ = strconv.Atoi(exitCode)
+	result.oomKilled = oomKilled == "true"
+	result.outputExceeded = result.exitCode == sigxfszExit
+	return result, nil
+}
+
+// containerCmd runs absolute commands by name, so images need not install
+// toolchains where the host does.
+func containerCmd(cmd []string) []string {
+	if !filepath.IsAbs(cmd[0]) {
+		return cmd
+	}
+	return append([]string{filepath.Base(cmd[0])}, cmd[1:]...)
+}
+
+func (s *DockerSandbox) image(lang Language) (string, error) {
+	image, ok := s.images[lang.Name]
+	if !ok {
+		return "", fmt.Errorf("%w: no docker image for %s", ErrUnsupportedLanguage, lang.Name)
+	}
+	return image, nil
+}
+
+func memoryFlags(memoryLimit int) []string {
+	limit := strconv.Itoa(memoryLimit) + "k"
+	// without swap the memory limit is a hard one
+	return []string{"--memory=" + limit, "--memory-swap=" + limit}
+}
+
+// Compile mounts includeDirs read-only at their host paths.
+func (s *DockerSandbox) Compile(ctx context.Context, boxID string, lang Language, dir string, compileCmd []string, includeDirs []string, limits CompileLimits) (string, bool, error) {
+	image, err := s.image(lang)
+	if err != nil {
+		return "", false, err
+	}
+	if err := copyDir(dir, s.boxDir(boxID)); err != nil {
+		return "", false, fmt.Errorf("copy into box: %w", err)
+	}
+
+	flags := append(memoryFlags(limits.MemoryLimit), "--pids-limit="+strconv.Itoa(sandboxProcesses))
+	for _, includeDir := range includeDirs {
+		flags = append(flags, "--volume="+includeDir+":"+includeDir+":ro")
+	}
+	run, err := s.run(ctx, boxID, image, compileCmd, flags, "", limits.wallTime(), 0)
+	if err != nil {
+		return "", false, err
+	}
+	output := run.stdout + run.stderr
+	switch {
+	case ctx.Err() != nil:
+		return "", false, ctx.Err()
+	case run.timedOut:
+		return limits.timedOut(output)
+	case run.oomKilled:
+		return output + "\ncompilation exceeded the memory limit\n", false, nil
+	case run.exitCode != 0:
+		return output, false, nil
+	}
+
+	if err := copyDir(s.boxDir(boxID), dir); err != nil {
+		return "", false, fmt.Errorf("copy out of box: %w", err)
+	}
+	return output, true, nil
+}
+
+func (s *DockerSandbox) Run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error) {
+	if submission.Stdin != nil {
+		return ExecutionResult{}, ErrInteractiveUnsupported
+	}
+	image, err := s.image(lang)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	if err := copyDir(dir, s.boxDir(boxID)); err != nil {
+		return ExecutionResult{}, fmt.Errorf("copy into box: %w", err)
+	}
+
+	flags := append(memoryFlags(submission.MemoryLimit),
+		"--pids-limit="+strconv.Itoa(submission.MaxProcesses),
+		"--ulimit=fsize="+strconv.Itoa(submission.OutputLimit*1024))
+	for name, value := range submission.Env {
+		flags = append(flags, "--env="+name+"="+value)
+	}
+	run, err := s.run(ctx, boxID, image, lang.RunCmd, flags, submission.Input, submission.wallTime(), submission.OutputLimit)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+
+	result := ExecutionResult{
+		Stdout:   run.stdout,
+		Stderr:   run.stderr,
+		Time:     run.wallTime,
+		WallTime: run.wallTime,
+		ExitCode: run.exitCode,
+	}
+	switch {
+	case run.outputExceeded:
+		result.Status = StatusOutputLimitExceeded
+		result.Message = outputLimitMessage(submission.OutputLimit)
+	case run.timedOut:
+		result.Status = StatusTimeLimitExceeded
+	case run.oomKilled:
+		result.Status = StatusMemoryLimitExceeded
+		result.Memory = submission.MemoryLimit
+	case run.exitCode == sigsysExit:
+		result.Status = StatusSecurityViolation
+		result.Message = securityViolationMessage
+	case run.exitCode != 0:
+		result.Status = StatusRuntimeError
+		result.Message = "exited with code " + strconv.Itoa(run.exitCode)
+	default:
+		result.Status = StatusOK
+	}
+	if submission.ReturnFiles {
+		files, err := boxFiles(s.boxDir(boxID), submission.OutputLimit)
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("read box files: %w", err)
+		}
+		result.Files = files
+	}
+	return result, nil
+}
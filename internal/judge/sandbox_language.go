@@ -0,0 +1,90 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LanguageSandbox sends each language to its own backend, and the rest to
+// a fallback. The language of a box is only known once something is
+// compiled or run in it, so the chosen backend initializes the box then.
+type LanguageSandbox struct {
+	fallback   Sandbox
+	byLanguage map[string]Sandbox
+
+	mu     sync.Mutex
+	active map[string]Sandbox // box ID -> backend that initialized it
+}
+
+func NewLanguageSandbox(fallback Sandbox, byLanguage map[string]Sandbox) *LanguageSandbox {
+	return &LanguageSandbox{fallback: fallback, byLanguage: byLanguage, active: make(map[string]Sandbox)}
+}
+
+// Name lists the backends, e.g. "isolate, python=gvisor".
+func (s *LanguageSandbox) Name() string {
+	names := []string{s.fallback.Name()}
+	for lang, sandbox := range s.byLanguage {
+		names = append(names, lang+"="+sandbox.Name())
+	}
+	sort.Strings(names[1:])
+	return strings.Join(names, ", ")
+}
+
+// Init only forgets the box's previous backend; see box.
+func (s *LanguageSandbox) Init(boxID string) error {
+	return s.Cleanup(boxID)
+}
+
+// box returns the backend for lang with the box initialized in it.
+func (s *LanguageSandbox) box(boxID string, lang Language) (Sandbox, error) {
+	sandbox, ok := s.byLanguage[lang.Name]
+	if !ok {
+		sandbox = s.fallback
+	}
+	s.mu.Lock()
+	active, ok := s.active[boxID]
+	s.mu.Unlock()
+	if ok {
+		if active != sandbox {
+			return nil, fmt.Errorf("%w: box %s, %s", ErrBoxInUse, boxID, active.Name())
+		}
+		return sandbox, nil
+	}
+	if err := sandbox.Init(boxID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.active[boxID] = sandbox
+	s.mu.Unlock()
+	return sandbox, nil
+}
+
+func (s *LanguageSandbox) Compile(ctx context.Context, boxID string, lang Language, dir string, compileCmd []string, includeDirs []string, limits CompileLimits) (string, bool, error) {
+	sandbox, err := s.box(boxID, lang)
+	if err != nil {
+		return "", false, err
+	}
+	return sandbox.Compile(ctx, boxID, lang, dir, compileCmd, includeDirs, limits)
+}
+
+func (s *LanguageSandbox) Run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error) {
+	sandbox, err := s.box(boxID, lang)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	return sandbox.Run(ctx, boxID, lang, dir, submission)
+}
+
+func (s *LanguageSandbox) Cleanup(boxID string) error {
+	s.mu.Lock()
+	active, ok := s.active[boxID]
+	delete(s.active, boxID)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return active.Cleanup(boxID)
+}
@@ -0,0 +1,254 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nsjailMounts are bound read-only into the jail when they exist, the same
+// host directories isolate shows its boxes.
+var nsjailMounts = []string{"/bin", "/lib", "/lib64", "/usr", "/etc/alternatives"}
+
+// NsjailSandbox runs programs with nsjail, which needs no setuid helper:
+// each run gets its own user, PID, mount and network namespaces, the box
+// directory at /box and the toolchain read-only. CPU time, address space
+// and processes are capped with resource limits and the wall time by
+// nsjail. Jailed processes are nsjail's children, so nsjail's resource
+// usage reports their CPU time and peak memory.
+type NsjailSandbox struct {
+	dir   string
+	tools *toolboxes
+}
+
+// NewNsjailSandbox keeps its boxes in workDir/boxes and the toolboxes of
+// languages with Tools in workDir/tools, names the random per-submission
+// directories cannot take.
+func NewNsjailSandbox(workDir string) *NsjailSandbox {
+	return &NsjailSandbox{dir: filepath.Join(workDir, "boxes"), tools: newToolboxes(filepath.Join(workDir, "tools"))}
+}
+
+func (s *NsjailSandbox) Name() string {
+	return "nsjail"
+}
+
+func (s *NsjailSandbox) boxDir(boxID string) string {
+	return filepath.Join(s.dir, boxID)
+}
+
+func (s *NsjailSandbox) Init(boxID string) error {
+	if err := os.RemoveAll(s.boxDir(boxID)); err != nil {
+		return err
+	}
+	return os.MkdirAll(s.boxDir(boxID), 0o755)
+}
+
+func (s *NsjailSandbox) Cleanup(boxID string) error {
+	return os.RemoveAll(s.boxDir(boxID))
+}
+
+// nsjailLimits are what one jailed run may use.
+type nsjailLimits struct {
+	timeLimit float64
+	// wallTime is zero for WallTimeLimit(timeLimit)
+	wallTime    float64
+	memoryLimit int
+	processes   int
+	// network keeps the host's network namespace
+	network bool
+	// outputLimit caps stdout, stderr and written files in kilobytes,
+	// zero for no limit
+	outputLimit int
+	env         map[string]string
+	mounts      []string
+	// replaced are mounted over what the mounts show, destination to source
+	replaced map[string]string
+}
+
+// nsjailRun is one finished jailed run.
+type nsjailRun struct {
+	stdout, stderr string
+	time, wallTime float64
+	memory         int
+	exitCode       int
+	timedOut       bool
+	outputExceeded bool
+}
+
+func (s *NsjailSandbox) run(ctx context.Context, boxID string, cmd []string, stdin string, limits nsjailLimits) (nsjailRun, error) {
+	// nsjail does not search PATH for the program it runs
+	program := cmd[0]
+	if !strings.Contains(program, "/") {
+		path, err := exec.LookPath(program)
+		if err != nil {
+			return nsjailRun{}, fmt.Errorf("find %s: %w", program, err)
+		}
+		program = path
+	}
+
+	wallLimit := WallTimeLimit(limits.timeLimit)
+	if limits.wallTime > 0 {
+		wallLimit = limits.wallTime
+	}
+	args := []string{
+		"--mode=o", "--quiet",
+		"--bindmount=" + s.boxDir(boxID) + ":/box", "--cwd=/box", "--tmpfsmount=/tmp",
+		"--time_limit=" + strconv.Itoa(int(math.Ceil(wallLimit))),
+		// the CPU limit is a whole number of seconds; a run using more
+		// than the real limit is told apart afterwards
+		"--rlimit_cpu=" + strconv.Itoa(int(math.Ceil(limits.timeLimit))+1),
+		"--rlimit_as=" + strconv.Itoa((limits.memoryLimit+1023)/1024),
+		"--rlimit_nproc=" + strconv.Itoa(limits.processes),
+		"--env=PATH=/usr/local/bin:/usr/bin:/bin",
+	}
+	if limits.network {
+		args = append(args, "--disable_clone_newnet")
+	}
+	if limits.outputLimit > 0 {
+		args = append(args, "--rlimit_fsize="+strconv.Itoa((limits.outputLimit+1023)/1024))
+	}
+	for _, mount := range append(append([]string{}, nsjailMounts...), limits.mounts...) {
+		if _, err := os.Stat(mount); err == nil {
+			args = append(args, "--bindmount_ro="+mount)
+		}
+	}
+	for path, source := range limits.replaced {
+		args = append(args, "--bindmount_ro="+source+":"+path)
+	}
+	for name, value := range limits.env {
+		args = append(args, "--env="+name+"="+value)
+	}
+	args = append(args, "--", program)
+	args = append(args, cmd[1:]...)
+
+	// the context is a backstop in case nsjail itself hangs
+	runCtx, cancel := context.WithTimeout(ctx, secondsDuration(wallLimit+1))
+	defer cancel()
+	stdout := newOutputBuffer(limits.outputLimit, cancel)
+	stderr := newOutputBuffer(limits.outputLimit, cancel)
+	jail := exec.CommandContext(runCtx, "nsjail", args...)
+	jail.Stdin = strings.NewReader(stdin)
+	jail.Stdout = stdout
+	jail.Stderr = stderr
+
+	start := time.Now()
+	err := jail.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nsjailRun{}, fmt.Errorf("nsjail: %w", err)
+	}
+	state := jail.ProcessState
+	result := nsjailRun{
+		stdout:   stdout.String(),
+		stderr:   stderr.String(),
+		time:     cpuTime(state),
+		wallTime: time.Since(start).Seconds(),
+		memory:   peakRSS(state),
+		exitCode: state.ExitCode(),
+	}
+	result.outputExceeded = stdout.exceeded || stderr.exceeded || result.exitCode == sigxfszExit
+	result.timedOut = result.wallTime >= wallLimit || result.time > limits.timeLimit
+	return result, nil
+}
+
+// Compile binds includeDirs read-only at their host paths.
+func (s *NsjailSandbox) Compile(ctx context.Context, boxID string, lang Language, dir string, compileCmd []string, includeDirs []string, limits CompileLimits) (string, bool, error) {
+	if err := copyDir(dir, s.boxDir(boxID)); err != nil {
+		return "", false, fmt.Errorf("copy into box: %w", err)
+	}
+	run, err := s.run(ctx, boxID, compileCmd, "", nsjailLimits{
+		timeLimit:   limits.TimeLimit,
+		wallTime:    limits.wallTime(),
+		memoryLimit: limits.MemoryLimit,
+		processes:   sandboxProcesses,
+		mounts:      append(append([]string{}, includeDirs...), lang.Mounts...),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	output := run.stdout + run.stderr
+	switch {
+	case ctx.Err() != nil:
+		return "", false, ctx.Err()
+	case run.timedOut:
+		return limits.timedOut(output)
+	case run.exitCode != 0:
+		return output, false, nil
+	}
+
+	if err := copyDir(s.boxDir(boxID), dir); err != nil {
+		return "", false, fmt.Errorf("copy out of box: %w", err)
+	}
+	return output, true, nil
+}
+
+func (s *NsjailSandbox) Run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error) {
+	if submission.Stdin != nil {
+		return ExecutionResult{}, ErrInteractiveUnsupported
+	}
+	if err := copyDir(dir, s.boxDir(boxID)); err != nil {
+		return ExecutionResult{}, fmt.Errorf("copy into box: %w", err)
+	}
+	var replaced map[string]string
+	if len(lang.Tools) > 0 {
+		var err error
+		if replaced, err = s.tools.mounts(lang); err != nil {
+			return ExecutionResult{}, err
+		}
+	}
+	run, err := s.run(ctx, boxID, lang.RunCmd, submission.Input, nsjailLimits{
+		timeLimit:   submission.TimeLimit,
+		wallTime:    submission.WallTimeLimit,
+		memoryLimit: submission.MemoryLimit,
+		processes:   submission.MaxProcesses,
+		outputLimit: submission.OutputLimit,
+		network:     submission.NetworkEnabled,
+		env:         submission.Env,
+		mounts:      lang.Mounts,
+		replaced:    replaced,
+	})
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+
+	result := ExecutionResult{
+		Stdout:   run.stdout,
+		Stderr:   run.stderr,
+		Time:     run.time,
+		WallTime: run.wallTime,
+		Memory:   run.memory,
+		ExitCode: run.exitCode,
+	}
+	switch {
+	case run.outputExceeded:
+		result.Status = StatusOutputLimitExceeded
+		result.Message = outputLimitMessage(submission.OutputLimit)
+	case run.timedOut:
+		result.Status = StatusTimeLimitExceeded
+	case run.memory >= submission.MemoryLimit:
+		result.Status = StatusMemoryLimitExceeded
+	case run.exitCode == sigsysExit:
+		result.Status = StatusSecurityViolation
+		result.Message = securityViolationMessage
+	case run.exitCode != 0:
+		result.Status = StatusRuntimeError
+		result.Message = "exited with code " + strconv.Itoa(run.exitCode)
+	default:
+		result.Status = StatusOK
+	}
+	if submission.ReturnFiles {
+		files, err := boxFiles(s.boxDir(boxID), submission.OutputLimit)
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("read box files: %w", err)
+		}
+		result.Files = files
+	}
+	return result, nil
+}
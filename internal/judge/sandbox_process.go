@@ -0,0 +1,225 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ProcessSandbox runs programs as plain child processes of the judge, each
+// box being a directory under the work directory. It is INSECURE: it keeps
+// nothing out of the host and exists for CI and development machines
+// without isolate, macOS and Windows included. Programs run in a process
+// group of their own, killed as a whole, with the wall time and the output
+// to stdout and stderr limited; on Unix, rlimits also bound CPU time,
+// memory and file sizes. CPU time and memory are checked against the
+// limits afterwards.
+type ProcessSandbox struct {
+	dir string
+}
+
+// processLimits are the rlimits of a program; zero is unlimited.
+type processLimits struct {
+	cpuSeconds int
+	memoryKB   int
+	fileKB     int
+}
+
+// cpuLimitSeconds is a CPU time rlimit past limit, which is checked
+// exactly afterwards, so programs just over it are judged as such rather
+// than killed by SIGXCPU.
+func cpuLimitSeconds(limit float64) int {
+	return int(math.Ceil(limit)) + 1
+}
+
+// hostProgram finds a language's program where a development machine keeps
+// it elsewhere than the judge image, e.g. python3 in /opt/homebrew/bin.
+func hostProgram(name string) string {
+	if !path.IsAbs(name) {
+		return name
+	}
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+	if found, err := exec.LookPath(path.Base(name)); err == nil {
+		return found
+	}
+	return name
+}
+
+// NewProcessSandbox keeps its boxes in workDir/boxes, a name the random
+// per-submission directories cannot take.
+func NewProcessSandbox(workDir string) *ProcessSandbox {
+	return &ProcessSandbox{dir: filepath.Join(workDir, "boxes")}
+}
+
+func (s *ProcessSandbox) Name() string {
+	return "process"
+}
+
+func (s *ProcessSandbox) boxDir(boxID string) string {
+	return filepath.Join(s.dir, boxID)
+}
+
+func (s *ProcessSandbox) Init(boxID string) error {
+	if err := os.RemoveAll(s.boxDir(boxID)); err != nil {
+		return err
+	}
+	return os.MkdirAll(s.boxDir(boxID), 0o755)
+}
+
+func (s *ProcessSandbox) Cleanup(boxID string) error {
+	return os.RemoveAll(s.boxDir(boxID))
+}
+
+// Compile ignores includeDirs, which the host process sees anyway.
+func (s *ProcessSandbox) Compile(ctx context.Context, boxID string, lang Language, dir string, compileCmd []string, includeDirs []string, limits CompileLimits) (string, bool, error) {
+	boxDir := s.boxDir(boxID)
+	if err := copyDir(dir, boxDir); err != nil {
+		return "", false, fmt.Errorf("copy into box: %w", err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, secondsDuration(limits.wallTime()))
+	defer cancel()
+	var output bytes.Buffer
+	cmd := limitedCommand(runCtx, processLimits{
+		cpuSeconds: cpuLimitSeconds(limits.TimeLimit),
+		memoryKB:   limits.MemoryLimit,
+	}, hostProgram(compileCmd[0]), compileCmd[1:]...)
+	cmd.Dir = boxDir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if cmd.Process != nil {
+		killGroup(cmd)
+	}
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// the compiler exited and left a child holding its output
+		err = nil
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", false, fmt.Errorf("run compiler: %w", err)
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) || cpuTime(cmd.ProcessState) > limits.TimeLimit {
+		return limits.timedOut(output.String())
+	}
+	if err != nil {
+		return output.String(), false, nil
+	}
+
+	if err := copyDir(boxDir, dir); err != nil {
+		return "", false, fmt.Errorf("copy out of box: %w", err)
+	}
+	return output.String(), true, nil
+}
+
+func (s *ProcessSandbox) Run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error) {
+	boxDir := s.boxDir(boxID)
+	if err := copyDir(dir, boxDir); err != nil {
+		return ExecutionResult{}, fmt.Errorf("copy into box: %w", err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, secondsDuration(submission.wallTime()))
+	defer cancel()
+	killCtx, kill := context.WithCancel(runCtx)
+	defer kill()
+	stdout := newOutputBuffer(submission.OutputLimit, kill)
+	stderr := newOutputBuffer(submission.OutputLimit, kill)
+	cmd := limitedCommand(killCtx, processLimits{
+		cpuSeconds: cpuLimitSeconds(submission.TimeLimit),
+		memoryKB:   submission.MemoryLimit,
+		fileKB:     submission.OutputLimit,
+	}, hostProgram(lang.RunCmd[0]), lang.RunCmd[1:]...)
+	cmd.Dir = boxDir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	for name, value := range submission.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	if submission.Stdin != nil {
+		if err := feedStdin(cmd, submission.Stdin); err != nil {
+			return ExecutionResult{}, err
+		}
+	} else {
+		cmd.Stdin = strings.NewReader(submission.Input)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return ExecutionResult{}, fmt.Errorf("run: %w", err)
+	}
+	var sampler *usageSampler
+	if submission.Timeline {
+		sampler = sampleUsage(procUsage(cmd.Process.Pid))
+	}
+	finishStream := streamOutput(ctx, submission.Output, stdout.output, stderr.output)
+	err := cmd.Wait()
+	finishStream()
+	wallTime := time.Since(start).Seconds()
+	killGroup(cmd)
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// the program exited and left a child holding its output
+		err = nil
+	}
+	var timeline []UsageSample
+	if sampler != nil {
+		timeline = sampler.Stop()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return ExecutionResult{}, fmt.Errorf("run: %w", err)
+	}
+
+	state := cmd.ProcessState
+	result := ExecutionResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Time:     cpuTime(state),
+		WallTime: wallTime,
+		Memory:   peakRSS(state),
+		ExitCode: state.ExitCode(),
+		Timeline: timeline,
+	}
+	switch {
+	case stdout.exceeded || stderr.exceeded:
+		result.Status = StatusOutputLimitExceeded
+		result.Message = outputLimitMessage(submission.OutputLimit)
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded) || result.Time > submission.TimeLimit:
+		result.Status = StatusTimeLimitExceeded
+	case result.Memory >= submission.MemoryLimit:
+		result.Status = StatusMemoryLimitExceeded
+	case !state.Success():
+		result.Status = StatusRuntimeError
+		result.Message = state.String()
+	default:
+		result.Status = StatusOK
+	}
+	if submission.ReturnFiles {
+		files, err := boxFiles(boxDir, submission.OutputLimit)
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("read box files: %w", err)
+		}
+		result.Files = files
+	}
+	return result, nil
+}
+
+func cpuTime(state *os.ProcessState) float64 {
+	return (state.UserTime() + state.SystemTime()).Seconds()
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// limitedCommand runs the program through the shell, whose ulimit sets
+// the CPU time, address space and file size limits, in a process group of
+// its own so cancelling it kills whatever it forked. Address space limits
+// are not supported everywhere, e.g. on macOS; memory is then only checked
+// afterwards.
+func limitedCommand(ctx context.Context, limits processLimits, name string, args ...string) *exec.Cmd {
+	script := "ulimit -c 0"
+	if limits.cpuSeconds > 0 {
+		script += fmt.Sprintf(" && ulimit -t %d", limits.cpuSeconds)
+	}
+	if limits.fileKB > 0 {
+		// POSIX counts file sizes in 512-byte blocks
+		script += fmt.Sprintf(" && ulimit -f %d", limits.fileKB*2)
+	}
+	if limits.memoryKB > 0 {
+		script += fmt.Sprintf(" && { ulimit -v %d 2>/dev/null || true; }", limits.memoryKB)
+	}
+	script += ` && exec "$0" "$@"`
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", script, name}, args...)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// children left holding stdout would otherwise keep Wait waiting
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// killGroup kills what the program left running in its process group.
+func killGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package judge
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// limitedCommand runs the program in a process group of its own, so
+// cancelling it kills its whole process tree. Windows has no rlimits: CPU
+// time and memory are only checked afterwards.
+func limitedCommand(ctx context.Context, limits processLimits, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// killGroup does nothing: the process tree is gone once taskkill ran, and
+// children of a program that exited by itself cannot be found.
+func killGroup(cmd *exec.Cmd) {}
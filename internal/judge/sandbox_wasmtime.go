@@ -0,0 +1,141 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidWasmConfig = errors.New("invalid WebAssembly runtime configuration")
+	// ErrNotWasm is returned by the wasm backend for languages that do not
+	// build a WebAssembly module.
+	ErrNotWasm = errors.New("language does not build a WebAssembly module")
+)
+
+const (
+	defaultWasmRuntime = "/usr/local/bin/wasmtime"
+	// defaultWasmFuelPerSecond is roughly the WebAssembly instructions a
+	// judge core executes per second.
+	defaultWasmFuelPerSecond = 500_000_000
+	// wasmRuntimeMemoryKB is what wasmtime needs beyond the module's own
+	// memory, e.g. for the compiled code.
+	wasmRuntimeMemoryKB = 256 * 1024
+	// wasmRuntimeProcesses are wasmtime's own threads; modules cannot
+	// start processes.
+	wasmRuntimeProcesses = 16
+)
+
+// Messages wasmtime prints when a module hits the fuel or memory limit.
+const (
+	wasmOutOfFuel        = "all fuel consumed"
+	wasmMemoryGrowFailed = "forcing trap when growing memory"
+)
+
+type WasmConfig struct {
+	// Runtime is the wasmtime binary, at a path the inner backend's boxes
+	// show.
+	Runtime string
+	// FuelPerSecond converts time limits to fuel, the instructions a
+	// module may execute.
+	FuelPerSecond int64
+}
+
+// WasmConfigFromEnv reads JUDGE_WASM_RUNTIME and
+// JUDGE_WASM_FUEL_PER_SECOND.
+func WasmConfigFromEnv() (WasmConfig, error) {
+	config := WasmConfig{Runtime: defaultWasmRuntime, FuelPerSecond: defaultWasmFuelPerSecond}
+	if value := strings.TrimSpace(os.Getenv("JUDGE_WASM_RUNTIME")); value != "" {
+		config.Runtime = value
+	}
+	if value := strings.TrimSpace(os.Getenv("JUDGE_WASM_FUEL_PER_SECOND")); value != "" {
+		fuel, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || fuel <= 0 {
+			return WasmConfig{}, fmt.Errorf("%w: fuel per second %q", ErrInvalidWasmConfig, value)
+		}
+		config.FuelPerSecond = fuel
+	}
+	return config, nil
+}
+
+// WasmSandbox runs languages that build a WebAssembly module under
+// wasmtime inside another backend's boxes. Time limits become fuel, so a
+// program runs out of time after the same instructions on every worker
+// whatever its load; the inner backend's CPU time limit, doubled, only
+// stops a runtime that hangs. The module's memory is capped by wasmtime,
+// where failing to grow it is a memory limit verdict. Compilers run in the
+// inner backend as for any language.
+type WasmSandbox struct {
+	Sandbox
+	config WasmConfig
+}
+
+func NewWasmSandbox(inner Sandbox, config WasmConfig) *WasmSandbox {
+	return &WasmSandbox{Sandbox: inner, config: config}
+}
+
+// Name is e.g. "wasm(isolate)".
+func (s *WasmSandbox) Name() string {
+	return "wasm(" + s.Sandbox.Name() + ")"
+}
+
+func (s *WasmSandbox) Run(ctx context.Context, boxID string, lang Language, dir string, submission Submission) (ExecutionResult, error) {
+	if lang.Module == "" {
+		return ExecutionResult{}, fmt.Errorf("%w: %s", ErrNotWasm, lang.Name)
+	}
+	// keep what the judge runs the language's command through, such as
+	// the syscall guard
+	var wrapper []string
+	if registered, ok := LookupLanguage(lang.Name); ok && len(lang.RunCmd) > len(registered.RunCmd) {
+		wrapper = lang.RunCmd[:len(lang.RunCmd)-len(registered.RunCmd)]
+	}
+	lang.RunCmd = append(append([]string{}, wrapper...), s.runCmd(lang, submission)...)
+
+	inner := submission
+	inner.TimeLimit = 2*submission.TimeLimit + 1
+	inner.MemoryLimit = submission.MemoryLimit + wasmRuntimeMemoryKB
+	inner.MaxProcesses = max(submission.MaxProcesses, wasmRuntimeProcesses)
+	result, err := s.Sandbox.Run(ctx, boxID, lang, dir, inner)
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case strings.Contains(result.Stderr, wasmOutOfFuel):
+		result.Status = StatusTimeLimitExceeded
+	case strings.Contains(result.Stderr, wasmMemoryGrowFailed):
+		result.Status = StatusMemoryLimitExceeded
+	}
+	return result, nil
+}
+
+// runCmd runs the module with the submission's limits as fuel and a
+// memory cap, its box directory preopened and its environment passed on.
+func (s *WasmSandbox) runCmd(lang Language, submission Submission) []string {
+	fuel := int64(submission.TimeLimit * float64(s.config.FuelPerSecond))
+	memory := strconv.Itoa(submission.MemoryLimit * 1024)
+	cmd := []string{s.config.Runtime, "run",
+		"-W", "fuel=" + strconv.FormatInt(fuel, 10),
+		"-W", "max-memory-size=" + memory,
+		"-W", "trap-on-grow-failure=y",
+		// reserve no more address space than the module may use, so the
+		// inner backend's address space limit holds
+		"-O", "memory-reservation=" + memory,
+		"-O", "memory-guard-size=0",
+		"-C", "cache=n",
+		"-C", "parallel-compilation=n",
+		"--dir", ".",
+	}
+	names := make([]string, 0, len(submission.Env))
+	for name := range submission.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd = append(cmd, "--env", name+"="+submission.Env[name])
+	}
+	return append(cmd, "--", lang.Module)
+}
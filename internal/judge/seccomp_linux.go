@@ -0,0 +1,139 @@
+//go:build linux && (amd64 || arm64)
+
+package judge
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccompSyscalls are the system calls a policy may name, those present on
+// every architecture the judge runs on; archSyscalls adds the others.
+// execve is missing as the guard needs it to start the program.
+var seccompSyscalls = map[string]int{
+	"accept":            unix.SYS_ACCEPT,
+	"accept4":           unix.SYS_ACCEPT4,
+	"add_key":           unix.SYS_ADD_KEY,
+	"bind":              unix.SYS_BIND,
+	"bpf":               unix.SYS_BPF,
+	"chroot":            unix.SYS_CHROOT,
+	"clone":             unix.SYS_CLONE,
+	"clone3":            unix.SYS_CLONE3,
+	"connect":           unix.SYS_CONNECT,
+	"delete_module":     unix.SYS_DELETE_MODULE,
+	"execveat":          unix.SYS_EXECVEAT,
+	"finit_module":      unix.SYS_FINIT_MODULE,
+	"init_module":       unix.SYS_INIT_MODULE,
+	"io_uring_enter":    unix.SYS_IO_URING_ENTER,
+	"io_uring_register": unix.SYS_IO_URING_REGISTER,
+	"io_uring_setup":    unix.SYS_IO_URING_SETUP,
+	"ioctl":             unix.SYS_IOCTL,
+	"kexec_load":        unix.SYS_KEXEC_LOAD,
+	"keyctl":            unix.SYS_KEYCTL,
+	"kill":              unix.SYS_KILL,
+	"listen":            unix.SYS_LISTEN,
+	"memfd_create":      unix.SYS_MEMFD_CREATE,
+	"mount":             unix.SYS_MOUNT,
+	"name_to_handle_at": unix.SYS_NAME_TO_HANDLE_AT,
+	"open_by_handle_at": unix.SYS_OPEN_BY_HANDLE_AT,
+	"perf_event_open":   unix.SYS_PERF_EVENT_OPEN,
+	"personality":       unix.SYS_PERSONALITY,
+	"pivot_root":        unix.SYS_PIVOT_ROOT,
+	"prctl":             unix.SYS_PRCTL,
+	"process_vm_readv":  unix.SYS_PROCESS_VM_READV,
+	"process_vm_writev": unix.SYS_PROCESS_VM_WRITEV,
+	"ptrace":            unix.SYS_PTRACE,
+	"reboot":            unix.SYS_REBOOT,
+	"request_key":       unix.SYS_REQUEST_KEY,
+	"setns":             unix.SYS_SETNS,
+	"socket":            unix.SYS_SOCKET,
+	"socketpair":        unix.SYS_SOCKETPAIR,
+	"swapoff":           unix.SYS_SWAPOFF,
+	"swapon":            unix.SYS_SWAPON,
+	"tgkill":            unix.SYS_TGKILL,
+	"tkill":             unix.SYS_TKILL,
+	"umount2":           unix.SYS_UMOUNT2,
+	"unshare":           unix.SYS_UNSHARE,
+	"userfaultfd":       unix.SYS_USERFAULTFD,
+}
+
+// x32ABIBit marks the x86-64 x32 system call numbers, which would bypass a
+// filter matching the 64-bit ones; they are always refused.
+const x32ABIBit = 0x40000000
+
+func syscallNumber(name string) (int, bool) {
+	if nr, ok := seccompSyscalls[name]; ok {
+		return nr, true
+	}
+	nr, ok := archSyscalls[name]
+	return nr, ok
+}
+
+// seccompFilter compiles the policy to a classic BPF program over struct
+// seccomp_data: programs of another architecture are killed, then each
+// listed call gets its action and everything else is allowed.
+func seccompFilter(policy SyscallPolicy) ([]unix.SockFilter, error) {
+	const (
+		nrOffset   = 0
+		archOffset = 4
+	)
+	stmt := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	kill := stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_KILL_PROCESS)
+
+	filter := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, archOffset),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, auditArch, 1, 0),
+		kill,
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, nrOffset),
+		jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32ABIBit, 0, 1),
+		kill,
+	}
+	rules := []struct {
+		names  []string
+		action uint32
+	}{
+		{policy.Kill, unix.SECCOMP_RET_KILL_PROCESS},
+		{policy.Deny, unix.SECCOMP_RET_ERRNO | uint32(unix.ENOSYS)},
+	}
+	for _, rule := range rules {
+		for _, name := range rule.names {
+			nr, ok := syscallNumber(name)
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown system call %q", ErrInvalidSyscallPolicy, name)
+			}
+			filter = append(filter,
+				jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, 1),
+				stmt(unix.BPF_RET|unix.BPF_K, rule.action),
+			)
+		}
+	}
+	return append(filter, stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW)), nil
+}
+
+// InstallSyscallPolicy restricts the calling process, and the program it
+// executes next, to the policy. Nothing can lift the restriction again.
+func InstallSyscallPolicy(policy SyscallPolicy) error {
+	filter, err := seccompFilter(policy)
+	if err != nil {
+		return err
+	}
+	runtime.LockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	program := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	// TSYNC applies the filter to every thread of the Go runtime
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER, unix.SECCOMP_FILTER_FLAG_TSYNC, uintptr(unsafe.Pointer(&program)))
+	if errno != 0 {
+		return fmt.Errorf("install seccomp filter: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package judge
+
+import "golang.org/x/sys/unix"
+
+const auditArch = unix.AUDIT_ARCH_X86_64
+
+// archSyscalls are system calls that arm64 does not have.
+var archSyscalls = map[string]int{
+	"fork":  unix.SYS_FORK,
+	"vfork": unix.SYS_VFORK,
+}
@@ -0,0 +1,9 @@
+package judge
+
+import "golang.org/x/sys/unix"
+
+const auditArch = unix.AUDIT_ARCH_AARCH64
+
+// archSyscalls is empty: arm64 only has the system calls common to both
+// architectures.
+var archSyscalls = map[string]int{}
@@ -0,0 +1,17 @@
+//go:build !(linux && (amd64 || arm64))
+
+package judge
+
+import "errors"
+
+var errSeccompUnsupported = errors.New("syscall policies need linux on amd64 or arm64")
+
+// syscallNumber knows no system calls, so SyscallPoliciesFromEnv rejects
+// every policy where the judge cannot install them.
+func syscallNumber(name string) (int, bool) {
+	return 0, false
+}
+
+func InstallSyscallPolicy(policy SyscallPolicy) error {
+	return errSeccompUnsupported
+}
@@ -0,0 +1,113 @@
+package judge
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidSyscallPolicy = errors.New("invalid syscall policy")
+
+const (
+	// sigsys is the signal the kernel kills a program with for a system
+	// call its seccomp filter forbids, 31 on every architecture the judge
+	// runs on.
+	sigsys = 31
+	// sigsysExit is the exit code jails and container runtimes report for
+	// a program killed by it.
+	sigsysExit = 128 + sigsys
+	// securityViolationMessage explains StatusSecurityViolation.
+	securityViolationMessage = "the program made a system call its language may not use"
+	defaultSyscallGuard      = "/usr/local/bin/syscall-guard"
+)
+
+// SyscallPolicy restricts the system calls of a language's programs, on
+// top of what the sandbox allows. Kill stops the program, which is judged
+// as StatusSecurityViolation; Deny fails the call with ENOSYS, as for
+// clone3, which the C library then replaces with clone.
+type SyscallPolicy struct {
+	Kill []string
+	Deny []string
+}
+
+// SyscallPolicies maps language names to their policy; "*" applies to
+// every language.
+type SyscallPolicies map[string]SyscallPolicy
+
+// SyscallPoliciesFromEnv reads JUDGE_SYSCALL_POLICIES, comma-separated
+// language=syscall:syscall pairs such as
+// "c=ptrace:process_vm_writev:-clone3,*=bpf". Calls prefixed with - are
+// denied, the others kill the program; a language may be named more than
+// once. It also returns JUDGE_SYSCALL_GUARD, the path of the syscall-guard
+// program that installs the policy before running the program, which must
+// be the same inside every box.
+func SyscallPoliciesFromEnv() (SyscallPolicies, string, error) {
+	guard := os.Getenv("JUDGE_SYSCALL_GUARD")
+	if guard == "" {
+		guard = defaultSyscallGuard
+	}
+	value := strings.TrimSpace(os.Getenv("JUDGE_SYSCALL_POLICIES"))
+	if value == "" {
+		return nil, guard, nil
+	}
+	policies := make(SyscallPolicies)
+	for _, pair := range strings.Split(value, ",") {
+		lang, calls, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if _, known := LookupLanguage(lang); !ok || !known && lang != "*" {
+			return nil, "", fmt.Errorf("%w: %q is not language=syscall for a known language", ErrInvalidSyscallPolicy, pair)
+		}
+		policy := policies[lang]
+		for _, call := range strings.Split(calls, ":") {
+			name, deny := strings.CutPrefix(call, "-")
+			if _, ok := syscallNumber(name); !ok {
+				return nil, "", fmt.Errorf("%w: unknown system call %q", ErrInvalidSyscallPolicy, name)
+			}
+			if deny {
+				policy.Deny = append(policy.Deny, name)
+			} else {
+				policy.Kill = append(policy.Kill, name)
+			}
+		}
+		policies[lang] = policy
+	}
+	return policies, guard, nil
+}
+
+// For returns the policy of lang, the one for "*" included.
+func (p SyscallPolicies) For(lang string) SyscallPolicy {
+	all, own := p["*"], p[lang]
+	policy := SyscallPolicy{
+		Kill: append(append([]string{}, all.Kill...), own.Kill...),
+		Deny: append(append([]string{}, all.Deny...), own.Deny...),
+	}
+	sort.Strings(policy.Kill)
+	sort.Strings(policy.Deny)
+	return policy
+}
+
+// SetSyscallPolicies runs the programs of languages with a policy through
+// guard, which installs it. Compilers are not restricted.
+func (j *Judge) SetSyscallPolicies(policies SyscallPolicies, guard string) {
+	j.syscalls = policies
+	j.syscallGuard = guard
+}
+
+// guardRun returns lang with its RunCmd started by the syscall guard when
+// it has a policy.
+func (j *Judge) guardRun(lang Language) Language {
+	policy := j.syscalls.For(lang.Name)
+	if len(policy.Kill) == 0 && len(policy.Deny) == 0 {
+		return lang
+	}
+	runCmd := []string{j.syscallGuard}
+	if len(policy.Kill) > 0 {
+		runCmd = append(runCmd, "-kill="+strings.Join(policy.Kill, ","))
+	}
+	if len(policy.Deny) > 0 {
+		runCmd = append(runCmd, "-deny="+strings.Join(policy.Deny, ","))
+	}
+	lang.RunCmd = append(append(runCmd, "--"), lang.RunCmd...)
+	return lang
+}
@@ -0,0 +1,140 @@
+package judge
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UsageSample is a point of a run's usage timeline.
+type UsageSample struct {
+	Time   float64 `json:"time"`   // seconds of wall time since the start
+	CPU    float64 `json:"cpu"`    // seconds of CPU time used so far
+	Memory int     `json:"memory"` // kilobytes in use
+}
+
+const (
+	// timelineSamples bounds a timeline; longer runs are sampled less often.
+	timelineSamples = 64
+	// timelineInterval is the first sampling interval, doubled whenever the
+	// timeline fills up.
+	timelineInterval = 10 * time.Millisecond
+)
+
+// usageReader reads the CPU time in seconds and the memory in kilobytes of
+// a running program, or false while there is nothing to read yet.
+type usageReader func() (cpu float64, memory int, ok bool)
+
+// usageSampler samples a program's usage in the background until stopped.
+type usageSampler struct {
+	stop chan struct{}
+	done chan []UsageSample
+}
+
+func sampleUsage(read usageReader) *usageSampler {
+	s := &usageSampler{stop: make(chan struct{}), done: make(chan []UsageSample, 1)}
+	go s.run(read)
+	return s
+}
+
+func (s *usageSampler) run(read usageReader) {
+	start := time.Now()
+	interval := timelineInterval
+	var samples []UsageSample
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stop:
+			s.done <- samples
+			return
+		case <-timer.C:
+		}
+		if cpu, memory, ok := read(); ok {
+			if len(samples) == timelineSamples {
+				// keep every other sample and halve the rate
+				for i := 0; i < timelineSamples/2; i++ {
+					samples[i] = samples[2*i+1]
+				}
+				samples = samples[:timelineSamples/2]
+				interval *= 2
+			}
+			samples = append(samples, UsageSample{Time: time.Since(start).Seconds(), CPU: cpu, Memory: memory})
+		}
+		timer.Reset(interval)
+	}
+}
+
+// Stop ends sampling and returns the timeline, nil if nothing was read.
+func (s *usageSampler) Stop() []UsageSample {
+	close(s.stop)
+	return <-s.done
+}
+
+// cgroupUsage reads a cgroup v2 group's usage.
+func cgroupUsage(dir string) usageReader {
+	return func() (float64, int, bool) {
+		current, err := os.ReadFile(filepath.Join(dir, "memory.current"))
+		if err != nil {
+			return 0, 0, false
+		}
+		memory, err := strconv.Atoi(strings.TrimSpace(string(current)))
+		if err != nil {
+			return 0, 0, false
+		}
+		stat, err := os.ReadFile(filepath.Join(dir, "cpu.stat"))
+		if err != nil {
+			return 0, 0, false
+		}
+		var usec int
+		for _, line := range strings.Split(string(stat), "\n") {
+			if value, ok := strings.CutPrefix(line, "usage_usec "); ok {
+				usec, _ = strconv.Atoi(value)
+			}
+		}
+		return float64(usec) / 1e6, memory / 1024, true
+	}
+}
+
+// clockTicks is the unit of CPU times in /proc, USER_HZ, which Linux fixes
+// at 100 on every architecture the judge runs on.
+const clockTicks = 100
+
+// procUsage reads a single process's usage from /proc, which only Linux
+// has; elsewhere the timeline stays empty.
+func procUsage(pid int) usageReader {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	return func() (float64, int, bool) {
+		stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			return 0, 0, false
+		}
+		// the command name may contain spaces; fields resume after it
+		_, rest, ok := strings.Cut(string(stat), ") ")
+		if !ok {
+			return 0, 0, false
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 13 {
+			return 0, 0, false
+		}
+		utime, _ := strconv.Atoi(fields[11])
+		stime, _ := strconv.Atoi(fields[12])
+		statm, err := os.ReadFile(filepath.Join(dir, "statm"))
+		if err != nil {
+			return 0, 0, false
+		}
+		pages := strings.Fields(string(statm))
+		if len(pages) < 2 {
+			return 0, 0, false
+		}
+		resident, _ := strconv.Atoi(pages[1])
+		if resident == 0 {
+			// exited, waiting to be reaped
+			return 0, 0, false
+		}
+		return float64(utime+stime) / clockTicks, resident * os.Getpagesize() / 1024, true
+	}
+}
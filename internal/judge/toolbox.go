@@ -0,0 +1,93 @@
+package judge
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// toolboxes build, once per language, the directory a language with Tools
+// sees in place of /usr/bin: a copy of each listed command. Copies rather
+// than links, since links would point into the directory they hide.
+// Commands missing on the host are left out.
+type toolboxes struct {
+	dir   string
+	mu    sync.Mutex
+	built map[string]string // language name -> toolbox directory
+}
+
+func newToolboxes(dir string) *toolboxes {
+	return &toolboxes{dir: dir, built: make(map[string]string)}
+}
+
+func (t *toolboxes) toolbox(lang Language) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if dir, ok := t.built[lang.Name]; ok {
+		return dir, nil
+	}
+
+	dir := filepath.Join(t.dir, lang.Name)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	for _, tool := range lang.Tools {
+		path, err := exec.LookPath(tool)
+		if err == nil {
+			path, err = filepath.EvalSymlinks(path)
+		}
+		if err != nil {
+			log.Printf("Leaving %s out of the %s toolbox: %v", tool, lang.Name, err)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("copy %s into toolbox: %w", tool, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, tool), data, 0o755); err != nil {
+			return "", fmt.Errorf("copy %s into toolbox: %w", tool, err)
+		}
+	}
+	t.built[lang.Name] = dir
+	return dir, nil
+}
+
+// empty is a directory with nothing in it, mounted over the places other
+// commands live.
+func (t *toolboxes) empty() (string, error) {
+	dir := filepath.Join(t.dir, "empty")
+	return dir, os.MkdirAll(dir, 0o755)
+}
+
+// hiddenBinDirs are mounted empty for languages with Tools. /bin is only
+// hidden where it is a directory of its own rather than a link to /usr/bin.
+var hiddenBinDirs = []string{"/usr/sbin", "/usr/local/bin", "/usr/local/sbin"}
+
+// mounts maps the directories a language with Tools sees replaced to what
+// replaces them.
+func (t *toolboxes) mounts(lang Language) (map[string]string, error) {
+	toolbox, err := t.toolbox(lang)
+	if err != nil {
+		return nil, err
+	}
+	empty, err := t.empty()
+	if err != nil {
+		return nil, err
+	}
+	mounts := map[string]string{"/usr/bin": toolbox}
+	if info, err := os.Lstat("/bin"); err == nil && info.IsDir() {
+		mounts["/bin"] = toolbox
+	}
+	for _, dir := range hiddenBinDirs {
+		if _, err := os.Stat(dir); err == nil {
+			mounts[dir] = empty
+		}
+	}
+	return mounts, nil
+}
@@ -0,0 +1,238 @@
+package judge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrToolchainModified    = errors.New("toolchain binary changed since startup")
+	ErrToolchainUnavailable = errors.New("toolchain binary not available")
+)
+
+// Pin is the recorded identity of one toolchain binary.
+type Pin struct {
+	Binary  string    `json:"binary"`
+	Path    string    `json:"path"`
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ToolchainPins records checksums of every compiler and interpreter at
+// startup. A language whose binaries later change is refused until the worker
+// is restarted, so a toolchain swapped during a contest cannot silently alter
+// verdicts.
+type ToolchainPins struct {
+	mu       sync.RWMutex
+	pins     map[string]Pin    // by binary name
+	modified map[string]string // binary name -> reason
+	missing  map[string]error
+	runtime  *RuntimeVersion
+}
+
+// RuntimeVersion identifies the verified runtime directory a worker judges
+// with.
+type RuntimeVersion struct {
+	Dir     string `json:"dir"`
+	Version string `json:"version"`
+	Digest  string `json:"digest"`
+}
+
+// PinToolchains hashes the binaries of every registered language.
+func PinToolchains() *ToolchainPins {
+	t := &ToolchainPins{
+		pins:     make(map[string]Pin),
+		modified: make(map[string]string),
+		missing:  make(map[string]error),
+	}
+	for _, name := range LanguageNames() {
+		lang, _ := LookupLanguage(name)
+		for _, binary := range languageToolchain(lang) {
+			if _, done := t.pins[binary]; done {
+				continue
+			}
+			pin, err := pinBinary(binary)
+			if err != nil {
+				log.Printf("Toolchain %s unavailable: %v", binary, err)
+				t.missing[binary] = err
+				continue
+			}
+			t.pins[binary] = pin
+			log.Printf("Pinned %s (%s) sha256=%s", binary, pin.Path, pin.SHA256)
+		}
+	}
+	return t
+}
+
+// Check verifies the binaries of lang cheaply by comparing file metadata.
+// Content changes that preserve size and mtime are caught by Rehash.
+func (t *ToolchainPins) Check(lang Language) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, binary := range languageToolchain(lang) {
+		if err, ok := t.missing[binary]; ok {
+			return fmt.Errorf("%w: %s: %v", ErrToolchainUnavailable, binary, err)
+		}
+		if reason, ok := t.modified[binary]; ok {
+			return fmt.Errorf("%w: %s: %s", ErrToolchainModified, binary, reason)
+		}
+
+		pin := t.pins[binary]
+		info, err := os.Stat(pin.Path)
+		if err != nil {
+			t.markModifiedLocked(binary, err.Error())
+			return fmt.Errorf("%w: %s: %v", ErrToolchainModified, binary, err)
+		}
+		if info.Size() != pin.Size || !info.ModTime().Equal(pin.ModTime) {
+			t.markModifiedLocked(binary, "file metadata changed")
+			return fmt.Errorf("%w: %s: file metadata changed", ErrToolchainModified, binary)
+		}
+	}
+	return nil
+}
+
+// Rehash recomputes the checksum of every pinned binary.
+func (t *ToolchainPins) Rehash() {
+	t.mu.RLock()
+	pins := make([]Pin, 0, len(t.pins))
+	for _, pin := range t.pins {
+		pins = append(pins, pin)
+	}
+	t.mu.RUnlock()
+
+	for _, pin := range pins {
+		sum, err := hashFile(pin.Path)
+		reason := ""
+		switch {
+		case err != nil:
+			reason = err.Error()
+		case sum != pin.SHA256:
+			reason = "checksum is now " + sum
+		}
+		if reason != "" {
+			t.mu.Lock()
+			t.markModifiedLocked(pin.Binary, reason)
+			t.mu.Unlock()
+		}
+	}
+}
+
+// RunVerifier rehashes every interval until ctx is cancelled.
+func (t *ToolchainPins) RunVerifier(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Rehash()
+		}
+	}
+}
+
+// SetRuntime records the runtime directory verified at startup, so workers
+// can be compared by its digest.
+func (t *ToolchainPins) SetRuntime(dir string, manifest RuntimeManifest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.runtime = &RuntimeVersion{Dir: dir, Version: manifest.Version, Digest: manifest.Digest}
+}
+
+func (t *ToolchainPins) clearRuntime() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.runtime = nil
+}
+
+// ToolchainStatus is the report served by the judge's toolchain endpoint.
+type ToolchainStatus struct {
+	Pins     []Pin             `json:"pins"`
+	Modified map[string]string `json:"modified,omitempty"`
+	Missing  []string          `json:"missing,omitempty"`
+	Runtime  *RuntimeVersion   `json:"runtime,omitempty"`
+}
+
+func (t *ToolchainPins) Status() ToolchainStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	status := ToolchainStatus{Modified: make(map[string]string, len(t.modified)), Runtime: t.runtime}
+	for _, pin := range t.pins {
+		status.Pins = append(status.Pins, pin)
+	}
+	sort.Slice(status.Pins, func(i, j int) bool { return status.Pins[i].Binary < status.Pins[j].Binary })
+	for binary, reason := range t.modified {
+		status.Modified[binary] = reason
+	}
+	for binary := range t.missing {
+		status.Missing = append(status.Missing, binary)
+	}
+	sort.Strings(status.Missing)
+	return status
+}
+
+func (t *ToolchainPins) markModifiedLocked(binary, reason string) {
+	if _, already := t.modified[binary]; !already {
+		log.Printf("Toolchain binary %s modified: %s; refusing to judge with it", binary, reason)
+	}
+	t.modified[binary] = reason
+}
+
+// languageToolchain returns the binaries a language depends on.
+func languageToolchain(l Language) []string {
+	var binaries []string
+	if len(l.CompileCmd) > 0 {
+		binaries = append(binaries, l.CompileCmd[0])
+	}
+	if len(l.RunCmd) > 0 && filepath.IsAbs(l.RunCmd[0]) {
+		binaries = append(binaries, l.RunCmd[0])
+	}
+	return binaries
+}
+
+func pinBinary(binary string) (Pin, error) {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return Pin{}, err
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return Pin{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Pin{}, err
+	}
+	sum, err := hashFile(path)
+	if err != nil {
+		return Pin{}, err
+	}
+	return Pin{Binary: binary, Path: path, SHA256: sum, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
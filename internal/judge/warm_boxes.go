@@ -0,0 +1,134 @@
+package judge
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// BoxRecycler is implemented by backends whose boxes can be emptied and
+// used again, which is cheaper than cleaning them up and initializing them
+// for the next execution.
+type BoxRecycler interface {
+	// Recycle removes what an execution left in an initialized box and
+	// keeps it initialized.
+	Recycle(boxID string) error
+}
+
+// warmBoxes are the free boxes left initialized by the execution before.
+type warmBoxes struct {
+	mu     sync.Mutex
+	boxes  map[string]bool
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// WarmPoolStatus reports how often executions found their box ready.
+type WarmPoolStatus struct {
+	Warm    int     `json:"warm"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// WarmBoxesFromEnv reads JUDGE_WARM_BOXES, false to clean up and
+// initialize a box for every execution. It defaults to true.
+func WarmBoxesFromEnv() bool {
+	value := os.Getenv("JUDGE_WARM_BOXES")
+	if value == "" {
+		return true
+	}
+	warm, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid JUDGE_WARM_BOXES %q, keeping boxes warm", value)
+		return true
+	}
+	return warm
+}
+
+// SetWarmBoxes keeps boxes initialized between executions, emptied after
+// each, when the sandbox supports it. It must be called before executions
+// start and after SetChaos.
+func (j *Judge) SetWarmBoxes(enabled bool) {
+	if !enabled {
+		j.warm = nil
+		return
+	}
+	if _, ok := j.sandbox.(BoxRecycler); !ok {
+		log.Printf("Sandbox %s cannot recycle boxes; initializing a box per execution", j.sandbox.Name())
+		return
+	}
+	j.warm = &warmBoxes{boxes: make(map[string]bool)}
+}
+
+// initBox readies a leased box for an execution, initializing it unless it
+// is warm.
+func (j *Judge) initBox(boxID string) error {
+	if j.warm != nil {
+		j.warm.mu.Lock()
+		warm := j.warm.boxes[boxID]
+		delete(j.warm.boxes, boxID)
+		j.warm.mu.Unlock()
+		if warm {
+			j.warm.hits.Add(1)
+			return nil
+		}
+		j.warm.misses.Add(1)
+	}
+	return j.sandbox.Init(boxID)
+}
+
+// releaseBox empties the box for the next execution, or cleans it up when
+// boxes are not kept warm or emptying it failed.
+func (j *Judge) releaseBox(boxID string) {
+	if j.warm != nil {
+		err := j.sandbox.(BoxRecycler).Recycle(boxID)
+		if err == nil {
+			j.warm.mu.Lock()
+			j.warm.boxes[boxID] = true
+			j.warm.mu.Unlock()
+			return
+		}
+		log.Printf("Error recycling box %s, cleaning it up: %v", boxID, err)
+	}
+	j.sandbox.Cleanup(boxID)
+}
+
+// isWarm reports whether a free box is warm.
+func (j *Judge) isWarm(boxID string) bool {
+	if j.warm == nil {
+		return false
+	}
+	j.warm.mu.Lock()
+	defer j.warm.mu.Unlock()
+	return j.warm.boxes[boxID]
+}
+
+// forgetWarm marks a box cleaned up.
+func (j *Judge) forgetWarm(boxID string) {
+	if j.warm == nil {
+		return
+	}
+	j.warm.mu.Lock()
+	delete(j.warm.boxes, boxID)
+	j.warm.mu.Unlock()
+}
+
+// WarmPool returns the pool's hit rate since the worker started, or nil
+// when boxes are not kept warm.
+func (j *Judge) WarmPool() *WarmPoolStatus {
+	if j.warm == nil {
+		return nil
+	}
+	j.warm.mu.Lock()
+	status := &WarmPoolStatus{Warm: len(j.warm.boxes)}
+	j.warm.mu.Unlock()
+	status.Hits = j.warm.hits.Load()
+	status.Misses = j.warm.misses.Load()
+	if total := status.Hits + status.Misses; total > 0 {
+		status.HitRate = float64(status.Hits) / float64(total)
+	}
+	return status
+}
@@ -0,0 +1,320 @@
+// Package listing implements the query convention shared by list endpoints:
+// limit and cursor for paging, sort for ordering and fields for sparse
+// fieldsets. It works on the JSON form of the listed items, so the names
+// clients use are the ones they see in responses.
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidLimit  = fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrUnknownField  = errors.New("unknown field")
+)
+
+const (
+	// DefaultLimit is the page size when the request sets none.
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// SortKey orders by one top-level field.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Query is a parsed list request.
+type Query struct {
+	Limit  int
+	Cursor string
+	Sort   []SortKey
+	// Fields selects the fields of each item; empty means all of them.
+	Fields []string
+}
+
+// ParseQuery reads limit, cursor, sort and fields. sort is a comma
+// separated list of field names, each optionally prefixed with - for
+// descending order; defaultSort is used when it is absent.
+func ParseQuery(values url.Values, defaultSort string) (Query, error) {
+	query := Query{Limit: DefaultLimit, Cursor: values.Get("cursor")}
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return Query{}, ErrInvalidLimit
+		}
+		query.Limit = limit
+	}
+
+	spec := values.Get("sort")
+	if spec == "" {
+		spec = defaultSort
+	}
+	for _, field := range splitList(spec) {
+		key := SortKey{Field: strings.TrimPrefix(field, "-")}
+		key.Desc = key.Field != field
+		query.Sort = append(query.Sort, key)
+	}
+	query.Fields = splitList(values.Get("fields"))
+	return query, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Page is one page of a list. NextCursor is empty on the last page.
+type Page struct {
+	Items      []any
+	NextCursor string
+}
+
+// cursor marks the last item of a page by its sort values and tiebreaker,
+// so the next page starts after it even if items were added or removed.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+type row struct {
+	value  any
+	fields map[string]any
+	// keys are the sort values followed by the tiebreaker.
+	keys []any
+}
+
+// Apply sorts items, skips those up to the query's cursor and returns at
+// most Limit of them. Items equal on every sort key keep their id order,
+// or their order in items when they have no id field.
+func Apply[T any](items []T, query Query) (Page, error) {
+	known := jsonFields(reflect.TypeOf((*T)(nil)).Elem())
+	for _, key := range query.Sort {
+		if !known[key.Field] {
+			return Page{}, fmt.Errorf("%w: %s", ErrUnknownField, key.Field)
+		}
+	}
+	for _, field := range query.Fields {
+		if !known[field] {
+			return Page{}, fmt.Errorf("%w: %s", ErrUnknownField, field)
+		}
+	}
+
+	rows := make([]row, len(items))
+	for i, item := range items {
+		fields, err := toFields(item)
+		if err != nil {
+			return Page{}, err
+		}
+		keys := make([]any, 0, len(query.Sort)+1)
+		for _, key := range query.Sort {
+			keys = append(keys, fields[key.Field])
+		}
+		if known["id"] {
+			keys = append(keys, fields["id"])
+		} else {
+			keys = append(keys, float64(i))
+		}
+		rows[i] = row{value: item, fields: fields, keys: keys}
+	}
+	less := func(a, b []any) bool {
+		for i, value := range a {
+			c := compare(value, b[i])
+			if c == 0 {
+				continue
+			}
+			if i < len(query.Sort) && query.Sort[i].Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i].keys, rows[j].keys) })
+
+	spec := sortSpec(query.Sort)
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor, spec, len(query.Sort)+1)
+		if err != nil {
+			return Page{}, err
+		}
+		start := sort.Search(len(rows), func(i int) bool { return less(after, rows[i].keys) })
+		rows = rows[start:]
+	}
+
+	var page Page
+	if len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+		next, err := encodeCursor(cursor{Sort: spec, Values: rows[len(rows)-1].keys})
+		if err != nil {
+			return Page{}, err
+		}
+		page.NextCursor = next
+	}
+	page.Items = make([]any, len(rows))
+	for i, r := range rows {
+		page.Items[i] = r.value
+		if len(query.Fields) > 0 {
+			selected := make(map[string]any, len(query.Fields))
+			for _, field := range query.Fields {
+				if value, ok := r.fields[field]; ok {
+					selected[field] = value
+				}
+			}
+			page.Items[i] = selected
+		}
+	}
+	return page, nil
+}
+
+func toFields(item any) (map[string]any, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+func sortSpec(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.Field
+		if key.Desc {
+			parts[i] = "-" + key.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(c cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor rejects cursors issued for a different sort order.
+func decodeCursor(value, spec string, keys int) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != spec || len(c.Values) != keys {
+		return nil, ErrInvalidCursor
+	}
+	return c.Values, nil
+}
+
+// compare orders decoded JSON values: null first, then booleans, numbers
+// and strings. Strings holding numbers or RFC 3339 times compare by value,
+// so numeric IDs and timestamps sort naturally.
+func compare(a, b any) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	case float64:
+		return compareFloat(a, b.(float64))
+	case string:
+		b := b.(string)
+		if x, err := strconv.ParseFloat(a, 64); err == nil {
+			if y, err := strconv.ParseFloat(b, 64); err == nil {
+				return compareFloat(x, y)
+			}
+		}
+		if x, err := time.Parse(time.RFC3339Nano, a); err == nil {
+			if y, err := time.Parse(time.RFC3339Nano, b); err == nil {
+				return x.Compare(y)
+			}
+		}
+		return strings.Compare(a, b)
+	}
+	return 0
+}
+
+func rank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// jsonFields returns the top-level JSON field names of t, including those
+// promoted from embedded structs.
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			for embedded := range jsonFields(field.Type) {
+				fields[embedded] = true
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
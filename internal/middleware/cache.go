@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"online-judge/internal/cache"
+	"time"
+)
+
+type cachedResponse struct {
+	contentType string
+	body        []byte
+}
+
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// CacheResponse serves successful GET responses from the cache, keyed by
+// prefix, the locale Localize resolved and request URI, so a response
+// localized for one language is never served for another. Entries still
+// start with prefix, which InvalidateCache drops.
+func CacheResponse(store *cache.Cache, prefix string, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := prefix + CurrentLocale(c) + ":" + c.Request.URL.RequestURI()
+		if value, ok := store.Get(key); ok {
+			resp := value.(cachedResponse)
+			c.Header("X-Cache", "HIT")
+			c.Data(http.StatusOK, resp.contentType, resp.body)
+			c.Abort()
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+		c.Header("X-Cache", "MISS")
+		c.Next()
+
+		if recorder.Status() == http.StatusOK {
+			store.Set(key, cachedResponse{
+				contentType: recorder.Header().Get("Content-Type"),
+				body:        recorder.body.Bytes(),
+			}, ttl)
+		}
+	}
+}
+
+// InvalidateCache drops every entry under the given prefixes once a write
+// handler has succeeded.
+func InvalidateCache(store *cache.Cache, prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() < http.StatusBadRequest {
+			for _, prefix := range prefixes {
+				store.InvalidatePrefix(prefix)
+			}
+		}
+	}
+}
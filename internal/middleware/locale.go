@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"online-judge/internal/i18n"
+	"strings"
+)
+
+const localeKey = "locale"
+
+// errorTranslator rewrites the "error" message of JSON error responses into
+// the request's locale, so handlers keep writing English messages.
+type errorTranslator struct {
+	gin.ResponseWriter
+	locale string
+}
+
+func (w *errorTranslator) Write(b []byte) (int, error) {
+	if w.Status() < http.StatusBadRequest || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return w.ResponseWriter.Write(b)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(b, &body); err != nil {
+		return w.ResponseWriter.Write(b)
+	}
+	message, ok := body["error"].(string)
+	if !ok {
+		return w.ResponseWriter.Write(b)
+	}
+	body["error"] = i18n.Translate(w.locale, message)
+	translated, err := json.Marshal(body)
+	if err != nil {
+		return w.ResponseWriter.Write(b)
+	}
+	if _, err := w.ResponseWriter.Write(translated); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Localize picks the request's locale from Accept-Language, falling back to
+// defaultLocale, and translates error messages written by later handlers.
+func Localize(defaultLocale string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := i18n.Negotiate(c.GetHeader("Accept-Language"), defaultLocale)
+		c.Set(localeKey, locale)
+		c.Header("Content-Language", locale)
+		c.Header("Vary", "Accept-Language")
+		if locale != i18n.English {
+			c.Writer = &errorTranslator{ResponseWriter: c.Writer, locale: locale}
+		}
+		c.Next()
+	}
+}
+
+// CurrentLocale returns the locale stored by Localize, or English when the
+// middleware did not run.
+func CurrentLocale(c *gin.Context) string {
+	if locale, ok := c.Get(localeKey); ok {
+		return locale.(string)
+	}
+	return i18n.English
+}
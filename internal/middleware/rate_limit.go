@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"online-judge/internal/store"
+	"strconv"
+	"time"
+)
+
+// RateLimit allows each client IP limit requests per window, counted in the
+// shared store so the limit holds across replicas. Requests over the limit
+// get 429 with a Retry-After header. A limit of zero or less disables it.
+func RateLimit(st store.Store, prefix string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		start := now.Truncate(window)
+		key := "ratelimit:" + prefix + c.ClientIP() + ":" + strconv.FormatInt(start.Unix(), 10)
+		count, err := st.Incr(key)
+		if err != nil {
+			// Fail open: an unreachable store should not take the API down
+			log.Printf("Error counting request for rate limit: %v", err)
+			c.Next()
+			return
+		}
+		if count == 1 {
+			if err := st.Expire(key, window); err != nil {
+				log.Printf("Error expiring rate limit counter %s: %v", key, err)
+			}
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(max(int64(limit)-count, 0), 10))
+		if count > int64(limit) {
+			retryAfter := start.Add(window).Sub(now)
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
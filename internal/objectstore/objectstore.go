@@ -0,0 +1,200 @@
+// Package objectstore talks to S3-compatible object storage through
+// presigned URLs (AWS Signature Version 4), so clients can move large
+// objects without the data passing through the API server.
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+type Config struct {
+	// Endpoint is the storage URL, e.g. https://s3.eu-west-1.amazonaws.com
+	// or a MinIO server. Objects are addressed path-style.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// ConfigFromEnv reads S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY_ID and
+// S3_SECRET_ACCESS_KEY. The returned bool is false when S3_BUCKET is not set.
+func ConfigFromEnv() (Config, bool) {
+	config := Config{
+		Endpoint:        os.Getenv("S3_ENDPOINT"),
+		Region:          os.Getenv("S3_REGION"),
+		Bucket:          os.Getenv("S3_BUCKET"),
+		AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	return config, config.Bucket != ""
+}
+
+type Client struct {
+	config     Config
+	httpClient *http.Client
+}
+
+func New(config Config) *Client {
+	return &Client{config: config, httpClient: &http.Client{}}
+}
+
+// PresignPut returns a URL that uploads key with a PUT request until ttl
+// has passed.
+func (c *Client) PresignPut(key string, ttl time.Duration) (string, error) {
+	return c.presign(http.MethodPut, key, ttl, time.Now())
+}
+
+// PresignGet returns a URL that downloads key until ttl has passed.
+func (c *Client) PresignGet(key string, ttl time.Duration) (string, error) {
+	return c.presign(http.MethodGet, key, ttl, time.Now())
+}
+
+// Size returns the size of an object in bytes.
+func (c *Client) Size(ctx context.Context, key string) (int64, error) {
+	resp, err := c.do(ctx, http.MethodHead, key)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+// Open streams an object; the caller closes the reader.
+func (c *Client) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, key)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes an object. Deleting a missing object is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, key string) (*http.Response, error) {
+	signed, err := c.presign(method, key, time.Minute, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, signed, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		resp.Body.Close()
+		return nil, fmt.Errorf("object storage %s %s: %s", method, key, resp.Status)
+	}
+	return resp, nil
+}
+
+// presign builds a query-string authenticated URL as described in
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html.
+func (c *Client) presign(method, key string, ttl time.Duration, now time.Time) (string, error) {
+	endpoint, err := url.Parse(c.config.Endpoint)
+	if err != nil {
+		return "", err
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + c.config.Region + "/s3/aws4_request"
+
+	path := strings.TrimSuffix(endpoint.Path, "/") + "/" + uriEncode(c.config.Bucket, false) + "/" + uriEncode(key, true)
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    c.config.AccessKeyID + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       fmt.Sprint(int(ttl.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, uriEncode(name, false)+"="+uriEncode(query[name], false))
+	}
+	canonicalQuery := strings.Join(pairs, "&")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		canonicalQuery,
+		"host:" + endpoint.Host,
+		"",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(hashed[:])}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.config.SecretAccessKey), now.Format("20060102"))
+	for _, part := range []string{c.config.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return endpoint.Scheme + "://" + endpoint.Host + path + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode escapes everything but unreserved characters, as SigV4
+// requires; slashes are kept when encoding an object key.
+func uriEncode(value string, keepSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', keepSlash && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupBackupRoutes(router *gin.RouterGroup, backupService *services.BackupService, responseCache *cache.Cache, authenticator *auth.Authenticator) {
+	backupController := controllers.NewBackupController(backupService)
+
+	// a restore may replace any data, so drop every cached response
+	invalidateAll := middleware.InvalidateCache(responseCache, "")
+
+	backupRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		backupRoutes.GET("/backup", backupController.Export)
+		backupRoutes.POST("/restore", invalidateAll, backupController.Restore)
+	}
+}
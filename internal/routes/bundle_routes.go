@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupBundleRoutes(router *gin.RouterGroup, bundler *services.ContestBundler, authenticator *auth.Authenticator) {
+	bundleController := controllers.NewBundleController(bundler)
+
+	bundleRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		bundleRoutes.GET("", bundleController.Export)
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupContestCloneRoutes(router *gin.RouterGroup, cloner *services.ContestCloner, responseCache *cache.Cache, authenticator *auth.Authenticator) {
+	cloneController := controllers.NewContestCloneController(cloner)
+
+	// the copy shows up in contest lists, and its fresh problems in
+	// problem lists
+	invalidateContests := middleware.InvalidateCache(responseCache, ContestCachePrefix)
+	invalidateProblems := middleware.InvalidateCache(responseCache, ProblemCachePrefix)
+
+	cloneRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		cloneRoutes.POST("", invalidateContests, invalidateProblems, cloneController.Clone)
+	}
+}
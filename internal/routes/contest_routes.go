@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"time"
+)
+
+// ContestCachePrefix namespaces cached contest responses.
+const ContestCachePrefix = "contests:"
+
+func SetupContestRoutes(router *gin.RouterGroup, contestService *services.ContestService, scoreboardService *services.ScoreboardService, responseCache *cache.Cache, authenticator *auth.Authenticator) {
+	contestController := controllers.NewContestController(contestService, scoreboardService)
+
+	cached := middleware.CacheResponse(responseCache, ContestCachePrefix, 5*time.Second)
+	invalidate := middleware.InvalidateCache(responseCache, ContestCachePrefix)
+	requireAuth := middleware.RequireAuth(authenticator)
+	requireAdmin := middleware.RequireRole(auth.RoleAdmin)
+	requireJudge := middleware.RequireRole(auth.RoleJudge)
+
+	contestRoutes := router.Group("")
+	{
+		contestRoutes.POST("", requireAuth, requireAdmin, invalidate, contestController.CreateContest)
+		contestRoutes.GET("", cached, contestController.ListContests)
+		contestRoutes.GET("/:id", cached, contestController.GetContest)
+		contestRoutes.POST("/:id/register", requireAuth, contestController.Register)
+		contestRoutes.POST("/:id/resolve", requireAuth, requireAdmin, invalidate, contestController.Resolve)
+		// the public standings are frozen, and served from the cache
+		contestRoutes.GET("/:id/standings", cached, contestController.Standings)
+		contestRoutes.GET("/:id/standings/live", requireAuth, requireJudge, contestController.LiveStandings)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupContestWebhookRoutes(router *gin.RouterGroup, webhookService *services.ContestWebhookService, authenticator *auth.Authenticator) {
+	webhookController := controllers.NewContestWebhookController(webhookService)
+
+	webhookRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		webhookRoutes.GET("", webhookController.GetWebhook)
+		webhookRoutes.PUT("", webhookController.SetWebhook)
+		webhookRoutes.DELETE("", webhookController.DeleteWebhook)
+	}
+}
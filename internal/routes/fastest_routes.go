@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupFastestRoutes(router *gin.RouterGroup, fastestService *services.FastestSolutionsService, authenticator *auth.Authenticator) {
+	fastestController := controllers.NewFastestController(fastestService)
+
+	requireAuth := middleware.RequireAuth(authenticator)
+
+	fastestRoutes := router.Group("")
+	{
+		fastestRoutes.GET("", fastestController.Leaderboard)
+		fastestRoutes.GET("/sharing", requireAuth, fastestController.GetSharing)
+		fastestRoutes.PUT("/sharing", requireAuth, fastestController.SetSharing)
+	}
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"time"
+)
+
+func SetupGymRoutes(router *gin.RouterGroup, contestService *services.ContestService, scoreboardService *services.ScoreboardService, responseCache *cache.Cache, authenticator *auth.Authenticator) {
+	gymController := controllers.NewGymController(contestService, scoreboardService)
+
+	// the gym only changes when a contest finishes, which invalidates the
+	// contest cache
+	cached := middleware.CacheResponse(responseCache, ContestCachePrefix, 5*time.Second)
+	requireAuth := middleware.RequireAuth(authenticator)
+
+	gymRoutes := router.Group("")
+	{
+		gymRoutes.GET("", cached, gymController.ListGym)
+		gymRoutes.GET("/:id", cached, gymController.GetGymContest)
+		gymRoutes.GET("/:id/standings", gymController.PracticeStandings)
+		gymRoutes.POST("/:id/virtual", requireAuth, gymController.StartVirtual)
+		gymRoutes.GET("/:id/virtual", requireAuth, gymController.GetVirtual)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupIntegrityRoutes(router *gin.RouterGroup, integrityService *services.IntegrityService, authenticator *auth.Authenticator) {
+	integrityController := controllers.NewIntegrityController(integrityService)
+
+	integrityRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleJudge))
+	{
+		integrityRoutes.GET("", integrityController.ListFlags)
+		integrityRoutes.POST("/analyze", integrityController.Analyze)
+		integrityRoutes.POST("/:flagId/review", integrityController.ReviewFlag)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupInvariantRoutes(router *gin.RouterGroup, checker *services.InvariantChecker, authenticator *auth.Authenticator) {
+	invariantController := controllers.NewInvariantController(checker)
+
+	invariantRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		invariantRoutes.GET("/invariants", invariantController.ListViolations)
+	}
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/controllers"
+	"online-judge/internal/judge"
+	"online-judge/internal/middleware"
+)
+
+// SetupJudgeRoutes registers the judge worker API served by cmd/judge.
+// Everything but the health check needs the worker token, which lets the
+// caller run code and manage the worker.
+func SetupJudgeRoutes(router *gin.RouterGroup, j *judge.Judge, token string) {
+	judgeController := controllers.NewJudgeController(j)
+
+	// load balancers probe workers without the token
+	router.GET("/health", judgeController.Health)
+
+	judgeRoutes := router.Group("", middleware.RequireStaticToken(token))
+	{
+		judgeRoutes.POST("/submit", judgeController.Submit)
+		judgeRoutes.POST("/submit/stream", judgeController.StreamSubmit)
+		judgeRoutes.GET("/submit/interactive", judgeController.InteractiveSubmit)
+		judgeRoutes.POST("/prefetch", judgeController.Prefetch)
+		judgeRoutes.POST("/runs/:id/kill", judgeController.KillRun)
+		judgeRoutes.GET("/languages", judgeController.Languages)
+		judgeRoutes.GET("/toolchain", judgeController.Toolchain)
+		judgeRoutes.GET("/maintenance", judgeController.Maintenance)
+		judgeRoutes.POST("/maintenance/drain", judgeController.Drain)
+		judgeRoutes.POST("/maintenance/enable", judgeController.Enable)
+		judgeRoutes.POST("/maintenance/runtime", judgeController.SwapRuntime)
+		judgeRoutes.POST("/maintenance/self-test", judgeController.SelfTest)
+	}
+}
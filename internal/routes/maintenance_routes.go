@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+// SetupMaintenanceRoutes shows everyone whether judging is paused and lets
+// admins pause and resume it.
+func SetupMaintenanceRoutes(router *gin.RouterGroup, maintenanceService *services.MaintenanceService, authenticator *auth.Authenticator) {
+	maintenanceController := controllers.NewMaintenanceController(maintenanceService)
+
+	router.GET("", maintenanceController.GetStatus)
+
+	adminRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		adminRoutes.PUT("", maintenanceController.Pause)
+		adminRoutes.DELETE("", maintenanceController.Resume)
+	}
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupNotificationRoutes(router *gin.RouterGroup, notificationService *services.NotificationService, authenticator *auth.Authenticator) {
+	notificationController := controllers.NewNotificationController(notificationService)
+
+	// the caller's own subscription
+	subscriptionRoutes := router.Group("/subscription", middleware.RequireAuth(authenticator))
+	{
+		subscriptionRoutes.GET("", notificationController.GetSubscription)
+		subscriptionRoutes.PUT("", notificationController.Subscribe)
+		subscriptionRoutes.DELETE("", notificationController.Unsubscribe)
+		subscriptionRoutes.POST("/email/confirm", notificationController.ConfirmEmail)
+		subscriptionRoutes.POST("/email/resend", notificationController.ResendConfirmation)
+	}
+
+	// any user's subscription, for admins
+	adminRoutes := router.Group("/subscriptions", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		adminRoutes.GET("/:userId", notificationController.GetSubscription)
+		adminRoutes.PUT("/:userId", notificationController.Subscribe)
+		adminRoutes.DELETE("/:userId", notificationController.Unsubscribe)
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupPlaygroundRoutes(router *gin.RouterGroup, playgroundService *services.PlaygroundService, authenticator *auth.Authenticator) {
+	playgroundController := controllers.NewPlaygroundController(playgroundService)
+
+	// sessions belong to the user who created them
+	playgroundRoutes := router.Group("/sessions", middleware.RequireAuth(authenticator))
+	{
+		playgroundRoutes.POST("", playgroundController.CreateSession)
+		playgroundRoutes.GET("/:id", playgroundController.GetSession)
+		playgroundRoutes.DELETE("/:id", playgroundController.DeleteSession)
+		playgroundRoutes.PUT("/:id/files/:name", playgroundController.PutFile)
+		playgroundRoutes.POST("/:id/run", playgroundController.Run)
+		playgroundRoutes.GET("/:id/stream", playgroundController.StreamRun)
+	}
+}
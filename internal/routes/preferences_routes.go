@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupPreferencesRoutes(router *gin.RouterGroup, preferencesService *services.PreferencesService, authenticator *auth.Authenticator) {
+	preferencesController := controllers.NewPreferencesController(preferencesService)
+
+	preferencesRoutes := router.Group("", middleware.RequireAuth(authenticator))
+	{
+		preferencesRoutes.GET("", preferencesController.GetPreferences)
+		preferencesRoutes.PUT("", preferencesController.SetPreferences)
+		preferencesRoutes.DELETE("", preferencesController.ResetPreferences)
+	}
+}
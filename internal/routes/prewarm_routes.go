@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupPrewarmRoutes(router *gin.RouterGroup, prewarmer *services.ContestPrewarmer, authenticator *auth.Authenticator) {
+	prewarmController := controllers.NewPrewarmController(prewarmer)
+
+	prewarmRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		prewarmRoutes.POST("", prewarmController.Prewarm)
+	}
+}
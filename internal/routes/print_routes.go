@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupPrintRoutes(router *gin.RouterGroup, printService *services.PrintService, authenticator *auth.Authenticator) {
+	printController := controllers.NewPrintController(printService)
+
+	requireStaff := middleware.RequireRole(auth.RoleJudge)
+
+	printRoutes := router.Group("", middleware.RequireAuth(authenticator))
+	{
+		printRoutes.POST("", printController.SubmitPrint)
+		printRoutes.GET("", requireStaff, printController.ListPrints)
+		printRoutes.POST("/:printId/claim", requireStaff, printController.ClaimPrint)
+		printRoutes.POST("/:printId/done", requireStaff, printController.FinishPrint)
+	}
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+// SetupPrivacyRoutes lets the caller export their data and request the
+// deletion of their account.
+func SetupPrivacyRoutes(router *gin.RouterGroup, privacyService *services.PrivacyService, authenticator *auth.Authenticator) {
+	privacyController := controllers.NewPrivacyController(privacyService)
+
+	privacyRoutes := router.Group("", middleware.RequireAuth(authenticator))
+	{
+		privacyRoutes.GET("/data", privacyController.ExportData)
+		privacyRoutes.POST("/deletion", privacyController.RequestDeletion)
+		privacyRoutes.GET("/deletion", privacyController.GetDeletion)
+		privacyRoutes.DELETE("/deletion", privacyController.CancelDeletion)
+	}
+}
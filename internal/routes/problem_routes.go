@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"time"
+)
+
+// ProblemCachePrefix namespaces cached problem responses.
+const ProblemCachePrefix = "problems:"
+
+// ProblemStatsCachePrefix namespaces cached problem statistics.
+const ProblemStatsCachePrefix = "problem-stats:"
+
+func SetupProblemRoutes(router *gin.RouterGroup, problemService *services.ProblemService, dryRunService *services.DryRunService, optimizationService *services.OptimizationService, statsService *services.ProblemStatsService, responseCache *cache.Cache, authenticator *auth.Authenticator) {
+	problemController := controllers.NewProblemController(problemService, dryRunService, optimizationService, statsService)
+
+	cached := middleware.CacheResponse(responseCache, ProblemCachePrefix, 30*time.Second)
+	invalidate := middleware.InvalidateCache(responseCache, ProblemCachePrefix)
+	// stats change with every judgement, which does not invalidate caches
+	statsCached := middleware.CacheResponse(responseCache, ProblemStatsCachePrefix, 10*time.Second)
+	requireAuth := middleware.RequireAuth(authenticator)
+	requireAdmin := middleware.RequireRole(auth.RoleAdmin)
+
+	problemRoutes := router.Group("")
+	{
+		problemRoutes.POST("", requireAuth, requireAdmin, invalidate, problemController.CreateProblem)
+		problemRoutes.PATCH("", requireAuth, requireAdmin, invalidate, problemController.BulkEditProblems)
+		problemRoutes.PUT("/:id", requireAuth, requireAdmin, invalidate, problemController.UpdateProblem)
+		problemRoutes.POST("/:id/clone", requireAuth, requireAdmin, invalidate, problemController.CloneProblem)
+		problemRoutes.GET("", cached, problemController.ListProblems)
+		problemRoutes.GET("/:id", cached, problemController.GetProblem)
+		problemRoutes.GET("/:id/tests", requireAuth, requireAdmin, problemController.GetTests)
+		problemRoutes.PUT("/:id/tests", requireAuth, requireAdmin, invalidate, problemController.SetTests)
+		problemRoutes.PUT("/:id/tests/:test/metadata", requireAuth, requireAdmin, problemController.SetTestMetadata)
+		problemRoutes.GET("/:id/judge-script", requireAuth, requireAdmin, problemController.GetJudgeScript)
+		problemRoutes.PUT("/:id/judge-script", requireAuth, requireAdmin, problemController.SetJudgeScript)
+		problemRoutes.DELETE("/:id/judge-script", requireAuth, requireAdmin, problemController.DeleteJudgeScript)
+		problemRoutes.GET("/:id/unit-tests", requireAuth, requireAdmin, problemController.GetUnitTests)
+		problemRoutes.PUT("/:id/unit-tests", requireAuth, requireAdmin, problemController.SetUnitTests)
+		problemRoutes.DELETE("/:id/unit-tests", requireAuth, requireAdmin, problemController.DeleteUnitTests)
+		problemRoutes.POST("/:id/dry-run", requireAuth, problemController.DryRun)
+		problemRoutes.POST("/:id/custom-run", requireAuth, problemController.CustomRun)
+		problemRoutes.GET("/:id/leaderboard", problemController.Leaderboard)
+		problemRoutes.GET("/:id/stats", statsCached, problemController.Stats)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupProfileRoutes(router *gin.RouterGroup, achievementService *services.AchievementService, authenticator *auth.Authenticator) {
+	profileController := controllers.NewProfileController(achievementService)
+
+	profileRoutes := router.Group("", middleware.RequireAuth(authenticator))
+	{
+		profileRoutes.GET("/:id/profile", profileController.GetProfile)
+	}
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/cache"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"online-judge/internal/store"
+	"time"
+)
+
+// PublicCachePrefix namespaces cached public API responses. They are never
+// invalidated on writes; clients see changes within the cache TTL.
+const PublicCachePrefix = "public:"
+
+// SetupPublicRoutes serves the read-only public API without authentication.
+// Every request counts towards the client's rate limit, cached or not.
+func SetupPublicRoutes(router *gin.RouterGroup, publicService *services.PublicService, contestService *services.ContestService, st store.Store, responseCache *cache.Cache) {
+	publicController := controllers.NewPublicController(publicService, contestService)
+
+	rateLimit := middleware.RateLimit(st, PublicCachePrefix, publicService.RateLimit(), time.Minute)
+	cached := middleware.CacheResponse(responseCache, PublicCachePrefix, time.Minute)
+
+	publicRoutes := router.Group("", rateLimit, cached)
+	{
+		publicRoutes.GET("/problems", publicController.ListProblems)
+		publicRoutes.GET("/problems/:id", publicController.GetProblem)
+		publicRoutes.GET("/problems/:id/stats", publicController.ProblemStats)
+		publicRoutes.GET("/contests", publicController.ListContests)
+		publicRoutes.GET("/contests/:id/standings", publicController.Standings)
+		publicRoutes.GET("/stats", publicController.Stats)
+	}
+}
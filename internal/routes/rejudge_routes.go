@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupRejudgeRoutes(router *gin.RouterGroup, reconciler *services.RejudgeReconciler, authenticator *auth.Authenticator) {
+	rejudgeController := controllers.NewRejudgeController(reconciler)
+
+	rejudgeRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleJudge))
+	{
+		rejudgeRoutes.POST("", rejudgeController.Rejudge)
+		rejudgeRoutes.GET("/:id", rejudgeController.GetRejudge)
+	}
+}
@@ -0,0 +1,281 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/judge"
+	"online-judge/internal/routes"
+	"online-judge/internal/services"
+	"online-judge/internal/store"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// replica is one API instance: its own services, caches and router over
+// the store shared by every replica.
+type replica struct {
+	router    *gin.Engine
+	scheduler *services.ContestScheduler
+}
+
+func newReplica(instanceID string, st store.Store, authenticator *auth.Authenticator, judgeURL string) *replica {
+	contestService := services.NewContestService(st)
+	playgroundService := services.NewPlaygroundService(services.DefaultPlaygroundConfig(), st, judge.NewClient(judgeURL))
+
+	router := gin.New()
+	api := router.Group("/api")
+	routes.SetupPlaygroundRoutes(api.Group("/playground"), playgroundService, authenticator)
+	routes.SetupContestRoutes(api.Group("/contests"), contestService, nil, cache.New(), authenticator)
+
+	return &replica{
+		router:    router,
+		scheduler: services.NewContestScheduler(contestService, nil, st, instanceID, 20*time.Millisecond),
+	}
+}
+
+func (r *replica) do(t *testing.T, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	switch body := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(body))
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.router.ServeHTTP(w, req)
+	return w
+}
+
+// fakeJudge echoes a run's input to stdout and leaves it in out.txt next
+// to the files it was given.
+func fakeJudge(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var submission judge.Submission
+		if r.URL.Path != "/submit" || json.NewDecoder(r.Body).Decode(&submission) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		files := map[string]string{"out.txt": submission.Input}
+		for name, content := range submission.Files {
+			files[name] = content
+		}
+		json.NewEncoder(w).Encode(judge.ExecutionResult{Status: judge.StatusOK, Stdout: submission.Input, Files: files})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func issue(t *testing.T, authenticator *auth.Authenticator, userID string, role auth.Role) string {
+	t.Helper()
+	token, err := authenticator.Issue(auth.Principal{UserID: userID, Role: role}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newReplicas(t *testing.T) (*replica, *replica, *auth.Authenticator) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	st := store.NewMemoryStore()
+	authenticator := auth.NewAuthenticator([]byte("replica-test-secret"))
+	judgeURL := fakeJudge(t).URL
+	return newReplica("a", st, authenticator, judgeURL), newReplica("b", st, authenticator, judgeURL), authenticator
+}
+
+func TestReplicasSharePlaygroundSessions(t *testing.T) {
+	a, b, authenticator := newReplicas(t)
+	owner := issue(t, authenticator, "alice", auth.RoleUser)
+	other := issue(t, authenticator, "bob", auth.RoleUser)
+
+	w := a.do(t, http.MethodPost, "/api/playground/sessions", owner, map[string]string{"language": "python"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create on a: %d %s", w.Code, w.Body)
+	}
+	var session services.PlaygroundSession
+	if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/playground/sessions/" + session.ID
+
+	if w := b.do(t, http.MethodPut, path+"/files/solve.py", owner, "print(input())"); w.Code >= 300 {
+		t.Fatalf("write on b: %d %s", w.Code, w.Body)
+	}
+	w = a.do(t, http.MethodPost, path+"/run", owner, map[string]string{"entry": "solve.py", "stdin": "42"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("run on a: %d %s", w.Code, w.Body)
+	}
+	var result services.PlaygroundRunResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "42" {
+		t.Errorf("run output = %q, want %q", result.Output, "42")
+	}
+
+	w = b.do(t, http.MethodGet, path, owner, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get on b: %d %s", w.Code, w.Body)
+	}
+	var got struct {
+		Session services.PlaygroundSession `json:"session"`
+		Files   []string                   `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Session.RunsUsed != 1 {
+		t.Errorf("runs used seen by b = %d, want 1", got.Session.RunsUsed)
+	}
+	if strings.Join(got.Files, ",") != "out.txt,solve.py" {
+		t.Errorf("files seen by b = %v, want [out.txt solve.py]", got.Files)
+	}
+
+	if w := b.do(t, http.MethodGet, path, other, nil); w.Code != http.StatusNotFound {
+		t.Errorf("get by another user on b: %d, want 404", w.Code)
+	}
+	if w := b.do(t, http.MethodDelete, path, owner, nil); w.Code >= 300 {
+		t.Fatalf("delete on b: %d %s", w.Code, w.Body)
+	}
+	if w := a.do(t, http.MethodGet, path, owner, nil); w.Code != http.StatusNotFound {
+		t.Errorf("get on a after delete on b: %d, want 404", w.Code)
+	}
+}
+
+func TestReplicasAssignUniqueContestIDs(t *testing.T) {
+	a, b, authenticator := newReplicas(t)
+	admin := issue(t, authenticator, "admin", auth.RoleAdmin)
+	start := time.Now().Add(time.Hour).UTC()
+
+	const perReplica = 20
+	var mu sync.Mutex
+	ids := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < perReplica; i++ {
+		for _, r := range []*replica{a, b} {
+			wg.Add(1)
+			go func(r *replica, i int) {
+				defer wg.Done()
+				w := r.do(t, http.MethodPost, "/api/contests", admin, map[string]any{
+					"title":     fmt.Sprintf("Round %d", i),
+					"startTime": start,
+					"endTime":   start.Add(2 * time.Hour),
+				})
+				if w.Code != http.StatusCreated {
+					t.Errorf("create contest: %d %s", w.Code, w.Body)
+					return
+				}
+				var contest services.Contest
+				if err := json.Unmarshal(w.Body.Bytes(), &contest); err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if ids[contest.ID] {
+					t.Errorf("contest ID %s assigned twice", contest.ID)
+				}
+				ids[contest.ID] = true
+			}(r, i)
+		}
+	}
+	wg.Wait()
+
+	if len(ids) != 2*perReplica {
+		t.Fatalf("got %d distinct contest IDs, want %d", len(ids), 2*perReplica)
+	}
+	for id := range ids {
+		for _, r := range []*replica{a, b} {
+			if w := r.do(t, http.MethodGet, "/api/contests/"+id, "", nil); w.Code != http.StatusOK {
+				t.Errorf("get contest %s: %d", id, w.Code)
+			}
+		}
+	}
+}
+
+func TestReplicasShareTheSchedulerLease(t *testing.T) {
+	a, b, authenticator := newReplicas(t)
+	admin := issue(t, authenticator, "admin", auth.RoleAdmin)
+
+	// createStarted creates a contest that has already started, so the
+	// leader moves it to running on its next tick.
+	createStarted := func(r *replica) services.Contest {
+		now := time.Now().UTC()
+		w := r.do(t, http.MethodPost, "/api/contests", admin, map[string]any{
+			"title":     "Running",
+			"startTime": now.Add(-time.Minute),
+			"endTime":   now.Add(time.Hour),
+		})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create contest: %d %s", w.Code, w.Body)
+		}
+		var contest services.Contest
+		if err := json.Unmarshal(w.Body.Bytes(), &contest); err != nil {
+			t.Fatal(err)
+		}
+		return contest
+	}
+
+	var transitions [2]atomic.Int32
+	replicas := []*replica{a, b}
+	for i, r := range replicas {
+		r.scheduler.OnTransition(func(services.ContestTransition) { transitions[i].Add(1) })
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, r := range replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			r.scheduler.Run(ctx)
+		}(r)
+	}
+	// several lease renewals by the leader while the other keeps trying
+	contests := []services.Contest{createStarted(a)}
+	time.Sleep(100 * time.Millisecond)
+	contests = append(contests, createStarted(b))
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	// one replica applied both transitions, the other none
+	ta, tb := transitions[0].Load(), transitions[1].Load()
+	if ta+tb != 2 || ta != 0 && tb != 0 {
+		t.Errorf("transitions applied by a = %d, by b = %d; want both by one replica", ta, tb)
+	}
+	for _, contest := range contests {
+		for _, r := range replicas {
+			w := r.do(t, http.MethodGet, "/api/contests/"+contest.ID, "", nil)
+			var got services.Contest
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != services.ContestRunning {
+				t.Errorf("status of contest %s = %s, want %s", contest.ID, got.Status, services.ContestRunning)
+			}
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupReservationRoutes(router *gin.RouterGroup, capacity *services.CapacityPool, authenticator *auth.Authenticator) {
+	reservationController := controllers.NewReservationController(capacity)
+
+	reservationRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		reservationRoutes.GET("", reservationController.GetReservation)
+		reservationRoutes.PUT("", reservationController.SetReservation)
+		reservationRoutes.DELETE("", reservationController.DeleteReservation)
+	}
+}
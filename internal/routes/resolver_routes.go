@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupResolverRoutes(router *gin.RouterGroup, exporter *services.ResolverExporter, authenticator *auth.Authenticator) {
+	resolverController := controllers.NewResolverController(exporter)
+
+	resolverRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		resolverRoutes.GET("", resolverController.Export)
+	}
+}
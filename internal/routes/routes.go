@@ -2,11 +2,173 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+	"online-judge/internal/store"
 )
 
-func SetupRoutes(router *gin.RouterGroup) {
+// Dependencies holds the services shared between route groups and background
+// workers started in main.
+type Dependencies struct {
+	Store               store.Store
+	Authenticator       *auth.Authenticator
+	ContestService      *services.ContestService
+	ScoreboardService   *services.ScoreboardService
+	PrintService        *services.PrintService
+	SeatService         *services.SeatService
+	ContestPrewarmer    *services.ContestPrewarmer
+	ContestWebhooks     *services.ContestWebhookService
+	CapacityPool        *services.CapacityPool
+	ContestBundler      *services.ContestBundler
+	ResolverExporter    *services.ResolverExporter
+	ContestCloner       *services.ContestCloner
+	IntegrityService    *services.IntegrityService
+	VerificationService *services.VerificationService
+	NotificationService *services.NotificationService
+	AchievementService  *services.AchievementService
+	PreferencesService  *services.PreferencesService
+	PrivacyService      *services.PrivacyService
+	MaintenanceService  *services.MaintenanceService
+	RejudgeReconciler   *services.RejudgeReconciler
+	SubmissionWatchdog  *services.SubmissionWatchdog
+	InvariantChecker    *services.InvariantChecker
+	ToolchainRollouts   *services.ToolchainRolloutService
+	ScalingAdvisor      *services.ScalingAdvisor
+	PublicService       *services.PublicService
+	BackupService       *services.BackupService
+	SubmissionService   *services.SubmissionService
+	SourceAccess        *services.SourceAccess
+	OverrideService     *services.OverrideService
+	ProblemService      *services.ProblemService
+	TestUploadService   *services.TestUploadService
+	DryRunService       *services.DryRunService
+	PlaygroundService   *services.PlaygroundService
+	OptimizationService *services.OptimizationService
+	ProblemStats        *services.ProblemStatsService
+	FastestSolutions    *services.FastestSolutionsService
+	ResponseCache       *cache.Cache
+	// Locale is used for messages when Accept-Language matches no
+	// supported locale.
+	Locale string
+}
+
+func SetupRoutes(router *gin.RouterGroup, deps Dependencies) {
+	router.Use(middleware.Localize(deps.Locale))
 
 	// run routes
 	runRoutes := router.Group("/run")
 	SetupRunRoutes(runRoutes)
+
+	// playground routes
+	playgroundRoutes := router.Group("/playground")
+	SetupPlaygroundRoutes(playgroundRoutes, deps.PlaygroundService, deps.Authenticator)
+
+	// contest routes
+	contestRoutes := router.Group("/contests")
+	SetupContestRoutes(contestRoutes, deps.ContestService, deps.ScoreboardService, deps.ResponseCache, deps.Authenticator)
+
+	// contest printing routes
+	printRoutes := router.Group("/contests/:id/prints")
+	SetupPrintRoutes(printRoutes, deps.PrintService, deps.Authenticator)
+
+	// onsite seat routes
+	seatRoutes := router.Group("/contests/:id/seats")
+	SetupSeatRoutes(seatRoutes, deps.SeatService, deps.Authenticator)
+
+	// judge worker readiness before a contest
+	prewarmRoutes := router.Group("/contests/:id/prewarm")
+	SetupPrewarmRoutes(prewarmRoutes, deps.ContestPrewarmer, deps.Authenticator)
+
+	// judgement webhooks for external scoreboards
+	webhookRoutes := router.Group("/contests/:id/webhook")
+	SetupContestWebhookRoutes(webhookRoutes, deps.ContestWebhooks, deps.Authenticator)
+
+	// judging capacity reserved for a contest
+	reservationRoutes := router.Group("/contests/:id/reservation")
+	SetupReservationRoutes(reservationRoutes, deps.CapacityPool, deps.Authenticator)
+
+	// offline judging bundle of a contest
+	bundleRoutes := router.Group("/contests/:id/bundle")
+	SetupBundleRoutes(bundleRoutes, deps.ContestBundler, deps.Authenticator)
+
+	// event feed for the ICPC resolver's award ceremony
+	resolverRoutes := router.Group("/contests/:id/resolver")
+	SetupResolverRoutes(resolverRoutes, deps.ResolverExporter, deps.Authenticator)
+
+	// copies of a contest on a new schedule, for recurring trainings
+	cloneRoutes := router.Group("/contests/:id/clone")
+	SetupContestCloneRoutes(cloneRoutes, deps.ContestCloner, deps.ResponseCache, deps.Authenticator)
+
+	// suspicious submission patterns for contest judges to review
+	integrityRoutes := router.Group("/contests/:id/integrity")
+	SetupIntegrityRoutes(integrityRoutes, deps.IntegrityService, deps.Authenticator)
+
+	// practice gym of finished contests
+	gymRoutes := router.Group("/gym")
+	SetupGymRoutes(gymRoutes, deps.ContestService, deps.ScoreboardService, deps.ResponseCache, deps.Authenticator)
+
+	// problem routes
+	problemRoutes := router.Group("/problems")
+	SetupProblemRoutes(problemRoutes, deps.ProblemService, deps.DryRunService, deps.OptimizationService, deps.ProblemStats, deps.ResponseCache, deps.Authenticator)
+
+	// fastest accepted solutions per language
+	fastestRoutes := router.Group("/problems/:id/fastest")
+	SetupFastestRoutes(fastestRoutes, deps.FastestSolutions, deps.Authenticator)
+
+	// other users' accepted solutions, once unlocked
+	solutionRoutes := router.Group("/problems/:id/solutions")
+	SetupSolutionRoutes(solutionRoutes, deps.SourceAccess, deps.Authenticator)
+
+	// direct-to-storage uploads of large tests
+	testUploadRoutes := router.Group("/problems/:id/test-uploads")
+	SetupTestUploadRoutes(testUploadRoutes, deps.TestUploadService, deps.ResponseCache, deps.Authenticator)
+
+	// submission routes
+	submissionRoutes := router.Group("/submissions")
+	SetupSubmissionRoutes(submissionRoutes, deps.SubmissionService, deps.OverrideService, deps.ProblemService, deps.SourceAccess, deps.Authenticator)
+
+	// double-judging review routes
+	verificationRoutes := router.Group("/verifications")
+	SetupVerificationRoutes(verificationRoutes, deps.VerificationService, deps.Authenticator)
+
+	// notification routes
+	notificationRoutes := router.Group("/notifications")
+	SetupNotificationRoutes(notificationRoutes, deps.NotificationService, deps.Authenticator)
+
+	// user profiles with streaks and achievements
+	userRoutes := router.Group("/users")
+	SetupProfileRoutes(userRoutes, deps.AchievementService, deps.Authenticator)
+
+	// the caller's editor settings, shared across their devices
+	preferencesRoutes := router.Group("/preferences")
+	SetupPreferencesRoutes(preferencesRoutes, deps.PreferencesService, deps.Authenticator)
+
+	// the caller's data export and account deletion
+	accountRoutes := router.Group("/account")
+	SetupPrivacyRoutes(accountRoutes, deps.PrivacyService, deps.Authenticator)
+
+	// rejudge routes
+	rejudgeRoutes := router.Group("/rejudges")
+	SetupRejudgeRoutes(rejudgeRoutes, deps.RejudgeReconciler, deps.Authenticator)
+
+	// judge worker autoscaling advice
+	scalingRoutes := router.Group("/scaling")
+	SetupScalingRoutes(scalingRoutes, deps.ScalingAdvisor, deps.Authenticator)
+
+	// judging paused for worker upgrades, with submissions still queued
+	maintenanceRoutes := router.Group("/maintenance")
+	SetupMaintenanceRoutes(maintenanceRoutes, deps.MaintenanceService, deps.Authenticator)
+
+	// unauthenticated read-only API for community tools
+	publicRoutes := router.Group("/public")
+	SetupPublicRoutes(publicRoutes, deps.PublicService, deps.ContestService, deps.Store, deps.ResponseCache)
+
+	// admin routes
+	adminRoutes := router.Group("/admin")
+	SetupBackupRoutes(adminRoutes, deps.BackupService, deps.ResponseCache, deps.Authenticator)
+	SetupWatchdogRoutes(adminRoutes, deps.SubmissionWatchdog, deps.Authenticator)
+	SetupInvariantRoutes(adminRoutes, deps.InvariantChecker, deps.Authenticator)
+	SetupToolchainRolloutRoutes(adminRoutes, deps.ToolchainRollouts, deps.Authenticator)
 }
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+// SetupScalingRoutes serves the scaling advice to autoscalers holding the
+// advisor's static token, or to admins when no token is configured.
+func SetupScalingRoutes(router *gin.RouterGroup, advisor *services.ScalingAdvisor, authenticator *auth.Authenticator) {
+	scalingController := controllers.NewScalingController(advisor)
+
+	guard := []gin.HandlerFunc{middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin)}
+	if token := advisor.Token(); token != "" {
+		guard = []gin.HandlerFunc{middleware.RequireStaticToken(token)}
+	}
+	scalingRoutes := router.Group("", guard...)
+	{
+		scalingRoutes.GET("", scalingController.GetAdvice)
+	}
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupSeatRoutes(router *gin.RouterGroup, seatService *services.SeatService, authenticator *auth.Authenticator) {
+	seatController := controllers.NewSeatController(seatService)
+
+	requireAdmin := middleware.RequireRole(auth.RoleAdmin)
+
+	seatRoutes := router.Group("", middleware.RequireAuth(authenticator))
+	{
+		seatRoutes.GET("", middleware.RequireRole(auth.RoleJudge), seatController.ListSeats)
+		seatRoutes.GET("/credentials", requireAdmin, seatController.Credentials)
+		seatRoutes.PUT("/:userId", requireAdmin, seatController.AssignSeat)
+		seatRoutes.DELETE("/:userId", requireAdmin, seatController.UnassignSeat)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupSolutionRoutes(router *gin.RouterGroup, sourceAccess *services.SourceAccess, authenticator *auth.Authenticator) {
+	solutionController := controllers.NewSolutionController(sourceAccess)
+
+	solutionRoutes := router.Group("", middleware.RequireAuth(authenticator))
+	{
+		solutionRoutes.GET("", solutionController.ListSolutions)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupSubmissionRoutes(router *gin.RouterGroup, submissionService *services.SubmissionService, overrideService *services.OverrideService, problemService *services.ProblemService, sourceAccess *services.SourceAccess, authenticator *auth.Authenticator) {
+	submissionController := controllers.NewSubmissionController(submissionService, overrideService, problemService, sourceAccess)
+
+	submissionRoutes := router.Group("", middleware.RequireAuth(authenticator))
+	{
+		submissionRoutes.GET("", submissionController.ListSubmissions)
+		submissionRoutes.POST("", submissionController.CreateSubmission)
+		submissionRoutes.GET("/:id", submissionController.GetSubmission)
+		submissionRoutes.GET("/:id/source", submissionController.GetSource)
+		submissionRoutes.POST("/:id/withdraw", submissionController.WithdrawSubmission)
+		submissionRoutes.POST("/:id/cancel", middleware.RequireRole(auth.RoleAdmin), submissionController.CancelSubmission)
+		submissionRoutes.POST("/:id/override", middleware.RequireRole(auth.RoleJudge), submissionController.OverrideVerdict)
+		submissionRoutes.DELETE("/:id", middleware.RequireRole(auth.RoleAdmin), submissionController.DeleteSubmission)
+	}
+}
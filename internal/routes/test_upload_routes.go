@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/cache"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupTestUploadRoutes(router *gin.RouterGroup, testUploadService *services.TestUploadService, responseCache *cache.Cache, authenticator *auth.Authenticator) {
+	testUploadController := controllers.NewTestUploadController(testUploadService)
+
+	invalidate := middleware.InvalidateCache(responseCache, ProblemCachePrefix)
+
+	testUploadRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		testUploadRoutes.POST("", testUploadController.CreateUpload)
+		testUploadRoutes.POST("/:uploadId/finalize", invalidate, testUploadController.FinalizeUpload)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupToolchainRolloutRoutes(router *gin.RouterGroup, rolloutService *services.ToolchainRolloutService, authenticator *auth.Authenticator) {
+	rolloutController := controllers.NewToolchainRolloutController(rolloutService)
+
+	rolloutRoutes := router.Group("/toolchain-rollouts", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		rolloutRoutes.POST("", rolloutController.StartRollout)
+		rolloutRoutes.GET("", rolloutController.ListRollouts)
+		rolloutRoutes.GET("/:id", rolloutController.GetRollout)
+	}
+}
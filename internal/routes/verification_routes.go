@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupVerificationRoutes(router *gin.RouterGroup, verificationService *services.VerificationService, authenticator *auth.Authenticator) {
+	verificationController := controllers.NewVerificationController(verificationService)
+
+	verificationRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleJudge))
+	{
+		verificationRoutes.GET("", verificationController.ListVerifications)
+		verificationRoutes.GET("/:submissionId", verificationController.GetVerification)
+		verificationRoutes.POST("/:submissionId/resolve", verificationController.ResolveVerification)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"online-judge/internal/auth"
+	"online-judge/internal/controllers"
+	"online-judge/internal/middleware"
+	"online-judge/internal/services"
+)
+
+func SetupWatchdogRoutes(router *gin.RouterGroup, watchdog *services.SubmissionWatchdog, authenticator *auth.Authenticator) {
+	watchdogController := controllers.NewWatchdogController(watchdog)
+
+	watchdogRoutes := router.Group("", middleware.RequireAuth(authenticator), middleware.RequireRole(auth.RoleAdmin))
+	{
+		watchdogRoutes.GET("/watchdog", watchdogController.GetStats)
+	}
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"online-judge/internal/clock"
+	"online-judge/internal/store"
+	"sort"
+	"time"
+)
+
+type AchievementID string
+
+const (
+	AchievementFirstAccepted AchievementID = "first_accepted"
+	AchievementSolved10      AchievementID = "solved_10"
+	AchievementSolved100     AchievementID = "solved_100"
+	AchievementFirstContest  AchievementID = "first_contest"
+	AchievementStreak7       AchievementID = "streak_7"
+	AchievementStreak30      AchievementID = "streak_30"
+)
+
+// achievementRules are checked in order against a user's progress.
+var achievementRules = []struct {
+	id     AchievementID
+	title  string
+	earned func(UserProfile) bool
+}{
+	{AchievementFirstAccepted, "First accepted solution", func(p UserProfile) bool { return p.Solved >= 1 }},
+	{AchievementSolved10, "10 problems solved", func(p UserProfile) bool { return p.Solved >= 10 }},
+	{AchievementSolved100, "100 problems solved", func(p UserProfile) bool { return p.Solved >= 100 }},
+	{AchievementFirstContest, "First contest", func(p UserProfile) bool { return p.Contests >= 1 }},
+	{AchievementStreak7, "7-day solve streak", func(p UserProfile) bool { return p.LongestStreak >= 7 }},
+	{AchievementStreak30, "30-day solve streak", func(p UserProfile) bool { return p.LongestStreak >= 30 }},
+}
+
+type Achievement struct {
+	ID        AchievementID `json:"id"`
+	Title     string        `json:"title"`
+	AwardedAt time.Time     `json:"awardedAt"`
+}
+
+// UserProfile is a user's practice progress. Days are UTC calendar days
+// with at least one accepted submission; a streak is a run of consecutive
+// such days. Contests counts contests the user submitted to as an official
+// participant.
+type UserProfile struct {
+	UserID        string        `json:"userId"`
+	Solved        int           `json:"solved"`
+	Contests      int           `json:"contests"`
+	CurrentStreak int           `json:"currentStreak"`
+	LongestStreak int           `json:"longestStreak"`
+	LastSolveDay  string        `json:"lastSolveDay,omitempty"` // YYYY-MM-DD
+	Achievements  []Achievement `json:"achievements"`
+	UpdatedAt     time.Time     `json:"updatedAt"`
+}
+
+const (
+	profileKeyPrefix    = "profile:user:"
+	achievementLeaseKey = "lease:achievements"
+	solveDayLayout      = "2006-01-02"
+)
+
+// AchievementIntervalFromEnv reads ACHIEVEMENT_INTERVAL_MINUTES, how often
+// profiles are recomputed, defaulting to 5 minutes.
+func AchievementIntervalFromEnv() time.Duration {
+	return time.Duration(intFromEnv("ACHIEVEMENT_INTERVAL_MINUTES", 5)) * time.Minute
+}
+
+// AchievementService recomputes every user's profile from their judged
+// submissions in a background job and awards achievements as they are
+// earned. Awards are kept even if a rejudge later takes back what earned
+// them. A store lease makes one replica run each pass.
+type AchievementService struct {
+	store               store.Store
+	submissionService   *SubmissionService
+	notificationService *NotificationService
+	interval            time.Duration
+	clock               clock.Clock
+}
+
+func NewAchievementService(st store.Store, submissionService *SubmissionService, notificationService *NotificationService, interval time.Duration) *AchievementService {
+	return &AchievementService{
+		store:               st,
+		submissionService:   submissionService,
+		notificationService: notificationService,
+		interval:            interval,
+		clock:               clock.System,
+	}
+}
+
+// SetClock replaces the wall clock, e.g. with a clock.Manual in tests.
+func (s *AchievementService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Run updates profiles every interval until ctx is cancelled.
+func (s *AchievementService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		ok, err := s.store.SetNX(achievementLeaseKey, []byte("1"), s.interval/2)
+		if err != nil {
+			log.Printf("Error acquiring achievement lease: %v", err)
+		} else if ok {
+			if err := s.Update(s.clock.Now()); err != nil {
+				log.Printf("Error updating profiles: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// userProgress collects what a profile is computed from.
+type userProgress struct {
+	solved   map[string]bool
+	contests map[string]bool
+	days     map[string]bool
+}
+
+// Update recomputes every profile as of now and notifies users of new
+// achievements.
+func (s *AchievementService) Update(now time.Time) error {
+	submissions, err := s.submissionService.List(SubmissionFilter{})
+	if err != nil {
+		return err
+	}
+
+	progress := make(map[string]*userProgress)
+	for _, submission := range submissions {
+		if submission.Status != SubmissionJudged {
+			continue
+		}
+		p, ok := progress[submission.UserID]
+		if !ok {
+			p = &userProgress{solved: make(map[string]bool), contests: make(map[string]bool), days: make(map[string]bool)}
+			progress[submission.UserID] = p
+		}
+		if submission.ContestID != "" && !submission.Virtual {
+			p.contests[submission.ContestID] = true
+		}
+		if submission.Verdict == VerdictAccepted {
+			p.solved[submission.ProblemID] = true
+			p.days[submission.CreatedAt.UTC().Format(solveDayLayout)] = true
+		}
+	}
+
+	for userID, p := range progress {
+		if err := s.updateProfile(userID, p, now); err != nil {
+			log.Printf("Error updating profile of user %s: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+func (s *AchievementService) updateProfile(userID string, p *userProgress, now time.Time) error {
+	profile, err := s.Profile(userID)
+	if err != nil {
+		return err
+	}
+	profile.Solved = len(p.solved)
+	profile.Contests = len(p.contests)
+	profile.LongestStreak, profile.LastSolveDay = longestStreak(p.days)
+	profile.CurrentStreak = currentStreak(p.days, now)
+	profile.UpdatedAt = now
+
+	awarded := make(map[AchievementID]bool, len(profile.Achievements))
+	for _, achievement := range profile.Achievements {
+		awarded[achievement.ID] = true
+	}
+	var earned []Achievement
+	for _, rule := range achievementRules {
+		if !awarded[rule.id] && rule.earned(profile) {
+			earned = append(earned, Achievement{ID: rule.id, Title: rule.title, AwardedAt: now})
+		}
+	}
+	profile.Achievements = append(profile.Achievements, earned...)
+
+	if err := setJSON(s.store, profileKeyPrefix+userID, profile, 0); err != nil {
+		return err
+	}
+	for _, achievement := range earned {
+		s.notificationService.Notify([]string{userID}, Notification{
+			Event:     EventAchievementAwarded,
+			Title:     "Achievement unlocked: " + achievement.Title,
+			Body:      "You earned the achievement " + achievement.Title + ".",
+			Data:      map[string]string{"achievement": string(achievement.ID)},
+			CreatedAt: now,
+		})
+	}
+	return nil
+}
+
+// Profile returns the user's profile as of the last update; users without
+// judged submissions get an empty one. A streak whose last day is before
+// yesterday reads as broken.
+func (s *AchievementService) Profile(userID string) (UserProfile, error) {
+	profile := UserProfile{UserID: userID}
+	err := getJSON(s.store, profileKeyPrefix+userID, &profile)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return UserProfile{}, err
+	}
+	if profile.LastSolveDay != "" && !activeStreakDay(profile.LastSolveDay, s.clock.Now()) {
+		profile.CurrentStreak = 0
+	}
+	if profile.Achievements == nil {
+		profile.Achievements = []Achievement{}
+	}
+	return profile, nil
+}
+
+// longestStreak returns the longest run of consecutive days and the last
+// day.
+func longestStreak(days map[string]bool) (int, string) {
+	sorted := make([]string, 0, len(days))
+	for day := range days {
+		sorted = append(sorted, day)
+	}
+	sort.Strings(sorted)
+
+	longest, run := 0, 0
+	var previous time.Time
+	for _, day := range sorted {
+		date, err := time.Parse(solveDayLayout, day)
+		if err != nil {
+			continue
+		}
+		if run > 0 && date.Equal(previous.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		previous = date
+	}
+	if len(sorted) == 0 {
+		return 0, ""
+	}
+	return longest, sorted[len(sorted)-1]
+}
+
+// currentStreak counts consecutive days back from today, or from yesterday
+// when nothing has been solved yet today.
+func currentStreak(days map[string]bool, now time.Time) int {
+	day := now.UTC()
+	if !days[day.Format(solveDayLayout)] {
+		day = day.AddDate(0, 0, -1)
+	}
+	streak := 0
+	for days[day.Format(solveDayLayout)] {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
+func activeStreakDay(day string, now time.Time) bool {
+	today := now.UTC().Format(solveDayLayout)
+	yesterday := now.UTC().AddDate(0, 0, -1).Format(solveDayLayout)
+	return day == today || day == yesterday
+}
+
+// UserData returns the privacy section holding the user's profile. Its
+// streaks and achievements are derived from submissions, so it is deleted.
+func (s *AchievementService) UserData() UserDataSection {
+	return userKeySection{store: s.store, name: "profile", prefixes: []string{profileKeyPrefix}, marker: ":", erase: true}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SnapshotFormatVersion is bumped whenever a section changes incompatibly.
+// The format is documented in docs/backup.md.
+const SnapshotFormatVersion = 1
+
+var ErrUnsupportedSnapshot = errors.New("unsupported snapshot format version")
+
+// Snapshot is the top-level backup document.
+type Snapshot struct {
+	Version   int                        `json:"version"`
+	CreatedAt time.Time                  `json:"createdAt"`
+	Sections  map[string]json.RawMessage `json:"sections"`
+}
+
+// SnapshotSection is implemented by every service whose state is part of a
+// backup. ImportSnapshot replaces the service's state entirely.
+type SnapshotSection interface {
+	SnapshotName() string
+	ExportSnapshot() (json.RawMessage, error)
+	ImportSnapshot(data json.RawMessage) error
+}
+
+type BackupService struct {
+	sections []SnapshotSection
+}
+
+func NewBackupService(sections ...SnapshotSection) *BackupService {
+	return &BackupService{sections: sections}
+}
+
+func (s *BackupService) Export() (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Version:   SnapshotFormatVersion,
+		CreatedAt: time.Now().UTC(),
+		Sections:  make(map[string]json.RawMessage, len(s.sections)),
+	}
+	for _, section := range s.sections {
+		data, err := section.ExportSnapshot()
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", section.SnapshotName(), err)
+		}
+		snapshot.Sections[section.SnapshotName()] = data
+	}
+	return snapshot, nil
+}
+
+// Restore imports every section present in the snapshot. Sections that this
+// server does not know about are rejected so a restore never silently drops
+// data; sections missing from the snapshot are left untouched.
+func (s *BackupService) Restore(snapshot *Snapshot) error {
+	if snapshot.Version != SnapshotFormatVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedSnapshot, snapshot.Version)
+	}
+
+	known := make(map[string]SnapshotSection, len(s.sections))
+	for _, section := range s.sections {
+		known[section.SnapshotName()] = section
+	}
+
+	names := make([]string, 0, len(snapshot.Sections))
+	for name := range snapshot.Sections {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("%w: unknown section %q", ErrUnsupportedSnapshot, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := known[name].ImportSnapshot(snapshot.Sections[name]); err != nil {
+			return fmt.Errorf("import %s: %w", name, err)
+		}
+	}
+	return nil
+}
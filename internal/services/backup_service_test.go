@@ -0,0 +1,140 @@
+package services_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"online-judge/internal/auth"
+	"online-judge/internal/services"
+	"online-judge/internal/store"
+	"testing"
+	"time"
+)
+
+// backupNode is the state of one deployment: its store and every service
+// whose state a snapshot holds.
+type backupNode struct {
+	store       store.Store
+	contests    *services.ContestService
+	problems    *services.ProblemService
+	submissions *services.SubmissionService
+	seats       *services.SeatService
+	prints      *services.PrintService
+	webhooks    *services.ContestWebhookService
+	capacity    *services.CapacityPool
+	backup      *services.BackupService
+}
+
+func newBackupNode() *backupNode {
+	st := store.NewMemoryStore()
+	n := &backupNode{store: st, contests: services.NewContestService(st), problems: services.NewProblemService(st, nil)}
+	n.seats = services.NewSeatService(st, n.contests, auth.NewAuthenticator([]byte("backup-test-secret")))
+	n.submissions = services.NewSubmissionService(st, n.contests, n.problems, n.seats)
+	n.prints = services.NewPrintService(st, n.contests, services.PrintConfig{QuotaPerTeam: 5, MaxBytes: 1024})
+	n.webhooks = services.NewContestWebhookService(st, n.contests, time.Second)
+	n.capacity = services.NewCapacityPool(st, n.contests, 8)
+	n.backup = services.NewBackupService(n.contests, n.contests.RegistrationSnapshot(), n.contests.VirtualSnapshot(),
+		n.problems, n.submissions, n.seats, n.prints, n.webhooks, n.capacity)
+	return n
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	source := newBackupNode()
+	admin := auth.Principal{UserID: "admin", Role: auth.RoleAdmin}
+
+	problem, err := source.problems.Create(services.Problem{Title: "Sum", TimeLimit: 1, MemoryLimit: 65536})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// contest 1 runs onsite, contest 2 is over and open for virtual participation
+	now := time.Now().UTC().Truncate(time.Second)
+	contests, err := json.Marshal([]services.Contest{
+		{ID: "1", Title: "Onsite", StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour), Problems: []string{problem.ID}, Status: services.ContestRunning},
+		{ID: "2", Title: "Past", StartTime: now.Add(-48 * time.Hour), EndTime: now.Add(-46 * time.Hour), Problems: []string{problem.ID}, Status: services.ContestFinished},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.contests.ImportSnapshot(contests); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.seats.Assign(services.Seat{ContestID: "1", UserID: "team", Room: "A", Seat: "1", IP: "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.contests.StartVirtual("2", "late"); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := source.submissions.Create(admin, services.SubmissionRequest{ContestID: "1", ProblemID: problem.ID, Language: "python", Source: "print(1)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.submissions.Cancel(cancelled.ID); err != nil {
+		t.Fatal(err)
+	}
+	queued, err := source.submissions.Create(admin, services.SubmissionRequest{ProblemID: problem.ID, Language: "python", Source: "print(2)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed, err := source.prints.Submit(admin, "1", "a.py", "print(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.prints.Claim("1", printed.ID, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.prints.Submit(admin, "1", "b.py", "print(2)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.webhooks.Set(services.ContestWebhook{ContestID: "1", URL: "https://scoreboard.example/feed", Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.capacity.Reserve(services.ContestReservation{ContestID: "1", Slots: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := source.backup.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"submissions", "virtualParticipations", "seats", "printJobs", "contestWebhooks", "capacityReservations"} {
+		if data := snapshot.Sections[name]; len(data) == 0 || bytes.Equal(data, []byte("[]")) {
+			t.Errorf("section %s is empty: %s", name, data)
+		}
+	}
+
+	restored := newBackupNode()
+	if err := restored.backup.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	again, err := restored.backup.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range snapshot.Sections {
+		if !bytes.Equal(again.Sections[name], data) {
+			t.Errorf("section %s after restore:\n got %s\nwant %s", name, again.Sections[name], data)
+		}
+	}
+
+	// what the restore derives besides the records
+	next, err := restored.submissions.NextQueued()
+	if err != nil {
+		t.Fatalf("queued submission not requeued: %v", err)
+	}
+	if next.ID != queued.ID {
+		t.Errorf("next queued submission = %s, want %s", next.ID, queued.ID)
+	}
+	if got, err := restored.submissions.Get(cancelled.ID); err != nil || got.Source != "print(1)" {
+		t.Errorf("restored submission %s source = %q, %v", cancelled.ID, got.Source, err)
+	}
+	if _, err := restored.prints.Claim("1", printed.ID, "other"); err != services.ErrPrintJobClaimed {
+		t.Errorf("claiming a restored claimed job: %v, want %v", err, services.ErrPrintJobClaimed)
+	}
+	job, err := restored.prints.Submit(admin, "1", "c.py", "print(3)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "3" {
+		t.Errorf("print job ID after restore = %s, want 3", job.ID)
+	}
+}
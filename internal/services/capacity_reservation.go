@@ -0,0 +1,204 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"online-judge/internal/clock"
+	"online-judge/internal/store"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrReservationNotFound  = errors.New("contest reservation not found")
+	ErrReservationsDisabled = errors.New("reservations need JUDGE_CAPACITY to be set")
+	ErrInvalidReservation   = errors.New("reservation needs a positive slot count and must end after it starts")
+	ErrCapacityExceeded     = errors.New("overlapping reservations would exceed judging capacity")
+)
+
+// ContestReservation sets aside judging slots for a contest's submissions
+// during a window, by default the contest itself, so practice traffic and
+// other contests cannot use them. The contest may still use shared slots
+// beyond its reservation.
+type ContestReservation struct {
+	ContestID string    `json:"contestId"`
+	Slots     int       `json:"slots" binding:"required"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+func (r ContestReservation) activeAt(now time.Time) bool {
+	return !now.Before(r.Start) && now.Before(r.End)
+}
+
+const (
+	reservationKeyPrefix  = "reservation:contest:"
+	capacitySlotKeyPrefix = "judging:slot:"
+)
+
+// CapacityPool hands out the fleet's judging slots, held as expiring keys
+// in the shared store like per-user judging slots. Slots are numbered;
+// each active reservation owns a range at the start, in contest ID order,
+// and the rest are shared.
+type CapacityPool struct {
+	store          store.Store
+	contestService *ContestService
+	capacity       int
+	clock          clock.Clock
+}
+
+// NewCapacityPool returns a pool of capacity slots, the number of
+// submissions the judge fleet grades at once. Zero disables the pool and
+// reservations.
+func NewCapacityPool(st store.Store, contestService *ContestService, capacity int) *CapacityPool {
+	return &CapacityPool{store: st, contestService: contestService, capacity: capacity, clock: clock.System}
+}
+
+// SetClock replaces the wall clock, e.g. with a clock.Manual in tests.
+func (p *CapacityPool) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// JudgeCapacityFromEnv reads JUDGE_CAPACITY.
+func JudgeCapacityFromEnv() int {
+	return intFromEnv("JUDGE_CAPACITY", 0)
+}
+
+func (p *CapacityPool) Capacity() int {
+	return p.capacity
+}
+
+// Reserve sets the contest's reservation, replacing any previous one.
+func (p *CapacityPool) Reserve(reservation ContestReservation) (ContestReservation, error) {
+	if p.capacity <= 0 {
+		return ContestReservation{}, ErrReservationsDisabled
+	}
+	contest, err := p.contestService.Get(reservation.ContestID)
+	if err != nil {
+		return ContestReservation{}, err
+	}
+	if reservation.Start.IsZero() {
+		reservation.Start = contest.StartTime
+	}
+	if reservation.End.IsZero() {
+		reservation.End = contest.EndTime
+	}
+	if reservation.Slots <= 0 || !reservation.End.After(reservation.Start) {
+		return ContestReservation{}, ErrInvalidReservation
+	}
+
+	others, err := listJSON[ContestReservation](p.store, reservationKeyPrefix)
+	if err != nil {
+		return ContestReservation{}, err
+	}
+	// Checked at each start within the window, where the overlap changes
+	for _, at := range append([]ContestReservation{reservation}, others...) {
+		if !reservation.activeAt(at.Start) {
+			continue
+		}
+		reserved := reservation.Slots
+		for _, other := range others {
+			if other.ContestID != reservation.ContestID && other.activeAt(at.Start) {
+				reserved += other.Slots
+			}
+		}
+		if reserved > p.capacity {
+			return ContestReservation{}, fmt.Errorf("%w: %d of %d slots", ErrCapacityExceeded, reserved, p.capacity)
+		}
+	}
+
+	if err := setJSON(p.store, reservationKeyPrefix+reservation.ContestID, reservation, 0); err != nil {
+		return ContestReservation{}, err
+	}
+	return reservation, nil
+}
+
+func (p *CapacityPool) Reservation(contestID string) (ContestReservation, error) {
+	var reservation ContestReservation
+	if err := getJSON(p.store, reservationKeyPrefix+contestID, &reservation); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ContestReservation{}, ErrReservationNotFound
+		}
+		return ContestReservation{}, err
+	}
+	return reservation, nil
+}
+
+func (p *CapacityPool) DeleteReservation(contestID string) error {
+	if _, err := p.Reservation(contestID); err != nil {
+		return err
+	}
+	return p.store.Delete(reservationKeyPrefix + contestID)
+}
+
+func (p *CapacityPool) SnapshotName() string {
+	return "capacityReservations"
+}
+
+func (p *CapacityPool) ExportSnapshot() (json.RawMessage, error) {
+	reservations, err := listJSON[ContestReservation](p.store, reservationKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(reservations)
+}
+
+func (p *CapacityPool) ImportSnapshot(data json.RawMessage) error {
+	var reservations []ContestReservation
+	if err := json.Unmarshal(data, &reservations); err != nil {
+		return err
+	}
+	return replaceJSON(p.store, reservationKeyPrefix, reservations, func(r ContestReservation) string { return r.ContestID })
+}
+
+// Acquire takes a slot the submission may use: one of its contest's
+// reserved slots if it has any free, otherwise a shared one. Virtual
+// participations count as practice. When no slot is free it returns false;
+// otherwise the returned function releases the slot.
+func (p *CapacityPool) Acquire(submission Submission) (func(), bool, error) {
+	if p == nil || p.capacity <= 0 {
+		return func() {}, true, nil
+	}
+	reservations, err := listJSON[ContestReservation](p.store, reservationKeyPrefix)
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Slice(reservations, func(i, j int) bool { return reservations[i].ContestID < reservations[j].ContestID })
+
+	now := p.clock.Now()
+	shared := 0
+	var slots []int
+	for _, reservation := range reservations {
+		if !reservation.activeAt(now) {
+			continue
+		}
+		if reservation.ContestID == submission.ContestID && !submission.Virtual {
+			for slot := shared; slot < shared+reservation.Slots; slot++ {
+				slots = append(slots, slot)
+			}
+		}
+		shared += reservation.Slots
+	}
+	for slot := shared; slot < p.capacity; slot++ {
+		slots = append(slots, slot)
+	}
+
+	for _, slot := range slots {
+		key := capacitySlotKeyPrefix + strconv.Itoa(slot)
+		ok, err := p.store.SetNX(key, []byte(submission.ID), judgingSlotTTL)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return func() {
+				if err := p.store.Delete(key); err != nil {
+					log.Printf("Error releasing judging slot %s: %v", key, err)
+				}
+			}, true, nil
+		}
+	}
+	return nil, false, nil
+}
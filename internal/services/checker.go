@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"online-judge/internal/judge"
+	"strconv"
+	"strings"
+)
+
+var ErrCheckerFailed = errors.New("checker failed")
+
+// Checker is a setter-provided program that scores one test. It runs in the
+// judge sandbox with input.txt, answer.txt and output.txt in its working
+// directory and prints a score between 0 and 1 on the first line of stdout,
+// optionally followed by a message for the setter. Checkers of optimization
+// problems also print a line "objective <value>" with the raw objective.
+type Checker struct {
+	Language string `json:"language" binding:"required"`
+	Source   string `json:"source" binding:"required"`
+}
+
+// Limits for running checkers; they are trusted code but still sandboxed.
+const (
+	checkerTimeLimit   = 10.0
+	checkerMemoryLimit = 512 * 1024
+)
+
+type ScoringPolicy string
+
+const (
+	// ScoringBinary accepts a submission only if every test is fully
+	// correct and stops at the first failing test.
+	ScoringBinary ScoringPolicy = "binary"
+	// ScoringAverage awards the mean test score.
+	ScoringAverage ScoringPolicy = "average"
+	// ScoringMinimum awards the lowest test score.
+	ScoringMinimum ScoringPolicy = "minimum"
+)
+
+func (p ScoringPolicy) valid() bool {
+	switch p {
+	case "", ScoringBinary, ScoringAverage, ScoringMinimum:
+		return true
+	}
+	return false
+}
+
+// aggregate combines per-test scores in [0, 1] into a submission score in
+// [0, 1].
+func (p ScoringPolicy) aggregate(scores []float64) float64 {
+	if len(scores) == 0 {
+		return 0
+	}
+	switch p {
+	case ScoringAverage:
+		sum := 0.0
+		for _, score := range scores {
+			sum += score
+		}
+		return sum / float64(len(scores))
+	case ScoringMinimum:
+		lowest := scores[0]
+		for _, score := range scores[1:] {
+			if score < lowest {
+				lowest = score
+			}
+		}
+		return lowest
+	default:
+		for _, score := range scores {
+			if score < 1 {
+				return 0
+			}
+		}
+		return 1
+	}
+}
+
+type checkerResult struct {
+	Score     float64
+	Message   string
+	Objective *float64
+}
+
+func runChecker(ctx context.Context, client *judge.Client, checker *Checker, test TestCase, output string) (checkerResult, error) {
+	result, err := client.Execute(ctx, judge.Submission{
+		Language:    checker.Language,
+		Code:        checker.Source,
+		TimeLimit:   checkerTimeLimit,
+		MemoryLimit: checkerMemoryLimit,
+		CacheBinary: true,
+		Files: map[string]string{
+			"input.txt":  test.Input,
+			"answer.txt": test.Output,
+			"output.txt": output,
+		},
+	})
+	if err != nil {
+		return checkerResult{}, err
+	}
+	if result.Status != judge.StatusOK {
+		return checkerResult{}, fmt.Errorf("%w: %s %s", ErrCheckerFailed, result.Status, result.CompileOutput)
+	}
+	return parseCheckerOutput(result.Stdout)
+}
+
+func parseCheckerOutput(stdout string) (checkerResult, error) {
+	first, rest, _ := strings.Cut(strings.TrimLeft(stdout, " \t\r\n"), "\n")
+	score, err := strconv.ParseFloat(strings.TrimSpace(first), 64)
+	if err != nil || score < 0 || score > 1 {
+		return checkerResult{}, fmt.Errorf("%w: score %q is not a number between 0 and 1", ErrCheckerFailed, first)
+	}
+
+	result := checkerResult{Score: score}
+	var message []string
+	for _, line := range strings.Split(rest, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "objective "); ok {
+			objective, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return checkerResult{}, fmt.Errorf("%w: objective %q is not a number", ErrCheckerFailed, value)
+			}
+			result.Objective = &objective
+			continue
+		}
+		message = append(message, line)
+	}
+	result.Message = strings.TrimSpace(strings.Join(message, "\n"))
+	return result, nil
+}
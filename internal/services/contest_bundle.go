@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContestBundler exports everything needed to judge one contest without
+// network access: the contest, its registrations, its problems with their
+// judge scripts and unit test suites, and their tests with object-stored data inlined. A bundle
+// is a Snapshot holding only that contest, so a fresh server restores it
+// like any backup.
+type ContestBundler struct {
+	contestService *ContestService
+	problemService *ProblemService
+}
+
+func NewContestBundler(contestService *ContestService, problemService *ProblemService) *ContestBundler {
+	return &ContestBundler{contestService: contestService, problemService: problemService}
+}
+
+// Export builds the contest's bundle. Object-stored tests are downloaded,
+// so it can take as long as the contest's test data takes to read.
+func (b *ContestBundler) Export(ctx context.Context, contestID string) (*Snapshot, error) {
+	contest, err := b.contestService.Get(contestID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := registrationKey(contest.ID, "")
+	keys, err := b.contestService.store.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	registrations := make([]contestRegistration, 0, len(keys))
+	for _, key := range keys {
+		registrations = append(registrations, contestRegistration{ContestID: contest.ID, UserID: strings.TrimPrefix(key, prefix)})
+	}
+
+	problems := make([]Problem, 0, len(contest.Problems))
+	tests := make(map[string][]TestCase, len(contest.Problems))
+	var scripts []JudgeScript
+	var suites []UnitTestSuite
+	for _, problemID := range contest.Problems {
+		problem, err := b.problemService.Get(problemID)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, problem)
+
+		problemTests, err := b.problemService.Tests(problemID)
+		if err != nil {
+			return nil, err
+		}
+		for i, test := range problemTests {
+			if test.Data == nil {
+				continue
+			}
+			if test, err = b.problemService.LoadTestData(ctx, test, true); err != nil {
+				return nil, fmt.Errorf("problem %s test %d: %w", problemID, i+1, err)
+			}
+			test.Data = nil
+			problemTests[i] = test
+		}
+		tests[problemID] = problemTests
+
+		script, err := b.problemService.JudgeScript(problemID)
+		if err == nil {
+			scripts = append(scripts, script)
+		} else if !errors.Is(err, ErrJudgeScriptNotFound) {
+			return nil, err
+		}
+		suite, err := b.problemService.UnitTests(problemID)
+		if err == nil {
+			suites = append(suites, suite)
+		} else if !errors.Is(err, ErrUnitTestsNotFound) {
+			return nil, err
+		}
+	}
+
+	snapshot := &Snapshot{
+		Version:   SnapshotFormatVersion,
+		CreatedAt: time.Now().UTC(),
+		Sections:  make(map[string]json.RawMessage, 6),
+	}
+	sections := map[string]any{
+		b.contestService.SnapshotName():                        []Contest{contest},
+		b.contestService.RegistrationSnapshot().SnapshotName(): registrations,
+		b.problemService.SnapshotName():                        problems,
+		b.problemService.TestsSnapshot().SnapshotName():        tests,
+		b.problemService.JudgeScriptsSnapshot().SnapshotName(): scripts,
+		b.problemService.UnitTestsSnapshot().SnapshotName():    suites,
+	}
+	for name, section := range sections {
+		data, err := json.Marshal(section)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", name, err)
+		}
+		snapshot.Sections[name] = data
+	}
+	return snapshot, nil
+}
+
+// LoadBundle reads a bundle written by Export from a file.
+func LoadBundle(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("decode bundle %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+// ContestClone says how to copy a contest onto a new schedule.
+type ContestClone struct {
+	// Title names the copy; empty keeps the original's.
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"startTime" binding:"required"`
+	// FreshProblems gives the copy its own copies of the problems, tests
+	// included, to be tweaked without touching the original's. Otherwise
+	// both contests share the same problems.
+	FreshProblems bool `json:"freshProblems"`
+}
+
+// ContestCloner copies contests, e.g. last week's training as this week's:
+// the problems, the schedule shifted to a new start with the freeze and
+// end the same time after it, and the webhook. Registrations, seats and
+// capacity reservations are the original's own and are not copied.
+type ContestCloner struct {
+	contestService *ContestService
+	problemService *ProblemService
+	webhookService *ContestWebhookService
+}
+
+func NewContestCloner(contestService *ContestService, problemService *ProblemService, webhookService *ContestWebhookService) *ContestCloner {
+	return &ContestCloner{contestService: contestService, problemService: problemService, webhookService: webhookService}
+}
+
+// Clone creates the copy of the contest, which starts upcoming.
+func (c *ContestCloner) Clone(contestID string, clone ContestClone) (Contest, error) {
+	original, err := c.contestService.Get(contestID)
+	if err != nil {
+		return Contest{}, err
+	}
+	if clone.StartTime.IsZero() {
+		return Contest{}, ErrInvalidContestTimes
+	}
+
+	shift := clone.StartTime.Sub(original.StartTime)
+	contest := Contest{
+		Title:     original.Title,
+		StartTime: clone.StartTime,
+		EndTime:   original.EndTime.Add(shift),
+		Problems:  append([]string{}, original.Problems...),
+	}
+	if clone.Title != "" {
+		contest.Title = clone.Title
+	}
+	if original.FreezeTime != nil {
+		freeze := original.FreezeTime.Add(shift)
+		contest.FreezeTime = &freeze
+	}
+	if clone.FreshProblems {
+		for i, problemID := range contest.Problems {
+			problem, err := c.problemService.Get(problemID)
+			if err != nil {
+				return Contest{}, err
+			}
+			copied, err := c.problemService.Clone(problemID, ProblemClone{Title: problem.Title, WithTests: true})
+			if err != nil {
+				return Contest{}, err
+			}
+			contest.Problems[i] = copied.ID
+		}
+	}
+
+	contest, err = c.contestService.Create(contest)
+	if err != nil {
+		return Contest{}, err
+	}
+	webhook, err := c.webhookService.get(contestID)
+	if errors.Is(err, ErrWebhookNotFound) {
+		return contest, nil
+	}
+	if err != nil {
+		return Contest{}, err
+	}
+	webhook.ContestID = contest.ID
+	if _, err := c.webhookService.Set(webhook); err != nil {
+		return Contest{}, err
+	}
+	return contest, nil
+}
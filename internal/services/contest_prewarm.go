@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"online-judge/internal/judge"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadinessCheck is one item of a worker's readiness checklist.
+type ReadinessCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type WorkerReadiness struct {
+	URL    string           `json:"url"`
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// PrewarmReport is the readiness checklist of every judge worker for a
+// contest. Ready is set once every check on every worker passed.
+type PrewarmReport struct {
+	ContestID string            `json:"contestId"`
+	Ready     bool              `json:"ready"`
+	Workers   []WorkerReadiness `json:"workers"`
+	CheckedAt time.Time         `json:"checkedAt"`
+}
+
+// ContestPrewarmer prepares the judge workers for a contest before it
+// begins: each worker downloads the contest's object-stored test data,
+// builds every checker and reports its toolchain, and the outcome is
+// collected into a checklist.
+type ContestPrewarmer struct {
+	contestService *ContestService
+	problemService *ProblemService
+	workers        []string
+}
+
+func NewContestPrewarmer(contestService *ContestService, problemService *ProblemService, workers []string) *ContestPrewarmer {
+	return &ContestPrewarmer{contestService: contestService, problemService: problemService, workers: workers}
+}
+
+// JudgeWorkersFromEnv reads JUDGE_WORKER_URLS, a comma-separated list of the
+// individual judge workers. It defaults to JUDGE_URL, which may be a load
+// balancer, in which case only the worker behind it that answers is
+// checked.
+func JudgeWorkersFromEnv() []string {
+	var workers []string
+	for _, url := range strings.Split(os.Getenv("JUDGE_WORKER_URLS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			workers = append(workers, url)
+		}
+	}
+	if len(workers) > 0 {
+		return workers
+	}
+	if url := os.Getenv("JUDGE_URL"); url != "" {
+		return []string{url}
+	}
+	return []string{"http://localhost:8081"}
+}
+
+// Prewarm runs the checklist on every worker in parallel.
+func (p *ContestPrewarmer) Prewarm(ctx context.Context, contestID string) (PrewarmReport, error) {
+	contest, err := p.contestService.Get(contestID)
+	if err != nil {
+		return PrewarmReport{}, err
+	}
+	// without object storage the data cannot be fetched; every worker's
+	// test data check then fails with this error
+	refs, refsErr := contestInputRefs(p.problemService, contest)
+	if refsErr != nil && !errors.Is(refsErr, ErrObjectStorageDisabled) {
+		return PrewarmReport{}, refsErr
+	}
+	checkers := make(map[string]*Checker)
+	for _, problemID := range contest.Problems {
+		problem, err := p.problemService.Get(problemID)
+		if err != nil {
+			return PrewarmReport{}, err
+		}
+		if problem.Checker != nil {
+			checkers[problemID] = problem.Checker
+		}
+	}
+
+	report := PrewarmReport{ContestID: contestID, Ready: true, Workers: make([]WorkerReadiness, len(p.workers))}
+	var wg sync.WaitGroup
+	for i, url := range p.workers {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			report.Workers[i] = p.checkWorker(ctx, url, refs, refsErr, checkers)
+		}(i, url)
+	}
+	wg.Wait()
+
+	for _, worker := range report.Workers {
+		report.Ready = report.Ready && worker.Ready
+	}
+	report.CheckedAt = time.Now()
+	return report, nil
+}
+
+func (p *ContestPrewarmer) checkWorker(ctx context.Context, url string, refs []judge.DataRef, refsErr error, checkers map[string]*Checker) WorkerReadiness {
+	client := judge.NewClient(url)
+	worker := WorkerReadiness{URL: url, Ready: true}
+	add := func(check ReadinessCheck) {
+		worker.Checks = append(worker.Checks, check)
+		worker.Ready = worker.Ready && check.OK
+	}
+
+	add(toolchainCheck(ctx, client))
+	switch {
+	case refsErr != nil:
+		add(ReadinessCheck{Name: "test data", Detail: refsErr.Error()})
+	case len(refs) > 0:
+		add(testDataCheck(ctx, client, refs))
+	}
+
+	problemIDs := make([]string, 0, len(checkers))
+	for problemID := range checkers {
+		problemIDs = append(problemIDs, problemID)
+	}
+	sort.Strings(problemIDs)
+	for _, problemID := range problemIDs {
+		add(checkerCheck(ctx, client, problemID, checkers[problemID]))
+	}
+	return worker
+}
+
+func toolchainCheck(ctx context.Context, client *judge.Client) ReadinessCheck {
+	check := ReadinessCheck{Name: "toolchain"}
+	status, err := client.Toolchain(ctx)
+	switch {
+	case err != nil:
+		check.Detail = err.Error()
+	case len(status.Modified) > 0 || len(status.Missing) > 0:
+		var problems []string
+		for binary, reason := range status.Modified {
+			problems = append(problems, binary+": "+reason)
+		}
+		sort.Strings(problems)
+		for _, binary := range status.Missing {
+			problems = append(problems, binary+": missing")
+		}
+		check.Detail = strings.Join(problems, "; ")
+	default:
+		check.OK = true
+		check.Detail = fmt.Sprintf("%d binaries match their pins", len(status.Pins))
+	}
+	return check
+}
+
+func testDataCheck(ctx context.Context, client *judge.Client, refs []judge.DataRef) ReadinessCheck {
+	check := ReadinessCheck{Name: "test data"}
+	results, err := client.PrefetchAndWait(ctx, refs)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	var failed []string
+	for _, result := range results {
+		if result.Error != "" {
+			failed = append(failed, result.Version+"/"+result.Name+": "+result.Error)
+		}
+	}
+	if len(failed) > 0 {
+		check.Detail = strings.Join(failed, "; ")
+		return check
+	}
+	check.OK = true
+	check.Detail = fmt.Sprintf("%d files cached", len(results))
+	return check
+}
+
+// checkerCheck builds the checker into the worker's binary cache by running
+// it on empty files; only a compile error or a failed request fails the
+// check.
+func checkerCheck(ctx context.Context, client *judge.Client, problemID string, checker *Checker) ReadinessCheck {
+	check := ReadinessCheck{Name: "checker for problem " + problemID}
+	result, err := client.Execute(ctx, judge.Submission{
+		Language:    checker.Language,
+		Code:        checker.Source,
+		TimeLimit:   checkerTimeLimit,
+		MemoryLimit: checkerMemoryLimit,
+		CacheBinary: true,
+		Files:       map[string]string{"input.txt": "", "answer.txt": "", "output.txt": ""},
+	})
+	switch {
+	case err != nil:
+		check.Detail = err.Error()
+	case result.Status.CompileFailed():
+		check.Detail = result.CompileOutput
+	case result.CompileTime == 0:
+		check.OK = true
+		check.Detail = "build cached"
+	default:
+		check.OK = true
+		check.Detail = fmt.Sprintf("compiled in %.2fs", result.CompileTime)
+	}
+	return check
+}
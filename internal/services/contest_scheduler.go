@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"online-judge/internal/clock"
+	"online-judge/internal/store"
+	"sync"
+	"time"
+)
+
+const schedulerLeaseKey = "lease:contest-scheduler"
+
+// ContestTransition describes a contest moving from one lifecycle state to another.
+type ContestTransition struct {
+	Contest Contest
+	From    ContestStatus
+	To      ContestStatus
+	At      time.Time
+}
+
+// ContestTransitionListener is notified after every automatic transition.
+type ContestTransitionListener func(ContestTransition)
+
+// SystemTester runs the post-contest system-test phase for a finished contest.
+// StartSystemTest is called again for a contest whose system test is still
+// running when a replica takes over the scheduler lease, so it must
+// continue the phase without repeating finished work.
+type SystemTester interface {
+	StartSystemTest(contest Contest) error
+}
+
+// ContestScheduler periodically moves contests through
+// upcoming → running → frozen → finished according to their configured times
+// and starts system testing once a contest finishes. When several API
+// replicas run, only the one holding the scheduler lease applies transitions.
+type ContestScheduler struct {
+	contestService *ContestService
+	systemTester   SystemTester
+	store          store.Store
+	instanceID     string
+	interval       time.Duration
+	clock          clock.Clock
+	listeners      []ContestTransitionListener
+	// leading is whether this replica held the lease at the last tick.
+	leading bool
+}
+
+func NewContestScheduler(contestService *ContestService, systemTester SystemTester, st store.Store, instanceID string, interval time.Duration) *ContestScheduler {
+	return &ContestScheduler{
+		contestService: contestService,
+		systemTester:   systemTester,
+		store:          st,
+		instanceID:     instanceID,
+		interval:       interval,
+		clock:          clock.System,
+	}
+}
+
+// SetClock replaces the wall clock, e.g. with a clock.Manual in tests.
+func (s *ContestScheduler) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// OnTransition registers a listener. It must be called before Run.
+func (s *ContestScheduler) OnTransition(listener ContestTransitionListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// Run ticks until ctx is cancelled.
+func (s *ContestScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.tickIfLeader(s.clock.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tickIfLeader(s.clock.Now())
+		}
+	}
+}
+
+func (s *ContestScheduler) tickIfLeader(now time.Time) {
+	leader, err := s.acquireLease()
+	if err != nil {
+		log.Printf("Error acquiring contest scheduler lease: %v", err)
+		return
+	}
+	if leader && !s.leading {
+		s.resumeSystemTests()
+	}
+	s.leading = leader
+	if leader {
+		s.Tick(now)
+	}
+}
+
+// resumeSystemTests continues the system tests a previous leader started
+// but did not record an outcome for, e.g. because its replica crashed.
+func (s *ContestScheduler) resumeSystemTests() {
+	if s.systemTester == nil {
+		return
+	}
+	contests, err := s.contestService.List()
+	if err != nil {
+		log.Printf("Error listing contests to resume system tests: %v", err)
+		return
+	}
+	for _, contest := range contests {
+		if contest.Status == ContestFinished && contest.SystemTest == SystemTestRunning {
+			log.Printf("Resuming system test for contest %s", contest.ID)
+			s.startSystemTest(contest)
+		}
+	}
+}
+
+// acquireLease takes or renews the scheduler lease. The lease outlives a few
+// ticks so a crashed leader is replaced quickly.
+func (s *ContestScheduler) acquireLease() (bool, error) {
+	ttl := 3 * s.interval
+	ok, err := s.store.SetNX(schedulerLeaseKey, []byte(s.instanceID), ttl)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	holder, err := s.store.Get(schedulerLeaseKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if string(holder) != s.instanceID {
+		return false, nil
+	}
+	return true, s.store.Expire(schedulerLeaseKey, ttl)
+}
+
+// Tick applies every transition that is due at now.
+func (s *ContestScheduler) Tick(now time.Time) {
+	contests, err := s.contestService.List()
+	if err != nil {
+		log.Printf("Error listing contests for scheduling: %v", err)
+		return
+	}
+
+	for _, contest := range contests {
+		next := contest.StatusAt(now)
+		if !statusAdvances(contest.Status, next) {
+			continue
+		}
+
+		from := contest.Status
+		updated, err := s.contestService.update(contest.ID, func(c *Contest) {
+			c.Status = next
+			if next == ContestFinished && s.systemTester != nil {
+				c.SystemTest = SystemTestRunning
+			}
+		})
+		if err != nil {
+			log.Printf("Error transitioning contest %s: %v", contest.ID, err)
+			continue
+		}
+
+		transition := ContestTransition{Contest: updated, From: from, To: next, At: now}
+		for _, listener := range s.listeners {
+			listener(transition)
+		}
+
+		if next == ContestFinished && s.systemTester != nil {
+			s.startSystemTest(updated)
+		}
+	}
+}
+
+func (s *ContestScheduler) startSystemTest(contest Contest) {
+	if err := s.systemTester.StartSystemTest(contest); err != nil {
+		log.Printf("Error starting system test for contest %s: %v", contest.ID, err)
+		s.contestService.SetSystemTestStatus(contest.ID, SystemTestFailed)
+	}
+}
+
+var contestStatusOrder = map[ContestStatus]int{
+	ContestUpcoming: 0,
+	ContestRunning:  1,
+	ContestFrozen:   2,
+	ContestFinished: 3,
+}
+
+// statusAdvances reports whether moving from current to next goes forward;
+// the scheduler never moves a contest backwards.
+func statusAdvances(current, next ContestStatus) bool {
+	return contestStatusOrder[next] > contestStatusOrder[current]
+}
+
+// SystemTestJob re-evaluates a finished contest, e.g. by rejudging its
+// submissions against the full test set.
+type SystemTestJob func(contest Contest) error
+
+// SystemTestPhase is the default SystemTester. It runs every registered job in
+// the background and records the outcome on the contest.
+type SystemTestPhase struct {
+	contestService *ContestService
+	jobs           []SystemTestJob
+	onComplete     []func(contest Contest)
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewSystemTestPhase(contestService *ContestService) *SystemTestPhase {
+	return &SystemTestPhase{contestService: contestService, running: make(map[string]bool)}
+}
+
+// AddJob registers a job. It must be called before the scheduler starts.
+// Jobs are run again when a system test is resumed, so they must skip the
+// work a previous run finished.
+func (p *SystemTestPhase) AddJob(job SystemTestJob) {
+	p.jobs = append(p.jobs, job)
+}
+
+// OnComplete registers a callback invoked with the updated contest once all
+// jobs have finished. It must be called before the scheduler starts.
+func (p *SystemTestPhase) OnComplete(fn func(contest Contest)) {
+	p.onComplete = append(p.onComplete, fn)
+}
+
+// StartSystemTest runs the jobs for the contest unless this replica is
+// already running them.
+func (p *SystemTestPhase) StartSystemTest(contest Contest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running[contest.ID] {
+		return nil
+	}
+	p.running[contest.ID] = true
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.running, contest.ID)
+			p.mu.Unlock()
+		}()
+
+		status := SystemTestDone
+		for _, job := range p.jobs {
+			if err := job(contest); err != nil {
+				log.Printf("System test job failed for contest %s: %v", contest.ID, err)
+				status = SystemTestFailed
+				break
+			}
+		}
+		updated, err := p.contestService.SetSystemTestStatus(contest.ID, status)
+		if err != nil {
+			log.Printf("Error recording system test status for contest %s: %v", contest.ID, err)
+			return
+		}
+		for _, fn := range p.onComplete {
+			fn(updated)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,66 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"online-judge/internal/services"
+	"online-judge/internal/store"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerResumesRunningSystemTest(t *testing.T) {
+	st := store.NewMemoryStore()
+	contests := services.NewContestService(st)
+
+	// the leader that set the system test running crashed before it ended
+	now := time.Now().UTC()
+	data, err := json.Marshal([]services.Contest{{
+		ID: "1", Title: "Interrupted", StartTime: now.Add(-3 * time.Hour), EndTime: now.Add(-time.Hour),
+		Status: services.ContestFinished, SystemTest: services.SystemTestRunning,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := contests.ImportSnapshot(data); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs atomic.Int32
+	completed := make(chan services.Contest, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, instanceID := range []string{"a", "b"} {
+		phase := services.NewSystemTestPhase(contests)
+		phase.AddJob(func(services.Contest) error {
+			runs.Add(1)
+			return nil
+		})
+		phase.OnComplete(func(contest services.Contest) { completed <- contest })
+		scheduler := services.NewContestScheduler(contests, phase, st, instanceID, 20*time.Millisecond)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scheduler.Run(ctx)
+		}()
+	}
+
+	select {
+	case contest := <-completed:
+		if contest.SystemTest != services.SystemTestDone {
+			t.Errorf("system test status = %q, want %q", contest.SystemTest, services.SystemTestDone)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("system test was not resumed")
+	}
+	// several more ticks, by the leader and the replica waiting for the lease
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	if n := runs.Load(); n != 1 {
+		t.Errorf("system test jobs ran %d times, want 1", n)
+	}
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"online-judge/internal/clock"
+	"online-judge/internal/store"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrContestNotFound     = errors.New("contest not found")
+	ErrInvalidContestTimes = errors.New("contest times must satisfy start < freeze <= end")
+	ErrRegistrationClosed  = errors.New("contest registration is closed")
+	ErrContestNotFinished  = errors.New("contest has not finished yet")
+	ErrVirtualExists       = errors.New("virtual participation already started")
+)
+
+type ContestStatus string
+
+const (
+	ContestUpcoming ContestStatus = "upcoming"
+	ContestRunning  ContestStatus = "running"
+	ContestFrozen   ContestStatus = "frozen"
+	ContestFinished ContestStatus = "finished"
+)
+
+type SystemTestStatus string
+
+const (
+	SystemTestNone    SystemTestStatus = ""
+	SystemTestRunning SystemTestStatus = "running"
+	SystemTestDone    SystemTestStatus = "done"
+	SystemTestFailed  SystemTestStatus = "failed"
+)
+
+type Contest struct {
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	StartTime  time.Time        `json:"startTime"`
+	FreezeTime *time.Time       `json:"freezeTime,omitempty"`
+	EndTime    time.Time        `json:"endTime"`
+	Problems   []string         `json:"problems"`
+	Status     ContestStatus    `json:"status"`
+	SystemTest SystemTestStatus `json:"systemTest,omitempty"`
+	// Resolved lifts the freeze once the contest is over, typically after
+	// the award ceremony; until then standings hide results of
+	// submissions made from FreezeTime on.
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+// freezeCutoff returns the time from which submissions' results are hidden
+// from contestants and the public, if the contest has an unresolved freeze.
+func (c Contest) freezeCutoff() (time.Time, bool) {
+	if c.FreezeTime == nil || c.Resolved {
+		return time.Time{}, false
+	}
+	return *c.FreezeTime, true
+}
+
+// resultsPublic reports whether the contest's results may be published:
+// it has finished and any freeze has been resolved.
+func (c Contest) resultsPublic() bool {
+	_, frozen := c.freezeCutoff()
+	return c.Status == ContestFinished && !frozen
+}
+
+// StatusAt returns the lifecycle state the contest should be in at the given time.
+func (c Contest) StatusAt(now time.Time) ContestStatus {
+	switch {
+	case now.Before(c.StartTime):
+		return ContestUpcoming
+	case !now.Before(c.EndTime):
+		return ContestFinished
+	case c.FreezeTime != nil && !now.Before(*c.FreezeTime):
+		return ContestFrozen
+	default:
+		return ContestRunning
+	}
+}
+
+const (
+	contestKeyPrefix          = "contest:"
+	contestIDKey              = "counter:contest"
+	contestRegistrationPrefix = "registration:contest:"
+	contestVirtualPrefix      = "virtual:contest:"
+)
+
+// VirtualParticipation is a user replaying a finished contest on their own
+// clock, with the contest's original duration.
+type VirtualParticipation struct {
+	ContestID string    `json:"contestId"`
+	UserID    string    `json:"userId"`
+	StartedAt time.Time `json:"startedAt"`
+	EndsAt    time.Time `json:"endsAt"`
+}
+
+// ActiveAt reports whether the virtual contest is running at the given time.
+func (v VirtualParticipation) ActiveAt(now time.Time) bool {
+	return !now.Before(v.StartedAt) && now.Before(v.EndsAt)
+}
+
+// AcceptsSubmissions reports whether contest submissions are open.
+func (c Contest) AcceptsSubmissions() bool {
+	return c.Status == ContestRunning || c.Status == ContestFrozen
+}
+
+// HasProblem reports whether problemID is part of the contest.
+func (c Contest) HasProblem(problemID string) bool {
+	for _, id := range c.Problems {
+		if id == problemID {
+			return true
+		}
+	}
+	return false
+}
+
+// ContestService keeps contests in the shared store so every API replica sees
+// the same state.
+type ContestService struct {
+	store store.Store
+	clock clock.Clock
+}
+
+func NewContestService(st store.Store) *ContestService {
+	return &ContestService{store: st, clock: clock.System}
+}
+
+// SetClock replaces the wall clock, e.g. with a clock.Manual in tests.
+func (s *ContestService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+func (s *ContestService) Create(contest Contest) (Contest, error) {
+	if !contest.StartTime.Before(contest.EndTime) {
+		return Contest{}, ErrInvalidContestTimes
+	}
+	if f := contest.FreezeTime; f != nil && (!f.After(contest.StartTime) || f.After(contest.EndTime)) {
+		return Contest{}, ErrInvalidContestTimes
+	}
+
+	id, err := s.store.Incr(contestIDKey)
+	if err != nil {
+		return Contest{}, err
+	}
+	contest.ID = strconv.FormatInt(id, 10)
+	contest.Status = ContestUpcoming
+	contest.SystemTest = SystemTestNone
+	if err := setJSON(s.store, contestKeyPrefix+contest.ID, contest, 0); err != nil {
+		return Contest{}, err
+	}
+	return contest, nil
+}
+
+func (s *ContestService) Get(id string) (Contest, error) {
+	var contest Contest
+	if err := getJSON(s.store, contestKeyPrefix+id, &contest); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return Contest{}, ErrContestNotFound
+		}
+		return Contest{}, err
+	}
+	return contest, nil
+}
+
+func (s *ContestService) List() ([]Contest, error) {
+	contests, err := listJSON[Contest](s.store, contestKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(contests, func(i, j int) bool {
+		return contests[i].StartTime.Before(contests[j].StartTime)
+	})
+	return contests, nil
+}
+
+// update applies fn to the stored contest and saves it. Contest writes are
+// rare and, for automatic transitions, serialized by the scheduler lease, so
+// a read-modify-write is sufficient.
+func (s *ContestService) update(id string, fn func(*Contest)) (Contest, error) {
+	contest, err := s.Get(id)
+	if err != nil {
+		return Contest{}, err
+	}
+	fn(&contest)
+	if err := setJSON(s.store, contestKeyPrefix+id, contest, 0); err != nil {
+		return Contest{}, err
+	}
+	return contest, nil
+}
+
+// Resolve lifts a finished contest's freeze, so its standings show every
+// result.
+func (s *ContestService) Resolve(id string) (Contest, error) {
+	contest, err := s.Get(id)
+	if err != nil {
+		return Contest{}, err
+	}
+	if contest.Status != ContestFinished {
+		return Contest{}, ErrContestNotFinished
+	}
+	return s.update(id, func(contest *Contest) {
+		contest.Resolved = true
+	})
+}
+
+// Register signs a user up for a contest. Registration closes when the
+// contest finishes.
+func (s *ContestService) Register(contestID, userID string) error {
+	contest, err := s.Get(contestID)
+	if err != nil {
+		return err
+	}
+	if contest.Status == ContestFinished {
+		return ErrRegistrationClosed
+	}
+	return s.store.Set(registrationKey(contestID, userID), []byte("1"), 0)
+}
+
+func (s *ContestService) IsRegistered(contestID, userID string) (bool, error) {
+	_, err := s.store.Get(registrationKey(contestID, userID))
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func registrationKey(contestID, userID string) string {
+	return contestRegistrationPrefix + contestID + ":" + userID
+}
+
+// RegistrationCount returns how many users registered for the contest.
+func (s *ContestService) RegistrationCount(contestID string) (int, error) {
+	keys, err := s.store.Keys(contestRegistrationPrefix + contestID + ":")
+	return len(keys), err
+}
+
+// Registrants returns the IDs of the users registered for the contest.
+func (s *ContestService) Registrants(contestID string) ([]string, error) {
+	prefix := contestRegistrationPrefix + contestID + ":"
+	keys, err := s.store.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		userIDs = append(userIDs, strings.TrimPrefix(key, prefix))
+	}
+	sort.Strings(userIDs)
+	return userIDs, nil
+}
+
+// StartVirtual starts a virtual participation in a finished contest. Each
+// user gets one virtual run per contest.
+func (s *ContestService) StartVirtual(contestID, userID string) (VirtualParticipation, error) {
+	contest, err := s.Get(contestID)
+	if err != nil {
+		return VirtualParticipation{}, err
+	}
+	if contest.Status != ContestFinished {
+		return VirtualParticipation{}, ErrContestNotFinished
+	}
+
+	now := s.clock.Now()
+	virtual := VirtualParticipation{
+		ContestID: contestID,
+		UserID:    userID,
+		StartedAt: now,
+		EndsAt:    now.Add(contest.EndTime.Sub(contest.StartTime)),
+	}
+	data, err := json.Marshal(virtual)
+	if err != nil {
+		return VirtualParticipation{}, err
+	}
+	ok, err := s.store.SetNX(virtualKey(contestID, userID), data, 0)
+	if err != nil {
+		return VirtualParticipation{}, err
+	}
+	if !ok {
+		return VirtualParticipation{}, ErrVirtualExists
+	}
+	return virtual, nil
+}
+
+// Virtual returns the user's virtual participation in the contest, if any.
+func (s *ContestService) Virtual(contestID, userID string) (VirtualParticipation, bool, error) {
+	var virtual VirtualParticipation
+	err := getJSON(s.store, virtualKey(contestID, userID), &virtual)
+	if errors.Is(err, store.ErrNotFound) {
+		return VirtualParticipation{}, false, nil
+	}
+	return virtual, err == nil, err
+}
+
+// VirtualParticipations lists every virtual participation in the contest.
+func (s *ContestService) VirtualParticipations(contestID string) ([]VirtualParticipation, error) {
+	return listJSON[VirtualParticipation](s.store, contestVirtualPrefix+contestID+":")
+}
+
+func virtualKey(contestID, userID string) string {
+	return contestVirtualPrefix + contestID + ":" + userID
+}
+
+func (s *ContestService) SetSystemTestStatus(id string, status SystemTestStatus) (Contest, error) {
+	return s.update(id, func(c *Contest) { c.SystemTest = status })
+}
+
+func (s *ContestService) SnapshotName() string {
+	return "contests"
+}
+
+func (s *ContestService) ExportSnapshot() (json.RawMessage, error) {
+	contests, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(contests)
+}
+
+func (s *ContestService) ImportSnapshot(data json.RawMessage) error {
+	var contests []Contest
+	if err := json.Unmarshal(data, &contests); err != nil {
+		return err
+	}
+
+	if err := replaceJSON(s.store, contestKeyPrefix, contests, func(c Contest) string { return c.ID }); err != nil {
+		return err
+	}
+	ids := make([]string, len(contests))
+	for i, contest := range contests {
+		ids[i] = contest.ID
+	}
+	return restoreCounter(s.store, contestIDKey, ids)
+}
+
+// RegistrationSnapshot returns the backup section holding contest
+// registrations, which are stored separately from the contests themselves.
+func (s *ContestService) RegistrationSnapshot() SnapshotSection {
+	return registrationSnapshot{store: s.store}
+}
+
+type registrationSnapshot struct {
+	store store.Store
+}
+
+type contestRegistration struct {
+	ContestID string `json:"contestId"`
+	UserID    string `json:"userId"`
+}
+
+func (r registrationSnapshot) SnapshotName() string {
+	return "contestRegistrations"
+}
+
+func (r registrationSnapshot) ExportSnapshot() (json.RawMessage, error) {
+	keys, err := r.store.Keys(contestRegistrationPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	registrations := make([]contestRegistration, 0, len(keys))
+	for _, key := range keys {
+		contestID, userID, ok := strings.Cut(strings.TrimPrefix(key, contestRegistrationPrefix), ":")
+		if ok {
+			registrations = append(registrations, contestRegistration{ContestID: contestID, UserID: userID})
+		}
+	}
+	return json.Marshal(registrations)
+}
+
+func (r registrationSnapshot) ImportSnapshot(data json.RawMessage) error {
+	var registrations []contestRegistration
+	if err := json.Unmarshal(data, &registrations); err != nil {
+		return err
+	}
+
+	keys, err := r.store.Keys(contestRegistrationPrefix)
+	if err != nil {
+		return err
+	}
+	if err := r.store.Delete(keys...); err != nil {
+		return err
+	}
+	for _, registration := range registrations {
+		if err := r.store.Set(registrationKey(registration.ContestID, registration.UserID), []byte("1"), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VirtualSnapshot returns the backup section holding virtual
+// participations.
+func (s *ContestService) VirtualSnapshot() SnapshotSection {
+	return virtualSnapshot{store: s.store}
+}
+
+type virtualSnapshot struct {
+	store store.Store
+}
+
+func (v virtualSnapshot) SnapshotName() string {
+	return "virtualParticipations"
+}
+
+func (v virtualSnapshot) ExportSnapshot() (json.RawMessage, error) {
+	participations, err := listJSON[VirtualParticipation](v.store, contestVirtualPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(participations)
+}
+
+func (v virtualSnapshot) ImportSnapshot(data json.RawMessage) error {
+	var participations []VirtualParticipation
+	if err := json.Unmarshal(data, &participations); err != nil {
+		return err
+	}
+	return replaceJSON(v.store, contestVirtualPrefix, participations,
+		func(p VirtualParticipation) string { return p.ContestID + ":" + p.UserID })
+}
+
+// RegistrationUserData returns the privacy section holding the user's
+// contest registrations.
+func (s *ContestService) RegistrationUserData() UserDataSection {
+	return userKeySection{store: s.store, name: "contestRegistrations", prefixes: []string{contestRegistrationPrefix}, marker: ":"}
+}
+
+// VirtualUserData returns the privacy section holding the user's virtual
+// participations.
+func (s *ContestService) VirtualUserData() UserDataSection {
+	return userKeySection{store: s.store, name: "virtualParticipations", prefixes: []string{contestVirtualPrefix}, marker: ":"}
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"online-judge/internal/store"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound   = errors.New("contest webhook not found")
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an absolute http or https URL")
+)
+
+// ContestWebhook receives a contest's judgements for an external
+// scoreboard or resolver. Each delivery is a JSON array of events signed
+// with Secret in the X-Webhook-Signature header.
+type ContestWebhook struct {
+	ContestID string `json:"contestId"`
+	URL       string `json:"url" binding:"required"`
+	Secret    string `json:"secret,omitempty"`
+}
+
+// WebhookEvent is an entry of a CLICS-style event feed.
+type WebhookEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Op   string `json:"op"`
+	Data any    `json:"data"`
+}
+
+// clicsSubmission and clicsJudgement follow the Contest API objects of the
+// same name; team_id is the submitting user.
+type clicsSubmission struct {
+	ID          string `json:"id"`
+	LanguageID  string `json:"language_id"`
+	ProblemID   string `json:"problem_id"`
+	TeamID      string `json:"team_id"`
+	Time        string `json:"time"`
+	ContestTime string `json:"contest_time"`
+}
+
+type clicsJudgement struct {
+	ID               string  `json:"id"`
+	SubmissionID     string  `json:"submission_id"`
+	JudgementTypeID  string  `json:"judgement_type_id"`
+	Score            float64 `json:"score"`
+	StartTime        string  `json:"start_time"`
+	StartContestTime string  `json:"start_contest_time"`
+	EndTime          string  `json:"end_time"`
+	EndContestTime   string  `json:"end_contest_time"`
+	MaxRunTime       float64 `json:"max_run_time"`
+}
+
+// clicsJudgementTypes maps verdicts to Contest API judgement type IDs.
+// Partial scores have no type of their own and are sent as WA with a score.
+var clicsJudgementTypes = map[Verdict]string{
+	VerdictAccepted:                 "AC",
+	VerdictPartial:                  "WA",
+	VerdictWrongAnswer:              "WA",
+	VerdictTimeLimitExceeded:        "TLE",
+	VerdictMemoryLimitExceeded:      "MLE",
+	VerdictOutputLimitExceeded:      "OLE",
+	VerdictDiskQuotaExceeded:        "RTE",
+	VerdictRuntimeError:             "RTE",
+	VerdictCompileError:             "CE",
+	VerdictCompileTimeLimitExceeded: "CTL",
+	VerdictInternalError:            "JE",
+	VerdictSecurityViolation:        "RTE",
+}
+
+const (
+	webhookKeyPrefix    = "webhook:contest:"
+	webhookEventsPrefix = "webhook:events:"
+	webhookLockPrefix   = "webhook:lock:"
+	webhookEventIDKey   = "counter:webhook-event"
+	// webhookBatchSize caps the events sent in one delivery.
+	webhookBatchSize = 100
+)
+
+// ContestWebhookService queues judgement events for contests with a
+// webhook and delivers them in batches. Events are queued in the store, so
+// any replica may deliver them; a short lock per contest keeps deliveries
+// in order.
+type ContestWebhookService struct {
+	store          store.Store
+	contestService *ContestService
+	client         *http.Client
+	interval       time.Duration
+}
+
+func NewContestWebhookService(st store.Store, contestService *ContestService, interval time.Duration) *ContestWebhookService {
+	return &ContestWebhookService{
+		store:          st,
+		contestService: contestService,
+		client:         &http.Client{Timeout: 10 * time.Second},
+		interval:       interval,
+	}
+}
+
+// WebhookIntervalFromEnv reads CONTEST_WEBHOOK_INTERVAL_SECONDS, the time
+// events are collected before a batch is sent; it defaults to 2 seconds.
+func WebhookIntervalFromEnv() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("CONTEST_WEBHOOK_INTERVAL_SECONDS")); err == nil && value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return 2 * time.Second
+}
+
+// Set configures the contest's webhook, replacing any previous one. Events
+// already queued are delivered to the new URL.
+func (s *ContestWebhookService) Set(webhook ContestWebhook) (ContestWebhook, error) {
+	if _, err := s.contestService.Get(webhook.ContestID); err != nil {
+		return ContestWebhook{}, err
+	}
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ContestWebhook{}, ErrInvalidWebhookURL
+	}
+	if err := setJSON(s.store, webhookKeyPrefix+webhook.ContestID, webhook, 0); err != nil {
+		return ContestWebhook{}, err
+	}
+	return webhook, nil
+}
+
+// Get returns the contest's webhook. The secret is not returned.
+func (s *ContestWebhookService) Get(contestID string) (ContestWebhook, error) {
+	webhook, err := s.get(contestID)
+	webhook.Secret = ""
+	return webhook, err
+}
+
+func (s *ContestWebhookService) get(contestID string) (ContestWebhook, error) {
+	var webhook ContestWebhook
+	if err := getJSON(s.store, webhookKeyPrefix+contestID, &webhook); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ContestWebhook{}, ErrWebhookNotFound
+		}
+		return ContestWebhook{}, err
+	}
+	return webhook, nil
+}
+
+// Delete removes the contest's webhook and drops its undelivered events.
+func (s *ContestWebhookService) Delete(contestID string) error {
+	if _, err := s.get(contestID); err != nil {
+		return err
+	}
+	return s.store.Delete(webhookKeyPrefix+contestID, webhookEventsPrefix+contestID)
+}
+
+// Record is a GradedListener that queues a submission and its judgement
+// for the contest's webhook. Practice and virtual submissions are skipped.
+func (s *ContestWebhookService) Record(submission Submission, _ Problem) {
+	if submission.ContestID == "" || submission.Virtual || submission.JudgedAt == nil {
+		return
+	}
+	if _, err := s.get(submission.ContestID); err != nil {
+		if !errors.Is(err, ErrWebhookNotFound) {
+			log.Printf("Error loading webhook for contest %s: %v", submission.ContestID, err)
+		}
+		return
+	}
+	contest, err := s.contestService.Get(submission.ContestID)
+	if err != nil {
+		log.Printf("Error loading contest %s for webhook: %v", submission.ContestID, err)
+		return
+	}
+
+	events := []struct {
+		kind string
+		data any
+	}{
+		{"submissions", clicsSubmissionOf(submission, contest)},
+		{"judgements", clicsJudgementOf(submission, contest)},
+	}
+	for _, event := range events {
+		if err := s.enqueue(submission.ContestID, event.kind, event.data); err != nil {
+			log.Printf("Error queueing webhook event for submission %s: %v", submission.ID, err)
+			return
+		}
+	}
+}
+
+func clicsSubmissionOf(submission Submission, contest Contest) clicsSubmission {
+	return clicsSubmission{
+		ID:          submission.ID,
+		LanguageID:  submission.Language,
+		ProblemID:   submission.ProblemID,
+		TeamID:      submission.UserID,
+		Time:        clicsTime(submission.CreatedAt),
+		ContestTime: clicsContestTime(submission.CreatedAt.Sub(contest.StartTime)),
+	}
+}
+
+// clicsJudgementOf describes a judged submission; judging starts when it
+// leaves the queue.
+func clicsJudgementOf(submission Submission, contest Contest) clicsJudgement {
+	start := submission.CreatedAt
+	if submission.Timing != nil {
+		start = start.Add(time.Duration(submission.Timing.QueueWait * float64(time.Second)))
+	}
+	maxRunTime := 0.0
+	for _, result := range submission.Results {
+		maxRunTime = max(maxRunTime, result.Time)
+	}
+	return clicsJudgement{
+		ID:               submission.ID,
+		SubmissionID:     submission.ID,
+		JudgementTypeID:  clicsJudgementTypes[submission.Verdict],
+		Score:            submission.Score,
+		StartTime:        clicsTime(start),
+		StartContestTime: clicsContestTime(start.Sub(contest.StartTime)),
+		EndTime:          clicsTime(*submission.JudgedAt),
+		EndContestTime:   clicsContestTime(submission.JudgedAt.Sub(contest.StartTime)),
+		MaxRunTime:       maxRunTime,
+	}
+}
+
+func (s *ContestWebhookService) enqueue(contestID, kind string, data any) error {
+	id, err := s.store.Incr(webhookEventIDKey)
+	if err != nil {
+		return err
+	}
+	// A judgement replaces the previous one after a rejudge, so it is
+	// always sent as an update.
+	event, err := json.Marshal(WebhookEvent{ID: strconv.FormatInt(id, 10), Type: kind, Op: "update", Data: data})
+	if err != nil {
+		return err
+	}
+	return s.store.Push(webhookEventsPrefix+contestID, event)
+}
+
+func clicsTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// clicsContestTime formats a duration as the Contest API's RELTIME,
+// h:mm:ss.uuu.
+func clicsContestTime(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%s%d:%02d:%02d.%03d", sign, ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// Run delivers queued events every interval until ctx is cancelled.
+func (s *ContestWebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.flushAll(ctx)
+		}
+	}
+}
+
+func (s *ContestWebhookService) flushAll(ctx context.Context) {
+	webhooks, err := listJSON[ContestWebhook](s.store, webhookKeyPrefix)
+	if err != nil {
+		log.Printf("Error listing contest webhooks: %v", err)
+		return
+	}
+	for _, webhook := range webhooks {
+		if err := s.flush(ctx, webhook); err != nil {
+			log.Printf("Error delivering webhook events for contest %s: %v", webhook.ContestID, err)
+		}
+	}
+}
+
+// flush sends every queued event of the contest, a batch at a time. On a
+// failed delivery the batch is put back at the head of the queue.
+func (s *ContestWebhookService) flush(ctx context.Context, webhook ContestWebhook) error {
+	lockKey := webhookLockPrefix + webhook.ContestID
+	ok, err := s.store.SetNX(lockKey, []byte("1"), s.interval+s.client.Timeout)
+	if err != nil || !ok {
+		return err
+	}
+	defer s.store.Delete(lockKey)
+
+	queueKey := webhookEventsPrefix + webhook.ContestID
+	for ctx.Err() == nil {
+		var batch []json.RawMessage
+		for len(batch) < webhookBatchSize {
+			event, err := s.store.Pop(queueKey)
+			if errors.Is(err, store.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				return putBack(s.store, queueKey, batch, err)
+			}
+			batch = append(batch, event)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.deliver(ctx, webhook, batch); err != nil {
+			return putBack(s.store, queueKey, batch, err)
+		}
+		if len(batch) < webhookBatchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *ContestWebhookService) deliver(ctx context.Context, webhook ContestWebhook, batch []json.RawMessage) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(webhook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", strings.TrimSpace(resp.Status))
+	}
+	return nil
+}
+
+func (s *ContestWebhookService) SnapshotName() string {
+	return "contestWebhooks"
+}
+
+func (s *ContestWebhookService) ExportSnapshot() (json.RawMessage, error) {
+	webhooks, err := listJSON[ContestWebhook](s.store, webhookKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(webhooks)
+}
+
+func (s *ContestWebhookService) ImportSnapshot(data json.RawMessage) error {
+	var webhooks []ContestWebhook
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return err
+	}
+	return replaceJSON(s.store, webhookKeyPrefix, webhooks, func(w ContestWebhook) string { return w.ContestID })
+}
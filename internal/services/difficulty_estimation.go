@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"online-judge/internal/store"
+	"strings"
+	"time"
+)
+
+const difficultyLeaseKey = "lease:difficulty-estimation"
+
+// DifficultyEstimate is a problem's difficulty on the Elo scale: a user
+// rated Rating solves the problem on their first attempt about half the
+// time. It is only estimated once Users users have tried the problem.
+type DifficultyEstimate struct {
+	Rating      int       `json:"rating"`
+	Users       int       `json:"users"`
+	Solvers     int       `json:"solvers"`
+	EstimatedAt time.Time `json:"estimatedAt"`
+}
+
+type DifficultyConfig struct {
+	// MinUsers is how many users must have tried a problem before its
+	// difficulty is estimated.
+	MinUsers int
+	Interval time.Duration
+}
+
+// DifficultyConfigFromEnv reads DIFFICULTY_MIN_USERS and
+// DIFFICULTY_INTERVAL_MINUTES.
+func DifficultyConfigFromEnv() DifficultyConfig {
+	return DifficultyConfig{
+		MinUsers: max(intFromEnv("DIFFICULTY_MIN_USERS", 20), 1),
+		Interval: time.Duration(max(intFromEnv("DIFFICULTY_INTERVAL_MINUTES", 60), 1)) * time.Minute,
+	}
+}
+
+// DifficultyEstimator periodically rates problems and their solvers
+// together from the per-user progress ProblemStatsService records, so a
+// problem solved mostly by strong users rates higher than its acceptance
+// rate alone suggests. Every replica runs it; a lease lets one estimate
+// per tick.
+type DifficultyEstimator struct {
+	store          store.Store
+	problemService *ProblemService
+	config         DifficultyConfig
+}
+
+func NewDifficultyEstimator(st store.Store, problemService *ProblemService, config DifficultyConfig) *DifficultyEstimator {
+	return &DifficultyEstimator{store: st, problemService: problemService, config: config}
+}
+
+// Run estimates difficulties every interval until ctx is cancelled.
+func (e *DifficultyEstimator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := e.store.SetNX(difficultyLeaseKey, []byte("1"), e.config.Interval/2)
+		if err != nil {
+			log.Printf("Error acquiring difficulty estimation lease: %v", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if _, err := e.Estimate(time.Now()); err != nil {
+			log.Printf("Error estimating problem difficulties: %v", err)
+		}
+	}
+}
+
+func (e *DifficultyEstimator) Name() string {
+	return "difficulty ratings"
+}
+
+// Recompute is the rejudge Recomputer: ratings are estimated again from
+// the statistics, so it must be registered after the statistics' own.
+func (e *DifficultyEstimator) Recompute(_ []string, _ []VerdictChange) error {
+	_, err := e.Estimate(time.Now())
+	return err
+}
+
+// difficultyAttempt is one user's outcome on a problem: the share of
+// their attempts up to the first accepted one that was accepted, or zero
+// if they never solved it.
+type difficultyAttempt struct {
+	user, problem int
+	outcome       float64
+}
+
+// Estimate rates every problem tried by enough users, stores the ratings
+// on the problems and returns how many were rated.
+func (e *DifficultyEstimator) Estimate(now time.Time) (int, error) {
+	problems, err := e.problemService.List()
+	if err != nil {
+		return 0, err
+	}
+	users := make(map[string]int)
+	var attempts []difficultyAttempt
+	tried := make([]int, len(problems))
+	solved := make([]int, len(problems))
+	for i, problem := range problems {
+		prefix := problemStatsPrefix + problem.ID + ":user:"
+		keys, err := e.store.Keys(prefix)
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			var progress problemStatsUser
+			if err := getJSON(e.store, key, &progress); errors.Is(err, store.ErrNotFound) {
+				continue
+			} else if err != nil {
+				return 0, err
+			}
+			userID := strings.TrimPrefix(key, prefix)
+			if _, ok := users[userID]; !ok {
+				users[userID] = len(users)
+			}
+			attempt := difficultyAttempt{user: users[userID], problem: i}
+			if progress.SolvedAfter > 0 {
+				attempt.outcome = 1 / float64(progress.SolvedAfter)
+				solved[i]++
+			}
+			attempts = append(attempts, attempt)
+			tried[i]++
+		}
+	}
+
+	_, ratings := fitDifficulties(len(users), len(problems), attempts)
+	rated := 0
+	for i, problem := range problems {
+		if tried[i] < e.config.MinUsers {
+			continue
+		}
+		estimate := DifficultyEstimate{
+			Rating:      int(math.Round(ratings[i]/100)) * 100,
+			Users:       tried[i],
+			Solvers:     solved[i],
+			EstimatedAt: now,
+		}
+		if err := e.problemService.setEstimatedDifficulty(problem.ID, estimate); errors.Is(err, ErrProblemNotFound) {
+			continue
+		} else if err != nil {
+			return rated, err
+		}
+		rated++
+	}
+	return rated, nil
+}
+
+// fitDifficulties rates users and problems on the Elo scale so that a
+// user's expected outcome on a problem, 1/(1+10^((problem-user)/400)),
+// matches the observed ones. Everyone also draws once against a 1500
+// rated opponent, which keeps the ratings of those who solved everything
+// or nothing finite and centres the scale.
+func fitDifficulties(users, problems int, attempts []difficultyAttempt) (userRatings, problemRatings []float64) {
+	const (
+		prior      = 1500.0
+		iterations = 200
+		step       = 400.0
+	)
+	userRatings = make([]float64, users)
+	problemRatings = make([]float64, problems)
+	for i := range userRatings {
+		userRatings[i] = prior
+	}
+	for i := range problemRatings {
+		problemRatings[i] = prior
+	}
+	expected := func(user, problem float64) float64 {
+		return 1 / (1 + math.Pow(10, (problem-user)/400))
+	}
+
+	userResidual := make([]float64, users)
+	userCount := make([]float64, users)
+	problemResidual := make([]float64, problems)
+	problemCount := make([]float64, problems)
+	for iteration := 0; iteration < iterations; iteration++ {
+		for i := range userRatings {
+			userResidual[i] = 0.5 - expected(userRatings[i], prior)
+			userCount[i] = 1
+		}
+		for i := range problemRatings {
+			problemResidual[i] = expected(prior, problemRatings[i]) - 0.5
+			problemCount[i] = 1
+		}
+		for _, attempt := range attempts {
+			residual := attempt.outcome - expected(userRatings[attempt.user], problemRatings[attempt.problem])
+			userResidual[attempt.user] += residual
+			userCount[attempt.user]++
+			problemResidual[attempt.problem] -= residual
+			problemCount[attempt.problem]++
+		}
+		for i := range userRatings {
+			userRatings[i] += step * userResidual[i] / userCount[i]
+		}
+		for i := range problemRatings {
+			problemRatings[i] += step * problemResidual[i] / problemCount[i]
+		}
+	}
+	return userRatings, problemRatings
+}
+
+// setEstimatedDifficulty stores the estimate on the problem. Setters edit
+// the problem without it; Update keeps the stored one.
+func (s *ProblemService) setEstimatedDifficulty(problemID string, estimate DifficultyEstimate) error {
+	problem, err := s.Get(problemID)
+	if err != nil {
+		return err
+	}
+	problem.EstimatedDifficulty = &estimate
+	return setJSON(s.store, problemKeyPrefix+problemID, problem, 0)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"online-judge/internal/judge"
+)
+
+var (
+	ErrNoSampleTests = errors.New("problem has no sample tests")
+	// ErrCustomInputTooLarge is returned for custom runs whose input
+	// exceeds customRunInputBytes.
+	ErrCustomInputTooLarge = errors.New("custom input is too large")
+)
+
+// customRunInputBytes caps the input typed for a custom run.
+const customRunInputBytes = 1 << 20
+
+// Relaxed limits used for dry runs, so that slow solutions still finish and
+// report how far over the real limits they are.
+const (
+	dryRunTimeFactor   = 3
+	dryRunTimeSlack    = 2.0 // seconds
+	dryRunMemoryFactor = 2
+)
+
+type DryRunTest struct {
+	Index    int          `json:"index"`
+	Status   judge.Status `json:"status"`
+	Passed   bool         `json:"passed"`
+	Time     float64      `json:"time"`
+	WallTime float64      `json:"wallTime"`
+	Memory   int          `json:"memory"`
+}
+
+// DryRunReport compares measured usage with the problem's real limits.
+// Ratios above 1 mean the real run would exceed the limit; ratios close to 1
+// mean the limit is tight.
+type DryRunReport struct {
+	TimeLimit     float64      `json:"timeLimit"`
+	MemoryLimit   int          `json:"memoryLimit"`
+	MaxTime       float64      `json:"maxTime"`
+	MaxMemory     int          `json:"maxMemory"`
+	TimeRatio     float64      `json:"timeRatio"`
+	MemoryRatio   float64      `json:"memoryRatio"`
+	WithinLimits  bool         `json:"withinLimits"`
+	CompileOutput string       `json:"compileOutput,omitempty"`
+	Tests         []DryRunTest `json:"tests"`
+}
+
+type DryRunService struct {
+	problemService *ProblemService
+	judgeClient    *judge.Client
+}
+
+func NewDryRunService(problemService *ProblemService, judgeClient *judge.Client) *DryRunService {
+	return &DryRunService{problemService: problemService, judgeClient: judgeClient}
+}
+
+// Estimate runs code against the problem's sample tests with relaxed limits.
+func (s *DryRunService) Estimate(ctx context.Context, problemID, language, code string) (DryRunReport, error) {
+	problem, err := s.problemService.Get(problemID)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	samples, err := s.problemService.SampleTests(problemID)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	if len(samples) == 0 {
+		return DryRunReport{}, ErrNoSampleTests
+	}
+
+	report := DryRunReport{
+		TimeLimit:   problem.TimeLimit,
+		MemoryLimit: problem.MemoryLimit,
+		Tests:       make([]DryRunTest, 0, len(samples)),
+	}
+
+	for i, test := range samples {
+		test, err := s.problemService.LoadTestData(ctx, test, true)
+		if err != nil {
+			return DryRunReport{}, err
+		}
+		result, err := s.judgeClient.Execute(ctx, judge.Submission{
+			Language:       language,
+			Code:           code,
+			Input:          test.Input,
+			TimeLimit:      problem.TimeLimit*dryRunTimeFactor + dryRunTimeSlack,
+			MemoryLimit:    problem.MemoryLimit * dryRunMemoryFactor,
+			OutputLimit:    problem.OutputLimit,
+			Env:            problem.Env,
+			NetworkEnabled: problem.NetworkEnabled,
+			Files:          problem.runFiles(),
+		})
+		if err != nil {
+			return DryRunReport{}, err
+		}
+		if result.Status.CompileFailed() {
+			report.CompileOutput = result.CompileOutput
+			return report, nil
+		}
+
+		report.Tests = append(report.Tests, DryRunTest{
+			Index:    i + 1,
+			Status:   result.Status,
+			Passed:   result.Status == judge.StatusOK && problem.outputsMatch(test.Output, result.Stdout),
+			Time:     result.Time,
+			WallTime: result.WallTime,
+			Memory:   result.Memory,
+		})
+		report.MaxTime = math.Max(report.MaxTime, result.Time)
+		if result.Memory > report.MaxMemory {
+			report.MaxMemory = result.Memory
+		}
+	}
+
+	report.TimeRatio = report.MaxTime / problem.TimeLimit
+	report.MemoryRatio = float64(report.MaxMemory) / float64(problem.MemoryLimit)
+	report.WithinLimits = report.TimeRatio <= 1 && report.MemoryRatio <= 1
+	return report, nil
+}
+
+// CustomRun runs code once on input the user typed, with the problem's
+// real limits, sending its stdout and stderr to out while it runs. out is
+// not closed.
+func (s *DryRunService) CustomRun(ctx context.Context, problemID, language, code, input string, out chan<- judge.OutputChunk) (judge.ExecutionResult, error) {
+	if len(input) > customRunInputBytes {
+		return judge.ExecutionResult{}, ErrCustomInputTooLarge
+	}
+	problem, err := s.problemService.Get(problemID)
+	if err != nil {
+		return judge.ExecutionResult{}, err
+	}
+	return s.judgeClient.ExecuteStream(ctx, judge.Submission{
+		Language:       language,
+		Code:           code,
+		Input:          input,
+		TimeLimit:      problem.TimeLimit,
+		MemoryLimit:    problem.MemoryLimit,
+		OutputLimit:    problem.OutputLimit,
+		Env:            problem.Env,
+		NetworkEnabled: problem.NetworkEnabled,
+		Files:          problem.runFiles(),
+	}, out)
+}
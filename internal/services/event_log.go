@@ -0,0 +1,418 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"online-judge/internal/store"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrEventLogBusy    = errors.New("event log chain is being appended to, try again")
+	ErrEventChainBroke = errors.New("event log chain is broken")
+)
+
+const (
+	eventLogQueueKey     = "queue:event-log"
+	eventLogLockKey      = "lock:event-log"
+	eventLogHeadPrefix   = "event-log:head:"
+	eventLogCursorPrefix = "event-log:cursor:"
+	eventLogChainPrefix  = "lock:event-log:"
+	eventLogBatchSize    = 100
+)
+
+// Types of judgement events.
+const (
+	EventStatus  = "status"
+	EventVerdict = "verdict"
+	EventFinal   = "final"
+)
+
+// JudgementEvent is one entry of the append-only event log: a status
+// change, a verdict or a final-test result of a submission. Events are
+// chained per contest, practice submissions forming a chain of their own
+// with an empty ContestID: Seq counts from 1 and Hash is the hex SHA-256
+// of PrevHash followed by the event's JSON encoding with Hash empty.
+type JudgementEvent struct {
+	Seq          int64            `json:"seq"`
+	ContestID    string           `json:"contestId"`
+	Type         string           `json:"type"`
+	SubmissionID string           `json:"submissionId"`
+	UserID       string           `json:"userId"`
+	ProblemID    string           `json:"problemId"`
+	Virtual      bool             `json:"virtual,omitempty"`
+	Status       SubmissionStatus `json:"status,omitempty"`
+	Verdict      Verdict          `json:"verdict,omitempty"`
+	Score        float64          `json:"score"`
+	At           time.Time        `json:"at"`
+	PrevHash     string           `json:"prevHash"`
+	Hash         string           `json:"hash"`
+}
+
+func (e JudgementEvent) hash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(append([]byte(e.PrevHash), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// eventChainHead is the last event appended to a chain.
+type eventChainHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// eventCursor is how much of a submission has been logged.
+type eventCursor struct {
+	Transitions int     `json:"transitions"`
+	Verdict     Verdict `json:"verdict,omitempty"`
+	Score       float64 `json:"score"`
+	Final       bool    `json:"final,omitempty"`
+}
+
+// EventSink stores chained events, oldest first. It must not reorder a
+// batch.
+type EventSink interface {
+	Append(ctx context.Context, events []json.RawMessage) error
+}
+
+// EventSinkFromEnv returns the sink configured by EVENT_LOG_FILE, a file of
+// JSON lines, or EVENT_LOG_KAFKA_URL and EVENT_LOG_KAFKA_TOPIC, a Kafka REST
+// proxy and topic. The returned bool is false when neither is set.
+func EventSinkFromEnv() (EventSink, bool) {
+	if path := os.Getenv("EVENT_LOG_FILE"); path != "" {
+		return NewFileEventSink(path), true
+	}
+	if url := os.Getenv("EVENT_LOG_KAFKA_URL"); url != "" {
+		topic := os.Getenv("EVENT_LOG_KAFKA_TOPIC")
+		if topic == "" {
+			topic = "judgements"
+		}
+		return NewKafkaEventSink(url, topic), true
+	}
+	return nil, false
+}
+
+// FileEventSink appends events to a file, one JSON object per line, and
+// syncs it after every batch.
+type FileEventSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileEventSink(path string) *FileEventSink {
+	return &FileEventSink{path: path}
+}
+
+func (s *FileEventSink) Append(_ context.Context, events []json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buf bytes.Buffer
+	for _, event := range events {
+		buf.Write(event)
+		buf.WriteByte('\n')
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// KafkaEventSink produces events to a topic through a Kafka REST proxy.
+// Events are keyed by contest, so each chain stays in one partition and
+// in order.
+type KafkaEventSink struct {
+	url    string
+	topic  string
+	client *http.Client
+}
+
+func NewKafkaEventSink(url, topic string) *KafkaEventSink {
+	return &KafkaEventSink{
+		url:    strings.TrimRight(url, "/"),
+		topic:  topic,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *KafkaEventSink) Append(ctx context.Context, events []json.RawMessage) error {
+	type record struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+	records := make([]record, 0, len(events))
+	for _, event := range events {
+		var chain struct {
+			ContestID string `json:"contestId"`
+		}
+		if err := json.Unmarshal(event, &chain); err != nil {
+			return err
+		}
+		records = append(records, record{Key: "contest:" + chain.ContestID, Value: event})
+	}
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/topics/"+s.topic, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("kafka proxy responded %s", resp.Status)
+	}
+	return nil
+}
+
+// EventLog records every status change and verdict of every submission
+// in hash-chained order, so contest results can be audited outside the
+// judge and standings rebuilt from the log alone. Events are chained as
+// submissions are saved and queued in the store; Run hands them to the
+// sink.
+type EventLog struct {
+	store    store.Store
+	sink     EventSink
+	interval time.Duration
+}
+
+func NewEventLog(st store.Store, sink EventSink) *EventLog {
+	return &EventLog{store: st, sink: sink, interval: time.Second}
+}
+
+// Record is a SavedListener that chains the submission's changes since it
+// was last saved.
+func (l *EventLog) Record(submission Submission) {
+	if err := l.record(submission); err != nil {
+		log.Printf("Error logging events of submission %s: %v", submission.ID, err)
+	}
+}
+
+func (l *EventLog) record(submission Submission) error {
+	unlock, err := l.lock(submission.ContestID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var cursor eventCursor
+	if err := getJSON(l.store, eventLogCursorPrefix+submission.ID, &cursor); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	base := JudgementEvent{
+		ContestID:    submission.ContestID,
+		SubmissionID: submission.ID,
+		UserID:       submission.UserID,
+		ProblemID:    submission.ProblemID,
+		Virtual:      submission.Virtual,
+	}
+	var events []JudgementEvent
+	// transitions only grow; a stale copy saved late has none to add
+	for _, transition := range submission.Transitions[min(cursor.Transitions, len(submission.Transitions)):] {
+		event := base
+		event.Type, event.Status, event.At = EventStatus, transition.To, transition.At
+		events = append(events, event)
+	}
+	if submission.Verdict != "" && (submission.Verdict != cursor.Verdict || submission.Score != cursor.Score) {
+		event := base
+		event.Type, event.Verdict, event.Score, event.At = EventVerdict, submission.Verdict, submission.Score, submission.StatusSince()
+		if submission.JudgedAt != nil {
+			event.At = *submission.JudgedAt
+		}
+		events = append(events, event)
+	}
+	if submission.Final != nil && !cursor.Final {
+		event := base
+		event.Type, event.Verdict, event.Score, event.At = EventFinal, submission.Final.Verdict, submission.Final.Score, submission.Final.JudgedAt
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	var head eventChainHead
+	if err := getJSON(l.store, eventLogHeadPrefix+submission.ContestID, &head); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	for _, event := range events {
+		head.Seq++
+		event.Seq, event.PrevHash = head.Seq, head.Hash
+		event.Hash = event.hash()
+		head.Hash = event.Hash
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := l.store.Push(eventLogQueueKey, data); err != nil {
+			return err
+		}
+	}
+	if err := setJSON(l.store, eventLogHeadPrefix+submission.ContestID, head, 0); err != nil {
+		return err
+	}
+	return setJSON(l.store, eventLogCursorPrefix+submission.ID, eventCursor{
+		Transitions: len(submission.Transitions),
+		Verdict:     submission.Verdict,
+		Score:       submission.Score,
+		Final:       submission.Final != nil,
+	}, 0)
+}
+
+// lock serializes appends to one contest's chain across replicas. Appends
+// take a few store round trips, so a short wait suffices.
+func (l *EventLog) lock(contestID string) (func(), error) {
+	key := eventLogChainPrefix + contestID
+	for attempt := 0; attempt < 50; attempt++ {
+		ok, err := l.store.SetNX(key, []byte("1"), 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() { l.store.Delete(key) }, nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return nil, ErrEventLogBusy
+}
+
+// Run hands queued events to the sink every interval until ctx is
+// cancelled.
+func (l *EventLog) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.flush(ctx); err != nil {
+				log.Printf("Error writing the event log: %v", err)
+			}
+		}
+	}
+}
+
+// flush appends every queued event, a batch at a time. On a failed
+// append the batch is put back at the head of the queue; sinks may then
+// see a batch twice, which readers tell by its repeated Seq.
+func (l *EventLog) flush(ctx context.Context) error {
+	ok, err := l.store.SetNX(eventLogLockKey, []byte("1"), l.interval+time.Minute)
+	if err != nil || !ok {
+		return err
+	}
+	defer l.store.Delete(eventLogLockKey)
+
+	for ctx.Err() == nil {
+		var batch []json.RawMessage
+		for len(batch) < eventLogBatchSize {
+			event, err := l.store.Pop(eventLogQueueKey)
+			if errors.Is(err, store.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				return putBack(l.store, eventLogQueueKey, batch, err)
+			}
+			batch = append(batch, event)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := l.sink.Append(ctx, batch); err != nil {
+			return putBack(l.store, eventLogQueueKey, batch, err)
+		}
+		if len(batch) < eventLogBatchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// ReadEventLog reads a log of JSON lines and checks every chain in it. An
+// event repeated after a failed append is skipped; any other gap,
+// reordering or altered event is an ErrEventChainBroke.
+func ReadEventLog(r io.Reader) ([]JudgementEvent, error) {
+	heads := make(map[string]eventChainHead)
+	var events []JudgementEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var event JudgementEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		head := heads[event.ContestID]
+		if event.Seq <= head.Seq {
+			continue
+		}
+		if event.Seq != head.Seq+1 || event.PrevHash != head.Hash || event.Hash != event.hash() {
+			return nil, fmt.Errorf("%w: line %d, contest %q, event %d", ErrEventChainBroke, line, event.ContestID, event.Seq)
+		}
+		heads[event.ContestID] = eventChainHead{Seq: event.Seq, Hash: event.Hash}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// ReplayStandings rebuilds a contest's official standings from its events
+// the way ScoreboardService computes them from submissions.
+func ReplayStandings(events []JudgementEvent, contestID string, phase StandingsPhase) (Standings, error) {
+	if !phase.valid() {
+		return Standings{}, ErrInvalidStandingsPhase
+	}
+	byID := make(map[string]*Submission)
+	var order []string
+	for _, event := range events {
+		if event.ContestID != contestID || event.Virtual {
+			continue
+		}
+		submission, ok := byID[event.SubmissionID]
+		if !ok {
+			submission = &Submission{ID: event.SubmissionID, UserID: event.UserID, ContestID: contestID, ProblemID: event.ProblemID}
+			byID[event.SubmissionID] = submission
+			order = append(order, event.SubmissionID)
+		}
+		switch event.Type {
+		case EventStatus:
+			submission.Status = event.Status
+		case EventVerdict:
+			submission.Verdict, submission.Score = event.Verdict, event.Score
+		case EventFinal:
+			submission.Final = &FinalResult{Verdict: event.Verdict, Score: event.Score, JudgedAt: event.At}
+		}
+	}
+	submissions := make([]Submission, 0, len(order))
+	for _, id := range order {
+		submissions = append(submissions, *byID[id])
+	}
+	all := func(Submission) bool { return true }
+	return Standings{ContestID: contestID, Phase: phase, Rows: standingsRows(submissions, all, nil, phase)}, nil
+}
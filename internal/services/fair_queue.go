@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"online-judge/internal/store"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fairQueueRefresh bounds how long a newly used sub-queue can go unnoticed.
+const fairQueueRefresh = time.Second
+
+// FairQueue spreads pops across named sub-queues by weighted round robin,
+// so a flood into one sub-queue only delays the others by its share. The
+// sub-queues are store lists under prefix; the round-robin position is a
+// shared counter, so every replica takes part in the same rotation.
+type FairQueue struct {
+	store   store.Store
+	prefix  string
+	turnKey string
+	weight  func(name string) int
+
+	mu        sync.Mutex
+	ring      []string
+	refreshed time.Time
+}
+
+func NewFairQueue(st store.Store, prefix, turnKey string) *FairQueue {
+	return &FairQueue{
+		store:   st,
+		prefix:  prefix,
+		turnKey: turnKey,
+		weight:  func(string) int { return 1 },
+	}
+}
+
+// SetWeights sets how many turns each sub-queue gets per rotation. Weights
+// below 1 count as 1.
+func (q *FairQueue) SetWeights(weight func(name string) int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.weight = weight
+	q.refreshed = time.Time{}
+}
+
+func (q *FairQueue) Push(name string, value []byte) error {
+	return q.store.Push(q.prefix+name, value)
+}
+
+// Pop takes from the sub-queue whose turn it is, moving on to the next ones
+// when it is empty. It returns store.ErrNotFound when every sub-queue is
+// empty.
+func (q *FairQueue) Pop() ([]byte, error) {
+	ring, err := q.currentRing(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(ring) == 0 {
+		if ring, err = q.currentRing(true); err != nil {
+			return nil, err
+		}
+		if len(ring) == 0 {
+			return nil, store.ErrNotFound
+		}
+	}
+
+	turn, err := q.store.Incr(q.turnKey)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ring {
+		name := ring[(int(turn%int64(len(ring)))+i)%len(ring)]
+		value, err := q.store.Pop(q.prefix + name)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		return value, err
+	}
+
+	// everything known is drained; look for new sub-queues on the next pop
+	q.mu.Lock()
+	q.refreshed = time.Time{}
+	q.mu.Unlock()
+	return nil, store.ErrNotFound
+}
+
+// Len returns the number of values across all sub-queues.
+func (q *FairQueue) Len() (int64, error) {
+	keys, err := q.store.Keys(q.prefix)
+	if err != nil {
+		return 0, err
+	}
+	total := int64(0)
+	for _, key := range keys {
+		n, err := q.store.Len(key)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// currentRing returns the rotation, listing sub-queues from the store at
+// most once per fairQueueRefresh unless force is set. Each name appears as
+// many times as its weight.
+func (q *FairQueue) currentRing(force bool) ([]string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !force && time.Since(q.refreshed) < fairQueueRefresh {
+		return q.ring, nil
+	}
+
+	keys, err := q.store.Keys(q.prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	ring := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, q.prefix)
+		weight := q.weight(name)
+		if weight < 1 {
+			weight = 1
+		}
+		for i := 0; i < weight; i++ {
+			ring = append(ring, name)
+		}
+	}
+	q.ring = ring
+	q.refreshed = time.Now()
+	return ring, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"online-judge/internal/store"
+	"sort"
+)
+
+var ErrLanguageRequired = errors.New("language is required")
+
+// FastestEntry is a place on a problem's fastest-solutions leaderboard.
+// Source is only filled in when its author shares sources for the problem,
+// and for contest submissions once the contest has finished.
+type FastestEntry struct {
+	Rank     int    `json:"rank"`
+	Language string `json:"language"`
+	FastestSolution
+	Source string `json:"source,omitempty"`
+}
+
+// FastestSharing is a user's choice to show their leaderboard sources for
+// a problem to everyone.
+type FastestSharing struct {
+	ProblemID   string `json:"problemId"`
+	UserID      string `json:"userId"`
+	ShareSource bool   `json:"shareSource"`
+}
+
+const (
+	fastestEntryPrefix   = "fastest:entry:"
+	fastestSharingPrefix = "fastest:sharing:"
+	// DefaultFastestLimit and MaxFastestLimit bound leaderboard reads.
+	DefaultFastestLimit = 20
+	MaxFastestLimit     = 100
+)
+
+// FastestSolutionsService keeps each user's fastest accepted solution per
+// problem and language, ranked by peak CPU time, then peak memory, then
+// submission time. Sources stay private unless their author opts in.
+type FastestSolutionsService struct {
+	store             store.Store
+	problemService    *ProblemService
+	submissionService *SubmissionService
+	contestService    *ContestService
+}
+
+func NewFastestSolutionsService(st store.Store, problemService *ProblemService, submissionService *SubmissionService, contestService *ContestService) *FastestSolutionsService {
+	return &FastestSolutionsService{
+		store:             st,
+		problemService:    problemService,
+		submissionService: submissionService,
+		contestService:    contestService,
+	}
+}
+
+// Record is a GradedListener. A user's entry is replaced by a faster
+// accepted submission, and dropped when a rejudge takes the accepted
+// verdict away from the submission it holds.
+func (s *FastestSolutionsService) Record(submission Submission, problem Problem) error {
+	if submission.Status != SubmissionJudged {
+		return nil
+	}
+	key := fastestEntryPrefix + problem.ID + ":" + submission.Language + ":" + submission.UserID
+
+	var current FastestSolution
+	err := getJSON(s.store, key, &current)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	found := err == nil
+
+	if submission.Verdict != VerdictAccepted {
+		if found && current.SubmissionID == submission.ID {
+			return s.store.Delete(key)
+		}
+		return nil
+	}
+
+	candidate := fastestSolution(submission)
+	if found && current.SubmissionID != submission.ID && !candidate.faster(current) {
+		return nil
+	}
+	return setJSON(s.store, key, candidate, 0)
+}
+
+// Leaderboard returns the fastest limit solutions to the problem in the
+// language.
+func (s *FastestSolutionsService) Leaderboard(problemID, language string, limit int) ([]FastestEntry, error) {
+	if language == "" {
+		return nil, ErrLanguageRequired
+	}
+	if _, err := s.problemService.Get(problemID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultFastestLimit
+	}
+	limit = min(limit, MaxFastestLimit)
+
+	solutions, err := listJSON[FastestSolution](s.store, fastestEntryPrefix+problemID+":"+language+":")
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(solutions, func(i, j int) bool {
+		a, b := solutions[i], solutions[j]
+		if a.faster(b) || b.faster(a) {
+			return a.faster(b)
+		}
+		return a.SubmittedAt.Before(b.SubmittedAt)
+	})
+	if len(solutions) > limit {
+		solutions = solutions[:limit]
+	}
+
+	board := make([]FastestEntry, 0, len(solutions))
+	for i, solution := range solutions {
+		entry := FastestEntry{Rank: i + 1, Language: language, FastestSolution: solution}
+		sharing, err := s.Sharing(problemID, solution.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if sharing.ShareSource {
+			submission, err := s.submissionService.Get(solution.SubmissionID)
+			if err != nil && !errors.Is(err, ErrSubmissionNotFound) {
+				return nil, err
+			}
+			if visible, err := contestOver(s.contestService, submission); err != nil {
+				return nil, err
+			} else if visible {
+				entry.Source = submission.Source
+			}
+		}
+		board = append(board, entry)
+	}
+	return board, nil
+}
+
+// Sharing returns the user's sharing choice for the problem; sources are
+// not shared until the user says so.
+func (s *FastestSolutionsService) Sharing(problemID, userID string) (FastestSharing, error) {
+	sharing := FastestSharing{ProblemID: problemID, UserID: userID}
+	err := getJSON(s.store, fastestSharingPrefix+problemID+":"+userID, &sharing)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return FastestSharing{}, err
+	}
+	return sharing, nil
+}
+
+// SetSharing records whether the user shows their leaderboard sources for
+// the problem.
+func (s *FastestSolutionsService) SetSharing(sharing FastestSharing) (FastestSharing, error) {
+	if _, err := s.problemService.Get(sharing.ProblemID); err != nil {
+		return FastestSharing{}, err
+	}
+	if err := setJSON(s.store, fastestSharingPrefix+sharing.ProblemID+":"+sharing.UserID, sharing, 0); err != nil {
+		return FastestSharing{}, err
+	}
+	return sharing, nil
+}
+
+// UserData returns the privacy section holding the user's fastest
+// solutions and sharing choices.
+func (s *FastestSolutionsService) UserData() UserDataSection {
+	return userKeySection{store: s.store, name: "fastestSolutions", prefixes: []string{fastestEntryPrefix, fastestSharingPrefix}, marker: ":"}
+}
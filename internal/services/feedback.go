@@ -0,0 +1,96 @@
+package services
+
+// FeedbackPolicy decides how much of a failed submission's per-test
+// outcome its author may see. Judges always see everything; the policy is
+// applied by ForContestant before a submission leaves the server.
+type FeedbackPolicy string
+
+const (
+	// FeedbackFull shows every test's result.
+	FeedbackFull FeedbackPolicy = "full"
+	// FeedbackNone shows only the overall verdict and score.
+	FeedbackNone FeedbackPolicy = "none"
+	// FeedbackFirstFailing shows the index of the first failing test.
+	FeedbackFirstFailing FeedbackPolicy = "first_failing"
+	// FeedbackSampleDetails adds, for failed sample tests, the start of
+	// the input with the expected and actual output.
+	FeedbackSampleDetails FeedbackPolicy = "sample_details"
+)
+
+func (p FeedbackPolicy) valid() bool {
+	switch p {
+	case "", FeedbackFull, FeedbackNone, FeedbackFirstFailing, FeedbackSampleDetails:
+		return true
+	}
+	return false
+}
+
+// feedbackSnippetBytes caps each snippet kept for a sample test.
+const feedbackSnippetBytes = 256
+
+// TestFeedback is what a sample test looked like when it was judged.
+type TestFeedback struct {
+	Input    string `json:"input"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func sampleFeedback(test TestCase, stdout string) *TestFeedback {
+	return &TestFeedback{
+		Input:    snippet(test.Input),
+		Expected: snippet(test.Output),
+		Actual:   snippet(stdout),
+	}
+}
+
+func snippet(s string) string {
+	if len(s) <= feedbackSnippetBytes {
+		return s
+	}
+	cut := feedbackSnippetBytes
+	// Back up to a rune boundary so the snippet stays valid UTF-8.
+	for cut > 0 && s[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+// ForContestant returns the submission as its author may see it under the
+// problem's feedback policy.
+func (s Submission) ForContestant(policy FeedbackPolicy) Submission {
+	s.FirstFailedTest, s.Results = policy.apply(s.Results)
+	if s.Final != nil {
+		final := *s.Final
+		final.FirstFailedTest, final.Results = policy.apply(final.Results)
+		s.Final = &final
+	}
+	return s
+}
+
+// apply returns the first failing test and the results the policy lets
+// through.
+func (p FeedbackPolicy) apply(results []TestResult) (int, []TestResult) {
+	first := 0
+	for _, result := range results {
+		if result.Verdict != VerdictAccepted {
+			first = result.Test
+			break
+		}
+	}
+
+	switch p {
+	case FeedbackNone:
+		return 0, nil
+	case FeedbackFirstFailing:
+		return first, nil
+	case FeedbackSampleDetails:
+		var kept []TestResult
+		for _, result := range results {
+			if result.Sample && result.Verdict != VerdictAccepted {
+				kept = append(kept, result)
+			}
+		}
+		return first, kept
+	}
+	return first, results
+}
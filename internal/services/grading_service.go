@@ -0,0 +1,432 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"online-judge/internal/judge"
+	"online-judge/pkg/verdict"
+	"time"
+)
+
+// GradingService judges a submission against its problem's tests using a
+// judge worker and scores it according to the problem's scoring policy.
+type GradingService struct {
+	problemService    *ProblemService
+	submissionService *SubmissionService
+	judgeClient       *judge.Client
+	listeners         []GradedListener
+}
+
+// GradedListener is called with every submission after its outcome is
+// stored, including internal errors.
+type GradedListener func(submission Submission, problem Problem)
+
+func NewGradingService(problemService *ProblemService, submissionService *SubmissionService, judgeClient *judge.Client) *GradingService {
+	return &GradingService{
+		problemService:    problemService,
+		submissionService: submissionService,
+		judgeClient:       judgeClient,
+	}
+}
+
+// OnGraded registers a listener. It must be called before grading starts.
+func (s *GradingService) OnGraded(listener GradedListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// Grade judges the submission and stores the outcome.
+func (s *GradingService) Grade(ctx context.Context, submission Submission) (Submission, error) {
+	started := time.Now()
+	if err := submission.transition(SubmissionCompiling, started); err != nil {
+		return submission, err
+	}
+	if err := s.submissionService.UpdateUnlessMoved(submission); err != nil {
+		return submission, err
+	}
+
+	graded, err := s.grade(ctx, submission, false)
+	now := time.Now()
+	if err != nil {
+		log.Printf("Error grading submission %s: %v", submission.ID, err)
+		// Keep the transitions made while grading but none of its results
+		status, transitions := graded.Status, graded.Transitions
+		graded = submission
+		graded.Status, graded.Transitions = status, transitions
+		graded.transition(SubmissionFailed, now)
+		graded.Verdict = VerdictInternalError
+	}
+	graded.applyOverride(now)
+
+	graded.JudgedAt = &now
+	if graded.Timing == nil {
+		graded.Timing = &Timing{}
+	}
+	graded.Timing.QueueWait = started.Sub(submission.CreatedAt).Seconds()
+	graded.Timing.Total = now.Sub(submission.CreatedAt).Seconds()
+	// The watchdog may have given up on this worker in the meantime
+	if err := s.submissionService.UpdateUnlessMoved(graded); err != nil {
+		return graded, err
+	}
+
+	if problem, err := s.problemService.Get(graded.ProblemID); err == nil {
+		for _, listener := range s.listeners {
+			listener(graded, problem)
+		}
+	}
+	return graded, nil
+}
+
+// GradeFinal judges a contest submission on the full test set, including
+// final tests, and records the outcome in submission.Final. The provisional
+// outcome is left untouched.
+func (s *GradingService) GradeFinal(ctx context.Context, submission Submission) (Submission, error) {
+	graded, err := s.grade(ctx, submission, true)
+	if err != nil {
+		return submission, err
+	}
+
+	submission.TestOrderSeed = graded.TestOrderSeed
+	submission.Final = &FinalResult{
+		Verdict:  graded.Verdict,
+		Score:    graded.Score,
+		Results:  graded.Results,
+		JudgedAt: time.Now(),
+	}
+	submission.applyOverride(time.Now())
+	if err := s.submissionService.Update(submission); err != nil {
+		return submission, err
+	}
+	return submission, nil
+}
+
+// RunFinalTests is a SystemTestJob for marathon-style contests: each user's
+// latest judged submission to a problem with final tests is graded on the
+// full test set. Submissions that already have a final result are skipped,
+// so a resumed system test continues where the last run stopped.
+func (s *GradingService) RunFinalTests(contest Contest) error {
+	submissions, err := s.submissionService.ListByContest(contest.ID)
+	if err != nil {
+		return err
+	}
+
+	hasFinal := make(map[string]bool, len(contest.Problems))
+	for _, problemID := range contest.Problems {
+		tests, err := s.problemService.Tests(problemID)
+		if err != nil {
+			return err
+		}
+		hasFinal[problemID] = hasFinalTests(tests)
+	}
+
+	for _, latest := range latestJudged(submissions) {
+		if !hasFinal[latest.ProblemID] || latest.Final != nil {
+			continue
+		}
+		submission, err := s.submissionService.Get(latest.ID)
+		if err != nil {
+			return err
+		}
+		if _, err := s.GradeFinal(context.Background(), submission); err != nil {
+			return fmt.Errorf("final testing submission %s: %w", submission.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *GradingService) Name() string {
+	return "final results"
+}
+
+// Recompute is the rejudge Recomputer for final results: changed
+// submissions that system testing already graded are graded on the full
+// test set again. Manual verdicts already decide the final result.
+func (s *GradingService) Recompute(_ []string, changes []VerdictChange) error {
+	for _, change := range changes {
+		submission, err := s.submissionService.Get(change.SubmissionID)
+		if errors.Is(err, ErrSubmissionNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if submission.Final == nil || submission.ManualVerdict {
+			continue
+		}
+		if _, err := s.GradeFinal(context.Background(), submission); err != nil {
+			return fmt.Errorf("final testing submission %s: %w", submission.ID, err)
+		}
+	}
+	return nil
+}
+
+func hasFinalTests(tests []TestCase) bool {
+	for _, test := range tests {
+		if test.Final {
+			return true
+		}
+	}
+	return false
+}
+
+// latestJudged returns each user's last judged, non-virtual submission per
+// problem. submissions must be ordered oldest first.
+func latestJudged(submissions []Submission) []Submission {
+	index := make(map[string]int)
+	var latest []Submission
+	for _, submission := range submissions {
+		if submission.Status != SubmissionJudged || submission.Virtual {
+			continue
+		}
+		key := submission.UserID + ":" + submission.ProblemID
+		if i, ok := index[key]; ok {
+			latest[i] = submission
+			continue
+		}
+		index[key] = len(latest)
+		latest = append(latest, submission)
+	}
+	return latest
+}
+
+// grade runs the submission against its tests. Contest submissions skip
+// final tests unless final is set; virtual submissions come after system
+// testing and always run every test.
+func (s *GradingService) grade(ctx context.Context, submission Submission, final bool) (Submission, error) {
+	problem, err := s.problemService.Get(submission.ProblemID)
+	if err != nil {
+		return submission, err
+	}
+	tests, err := s.problemService.Tests(submission.ProblemID)
+	if err != nil {
+		return submission, err
+	}
+
+	order := identityOrder(len(tests))
+	if problem.RandomizeTestOrder {
+		if submission.TestOrderSeed == nil {
+			seed, err := newTestOrderSeed()
+			if err != nil {
+				return submission, err
+			}
+			submission.TestOrderSeed = &seed
+		}
+		order = shuffledOrder(len(tests), *submission.TestOrderSeed)
+	}
+
+	submission.Results = make([]TestResult, 0, len(tests))
+	submission.Timing = &Timing{}
+	submission.CompileOutput = ""
+	submission.Hint = ""
+	submission.Verdict = VerdictAccepted
+	submission.Score = 0
+
+	provisional := submission.ContestID != "" && !submission.Virtual && !final
+	selected := 0
+	for _, test := range tests {
+		if !provisional || !test.Final {
+			selected++
+		}
+	}
+
+	source, err := judgedSource(problem, submission)
+	if err != nil {
+		return submission, err
+	}
+
+	script, err := s.problemService.JudgeScript(problem.ID)
+	if err == nil {
+		var selectedTests []TestCase
+		var numbers []int
+		for _, index := range order {
+			if !provisional || !tests[index].Final {
+				selectedTests = append(selectedTests, tests[index])
+				numbers = append(numbers, index+1)
+			}
+		}
+		return s.gradeWithScript(ctx, submission, source, problem, script, selectedTests, numbers)
+	}
+	if !errors.Is(err, ErrJudgeScriptNotFound) {
+		return submission, err
+	}
+	suite, err := s.problemService.UnitTests(problem.ID)
+	if err == nil {
+		return s.gradeWithUnitTests(ctx, submission, source, problem, suite)
+	}
+	if !errors.Is(err, ErrUnitTestsNotFound) {
+		return submission, err
+	}
+
+	// Only binary scoring can stop early; partial-credit policies need every
+	// test's score.
+	stopOnFailure := problem.ScoringPolicy == ScoringBinary
+	scores := make([]float64, 0, selected)
+	version := TestDataVersion(tests)
+
+	for _, index := range order {
+		if provisional && tests[index].Final {
+			continue
+		}
+		test := tests[index]
+		run := judge.Submission{
+			Language:       submission.Language,
+			Code:           source.Code,
+			Input:          test.Input,
+			TimeLimit:      problem.TimeLimit,
+			MemoryLimit:    problem.MemoryLimit,
+			OutputLimit:    problem.OutputLimit,
+			Env:            problem.Env,
+			NetworkEnabled: problem.NetworkEnabled,
+			Files:          problem.runFiles(),
+			RunID:          submission.ID,
+			Region:         submission.JudgeRegion,
+		}
+		if test.Data != nil {
+			if run.InputRef, err = s.problemService.InputRef(version, test); err != nil {
+				return submission, err
+			}
+			if test, err = s.problemService.LoadTestData(ctx, test, problem.Checker != nil); err != nil {
+				return submission, err
+			}
+		}
+		result, err := s.judgeClient.Execute(ctx, run)
+		if err != nil {
+			return submission, err
+		}
+		submission.Timing.Compile += result.CompileTime
+
+		if result.Status.CompileFailed() {
+			submission.Verdict = VerdictCompileError
+			submission.CompileOutput = source.userOutput(result.CompileOutput)
+			if result.Status == judge.StatusCompileTimeLimitExceeded {
+				submission.Verdict = VerdictCompileTimeLimitExceeded
+			} else {
+				submission.Hint = compileHint(result.CompileOutput)
+			}
+			return submission, submission.transition(SubmissionJudged, time.Now())
+		}
+		if submission.Status == SubmissionCompiling {
+			// Recorded as soon as it happens so a submission stuck on
+			// its tests can be told from one stuck compiling
+			if err := submission.transition(SubmissionRunning, time.Now()); err != nil {
+				return submission, err
+			}
+			if err := s.submissionService.UpdateUnlessMoved(submission); err != nil {
+				return submission, err
+			}
+		}
+
+		testResult, err := s.evaluate(ctx, problem, test, result)
+		if err != nil {
+			return submission, err
+		}
+		testResult.Test = index + 1
+		if test.Sample {
+			testResult.Sample = true
+			testResult.Feedback = sampleFeedback(test, result.Stdout)
+		}
+		submission.Timing.Run += testResult.WallTime
+		submission.Timing.Checker += testResult.CheckerTime
+		if testResult.Verdict == VerdictRuntimeError && submission.Notebook {
+			testResult.ErrorLocation = notebookLocation(source.Code, result.Stderr)
+		}
+		submission.Results = append(submission.Results, testResult)
+		scores = append(scores, testResult.Score)
+		if testResult.Verdict == VerdictRuntimeError && submission.Hint == "" {
+			submission.Hint = runtimeHint(result)
+		}
+
+		if testResult.Verdict != VerdictAccepted && submission.Verdict == VerdictAccepted {
+			submission.Verdict = testResult.Verdict
+			if stopOnFailure && testResult.Verdict == VerdictPartial {
+				submission.Verdict = VerdictWrongAnswer
+			}
+		}
+		if testResult.Verdict != VerdictAccepted && stopOnFailure {
+			break
+		}
+	}
+
+	fraction := problem.ScoringPolicy.aggregate(scores)
+	if len(scores) < selected {
+		fraction = 0
+	}
+	submission.Score = fraction * problem.MaxScore
+	if submission.Verdict != VerdictAccepted && fraction > 0 {
+		submission.Verdict = VerdictPartial
+	}
+
+	return submission, submission.transition(SubmissionJudged, time.Now())
+}
+
+// evaluate turns one program run into a test result, consulting the
+// problem's checker when it has one.
+func (s *GradingService) evaluate(ctx context.Context, problem Problem, test TestCase, result judge.ExecutionResult) (TestResult, error) {
+	testResult := TestResult{
+		Verdict:  verdictFor(problem, result, test),
+		Time:     result.Time,
+		Memory:   result.Memory,
+		WallTime: result.WallTime,
+	}
+	if result.Status != judge.StatusOK {
+		return testResult, nil
+	}
+
+	if problem.Checker == nil {
+		if testResult.Verdict == VerdictAccepted {
+			testResult.Score = 1
+		}
+		return testResult, nil
+	}
+
+	start := time.Now()
+	checked, err := runChecker(ctx, s.judgeClient, problem.Checker, test, result.Stdout)
+	testResult.CheckerTime = time.Since(start).Seconds()
+	if err != nil {
+		return testResult, err
+	}
+	testResult.Score = checked.Score
+	testResult.CheckerMessage = checked.Message
+	testResult.Objective = checked.Objective
+	switch {
+	case checked.Score >= 1:
+		testResult.Verdict = VerdictAccepted
+	case checked.Score > 0:
+		testResult.Verdict = VerdictPartial
+	default:
+		testResult.Verdict = VerdictWrongAnswer
+	}
+	return testResult, nil
+}
+
+func verdictFor(problem Problem, result judge.ExecutionResult, test TestCase) Verdict {
+	return verdict.For(result.Status, func() bool {
+		return problem.outputsMatch(test.Output, result.Stdout)
+	})
+}
+
+func identityOrder(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// shuffledOrder is deterministic for a given seed so a recorded seed
+// reproduces the execution order exactly.
+func shuffledOrder(n int, seed int64) []int {
+	return mathrand.New(mathrand.NewSource(seed)).Perm(n)
+}
+
+func newTestOrderSeed() (int64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(buf[:]) >> 1), nil
+}
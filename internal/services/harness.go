@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"online-judge/internal/judge"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidHarness = errors.New("invalid harness")
+	ErrNoHarness      = errors.New("problem is judged with a harness, which has no version for this language")
+	ErrHarnessSplice  = errors.New("source cannot be spliced into the harness")
+)
+
+// A harness is a hidden program per language that reads the input, calls
+// the contestant's function and prints its result. Problems with harnesses
+// take only the function: the judge splices each submission into the
+// solution region of the harness, marked like a starter template's, so the
+// rest of the program is out of the contestant's reach.
+
+func validateHarnesses(harnesses map[string]StarterTemplate) error {
+	for language, harness := range harnesses {
+		if _, ok := judge.LookupLanguage(language); !ok {
+			return fmt.Errorf("%w: unknown language %q", ErrInvalidHarness, language)
+		}
+		_, _, ok, err := harness.region()
+		if err != nil {
+			return fmt.Errorf("%s: %w", language, err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s harness has no solution region", ErrInvalidHarness, language)
+		}
+	}
+	return nil
+}
+
+// checkSplice rejects sources the problem's harness cannot take, pointing
+// at the offending line of the source.
+func checkSplice(problem Problem, language, source string) error {
+	if len(problem.Harnesses) == 0 {
+		return nil
+	}
+	if _, ok := problem.Harnesses[language]; !ok {
+		return ErrNoHarness
+	}
+	for i, line := range strings.Split(source, "\n") {
+		if strings.Contains(line, templateRegionBegin) || strings.Contains(line, templateRegionEnd) {
+			return fmt.Errorf("%w: line %d holds a solution region marker", ErrHarnessSplice, i+1)
+		}
+	}
+	return nil
+}
+
+// splicedSource is the program judged for a submission.
+type splicedSource struct {
+	Code string
+	// offset is the number of harness lines before the submission's first
+	// line, and lines the number of lines the submission has; both are zero
+	// without a harness.
+	offset, lines int
+	sourceFile    string
+}
+
+// judgedSource splices the submission into the problem's harness for its
+// language, if the problem has harnesses.
+func judgedSource(problem Problem, submission Submission) (splicedSource, error) {
+	if len(problem.Harnesses) == 0 {
+		return splicedSource{Code: submission.Source}, nil
+	}
+	if err := checkSplice(problem, submission.Language, submission.Source); err != nil {
+		return splicedSource{}, err
+	}
+	code, offset, err := problem.Harnesses[submission.Language].fill(submission.Source)
+	if err != nil {
+		return splicedSource{}, err
+	}
+	lang, _ := judge.LookupLanguage(submission.Language)
+	return splicedSource{
+		Code:       code,
+		offset:     offset,
+		lines:      strings.Count(strings.TrimSuffix(submission.Source, "\n"), "\n") + 1,
+		sourceFile: lang.SourceFile,
+	}, nil
+}
+
+// gccSnippetLine matches the numbered source lines gcc quotes under its
+// messages, e.g. "   12 | return x;".
+var gccSnippetLine = regexp.MustCompile(`(?m)^(\s*)(\d+)( \| )`)
+
+// userOutput rewrites compiler output about the spliced program to the
+// submission's line numbers. References to lines of the harness name the
+// harness instead of a line.
+func (s splicedSource) userOutput(output string) string {
+	if s.offset == 0 {
+		return output
+	}
+	reference := regexp.MustCompile(regexp.QuoteMeta(s.sourceFile) + `:(\d+)(:\d+)?`)
+	output = reference.ReplaceAllStringFunc(output, func(match string) string {
+		parts := reference.FindStringSubmatch(match)
+		line, ok := s.userLine(parts[1])
+		if !ok {
+			return "harness"
+		}
+		return s.sourceFile + ":" + line + parts[2]
+	})
+	return gccSnippetLine.ReplaceAllStringFunc(output, func(match string) string {
+		parts := gccSnippetLine.FindStringSubmatch(match)
+		line, ok := s.userLine(parts[2])
+		if !ok {
+			return match
+		}
+		return parts[1] + strings.Repeat(" ", max(len(parts[2])-len(line), 0)) + line + parts[3]
+	})
+}
+
+// userLine maps a line of the spliced program to the submission's, if it
+// is one of the submission's lines.
+func (s splicedSource) userLine(text string) (string, bool) {
+	line, err := strconv.Atoi(text)
+	if err != nil {
+		return "", false
+	}
+	line -= s.offset
+	if line < 1 || line > s.lines {
+		return "", false
+	}
+	return strconv.Itoa(line), true
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"online-judge/internal/judge"
+	"strings"
+)
+
+// hintRule maps a fragment of compiler or runtime output to a hint.
+type hintRule struct {
+	fragment string
+	hint     string
+}
+
+// Rules are checked in order, so more specific fragments come first.
+var compileHintRules = []hintRule{
+	{"undefined reference to `main'", "missingMain"},
+	{"undefined reference to `WinMain", "missingMain"},
+	{"should be declared in a file named", "javaPublicClass"},
+	{"was not declared in this scope", "undeclared"},
+	{"undeclared (first use in this function)", "undeclared"},
+	{"cannot find symbol", "undeclared"},
+	{"expected ';'", "missingSemicolon"},
+	{"';' expected", "missingSemicolon"},
+}
+
+var runtimeHintRules = []hintRule{
+	{"IndentationError", "pythonIndentation"},
+	{"TabError", "pythonIndentation"},
+	{"SyntaxError", "pythonSyntax"},
+	{"NameError", "pythonName"},
+	{"ModuleNotFoundError", "pythonModule"},
+	{"RecursionError", "recursion"},
+	{"java.lang.StackOverflowError", "recursion"},
+	{"ArrayIndexOutOfBoundsException", "indexOutOfRange"},
+	{"IndexError", "indexOutOfRange"},
+	{"Exception in thread \"main\"", "uncaughtException"},
+	{"Caught fatal signal 11", "segfault"},
+}
+
+// hintTexts are written in English; the API localizes them per request.
+var hintTexts = map[string]string{
+	"missingMain":       "The linker could not find a main function. Every C/C++ program needs `int main()`; check its spelling and that it is not inside a class or namespace.",
+	"javaPublicClass":   "The public class must be named Main, because the source is saved as Main.java.",
+	"undeclared":        "A name is used before it is declared. Check for typos, missing #include or import lines, and variables declared inside a different block.",
+	"missingSemicolon":  "A statement is missing its semicolon. The error usually points at the line after the one missing it.",
+	"pythonIndentation": "Python uses indentation to group statements. Indent each block consistently and do not mix tabs and spaces.",
+	"pythonSyntax":      "Python could not parse the program. Look for missing colons after if/for/def, unbalanced brackets or Python 2 syntax such as print without parentheses.",
+	"pythonName":        "A name is used before it is defined. Check for typos and for variables defined only inside a branch that did not run.",
+	"pythonModule":      "The program imports a module that is not installed on the judge. Only the standard library is available.",
+	"recursion":         "The recursion went too deep. Check the base case, or rewrite the recursion as a loop.",
+	"indexOutOfRange":   "The program read or wrote past the end of a list or array. Check loop bounds and the sizes given in the input.",
+	"uncaughtException": "The program stopped with an uncaught exception; the message above names it and the line it happened on.",
+	"segfault":          "The program crashed with a segmentation fault, usually from an array index out of bounds, a null or dangling pointer, or very deep recursion.",
+	"nonZeroExit":       "The program exited with a non-zero exit code. In C/C++ main must return 0; in other languages this usually means an error was raised.",
+}
+
+// compileHint explains a compile error in beginner-friendly terms, or
+// returns "" when the output matches no known pattern.
+func compileHint(output string) string {
+	return matchHint(compileHintRules, output)
+}
+
+// runtimeHint explains a runtime error from the program's stderr and the
+// sandbox's message. Any other non-zero exit gets the generic NZEC hint.
+func runtimeHint(result judge.ExecutionResult) string {
+	if hint := matchHint(runtimeHintRules, result.Stderr+"\n"+result.Message); hint != "" {
+		return hint
+	}
+	if result.ExitCode != 0 {
+		return hintTexts["nonZeroExit"]
+	}
+	return ""
+}
+
+func matchHint(rules []hintRule, output string) string {
+	for _, rule := range rules {
+		if strings.Contains(output, rule.fragment) {
+			return hintTexts[rule.hint]
+		}
+	}
+	return ""
+}
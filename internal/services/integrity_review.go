@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"online-judge/internal/store"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrIntegrityFlagNotFound = errors.New("integrity flag not found")
+	ErrInvalidReview         = errors.New("review status must be open, confirmed or dismissed")
+)
+
+const (
+	integrityLeaseKey  = "lease:integrity-analysis"
+	integrityKeyPrefix = "integrity:contest:"
+)
+
+// Kinds of suspicious patterns the integrity analysis flags.
+const (
+	// FlagIdenticalWrongAnswers is a burst of wrong answers from different
+	// accounts with the same source, or failing the same test with the
+	// same checker message.
+	FlagIdenticalWrongAnswers = "identical_wrong_answers"
+	// FlagCorrelatedAccepts is a pair of accounts whose first accepted
+	// submissions land within seconds of each other on several problems.
+	FlagCorrelatedAccepts = "correlated_accepts"
+)
+
+type IntegrityFlagStatus string
+
+const (
+	IntegrityFlagOpen      IntegrityFlagStatus = "open"
+	IntegrityFlagConfirmed IntegrityFlagStatus = "confirmed"
+	IntegrityFlagDismissed IntegrityFlagStatus = "dismissed"
+)
+
+// IntegrityFlag is a pattern of submissions for contest judges to review.
+// Its ID is derived from the submissions involved, so analysing again
+// finds the same flag and keeps its review.
+type IntegrityFlag struct {
+	ID            string              `json:"id"`
+	ContestID     string              `json:"contestId"`
+	Kind          string              `json:"kind"`
+	ProblemID     string              `json:"problemId,omitempty"`
+	UserIDs       []string            `json:"userIds"`
+	SubmissionIDs []string            `json:"submissionIds"`
+	Detail        string              `json:"detail"`
+	Status        IntegrityFlagStatus `json:"status"`
+	Note          string              `json:"note,omitempty"`
+	ReviewedBy    string              `json:"reviewedBy,omitempty"`
+	DetectedAt    time.Time           `json:"detectedAt"`
+	ReviewedAt    *time.Time          `json:"reviewedAt,omitempty"`
+}
+
+type IntegrityConfig struct {
+	// AnswerWindow is the longest gap between identical wrong answers of
+	// one burst.
+	AnswerWindow time.Duration
+	// AcceptWindow is how close two accounts' accepted submissions on a
+	// problem must be to count as correlated.
+	AcceptWindow time.Duration
+	// MinCorrelatedAccepts is how many problems two accounts must have
+	// correlated accepts on before they are flagged.
+	MinCorrelatedAccepts int
+	Interval             time.Duration
+}
+
+// IntegrityConfigFromEnv reads INTEGRITY_ANSWER_WINDOW_SECONDS,
+// INTEGRITY_ACCEPT_WINDOW_SECONDS and INTEGRITY_MIN_CORRELATED_ACCEPTS.
+func IntegrityConfigFromEnv() IntegrityConfig {
+	return IntegrityConfig{
+		AnswerWindow:         time.Duration(intFromEnv("INTEGRITY_ANSWER_WINDOW_SECONDS", 30)) * time.Second,
+		AcceptWindow:         time.Duration(intFromEnv("INTEGRITY_ACCEPT_WINDOW_SECONDS", 5)) * time.Second,
+		MinCorrelatedAccepts: max(intFromEnv("INTEGRITY_MIN_CORRELATED_ACCEPTS", 3), 1),
+		Interval:             time.Minute,
+	}
+}
+
+// IntegrityService looks for submission patterns that suggest accounts
+// sharing code or answers during a contest. It only flags them; contest
+// judges decide. Every replica runs the analysis; a lease lets one
+// analyse per tick.
+type IntegrityService struct {
+	store             store.Store
+	contestService    *ContestService
+	submissionService *SubmissionService
+	config            IntegrityConfig
+}
+
+func NewIntegrityService(st store.Store, contestService *ContestService, submissionService *SubmissionService, config IntegrityConfig) *IntegrityService {
+	return &IntegrityService{
+		store:             st,
+		contestService:    contestService,
+		submissionService: submissionService,
+		config:            config,
+	}
+}
+
+// Run analyses running contests, and those that ended since the last
+// tick, until ctx is cancelled.
+func (s *IntegrityService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := s.store.SetNX(integrityLeaseKey, []byte("1"), s.config.Interval/2)
+		if err != nil {
+			log.Printf("Error acquiring integrity analysis lease: %v", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		now := time.Now()
+		contests, err := s.contestService.List()
+		if err != nil {
+			log.Printf("Error listing contests for integrity analysis: %v", err)
+			continue
+		}
+		for _, contest := range contests {
+			if now.Before(contest.StartTime) || now.Sub(contest.EndTime) > 2*s.config.Interval {
+				continue
+			}
+			if _, err := s.Analyze(contest.ID, now); err != nil {
+				log.Printf("Error analysing contest %s for integrity: %v", contest.ID, err)
+			}
+		}
+	}
+}
+
+// Analyze flags the contest's suspicious patterns as of now and returns
+// every flag of the contest. Flags found before keep their review.
+func (s *IntegrityService) Analyze(contestID string, now time.Time) ([]IntegrityFlag, error) {
+	if _, err := s.contestService.Get(contestID); err != nil {
+		return nil, err
+	}
+	submissions, err := s.submissionService.ListByContest(contestID)
+	if err != nil {
+		return nil, err
+	}
+	var judged []Submission
+	for _, submission := range submissions {
+		// virtual participants submit at other times than the contest
+		if !submission.Virtual && submission.Verdict != "" {
+			judged = append(judged, submission)
+		}
+	}
+
+	found := append(s.identicalWrongAnswers(judged), s.correlatedAccepts(judged)...)
+	for _, flag := range found {
+		flag.ContestID = contestID
+		flag.ID = integrityFlagID(flag)
+		key := integrityKey(contestID, flag.ID)
+		var existing IntegrityFlag
+		if err := getJSON(s.store, key, &existing); err == nil {
+			continue
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		flag.Status = IntegrityFlagOpen
+		flag.DetectedAt = now
+		if err := setJSON(s.store, key, flag, 0); err != nil {
+			return nil, err
+		}
+	}
+	return s.List(contestID, "")
+}
+
+// identicalWrongAnswers groups wrong answers by what they have in common
+// and flags bursts that span several accounts.
+func (s *IntegrityService) identicalWrongAnswers(submissions []Submission) []IntegrityFlag {
+	groups := make(map[string][]Submission)
+	var keys []string
+	add := func(key string, submission Submission) {
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], submission)
+	}
+	for _, submission := range submissions {
+		if submission.Verdict != VerdictWrongAnswer {
+			continue
+		}
+		if submission.SourceHash != "" {
+			add(submission.ProblemID+"\x00source\x00"+submission.SourceHash, submission)
+		}
+		if test, message, ok := firstWrongAnswer(submission); ok {
+			add(fmt.Sprintf("%s\x00answer\x00%d\x00%s", submission.ProblemID, test, message), submission)
+		}
+	}
+
+	var flags []IntegrityFlag
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		group := groups[key]
+		detail := "identical source"
+		if strings.Contains(key, "\x00answer\x00") {
+			test, _, _ := firstWrongAnswer(group[0])
+			detail = fmt.Sprintf("same wrong answer on test %d", test)
+		}
+		// submissions are oldest first; a burst ends at the first gap
+		// longer than the window
+		start := 0
+		for i := 1; i <= len(group); i++ {
+			if i < len(group) && group[i].CreatedAt.Sub(group[i-1].CreatedAt) <= s.config.AnswerWindow {
+				continue
+			}
+			burst := group[start:i]
+			start = i
+			flag := IntegrityFlag{Kind: FlagIdenticalWrongAnswers, ProblemID: burst[0].ProblemID}
+			for _, submission := range burst {
+				flag.SubmissionIDs = append(flag.SubmissionIDs, submission.ID)
+				flag.UserIDs = appendUnique(flag.UserIDs, submission.UserID)
+			}
+			if len(flag.UserIDs) < 2 {
+				continue
+			}
+			id := integrityFlagID(flag)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			flag.Detail = fmt.Sprintf("%d wrong answers from %d accounts within %s: %s",
+				len(burst), len(flag.UserIDs), burst[len(burst)-1].CreatedAt.Sub(burst[0].CreatedAt).Round(time.Second), detail)
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// firstWrongAnswer is the first test the submission failed and the
+// checker's message about it. Without a message every wrong answer on a
+// test would look the same.
+func firstWrongAnswer(submission Submission) (int, string, bool) {
+	for _, result := range submission.Results {
+		if result.Verdict == VerdictAccepted {
+			continue
+		}
+		if result.Verdict != VerdictWrongAnswer || result.CheckerMessage == "" {
+			return 0, "", false
+		}
+		return result.Test, result.CheckerMessage, true
+	}
+	return 0, "", false
+}
+
+// correlatedAccepts flags pairs of accounts whose first accepted
+// submissions are within the accept window on enough problems.
+func (s *IntegrityService) correlatedAccepts(submissions []Submission) []IntegrityFlag {
+	type accept struct {
+		userID       string
+		submissionID string
+		at           time.Time
+	}
+	byProblem := make(map[string][]accept)
+	first := make(map[string]bool)
+	for _, submission := range submissions {
+		key := submission.ProblemID + "\x00" + submission.UserID
+		if submission.Verdict != VerdictAccepted || first[key] {
+			continue
+		}
+		first[key] = true
+		byProblem[submission.ProblemID] = append(byProblem[submission.ProblemID], accept{submission.UserID, submission.ID, submission.CreatedAt})
+	}
+
+	type pair struct{ a, b string }
+	correlated := make(map[pair][]string)
+	var pairs []pair
+	problems := make([]string, 0, len(byProblem))
+	for problemID := range byProblem {
+		problems = append(problems, problemID)
+	}
+	sort.Strings(problems)
+	for _, problemID := range problems {
+		accepts := byProblem[problemID]
+		for i := range accepts {
+			for j := i + 1; j < len(accepts) && accepts[j].at.Sub(accepts[i].at) <= s.config.AcceptWindow; j++ {
+				p := pair{accepts[i].userID, accepts[j].userID}
+				if p.b < p.a {
+					p.a, p.b = p.b, p.a
+				}
+				if _, ok := correlated[p]; !ok {
+					pairs = append(pairs, p)
+				}
+				correlated[p] = append(correlated[p], accepts[i].submissionID, accepts[j].submissionID)
+			}
+		}
+	}
+
+	var flags []IntegrityFlag
+	for _, p := range pairs {
+		submissionIDs := correlated[p]
+		if problems := len(submissionIDs) / 2; problems >= s.config.MinCorrelatedAccepts {
+			flags = append(flags, IntegrityFlag{
+				Kind:          FlagCorrelatedAccepts,
+				UserIDs:       []string{p.a, p.b},
+				SubmissionIDs: submissionIDs,
+				Detail:        fmt.Sprintf("first accepted within %s of each other on %d problems", s.config.AcceptWindow, problems),
+			})
+		}
+	}
+	return flags
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func integrityFlagID(flag IntegrityFlag) string {
+	ids := append([]string{}, flag.SubmissionIDs...)
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(flag.Kind + "\x00" + strings.Join(ids, ",")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func integrityKey(contestID, flagID string) string {
+	return integrityKeyPrefix + contestID + ":" + flagID
+}
+
+// List returns the contest's flags, oldest first, optionally only those
+// with the given status.
+func (s *IntegrityService) List(contestID string, status IntegrityFlagStatus) ([]IntegrityFlag, error) {
+	all, err := listJSON[IntegrityFlag](s.store, integrityKeyPrefix+contestID+":")
+	if err != nil {
+		return nil, err
+	}
+	flags := make([]IntegrityFlag, 0, len(all))
+	for _, flag := range all {
+		if status == "" || flag.Status == status {
+			flags = append(flags, flag)
+		}
+	}
+	sort.SliceStable(flags, func(i, j int) bool {
+		return flags[i].DetectedAt.Before(flags[j].DetectedAt)
+	})
+	return flags, nil
+}
+
+// Review records a contest judge's decision on a flag. A flag may be
+// reviewed again, e.g. to reopen it.
+func (s *IntegrityService) Review(contestID, flagID, reviewer string, status IntegrityFlagStatus, note string) (IntegrityFlag, error) {
+	switch status {
+	case IntegrityFlagConfirmed, IntegrityFlagDismissed, IntegrityFlagOpen:
+	default:
+		return IntegrityFlag{}, ErrInvalidReview
+	}
+	key := integrityKey(contestID, flagID)
+	var flag IntegrityFlag
+	if err := getJSON(s.store, key, &flag); errors.Is(err, store.ErrNotFound) {
+		return IntegrityFlag{}, ErrIntegrityFlagNotFound
+	} else if err != nil {
+		return IntegrityFlag{}, err
+	}
+	now := time.Now()
+	flag.Status = status
+	flag.Note = note
+	flag.ReviewedBy = reviewer
+	flag.ReviewedAt = &now
+	if err := setJSON(s.store, key, flag, 0); err != nil {
+		return IntegrityFlag{}, err
+	}
+	return flag, nil
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"online-judge/internal/judge"
+	"online-judge/internal/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrJudgeScriptNotFound = errors.New("judge script not found")
+	ErrJudgeScriptFailed   = errors.New("judge script failed")
+	ErrInvalidJudgeScript  = errors.New("judge script language is not supported or its limits are out of range")
+)
+
+// JudgeScript is a setter-provided program that takes over evaluation of a
+// problem's submissions, for tasks that do not fit running a program on
+// each test, such as compile-time challenges or multi-stage pipelines. It
+// runs once per submission in the judge sandbox with this layout in its
+// working directory:
+//
+//	submission.src   the submitted source
+//	submission.json  {"id", "language", "timeLimit", "memoryLimit", "tests"}
+//	test<N>.in       input of test N, for each N listed in tests
+//	test<N>.ans      expected output of test N
+//
+// and prints a JudgeScriptVerdict as JSON on stdout. The submission's own
+// limits are for the script to enforce; the script runs under its own.
+type JudgeScript struct {
+	ProblemID string `json:"problemId"`
+	Language  string `json:"language" binding:"required"`
+	Source    string `json:"source" binding:"required"`
+	// TimeLimit and MemoryLimit bound the script and everything it starts;
+	// zero means the defaults.
+	TimeLimit   float64   `json:"timeLimit,omitempty"`
+	MemoryLimit int       `json:"memoryLimit,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Limits for judge scripts, which compile and run submissions themselves.
+const (
+	judgeScriptTimeLimit    = 60.0
+	judgeScriptMaxTimeLimit = 600.0
+	judgeScriptMemoryLimit  = 1024 * 1024
+	judgeScriptProcesses    = 64
+)
+
+// limits returns the script's time and memory limits with defaults applied.
+func (j JudgeScript) limits() (float64, int) {
+	timeLimit, memoryLimit := j.TimeLimit, j.MemoryLimit
+	if timeLimit == 0 {
+		timeLimit = judgeScriptTimeLimit
+	}
+	if memoryLimit == 0 {
+		memoryLimit = judgeScriptMemoryLimit
+	}
+	return timeLimit, memoryLimit
+}
+
+// judgeScriptInfo is submission.json.
+type judgeScriptInfo struct {
+	ID          string  `json:"id"`
+	Language    string  `json:"language"`
+	TimeLimit   float64 `json:"timeLimit"`
+	MemoryLimit int     `json:"memoryLimit"`
+	Tests       []int   `json:"tests"`
+}
+
+// JudgeScriptVerdict is what a judge script reports. Score is the fraction
+// of the problem's points awarded, between 0 and 1; Tests are optional.
+type JudgeScriptVerdict struct {
+	Verdict       Verdict           `json:"verdict"`
+	Score         float64           `json:"score"`
+	Message       string            `json:"message,omitempty"`
+	CompileOutput string            `json:"compileOutput,omitempty"`
+	Tests         []JudgeScriptTest `json:"tests,omitempty"`
+}
+
+// JudgeScriptTest is a judge script's outcome for one test.
+type JudgeScriptTest struct {
+	Test    int     `json:"test"`
+	Verdict Verdict `json:"verdict"`
+	Score   float64 `json:"score"`
+	Time    float64 `json:"time"`
+	Memory  int     `json:"memory"`
+	Message string  `json:"message,omitempty"`
+}
+
+func knownVerdict(v Verdict) bool {
+	switch v {
+	case VerdictAccepted, VerdictPartial, VerdictWrongAnswer, VerdictTimeLimitExceeded,
+		VerdictMemoryLimitExceeded, VerdictOutputLimitExceeded, VerdictDiskQuotaExceeded, VerdictRuntimeError, VerdictCompileError, VerdictCompileTimeLimitExceeded, VerdictInternalError,
+		VerdictSecurityViolation:
+		return true
+	}
+	return false
+}
+
+const judgeScriptKeyPrefix = "judgescript:problem:"
+
+// SetJudgeScript attaches a judge script to the problem, replacing any
+// previous one. Submissions graded from then on are evaluated by it.
+func (s *ProblemService) SetJudgeScript(script JudgeScript) (JudgeScript, error) {
+	if _, err := s.Get(script.ProblemID); err != nil {
+		return JudgeScript{}, err
+	}
+	if _, ok := judge.LookupLanguage(script.Language); !ok ||
+		script.TimeLimit < 0 || script.TimeLimit > judgeScriptMaxTimeLimit || script.MemoryLimit < 0 {
+		return JudgeScript{}, ErrInvalidJudgeScript
+	}
+	script.UpdatedAt = time.Now()
+	if err := setJSON(s.store, judgeScriptKeyPrefix+script.ProblemID, script, 0); err != nil {
+		return JudgeScript{}, err
+	}
+	return script, nil
+}
+
+// JudgeScript returns the problem's judge script, or ErrJudgeScriptNotFound
+// if its submissions are judged test by test.
+func (s *ProblemService) JudgeScript(problemID string) (JudgeScript, error) {
+	var script JudgeScript
+	if err := getJSON(s.store, judgeScriptKeyPrefix+problemID, &script); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return JudgeScript{}, ErrJudgeScriptNotFound
+		}
+		return JudgeScript{}, err
+	}
+	return script, nil
+}
+
+// DeleteJudgeScript returns the problem to test-by-test judging.
+func (s *ProblemService) DeleteJudgeScript(problemID string) error {
+	if _, err := s.JudgeScript(problemID); err != nil {
+		return err
+	}
+	return s.store.Delete(judgeScriptKeyPrefix + problemID)
+}
+
+// JudgeScriptsSnapshot returns the backup section holding every problem's
+// judge script.
+func (s *ProblemService) JudgeScriptsSnapshot() SnapshotSection {
+	return judgeScriptsSnapshot{store: s.store}
+}
+
+type judgeScriptsSnapshot struct {
+	store store.Store
+}
+
+func (j judgeScriptsSnapshot) SnapshotName() string {
+	return "judgeScripts"
+}
+
+func (j judgeScriptsSnapshot) ExportSnapshot() (json.RawMessage, error) {
+	scripts, err := listJSON[JudgeScript](j.store, judgeScriptKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(scripts)
+}
+
+func (j judgeScriptsSnapshot) ImportSnapshot(data json.RawMessage) error {
+	var scripts []JudgeScript
+	if err := json.Unmarshal(data, &scripts); err != nil {
+		return err
+	}
+	return replaceJSON(j.store, judgeScriptKeyPrefix, scripts, func(s JudgeScript) string { return s.ProblemID })
+}
+
+// gradeWithScript evaluates the submission with the problem's judge script
+// on the given tests, numbered from 1 in the problem's order. The script
+// gets source, the submission spliced into any harness, as submission.src.
+func (s *GradingService) gradeWithScript(ctx context.Context, submission Submission, source splicedSource, problem Problem, script JudgeScript, tests []TestCase, numbers []int) (Submission, error) {
+	info, err := json.Marshal(judgeScriptInfo{
+		ID:          submission.ID,
+		Language:    submission.Language,
+		TimeLimit:   problem.TimeLimit,
+		MemoryLimit: problem.MemoryLimit,
+		Tests:       numbers,
+	})
+	if err != nil {
+		return submission, err
+	}
+	files := map[string]string{
+		"submission.src":  source.Code,
+		"submission.json": string(info),
+	}
+	for i, test := range tests {
+		if test.Data != nil {
+			if test, err = s.problemService.LoadTestData(ctx, test, true); err != nil {
+				return submission, err
+			}
+		}
+		name := "test" + strconv.Itoa(numbers[i])
+		files[name+".in"] = test.Input
+		files[name+".ans"] = test.Output
+	}
+
+	timeLimit, memoryLimit := script.limits()
+	if submission.Status == SubmissionCompiling {
+		if err := submission.transition(SubmissionRunning, time.Now()); err != nil {
+			return submission, err
+		}
+		if err := s.submissionService.UpdateUnlessMoved(submission); err != nil {
+			return submission, err
+		}
+	}
+	start := time.Now()
+	result, err := s.judgeClient.Execute(ctx, judge.Submission{
+		Language:     script.Language,
+		Code:         script.Source,
+		TimeLimit:    timeLimit,
+		MemoryLimit:  memoryLimit,
+		MaxProcesses: judgeScriptProcesses,
+		// the script runs the submission, which may need the network
+		NetworkEnabled: problem.NetworkEnabled,
+		CacheBinary:    true,
+		Files:          files,
+		RunID:          submission.ID,
+	})
+	if err != nil {
+		return submission, err
+	}
+	submission.Timing.Checker = time.Since(start).Seconds()
+	if result.Status != judge.StatusOK {
+		return submission, fmt.Errorf("%w: %s %s", ErrJudgeScriptFailed, result.Status, result.CompileOutput)
+	}
+	verdict, err := parseJudgeScriptOutput(result.Stdout)
+	if err != nil {
+		return submission, err
+	}
+
+	submission.Verdict = verdict.Verdict
+	submission.Score = verdict.Score * problem.MaxScore
+	submission.CompileOutput = source.userOutput(verdict.CompileOutput)
+	if verdict.Verdict == VerdictCompileError {
+		submission.Hint = compileHint(verdict.CompileOutput)
+	}
+	sample := make(map[int]bool, len(tests))
+	for i, test := range tests {
+		sample[numbers[i]] = test.Sample
+	}
+	for _, test := range verdict.Tests {
+		submission.Results = append(submission.Results, TestResult{
+			Test:           test.Test,
+			Verdict:        test.Verdict,
+			Score:          test.Score,
+			CheckerMessage: test.Message,
+			Time:           test.Time,
+			Memory:         test.Memory,
+			Sample:         sample[test.Test],
+		})
+	}
+	return submission, submission.transition(SubmissionJudged, time.Now())
+}
+
+func parseJudgeScriptOutput(stdout string) (JudgeScriptVerdict, error) {
+	var verdict JudgeScriptVerdict
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stdout)), &verdict); err != nil {
+		return JudgeScriptVerdict{}, fmt.Errorf("%w: output is not a verdict: %v", ErrJudgeScriptFailed, err)
+	}
+	if !knownVerdict(verdict.Verdict) {
+		return JudgeScriptVerdict{}, fmt.Errorf("%w: unknown verdict %q", ErrJudgeScriptFailed, verdict.Verdict)
+	}
+	if verdict.Score < 0 || verdict.Score > 1 {
+		return JudgeScriptVerdict{}, fmt.Errorf("%w: score %v is not between 0 and 1", ErrJudgeScriptFailed, verdict.Score)
+	}
+	for _, test := range verdict.Tests {
+		if !knownVerdict(test.Verdict) || test.Score < 0 || test.Score > 1 {
+			return JudgeScriptVerdict{}, fmt.Errorf("%w: invalid result for test %d", ErrJudgeScriptFailed, test.Test)
+		}
+	}
+	return verdict, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"log"
+	"online-judge/internal/store"
+	"strconv"
+	"time"
+)
+
+const (
+	judgingSlotPrefix = "judging:user:"
+	// judgingSlotTTL bounds how long a slot outlives a replica that crashed
+	// mid-grading. It must exceed the longest expected grading time.
+	judgingSlotTTL = 15 * time.Minute
+	// DefaultMaxJudgingPerUser applies when MAX_JUDGING_PER_USER is not set.
+	DefaultMaxJudgingPerUser = 2
+)
+
+// JudgingLimiter caps how many submissions of one user are judged at the
+// same time across all replicas. Each user has a fixed number of slots held
+// as expiring keys in the shared store.
+type JudgingLimiter struct {
+	store   store.Store
+	perUser int
+}
+
+// NewJudgingLimiter returns a limiter allowing perUser concurrent
+// submissions per user. A limit of zero or less disables limiting.
+func NewJudgingLimiter(st store.Store, perUser int) *JudgingLimiter {
+	return &JudgingLimiter{store: st, perUser: perUser}
+}
+
+// MaxJudgingPerUserFromEnv reads MAX_JUDGING_PER_USER, falling back to
+// DefaultMaxJudgingPerUser when it is unset or invalid. Zero disables the
+// limit.
+func MaxJudgingPerUserFromEnv() int {
+	return intFromEnv("MAX_JUDGING_PER_USER", DefaultMaxJudgingPerUser)
+}
+
+// Acquire takes a free slot for the user. When every slot is taken it
+// returns false; otherwise the returned function releases the slot.
+func (l *JudgingLimiter) Acquire(userID string) (func(), bool, error) {
+	if l == nil || l.perUser <= 0 {
+		return func() {}, true, nil
+	}
+
+	for slot := 0; slot < l.perUser; slot++ {
+		key := judgingSlotPrefix + userID + ":" + strconv.Itoa(slot)
+		ok, err := l.store.SetNX(key, []byte("1"), judgingSlotTTL)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return func() {
+				if err := l.store.Delete(key); err != nil {
+					log.Printf("Error releasing judging slot %s: %v", key, err)
+				}
+			}, true, nil
+		}
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"online-judge/internal/auth"
+	"online-judge/internal/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMailNoTag          = errors.New("the recipient address has no user and problem tag")
+	ErrMailUnknownSender  = errors.New("the sender address is not registered for this user")
+	ErrMailNoAttachment   = errors.New("the message has no source file attachment")
+	ErrMailUnknownFileExt = errors.New("the attachment's file extension does not name a supported language")
+)
+
+const (
+	mailMessageKeyPrefix = "mail:message:"
+	// mailMessageTTL bounds how long a processed message is remembered, so a
+	// replica that retrieved it before another deleted it does not submit it
+	// twice.
+	mailMessageTTL        = 7 * 24 * time.Hour
+	maxMailAttachmentSize = 1 << 20
+)
+
+var mailLanguages = map[string]string{
+	".c":    "c",
+	".cpp":  "cpp",
+	".cc":   "cpp",
+	".java": "java",
+	".py":   "python",
+	// notebooks are judged as Python; see submit
+	".ipynb": "python",
+}
+
+type MailIntakeConfig struct {
+	// POP3Addr is the host:port of a POP3 server speaking implicit TLS.
+	POP3Addr string
+	Username string
+	Password string
+	// Senders maps user IDs to the only address they may submit from.
+	Senders  map[string]string
+	Interval time.Duration
+}
+
+// MailIntakeConfigFromEnv reads MAIL_INTAKE_POP3_ADDR, MAIL_INTAKE_USERNAME,
+// MAIL_INTAKE_PASSWORD, MAIL_INTAKE_SENDERS (comma-separated user=address
+// pairs) and MAIL_INTAKE_INTERVAL in seconds. The returned bool is false
+// when MAIL_INTAKE_POP3_ADDR is not set.
+func MailIntakeConfigFromEnv() (MailIntakeConfig, bool) {
+	config := MailIntakeConfig{
+		POP3Addr: os.Getenv("MAIL_INTAKE_POP3_ADDR"),
+		Username: os.Getenv("MAIL_INTAKE_USERNAME"),
+		Password: os.Getenv("MAIL_INTAKE_PASSWORD"),
+		Senders:  make(map[string]string),
+		Interval: time.Duration(intFromEnv("MAIL_INTAKE_INTERVAL", 30)) * time.Second,
+	}
+	for _, pair := range strings.Split(os.Getenv("MAIL_INTAKE_SENDERS"), ",") {
+		userID, address, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && userID != "" && address != "" {
+			config.Senders[userID] = strings.ToLower(address)
+		}
+	}
+	return config, config.POP3Addr != ""
+}
+
+// MailIntake turns emails in a mailbox into submissions, for exam rooms
+// whose network reaches a mail relay but not the web UI. The recipient's
+// sub-address names the submitter and problem: judge+alice+12@example.edu
+// submits to problem 12 as alice, judge+alice+3+12@example.edu to problem
+// 12 of contest 3. The first attachment is the source; its extension picks
+// the language. Mail is accepted only from the address registered for the
+// user, so the mailbox should only receive mail from the exam relay.
+type MailIntake struct {
+	store             store.Store
+	submissionService *SubmissionService
+	replies           *EmailChannel
+	config            MailIntakeConfig
+}
+
+// NewMailIntake creates the intake. replies may be nil, in which case
+// senders get no confirmation.
+func NewMailIntake(st store.Store, submissionService *SubmissionService, replies *EmailChannel, config MailIntakeConfig) *MailIntake {
+	return &MailIntake{store: st, submissionService: submissionService, replies: replies, config: config}
+}
+
+// Run polls the mailbox until ctx is cancelled.
+func (m *MailIntake) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.poll(); err != nil {
+			log.Printf("Error polling submission mailbox: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll handles every message in the mailbox and deletes it. Rejected
+// messages are answered with the reason instead of being retried.
+func (m *MailIntake) poll() error {
+	conn, err := dialPOP3(m.config.POP3Addr, m.config.Username, m.config.Password)
+	if err != nil {
+		return err
+	}
+	numbers, err := conn.list()
+	if err != nil {
+		conn.quit()
+		return err
+	}
+	for _, number := range numbers {
+		raw, err := conn.retrieve(number)
+		if err != nil {
+			conn.quit()
+			return err
+		}
+		if err := m.handle(raw); err != nil {
+			// leave the message for the next poll
+			log.Printf("Error handling submission email: %v", err)
+			continue
+		}
+		if err := conn.delete(number); err != nil {
+			conn.quit()
+			return err
+		}
+	}
+	return conn.quit()
+}
+
+// handle processes one message. It returns an error only for failures worth
+// retrying; a rejected submission is answered and counts as handled.
+func (m *MailIntake) handle(raw []byte) error {
+	sum := sha256.Sum256(raw)
+	key := mailMessageKeyPrefix + hex.EncodeToString(sum[:])
+	claimed, err := m.store.SetNX(key, []byte("1"), mailMessageTTL)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		log.Printf("Dropping unparseable submission email: %v", err)
+		return nil
+	}
+	from, err := mail.ParseAddress(message.Header.Get("From"))
+	if err != nil {
+		log.Printf("Dropping submission email without sender: %v", err)
+		return nil
+	}
+
+	submission, err := m.submit(strings.ToLower(from.Address), message)
+	if err != nil && !m.rejection(err) {
+		if releaseErr := m.store.Delete(key); releaseErr != nil {
+			log.Printf("Error releasing submission email %s: %v", key, releaseErr)
+		}
+		return err
+	}
+	m.reply(from.Address, message.Header.Get("Subject"), submission, err)
+	return nil
+}
+
+func (m *MailIntake) submit(sender string, message *mail.Message) (Submission, error) {
+	req, err := mailTag(message.Header)
+	if err != nil {
+		return Submission{}, err
+	}
+	if address, ok := m.config.Senders[req.UserID]; !ok || address != sender {
+		return Submission{}, ErrMailUnknownSender
+	}
+	name, source, err := mailAttachment(message)
+	if err != nil {
+		return Submission{}, err
+	}
+	language, ok := mailLanguages[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return Submission{}, ErrMailUnknownFileExt
+	}
+	req.Language = language
+	req.Source = string(source)
+	if strings.EqualFold(filepath.Ext(name), ".ipynb") {
+		req.Format = SubmissionFormatNotebook
+	}
+
+	return m.submissionService.Create(auth.Principal{UserID: req.UserID, Role: auth.RoleUser}, req)
+}
+
+// rejection reports whether err is the submitter's fault, so the message is
+// answered rather than retried.
+func (m *MailIntake) rejection(err error) bool {
+	for _, target := range []error{
+		ErrMailNoTag, ErrMailUnknownSender, ErrMailNoAttachment, ErrMailUnknownFileExt,
+		ErrContestNotFound, ErrContestNotRunning, ErrNotRegistered, ErrProblemNotInContest,
+		ErrEmptySource, ErrSeatIPMismatch, ErrProblemNotFound, ErrNoHarness, ErrHarnessSplice,
+		ErrInvalidNotebook,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MailIntake) reply(to, subject string, submission Submission, err error) {
+	if m.replies == nil {
+		return
+	}
+	notification := Notification{Title: "Re: " + subject}
+	if err != nil {
+		notification.Body = "Your submission was not accepted: " + err.Error() + "."
+	} else {
+		notification.Body = fmt.Sprintf("Submission %s for problem %s was received and queued for judging.", submission.ID, submission.ProblemID)
+	}
+	if err := m.replies.Send(NotificationSubscription{Email: to}, notification); err != nil {
+		log.Printf("Error replying to submission email from %s: %v", to, err)
+	}
+}
+
+// mailTag reads the user, contest and problem from the first recipient
+// address with a sub-address.
+func mailTag(header mail.Header) (SubmissionRequest, error) {
+	for _, field := range []string{"Delivered-To", "X-Original-To", "To", "Cc"} {
+		addresses, err := mail.ParseAddressList(header.Get(field))
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			local, _, _ := strings.Cut(address.Address, "@")
+			_, tag, ok := strings.Cut(local, "+")
+			if !ok {
+				continue
+			}
+			parts := strings.Split(tag, "+")
+			switch {
+			case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+				return SubmissionRequest{UserID: parts[0], ProblemID: parts[1]}, nil
+			case len(parts) == 3 && parts[0] != "" && parts[1] != "" && parts[2] != "":
+				return SubmissionRequest{UserID: parts[0], ContestID: parts[1], ProblemID: parts[2]}, nil
+			}
+		}
+	}
+	return SubmissionRequest{}, ErrMailNoTag
+}
+
+// mailAttachment returns the file name and content of the first attachment,
+// looking into nested multipart bodies.
+func mailAttachment(message *mail.Message) (string, []byte, error) {
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return "", nil, ErrMailNoAttachment
+	}
+	return findAttachment(multipart.NewReader(message.Body, params["boundary"]))
+}
+
+func findAttachment(reader *multipart.Reader) (string, []byte, error) {
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return "", nil, ErrMailNoAttachment
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		mediaType, params, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if strings.HasPrefix(mediaType, "multipart/") {
+			if name, content, err := findAttachment(multipart.NewReader(part, params["boundary"])); err == nil {
+				return name, content, nil
+			}
+			continue
+		}
+		name := part.FileName()
+		if name == "" {
+			continue
+		}
+		var body io.Reader = part
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		content, err := io.ReadAll(io.LimitReader(body, maxMailAttachmentSize))
+		if err != nil {
+			return "", nil, err
+		}
+		return name, content, nil
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"online-judge/internal/store"
+	"time"
+)
+
+const maintenanceKey = "maintenance:dispatch"
+
+// MaintenanceStatus tells users whether judging is paused. Submissions are
+// still accepted while it is and wait in the queue.
+type MaintenanceStatus struct {
+	Paused bool `json:"paused"`
+	// Message is the admin's note for users, such as when judging resumes.
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	Queued  int64      `json:"queued"`
+}
+
+// MaintenanceService pauses the dispatch of queued submissions to the judge
+// workers on every replica, so workers can be upgraded without turning
+// submissions away. Gradings already running finish.
+type MaintenanceService struct {
+	store             store.Store
+	submissionService *SubmissionService
+}
+
+func NewMaintenanceService(st store.Store, submissionService *SubmissionService) *MaintenanceService {
+	return &MaintenanceService{store: st, submissionService: submissionService}
+}
+
+// Pause stops dispatch, or replaces the message if it is already stopped.
+func (s *MaintenanceService) Pause(userID, message string) (MaintenanceStatus, error) {
+	status, err := s.Status()
+	if err != nil {
+		return MaintenanceStatus{}, err
+	}
+	if !status.Paused {
+		now := time.Now().UTC()
+		status.Since = &now
+	}
+	status.Paused = true
+	status.Message = message
+	if err := setJSON(s.store, maintenanceKey, status, 0); err != nil {
+		return MaintenanceStatus{}, err
+	}
+	log.Printf("Judging paused by %s", userID)
+	return status, nil
+}
+
+// Resume restarts dispatch; the queue drains in order.
+func (s *MaintenanceService) Resume(userID string) (MaintenanceStatus, error) {
+	if err := s.store.Delete(maintenanceKey); err != nil {
+		return MaintenanceStatus{}, err
+	}
+	log.Printf("Judging resumed by %s", userID)
+	return s.Status()
+}
+
+// Status returns whether dispatch is paused and how many submissions wait.
+func (s *MaintenanceService) Status() (MaintenanceStatus, error) {
+	var status MaintenanceStatus
+	if err := getJSON(s.store, maintenanceKey, &status); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return MaintenanceStatus{}, err
+	}
+	queued, err := s.submissionService.QueueLength()
+	if err != nil {
+		return MaintenanceStatus{}, err
+	}
+	status.Queued = queued
+	return status, nil
+}
+
+// Paused reports whether dispatch is paused.
+func (s *MaintenanceService) Paused() (bool, error) {
+	_, err := s.store.Get(maintenanceKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidNotebook = errors.New("invalid notebook")
+
+// SubmissionFormatNotebook marks a submission whose source is a Jupyter
+// notebook (.ipynb) rather than a program.
+const SubmissionFormatNotebook = "ipynb"
+
+// NotebookSettings decide which cells of a notebook submission are judged.
+// Problems without settings judge every code cell.
+type NotebookSettings struct {
+	// Tag, when set, limits judging to code cells carrying the tag, so
+	// exploration cells can stay in the notebook.
+	Tag string `json:"tag,omitempty"`
+}
+
+// notebook is the part of the .ipynb format the extraction reads.
+type notebook struct {
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"`
+		Metadata struct {
+			Tags []string `json:"tags"`
+		} `json:"metadata"`
+	} `json:"cells"`
+}
+
+// notebookCellHeader starts each cell's code in an extracted script, so
+// error locations can be mapped back to cells.
+const notebookCellHeader = "# %% cell "
+
+// extractNotebook turns a Python notebook into a script of its selected
+// code cells in order. IPython magics and shell escapes do not run outside
+// Jupyter and are commented out.
+func extractNotebook(data string, settings *NotebookSettings) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal([]byte(data), &nb); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidNotebook, err)
+	}
+	if name := nb.Metadata.LanguageInfo.Name; name != "" && name != "python" {
+		return "", fmt.Errorf("%w: the kernel language is %s, not python", ErrInvalidNotebook, name)
+	}
+
+	var script strings.Builder
+	for i, cell := range nb.Cells {
+		if cell.CellType != "code" {
+			continue
+		}
+		if settings != nil && settings.Tag != "" && !slices.Contains(cell.Metadata.Tags, settings.Tag) {
+			continue
+		}
+		source, err := cellSource(cell.Source)
+		if err != nil {
+			return "", fmt.Errorf("%w: cell %d: %v", ErrInvalidNotebook, i+1, err)
+		}
+		script.WriteString(notebookCellHeader + strconv.Itoa(i+1) + "\n")
+		for _, line := range strings.Split(strings.TrimRight(source, "\n"), "\n") {
+			if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "!") {
+				line = "# " + line
+			}
+			script.WriteString(line + "\n")
+		}
+	}
+	if script.Len() == 0 {
+		return "", fmt.Errorf("%w: no code cells to judge", ErrInvalidNotebook)
+	}
+	return script.String(), nil
+}
+
+// cellSource reads a cell's source, which notebooks store either as one
+// string or as a list of lines.
+func cellSource(raw json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// pythonTraceLine matches the lines of a Python traceback or syntax error
+// that point into the judged script.
+var pythonTraceLine = regexp.MustCompile(`File "(?:[^"]*/)?main\.py", line (\d+)`)
+
+// notebookLocation maps the innermost script line in a Python error to its
+// notebook cell, e.g. "cell 4, line 2", or returns "" when stderr points
+// at none.
+func notebookLocation(script, stderr string) string {
+	matches := pythonTraceLine.FindAllStringSubmatch(stderr, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	target, _ := strconv.Atoi(matches[len(matches)-1][1])
+	cell, header := "", 0
+	for i, line := range strings.Split(script, "\n") {
+		if i+1 >= target {
+			break
+		}
+		if strings.HasPrefix(line, notebookCellHeader) {
+			cell, header = strings.TrimPrefix(line, notebookCellHeader), i+1
+		}
+	}
+	if cell == "" {
+		return ""
+	}
+	return "cell " + cell + ", line " + strconv.Itoa(target-header)
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SherClockHolmes/webpush-go"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrInvalidPushEndpoint = errors.New("push endpoint must be an https URL of a known push service")
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPConfigFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and
+// SMTP_FROM. The returned bool is false when SMTP_HOST is not set.
+func SMTPConfigFromEnv() (SMTPConfig, bool) {
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	return config, config.Host != ""
+}
+
+type EmailChannel struct {
+	config SMTPConfig
+}
+
+func NewEmailChannel(config SMTPConfig) *EmailChannel {
+	return &EmailChannel{config: config}
+}
+
+func (ch *EmailChannel) Name() string {
+	return "email"
+}
+
+// Send delivers to confirmed addresses only; the confirmation message
+// itself is the one notification sent to an unconfirmed address.
+func (ch *EmailChannel) Send(subscription NotificationSubscription, notification Notification) error {
+	if subscription.Email == "" {
+		return nil
+	}
+	if !subscription.EmailConfirmed && notification.Event != EventEmailConfirmation {
+		return nil
+	}
+
+	var auth smtp.Auth
+	if ch.config.Username != "" {
+		auth = smtp.PlainAuth("", ch.config.Username, ch.config.Password, ch.config.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + ch.config.From,
+		"To: " + subscription.Email,
+		"Subject: " + sanitizeHeader(notification.Title),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		notification.Body,
+	}, "\r\n")
+
+	addr := net.JoinHostPort(ch.config.Host, ch.config.Port)
+	return smtp.SendMail(addr, auth, ch.config.From, []string{subscription.Email}, []byte(msg))
+}
+
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+type WebPushConfig struct {
+	Subscriber      string
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	TTL             int
+}
+
+// WebPushConfigFromEnv reads VAPID_SUBSCRIBER, VAPID_PUBLIC_KEY and
+// VAPID_PRIVATE_KEY. The returned bool is false when no key pair is set.
+func WebPushConfigFromEnv() (WebPushConfig, bool) {
+	config := WebPushConfig{
+		Subscriber:      os.Getenv("VAPID_SUBSCRIBER"),
+		VAPIDPublicKey:  os.Getenv("VAPID_PUBLIC_KEY"),
+		VAPIDPrivateKey: os.Getenv("VAPID_PRIVATE_KEY"),
+		TTL:             3600,
+	}
+	return config, config.VAPIDPublicKey != "" && config.VAPIDPrivateKey != ""
+}
+
+// pushServiceHosts are the push services browsers hand out endpoints
+// for. An entry starting with a dot also matches its subdomains.
+var pushServiceHosts = []string{
+	"fcm.googleapis.com",
+	"updates.push.services.mozilla.com",
+	"web.push.apple.com",
+	".push.apple.com",
+	".notify.windows.com",
+}
+
+// ValidatePushEndpoint accepts only https URLs on the default port of a
+// known push service, since the server POSTs to whatever URL a user
+// subscribes with.
+func ValidatePushEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || (u.Port() != "" && u.Port() != "443") {
+		return ErrInvalidPushEndpoint
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range pushServiceHosts {
+		if host == allowed || strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed) {
+			return nil
+		}
+	}
+	return ErrInvalidPushEndpoint
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, internal to a network
+// like the private ranges.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicAddress reports whether ip is routable on the internet.
+func publicAddress(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// newPushHTTPClient returns a client that checks every address it
+// connects to after DNS resolution, so a push host resolving to an
+// internal address is refused, and that does not follow redirects.
+func newPushHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+				return fmt.Errorf("push endpoint resolves to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type WebPushChannel struct {
+	config WebPushConfig
+	client *http.Client
+}
+
+func NewWebPushChannel(config WebPushConfig) *WebPushChannel {
+	return &WebPushChannel{config: config, client: newPushHTTPClient()}
+}
+
+func (ch *WebPushChannel) Name() string {
+	return "webpush"
+}
+
+func (ch *WebPushChannel) Send(subscription NotificationSubscription, notification Notification) error {
+	if len(subscription.Push) == 0 || notification.Event == EventEmailConfirmation {
+		return nil
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, push := range subscription.Push {
+		// subscriptions stored or restored before endpoints were checked
+		if err := ValidatePushEndpoint(push.Endpoint); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", push.Endpoint, err))
+			continue
+		}
+		resp, err := webpush.SendNotification(payload, &webpush.Subscription{
+			Endpoint: push.Endpoint,
+			Keys:     webpush.Keys{Auth: push.Auth, P256dh: push.P256dh},
+		}, &webpush.Options{
+			HTTPClient:      ch.client,
+			Subscriber:      ch.config.Subscriber,
+			VAPIDPublicKey:  ch.config.VAPIDPublicKey,
+			VAPIDPrivateKey: ch.config.VAPIDPrivateKey,
+			TTL:             ch.config.TTL,
+		})
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			failed = append(failed, fmt.Sprintf("%s: status %d", push.Endpoint, resp.StatusCode))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("web push failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"online-judge/internal/store"
+	"time"
+)
+
+var (
+	ErrNoNotificationTarget  = errors.New("subscription has no email or push endpoint")
+	ErrSubscriptionNotFound  = errors.New("notification subscription not found")
+	ErrInvalidEmailCode      = errors.New("invalid or expired email confirmation code")
+	ErrEmailAlreadyConfirmed = errors.New("email address is already confirmed")
+	ErrConfirmationTooSoon   = errors.New("a confirmation code was sent recently; try again later")
+)
+
+type NotificationEvent string
+
+const (
+	EventContestStarted         NotificationEvent = "contest_started"
+	EventClarificationAnswered  NotificationEvent = "clarification_answered"
+	EventVerdictChanged         NotificationEvent = "verdict_changed"
+	EventSystemTestResultsReady NotificationEvent = "system_test_results_ready"
+	EventAchievementAwarded     NotificationEvent = "achievement_awarded"
+
+	// EventEmailConfirmation carries the code confirming a subscription's
+	// email address. It goes to that address only and ignores Events.
+	EventEmailConfirmation NotificationEvent = "email_confirmation"
+)
+
+type Notification struct {
+	Event     NotificationEvent `json:"event"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+type PushSubscription struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+	P256dh   string `json:"p256dh" binding:"required"`
+	Auth     string `json:"auth" binding:"required"`
+}
+
+// NotificationSubscription is where and about what a user wants to be notified.
+// An empty Events list means every event. Email is only delivered to once
+// the user has confirmed the address.
+type NotificationSubscription struct {
+	UserID         string              `json:"userId"`
+	Email          string              `json:"email,omitempty"`
+	EmailConfirmed bool                `json:"emailConfirmed"`
+	Push           []PushSubscription  `json:"push,omitempty"`
+	Events         []NotificationEvent `json:"events,omitempty"`
+}
+
+func (s NotificationSubscription) wants(event NotificationEvent) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationChannel delivers a notification to one subscriber. Channels
+// skip subscribers that have no address for them.
+type NotificationChannel interface {
+	Name() string
+	Send(subscription NotificationSubscription, notification Notification) error
+}
+
+type notificationJob struct {
+	Subscription NotificationSubscription `json:"subscription"`
+	Notification Notification             `json:"notification"`
+}
+
+// emailConfirmation is the pending confirmation of a subscription's address.
+type emailConfirmation struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+const (
+	notificationSubscriptionPrefix = "notification:subscription:"
+	notificationConfirmationPrefix = "notification:email-confirmation:"
+	notificationConfirmationSent   = "notification:email-confirmation-sent:"
+	notificationQueueKey           = "queue:notifications"
+	emailConfirmationTTL           = 24 * time.Hour
+	emailConfirmationCooldown      = time.Minute
+)
+
+// NotificationService stores subscriptions in the shared store and queues
+// notifications there, so any replica running Run delivers them and a
+// crash of the replica that raised one does not drop it.
+type NotificationService struct {
+	store    store.Store
+	channels []NotificationChannel
+	interval time.Duration
+}
+
+func NewNotificationService(st store.Store, channels ...NotificationChannel) *NotificationService {
+	return &NotificationService{store: st, channels: channels, interval: time.Second}
+}
+
+// Subscribe stores the subscription. An address that is new or differs
+// from the stored one starts unconfirmed, and a confirmation code is
+// mailed to it; the caller's EmailConfirmed is ignored.
+func (s *NotificationService) Subscribe(subscription NotificationSubscription) (NotificationSubscription, error) {
+	if subscription.Email == "" && len(subscription.Push) == 0 {
+		return NotificationSubscription{}, ErrNoNotificationTarget
+	}
+	for _, push := range subscription.Push {
+		if err := ValidatePushEndpoint(push.Endpoint); err != nil {
+			return NotificationSubscription{}, err
+		}
+	}
+
+	subscription.EmailConfirmed = false
+	if subscription.Email != "" {
+		previous, err := s.Subscription(subscription.UserID)
+		if err != nil && !errors.Is(err, ErrSubscriptionNotFound) {
+			return NotificationSubscription{}, err
+		}
+		subscription.EmailConfirmed = previous.EmailConfirmed && previous.Email == subscription.Email
+	}
+	if err := setJSON(s.store, notificationSubscriptionPrefix+subscription.UserID, subscription, 0); err != nil {
+		return NotificationSubscription{}, err
+	}
+	if subscription.Email == "" || subscription.EmailConfirmed {
+		return subscription, s.store.Delete(notificationConfirmationPrefix + subscription.UserID)
+	}
+	// a code sent within the cooldown can be sent again with ResendConfirmation
+	if err := s.requestConfirmation(subscription); err != nil && !errors.Is(err, ErrConfirmationTooSoon) {
+		return NotificationSubscription{}, err
+	}
+	return subscription, nil
+}
+
+// ResendConfirmation mails a new confirmation code to the subscription's
+// unconfirmed address.
+func (s *NotificationService) ResendConfirmation(userID string) error {
+	subscription, err := s.Subscription(userID)
+	if err != nil {
+		return err
+	}
+	if subscription.Email == "" {
+		return ErrNoNotificationTarget
+	}
+	if subscription.EmailConfirmed {
+		return ErrEmailAlreadyConfirmed
+	}
+	return s.requestConfirmation(subscription)
+}
+
+// requestConfirmation stores a fresh code for the subscription's address
+// and queues the message carrying it, bypassing the subscribed events. It
+// sends at most one code per user per emailConfirmationCooldown.
+func (s *NotificationService) requestConfirmation(subscription NotificationSubscription) error {
+	ok, err := s.store.SetNX(notificationConfirmationSent+subscription.UserID, []byte("1"), emailConfirmationCooldown)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConfirmationTooSoon
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	confirmation := emailConfirmation{Email: subscription.Email, Code: hex.EncodeToString(b)}
+	if err := setJSON(s.store, notificationConfirmationPrefix+subscription.UserID, confirmation, emailConfirmationTTL); err != nil {
+		return err
+	}
+
+	subscription.Events = nil
+	s.enqueue(subscription, Notification{
+		Event: EventEmailConfirmation,
+		Title: "Confirm your email address",
+		Body: "Enter this code to receive notifications at this address: " + confirmation.Code +
+			"\r\n\r\nIf you did not ask for notifications, ignore this message.",
+	})
+	return nil
+}
+
+// ConfirmEmail marks the subscription's address confirmed when code is
+// the one last mailed to that address.
+func (s *NotificationService) ConfirmEmail(userID, code string) (NotificationSubscription, error) {
+	var confirmation emailConfirmation
+	if err := getJSON(s.store, notificationConfirmationPrefix+userID, &confirmation); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return NotificationSubscription{}, ErrInvalidEmailCode
+		}
+		return NotificationSubscription{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(confirmation.Code)) != 1 {
+		return NotificationSubscription{}, ErrInvalidEmailCode
+	}
+
+	subscription, err := s.Subscription(userID)
+	if err != nil {
+		return NotificationSubscription{}, err
+	}
+	if subscription.Email != confirmation.Email {
+		return NotificationSubscription{}, ErrInvalidEmailCode
+	}
+	subscription.EmailConfirmed = true
+	if err := setJSON(s.store, notificationSubscriptionPrefix+userID, subscription, 0); err != nil {
+		return NotificationSubscription{}, err
+	}
+	if err := s.store.Delete(notificationConfirmationPrefix + userID); err != nil {
+		log.Printf("Error removing email confirmation for user %s: %v", userID, err)
+	}
+	return subscription, nil
+}
+
+func (s *NotificationService) Unsubscribe(userID string) error {
+	return s.store.Delete(notificationSubscriptionPrefix+userID, notificationConfirmationPrefix+userID)
+}
+
+func (s *NotificationService) Subscription(userID string) (NotificationSubscription, error) {
+	var subscription NotificationSubscription
+	if err := getJSON(s.store, notificationSubscriptionPrefix+userID, &subscription); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return NotificationSubscription{}, ErrSubscriptionNotFound
+		}
+		return NotificationSubscription{}, err
+	}
+	return subscription, nil
+}
+
+// Notify queues a notification for the given users. Users without a
+// subscription for the event are skipped.
+func (s *NotificationService) Notify(userIDs []string, notification Notification) {
+	for _, userID := range userIDs {
+		subscription, err := s.Subscription(userID)
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error loading notification subscription for user %s: %v", userID, err)
+			continue
+		}
+		s.enqueue(subscription, notification)
+	}
+}
+
+// Broadcast queues a notification for every subscribed user.
+func (s *NotificationService) Broadcast(notification Notification) {
+	subscriptions, err := listJSON[NotificationSubscription](s.store, notificationSubscriptionPrefix)
+	if err != nil {
+		log.Printf("Error listing notification subscriptions: %v", err)
+		return
+	}
+	for _, subscription := range subscriptions {
+		s.enqueue(subscription, notification)
+	}
+}
+
+func (s *NotificationService) enqueue(subscription NotificationSubscription, notification Notification) {
+	if !subscription.wants(notification.Event) {
+		return
+	}
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(notificationJob{Subscription: subscription, Notification: notification})
+	if err == nil {
+		err = s.store.Push(notificationQueueKey, data)
+	}
+	if err != nil {
+		log.Printf("Error queueing %s notification for user %s: %v", notification.Event, subscription.UserID, err)
+	}
+}
+
+// Run delivers queued notifications every interval until ctx is
+// cancelled. Every replica may run it; each notification is popped by one.
+func (s *NotificationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.deliver(ctx); err != nil {
+				log.Printf("Error delivering notifications: %v", err)
+			}
+		}
+	}
+}
+
+// deliver sends every queued notification. A failed send is logged and
+// not retried, so one unreachable endpoint cannot hold up the queue.
+func (s *NotificationService) deliver(ctx context.Context) error {
+	for ctx.Err() == nil {
+		data, err := s.store.Pop(notificationQueueKey)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var job notificationJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			log.Printf("Invalid queued notification: %v", err)
+			continue
+		}
+		for _, channel := range s.channels {
+			if err := channel.Send(job.Subscription, job.Notification); err != nil {
+				log.Printf("Error sending %s notification via %s to user %s: %v",
+					job.Notification.Event, channel.Name(), job.Subscription.UserID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *NotificationService) SnapshotName() string {
+	return "notificationSubscriptions"
+}
+
+func (s *NotificationService) ExportSnapshot() (json.RawMessage, error) {
+	subscriptions, err := listJSON[NotificationSubscription](s.store, notificationSubscriptionPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(subscriptions)
+}
+
+func (s *NotificationService) ImportSnapshot(data json.RawMessage) error {
+	var subscriptions []NotificationSubscription
+	if err := json.Unmarshal(data, &subscriptions); err != nil {
+		return err
+	}
+	return replaceJSON(s.store, notificationSubscriptionPrefix, subscriptions,
+		func(sub NotificationSubscription) string { return sub.UserID })
+}
+
+// UserData returns the privacy section holding the user's subscription
+// and its addresses.
+func (s *NotificationService) UserData() UserDataSection {
+	return userKeySection{store: s.store, name: "notifications", prefixes: []string{notificationSubscriptionPrefix, notificationConfirmationPrefix, notificationConfirmationSent}, marker: ":", erase: true}
+}
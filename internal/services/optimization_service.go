@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"online-judge/internal/store"
+	"sort"
+	"time"
+)
+
+type OptimizationDirection string
+
+var ErrNotOptimizationProblem = errors.New("problem is not an optimization problem")
+
+const (
+	Minimize OptimizationDirection = "minimize"
+	Maximize OptimizationDirection = "maximize"
+)
+
+func (d OptimizationDirection) valid() bool {
+	return d == Minimize || d == Maximize
+}
+
+// better reports whether a is a strictly better objective than b.
+func (d OptimizationDirection) better(a, b float64) bool {
+	if d == Maximize {
+		return a > b
+	}
+	return a < b
+}
+
+type Optimization struct {
+	Direction OptimizationDirection `json:"direction" binding:"required"`
+}
+
+// optimizationEntry is a user's best complete run on an optimization problem.
+type optimizationEntry struct {
+	UserID       string    `json:"userId"`
+	SubmissionID string    `json:"submissionId"`
+	Objectives   []float64 `json:"objectives"` // indexed by test
+	Total        float64   `json:"total"`
+	SubmittedAt  time.Time `json:"submittedAt"`
+}
+
+type LeaderboardEntry struct {
+	Rank         int       `json:"rank"`
+	UserID       string    `json:"userId"`
+	SubmissionID string    `json:"submissionId"`
+	Objective    float64   `json:"objective"`
+	Score        float64   `json:"score"`
+	SubmittedAt  time.Time `json:"submittedAt"`
+}
+
+const optimizationEntryPrefix = "optimization:entry:"
+
+// OptimizationService keeps each user's best objective values per
+// optimization problem. Relative scores are computed when the leaderboard is
+// read, so they always reflect the current best value of every test.
+type OptimizationService struct {
+	store          store.Store
+	problemService *ProblemService
+}
+
+func NewOptimizationService(st store.Store, problemService *ProblemService) *OptimizationService {
+	return &OptimizationService{store: st, problemService: problemService}
+}
+
+// Record is a GradedListener. Only submissions with a feasible answer and an
+// objective on every test are considered; a user's entry is replaced when the new total is
+// better.
+func (s *OptimizationService) Record(submission Submission, problem Problem) error {
+	if problem.Optimization == nil || submission.Status != SubmissionJudged {
+		return nil
+	}
+
+	objectives := make([]float64, len(submission.Results))
+	total := 0.0
+	for _, result := range submission.Results {
+		if result.Objective == nil || result.Score <= 0 || result.Test < 1 || result.Test > len(objectives) {
+			return nil
+		}
+		objectives[result.Test-1] = *result.Objective
+		total += *result.Objective
+	}
+	if len(objectives) == 0 {
+		return nil
+	}
+
+	key := optimizationEntryPrefix + problem.ID + ":" + submission.UserID
+	var current optimizationEntry
+	err := getJSON(s.store, key, &current)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if err == nil && !problem.Optimization.Direction.better(total, current.Total) {
+		return nil
+	}
+
+	return setJSON(s.store, key, optimizationEntry{
+		UserID:       submission.UserID,
+		SubmissionID: submission.ID,
+		Objectives:   objectives,
+		Total:        total,
+		SubmittedAt:  submission.CreatedAt,
+	}, 0)
+}
+
+// Leaderboard ranks users by relative score. For each test, the best known
+// value earns full credit and other values earn best/value (minimize) or
+// value/best (maximize); the problem's max score is split evenly across tests.
+func (s *OptimizationService) Leaderboard(problemID string) ([]LeaderboardEntry, error) {
+	problem, err := s.problemService.Get(problemID)
+	if err != nil {
+		return nil, err
+	}
+	if problem.Optimization == nil {
+		return nil, ErrNotOptimizationProblem
+	}
+	direction := problem.Optimization.Direction
+
+	entries, err := listJSON[optimizationEntry](s.store, optimizationEntryPrefix+problemID+":")
+	if err != nil {
+		return nil, err
+	}
+
+	best := bestPerTest(entries, direction)
+	board := make([]LeaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		score := 0.0
+		for test, value := range entry.Objectives {
+			if test < len(best) {
+				score += relativeScore(value, best[test], direction)
+			}
+		}
+		if len(best) > 0 {
+			score = score / float64(len(best)) * problem.MaxScore
+		}
+		board = append(board, LeaderboardEntry{
+			UserID:       entry.UserID,
+			SubmissionID: entry.SubmissionID,
+			Objective:    entry.Total,
+			Score:        score,
+			SubmittedAt:  entry.SubmittedAt,
+		})
+	}
+
+	sort.SliceStable(board, func(i, j int) bool {
+		if board[i].Score != board[j].Score {
+			return board[i].Score > board[j].Score
+		}
+		return board[i].SubmittedAt.Before(board[j].SubmittedAt)
+	})
+	for i := range board {
+		board[i].Rank = i + 1
+	}
+	return board, nil
+}
+
+func bestPerTest(entries []optimizationEntry, direction OptimizationDirection) []float64 {
+	var best []float64
+	for _, entry := range entries {
+		for test, value := range entry.Objectives {
+			if test >= len(best) {
+				best = append(best, value)
+			} else if direction.better(value, best[test]) {
+				best[test] = value
+			}
+		}
+	}
+	return best
+}
+
+func relativeScore(value, best float64, direction OptimizationDirection) float64 {
+	switch {
+	case value == best:
+		return 1
+	case direction == Minimize && value > 0:
+		return best / value
+	case direction == Maximize && best > 0:
+		return value / best
+	default:
+		return 0
+	}
+}
+
+// UserData returns the privacy section holding the user's optimization
+// leaderboard entries.
+func (s *OptimizationService) UserData() UserDataSection {
+	return userKeySection{store: s.store, name: "optimizationEntries", prefixes: []string{optimizationEntryPrefix}, marker: ":"}
+}
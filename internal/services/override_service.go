@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidVerdict        = errors.New("verdict is not a known verdict")
+	ErrInvalidOverrideScore  = errors.New("score must be between 0 and the problem's max score")
+	ErrSubmissionNotJudged   = errors.New("only judged submissions can be overridden")
+	ErrOverrideReasonMissing = errors.New("an override needs a reason")
+)
+
+// VerdictOverride is one manual adjudication of a submission. Overrides are
+// kept on the submission in the order they were made, so the record shows
+// who changed the verdict, when, why and what it was before.
+type VerdictOverride struct {
+	Verdict         Verdict   `json:"verdict"`
+	Score           float64   `json:"score"`
+	PreviousVerdict Verdict   `json:"previousVerdict"`
+	PreviousScore   float64   `json:"previousScore"`
+	JudgeID         string    `json:"judgeId"`
+	Reason          string    `json:"reason"`
+	At              time.Time `json:"at"`
+}
+
+// OverrideRequest is what a judge sends. Score defaults to the problem's max
+// score for accepted and to 0 for other verdicts.
+type OverrideRequest struct {
+	Verdict Verdict  `json:"verdict" binding:"required"`
+	Score   *float64 `json:"score"`
+	Reason  string   `json:"reason" binding:"required"`
+}
+
+// OverrideService lets judges replace a submission's verdict by hand, for
+// example to accept a solution hit by a judge bug.
+type OverrideService struct {
+	problemService    *ProblemService
+	submissionService *SubmissionService
+	reconciler        *RejudgeReconciler
+}
+
+func NewOverrideService(problemService *ProblemService, submissionService *SubmissionService, reconciler *RejudgeReconciler) *OverrideService {
+	return &OverrideService{problemService: problemService, submissionService: submissionService, reconciler: reconciler}
+}
+
+// Override records a manual verdict for a judged submission. Standings are
+// derived from the stored verdict and score, so they follow immediately;
+// recomputers and the submission's owner are told through the rejudge
+// reconciler. For submissions that went through system testing the final
+// result is overridden as well.
+func (s *OverrideService) Override(submissionID, judgeID string, req OverrideRequest) (Submission, error) {
+	if !req.Verdict.Valid() {
+		return Submission{}, ErrInvalidVerdict
+	}
+	if req.Reason == "" {
+		return Submission{}, ErrOverrideReasonMissing
+	}
+	submission, err := s.submissionService.getRecord(submissionID)
+	if err != nil {
+		return Submission{}, err
+	}
+	if submission.Status != SubmissionJudged && submission.Status != SubmissionFailed {
+		return Submission{}, ErrSubmissionNotJudged
+	}
+	problem, err := s.problemService.Get(submission.ProblemID)
+	if err != nil {
+		return Submission{}, err
+	}
+
+	score := 0.0
+	if req.Verdict == VerdictAccepted {
+		score = problem.MaxScore
+	}
+	if req.Score != nil {
+		score = *req.Score
+	}
+	if score < 0 || score > problem.MaxScore {
+		return Submission{}, ErrInvalidOverrideScore
+	}
+
+	previous := submission.Verdict
+	submission.Overrides = append(submission.Overrides, VerdictOverride{
+		Verdict:         req.Verdict,
+		Score:           score,
+		PreviousVerdict: previous,
+		PreviousScore:   submission.Score,
+		JudgeID:         judgeID,
+		Reason:          req.Reason,
+		At:              time.Now(),
+	})
+	submission.applyOverride(submission.Overrides[len(submission.Overrides)-1].At)
+	if err := s.submissionService.Update(submission); err != nil {
+		return Submission{}, err
+	}
+
+	if err := s.reconciler.Reconcile([]VerdictChange{{
+		SubmissionID: submission.ID,
+		UserID:       submission.UserID,
+		ContestID:    submission.ContestID,
+		ProblemID:    submission.ProblemID,
+		OldVerdict:   string(previous),
+		NewVerdict:   string(submission.Verdict),
+	}}); err != nil {
+		return submission, err
+	}
+	return submission, nil
+}
+
+// applyOverride makes the latest manual verdict the submission's outcome. A
+// rejudge calls it after grading, so an adjudication survives the rejudge
+// and stays visible next to the fresh per-test results.
+func (s *Submission) applyOverride(at time.Time) {
+	if len(s.Overrides) == 0 {
+		return
+	}
+	latest := s.Overrides[len(s.Overrides)-1]
+	s.ManualVerdict = true
+	s.transition(SubmissionJudged, at)
+	s.Verdict = latest.Verdict
+	s.Score = latest.Score
+	if s.Final != nil {
+		s.Final.Verdict = latest.Verdict
+		s.Final.Score = latest.Score
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"online-judge/internal/store"
+	"strconv"
+	"time"
+)
+
+const (
+	invariantLeaseKey        = "lease:invariant-check"
+	invariantScoredPrefix    = "invariant:scored:"
+	invariantViolationPrefix = "invariant:violation:"
+)
+
+// Invariants of the judging pipeline checked in chaos mode.
+const (
+	// InvariantLost is a submission that has not reached an outcome long
+	// after it was submitted or last moved.
+	InvariantLost = "lost"
+	// InvariantDoubleScored is a grading attempt whose outcome was stored
+	// more than once.
+	InvariantDoubleScored = "double_scored"
+)
+
+// InvariantViolation is a submission the pipeline mishandled.
+type InvariantViolation struct {
+	Kind         string    `json:"kind"`
+	SubmissionID string    `json:"submissionId"`
+	Detail       string    `json:"detail"`
+	DetectedAt   time.Time `json:"detectedAt"`
+}
+
+type InvariantConfig struct {
+	// LostAfter is how long a submission may stay received, queued or in
+	// grading before it counts as lost. It must exceed what the watchdog
+	// needs to recover a stuck submission.
+	LostAfter time.Duration
+	Interval  time.Duration
+}
+
+// InvariantConfigFromEnv reads INVARIANT_LOST_AFTER_MINUTES.
+func InvariantConfigFromEnv() InvariantConfig {
+	return InvariantConfig{
+		LostAfter: time.Duration(max(intFromEnv("INVARIANT_LOST_AFTER_MINUTES", 15), 1)) * time.Minute,
+		Interval:  time.Minute,
+	}
+}
+
+// InvariantChecker records violations of the pipeline's guarantees while
+// faults are injected (see judge.ChaosConfig): every submission reaches an
+// outcome, and each grading attempt is scored once. Every replica runs the
+// check; a lease lets one check per tick.
+type InvariantChecker struct {
+	store             store.Store
+	submissionService *SubmissionService
+	config            InvariantConfig
+}
+
+func NewInvariantChecker(st store.Store, submissionService *SubmissionService, config InvariantConfig) *InvariantChecker {
+	return &InvariantChecker{store: st, submissionService: submissionService, config: config}
+}
+
+// Record is a GradedListener counting the outcomes stored per grading
+// attempt.
+func (c *InvariantChecker) Record(submission Submission, _ Problem) {
+	attempt := strconv.Itoa(submission.Attempts())
+	scored, err := c.store.Incr(invariantScoredPrefix + submission.ID + ":" + attempt)
+	if err != nil {
+		log.Printf("Error counting outcomes of submission %s: %v", submission.ID, err)
+		return
+	}
+	if scored > 1 {
+		c.violate(InvariantViolation{
+			Kind:         InvariantDoubleScored,
+			SubmissionID: submission.ID,
+			Detail:       fmt.Sprintf("attempt %s was scored %d times, last as %s", attempt, scored, submission.Verdict),
+			DetectedAt:   time.Now(),
+		})
+	}
+}
+
+// Run checks for lost submissions every interval until ctx is cancelled.
+func (c *InvariantChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := c.store.SetNX(invariantLeaseKey, []byte("1"), c.config.Interval/2)
+		if err != nil {
+			log.Printf("Error acquiring invariant check lease: %v", err)
+			continue
+		}
+		if ok {
+			c.Check(time.Now())
+		}
+	}
+}
+
+// Check records the submissions lost at now.
+func (c *InvariantChecker) Check(now time.Time) {
+	submissions, err := c.submissionService.List(SubmissionFilter{})
+	if err != nil {
+		log.Printf("Error listing submissions for the invariant check: %v", err)
+		return
+	}
+	for _, submission := range submissions {
+		pending := submission.Status == SubmissionReceived || submission.Status == SubmissionQueued || submission.Status.InProgress()
+		since := submission.StatusSince()
+		if !pending || now.Sub(since) < c.config.LostAfter {
+			continue
+		}
+		c.violate(InvariantViolation{
+			Kind:         InvariantLost,
+			SubmissionID: submission.ID,
+			Detail:       fmt.Sprintf("%s since %s", submission.Status, since.Format(time.RFC3339)),
+			DetectedAt:   now,
+		})
+	}
+}
+
+// violate stores the violation unless one of its kind is already stored
+// for the submission.
+func (c *InvariantChecker) violate(violation InvariantViolation) {
+	key := invariantViolationPrefix + violation.Kind + ":" + violation.SubmissionID
+	data, err := json.Marshal(violation)
+	if err != nil {
+		log.Printf("Error encoding invariant violation: %v", err)
+		return
+	}
+	stored, err := c.store.SetNX(key, data, 0)
+	if err != nil {
+		log.Printf("Error storing invariant violation: %v", err)
+		return
+	}
+	if stored {
+		log.Printf("Invariant violated: submission %s %s: %s", violation.SubmissionID, violation.Kind, violation.Detail)
+	}
+}
+
+// Violations returns every violation recorded.
+func (c *InvariantChecker) Violations() ([]InvariantViolation, error) {
+	return listJSON[InvariantViolation](c.store, invariantViolationPrefix)
+}
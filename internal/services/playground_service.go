@@ -0,0 +1,512 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"online-judge/internal/clock"
+	"online-judge/internal/judge"
+	"online-judge/internal/store"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("playground session not found")
+	ErrSessionExpired  = errors.New("playground session expired")
+	ErrQuotaExceeded   = errors.New("playground run quota exceeded")
+	ErrInvalidFileName = errors.New("invalid file name")
+	ErrFileTooLarge    = errors.New("file exceeds size limit")
+	ErrTooManyFiles    = errors.New("too many files in session")
+	ErrUnknownLanguage = errors.New("unsupported language")
+	ErrSessionLimitHit = errors.New("too many active playground sessions")
+	ErrUserSessionsHit = errors.New("you have too many active playground sessions")
+	ErrSessionBusy     = errors.New("playground session is already running a program")
+	ErrEntryNotFound   = errors.New("entry file not found in session")
+	ErrReservedFile    = errors.New("file name is reserved for the entry file")
+)
+
+// PlaygroundConfig holds the limits applied to every playground session.
+type PlaygroundConfig struct {
+	SessionTTL  time.Duration
+	MaxRuns     int
+	MaxFiles    int
+	MaxFileSize int64
+	// MaxSessions caps the active sessions of all users together, and
+	// MaxUserSessions those of one user, so one user cannot take them all.
+	MaxSessions     int
+	MaxUserSessions int
+	// RunTimeout bounds a run end to end, waiting for a judge worker
+	// included. The worker runs snippets in its sandbox with the judge's
+	// default time and memory limits.
+	RunTimeout time.Duration
+	// InteractiveTimeout is the wall-time limit for streamed runs, which
+	// spend most of their time waiting on user input.
+	InteractiveTimeout time.Duration
+	MaxOutputBytes     int
+}
+
+func DefaultPlaygroundConfig() PlaygroundConfig {
+	return PlaygroundConfig{
+		SessionTTL:         30 * time.Minute,
+		MaxRuns:            50,
+		MaxFiles:           16,
+		MaxFileSize:        64 * 1024,
+		MaxSessions:        100,
+		MaxUserSessions:    3,
+		RunTimeout:         30 * time.Second,
+		InteractiveTimeout: 60 * time.Second,
+		MaxOutputBytes:     64 * 1024,
+	}
+}
+
+// playgroundLanguages maps a playground language to the judge language its
+// entry files run as.
+var playgroundLanguages = map[string]string{
+	"python": "python",
+}
+
+const (
+	playgroundSessionPrefix = "playground:session:"
+	playgroundLockPrefix    = "playground:lock:"
+)
+
+type PlaygroundSession struct {
+	ID string `json:"id"`
+	// UserID owns the session; nobody else can see or use it.
+	UserID    string    `json:"userId"`
+	Language  string    `json:"language"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	RunsUsed  int       `json:"runsUsed"`
+	RunsLimit int       `json:"runsLimit"`
+}
+
+// playgroundRecord is what is kept in the store. Session files live in the
+// record rather than on local disk so any API replica can serve the session;
+// each run sends them to the judge worker.
+type playgroundRecord struct {
+	Session PlaygroundSession `json:"session"`
+	Files   map[string][]byte `json:"files"`
+}
+
+type PlaygroundRunResult struct {
+	Status     judge.Status `json:"status"`
+	Output     string       `json:"output"`
+	Message    string       `json:"message,omitempty"`
+	ExitCode   int          `json:"exitCode"`
+	TimedOut   bool         `json:"timedOut"`
+	Truncated  bool         `json:"truncated"`
+	DurationMs int64        `json:"durationMs"`
+}
+
+// playgroundResult reports a run as the playground shows it, the program's
+// stdout followed by its stderr.
+func playgroundResult(result judge.ExecutionResult) *PlaygroundRunResult {
+	output := result.Stdout + result.Stderr
+	if result.Status == judge.StatusCompileError {
+		output = result.CompileOutput
+	}
+	run := &PlaygroundRunResult{
+		Status:     result.Status,
+		Output:     output,
+		Message:    result.Message,
+		ExitCode:   result.ExitCode,
+		TimedOut:   result.Status == judge.StatusTimeLimitExceeded,
+		Truncated:  result.Status == judge.StatusOutputLimitExceeded,
+		DurationMs: int64(result.WallTime * 1000),
+	}
+	if run.TimedOut {
+		run.ExitCode = -1
+	}
+	return run
+}
+
+// PlaygroundService keeps playground sessions in the store and runs their
+// files on judge workers, never on the API server.
+type PlaygroundService struct {
+	config      PlaygroundConfig
+	store       store.Store
+	judgeClient *judge.Client
+	clock       clock.Clock
+	ids         clock.IDGenerator
+}
+
+func NewPlaygroundService(config PlaygroundConfig, st store.Store, judgeClient *judge.Client) *PlaygroundService {
+	return &PlaygroundService{config: config, store: st, judgeClient: judgeClient, clock: clock.System, ids: clock.RandomIDs(16)}
+}
+
+// SetClock replaces the wall clock, e.g. with a clock.Manual in tests.
+func (s *PlaygroundService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetIDGenerator replaces the random session IDs, e.g. with a
+// clock.Sequence in tests.
+func (s *PlaygroundService) SetIDGenerator(ids clock.IDGenerator) {
+	s.ids = ids
+}
+
+func (s *PlaygroundService) CreateSession(userID, language string) (PlaygroundSession, error) {
+	if _, ok := playgroundLanguages[language]; !ok {
+		return PlaygroundSession{}, ErrUnknownLanguage
+	}
+
+	records, err := listJSON[playgroundRecord](s.store, playgroundSessionPrefix)
+	if err != nil {
+		return PlaygroundSession{}, err
+	}
+	if len(records) >= s.config.MaxSessions {
+		return PlaygroundSession{}, ErrSessionLimitHit
+	}
+	owned := 0
+	for _, record := range records {
+		if record.Session.UserID == userID {
+			owned++
+		}
+	}
+	if owned >= s.config.MaxUserSessions {
+		return PlaygroundSession{}, ErrUserSessionsHit
+	}
+
+	id, err := s.ids.NewID()
+	if err != nil {
+		return PlaygroundSession{}, err
+	}
+
+	now := s.clock.Now()
+	record := &playgroundRecord{
+		Session: PlaygroundSession{
+			ID:        id,
+			UserID:    userID,
+			Language:  language,
+			CreatedAt: now,
+			ExpiresAt: now.Add(s.config.SessionTTL),
+			RunsLimit: s.config.MaxRuns,
+		},
+		Files: make(map[string][]byte),
+	}
+	if err := s.save(record); err != nil {
+		return PlaygroundSession{}, err
+	}
+	return record.Session, nil
+}
+
+func (s *PlaygroundService) GetSession(id, userID string) (PlaygroundSession, error) {
+	record, err := s.load(id, userID)
+	if err != nil {
+		return PlaygroundSession{}, err
+	}
+	return record.Session, nil
+}
+
+func (s *PlaygroundService) DeleteSession(id, userID string) error {
+	if _, err := s.load(id, userID); err != nil {
+		return err
+	}
+	return s.store.Delete(playgroundSessionPrefix + id)
+}
+
+// MaxFileSize is the largest file a session accepts, in bytes.
+func (s *PlaygroundService) MaxFileSize() int64 {
+	return s.config.MaxFileSize
+}
+
+// WriteFile stores a file in the session, replacing any previous version.
+func (s *PlaygroundService) WriteFile(id, userID, name string, content []byte) error {
+	if !validFileName(name) {
+		return ErrInvalidFileName
+	}
+	if int64(len(content)) > s.config.MaxFileSize {
+		return ErrFileTooLarge
+	}
+
+	unlock, err := s.lock(id, s.config.RunTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	record, err := s.load(id, userID)
+	if err != nil {
+		return err
+	}
+	if _, exists := record.Files[name]; !exists && len(record.Files) >= s.config.MaxFiles {
+		return ErrTooManyFiles
+	}
+	record.Files[name] = content
+	return s.save(record)
+}
+
+func (s *PlaygroundService) ListFiles(id, userID string) ([]string, error) {
+	record, err := s.load(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(record.Files))
+	for name := range record.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Run runs the entry file on a judge worker with the other session files
+// next to it. Files written by the program are kept for subsequent runs.
+func (s *PlaygroundService) Run(ctx context.Context, id, userID, entry, stdin string) (*PlaygroundRunResult, error) {
+	if !validFileName(entry) {
+		return nil, ErrInvalidFileName
+	}
+
+	unlock, err := s.lock(id, s.config.RunTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	run, err := s.prepareRun(id, userID, entry)
+	if err != nil {
+		return nil, err
+	}
+	run.submission.Input = stdin
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.RunTimeout)
+	defer cancel()
+	result, err := s.judgeClient.Execute(ctx, run.submission)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.finishRun(run, result.Files); err != nil {
+		return nil, err
+	}
+	return playgroundResult(result), nil
+}
+
+// playgroundRun is a run of a session's entry file on a judge worker.
+type playgroundRun struct {
+	record     *playgroundRecord
+	entry      string
+	submission judge.Submission
+	// sourceFile is where the judge puts the entry file; the files the
+	// worker sends back hold it there rather than under the entry's name
+	sourceFile string
+}
+
+// prepareRun charges a run against the session quota and builds the
+// submission running entry with the other session files.
+func (s *PlaygroundService) prepareRun(id, userID, entry string) (*playgroundRun, error) {
+	record, err := s.load(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	code, ok := record.Files[entry]
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+	language := playgroundLanguages[record.Session.Language]
+	lang, ok := judge.LookupLanguage(language)
+	if !ok {
+		return nil, ErrUnknownLanguage
+	}
+	files := make(map[string]string, len(record.Files))
+	for name, content := range record.Files {
+		if name == entry {
+			continue
+		}
+		if name == lang.SourceFile {
+			return nil, ErrReservedFile
+		}
+		files[name] = string(content)
+	}
+
+	if record.Session.RunsUsed >= record.Session.RunsLimit {
+		return nil, ErrQuotaExceeded
+	}
+	record.Session.RunsUsed++
+	if err := s.save(record); err != nil {
+		return nil, err
+	}
+	return &playgroundRun{
+		record: record,
+		entry:  entry,
+		submission: judge.Submission{
+			Language:    language,
+			Code:        string(code),
+			OutputLimit: max(s.config.MaxOutputBytes/1024, 1),
+			Files:       files,
+			ReturnFiles: true,
+		},
+		sourceFile: lang.SourceFile,
+	}, nil
+}
+
+// finishRun replaces the session files with those the program left,
+// keeping the session's file count and size limits. Without files, as
+// when the program did not compile, the session keeps its files.
+func (s *PlaygroundService) finishRun(run *playgroundRun, files map[string]string) error {
+	if files == nil {
+		return nil
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name != run.sourceFile && name != run.entry {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	kept := map[string][]byte{run.entry: run.record.Files[run.entry]}
+	for _, name := range names {
+		if len(kept) >= s.config.MaxFiles {
+			break
+		}
+		if !validFileName(name) || int64(len(files[name])) > s.config.MaxFileSize {
+			continue
+		}
+		kept[name] = []byte(files[name])
+	}
+
+	run.record.Files = kept
+	return s.save(run.record)
+}
+
+// load returns the session if userID owns it; anyone else is told it
+// does not exist.
+func (s *PlaygroundService) load(id, userID string) (*playgroundRecord, error) {
+	var record playgroundRecord
+	if err := getJSON(s.store, playgroundSessionPrefix+id, &record); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	if record.Session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	if s.clock.Now().After(record.Session.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+	if record.Files == nil {
+		record.Files = make(map[string][]byte)
+	}
+	return &record, nil
+}
+
+func (s *PlaygroundService) save(record *playgroundRecord) error {
+	ttl := record.Session.ExpiresAt.Sub(s.clock.Now())
+	if ttl <= 0 {
+		return ErrSessionExpired
+	}
+	return setJSON(s.store, playgroundSessionPrefix+record.Session.ID, record, ttl)
+}
+
+// lock serializes operations on a session across replicas. The lock expires
+// on its own if the holder dies mid-run.
+func (s *PlaygroundService) lock(id string, runTimeout time.Duration) (func(), error) {
+	key := playgroundLockPrefix + id
+	ok, err := s.store.SetNX(key, []byte("1"), runTimeout+10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSessionBusy
+	}
+	return func() { s.store.Delete(key) }, nil
+}
+
+func validFileName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	return filepath.Base(name) == name && !strings.ContainsAny(name, `/\`)
+}
+
+// PlaygroundProcess is an interactive run whose stdin and output are streamed
+// by the caller while the program is running on a judge worker.
+type PlaygroundProcess struct {
+	Stdin  io.WriteCloser
+	Output <-chan judge.OutputChunk
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	result *PlaygroundRunResult
+}
+
+// Wait blocks until the process exits and returns its result.
+func (p *PlaygroundProcess) Wait() *PlaygroundRunResult {
+	<-p.done
+	return p.result
+}
+
+// Kill terminates the process if it is still running.
+func (p *PlaygroundProcess) Kill() {
+	p.cancel()
+}
+
+// Start launches the entry file interactively on a judge worker, whose
+// sandbox enforces the default time and memory limits and InteractiveTimeout
+// of wall time. The session stays locked until the process exits, and the
+// run counts against the session quota. Callers must drain Output until it
+// is closed.
+func (s *PlaygroundService) Start(id, userID, entry string) (*PlaygroundProcess, error) {
+	if !validFileName(entry) {
+		return nil, ErrInvalidFileName
+	}
+
+	// RunTimeout leaves room to wait for a worker
+	timeout := s.config.InteractiveTimeout + s.config.RunTimeout
+	unlock, err := s.lock(id, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := s.prepareRun(id, userID, entry)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	run.submission.WallTimeLimit = s.config.InteractiveTimeout.Seconds()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	stdin, stdinWriter := io.Pipe()
+	output := make(chan judge.OutputChunk, 16)
+	process := &PlaygroundProcess{
+		Stdin:  stdinWriter,
+		Output: output,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer unlock()
+		defer cancel()
+
+		result, err := s.judgeClient.ExecuteInteractive(ctx, run.submission, stdin, output)
+		close(output)
+		// writes to Stdin fail from now on
+		stdin.Close()
+		switch {
+		case err != nil && errors.Is(ctx.Err(), context.Canceled):
+			process.result = &PlaygroundRunResult{Status: judge.StatusRuntimeError, ExitCode: -1, Message: "The program was stopped."}
+			close(process.done)
+			return
+		case err != nil:
+			log.Printf("Error running playground session %s: %v", id, err)
+			process.result = &PlaygroundRunResult{Status: judge.StatusInternalError, ExitCode: -1, Message: "The program could not be run."}
+			close(process.done)
+			return
+		}
+		if err := s.finishRun(run, result.Files); err != nil {
+			log.Printf("Error saving playground session %s: %v", id, err)
+		}
+		process.result = playgroundResult(result)
+		close(process.done)
+	}()
+
+	return process, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pop3Conn is the small part of POP3 (RFC 1939) the mail intake needs,
+// spoken over implicit TLS.
+type pop3Conn struct {
+	text *textproto.Conn
+}
+
+func dialPOP3(addr, username, password string) (*pop3Conn, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &pop3Conn{text: textproto.NewConn(conn)}
+	if _, err := c.response(); err != nil {
+		c.text.Close()
+		return nil, err
+	}
+	if _, err := c.cmd("USER %s", username); err != nil {
+		c.text.Close()
+		return nil, err
+	}
+	if _, err := c.cmd("PASS %s", password); err != nil {
+		c.text.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *pop3Conn) cmd(format string, args ...any) (string, error) {
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return c.response()
+}
+
+func (c *pop3Conn) response() (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if status, ok := strings.CutPrefix(line, "+OK"); ok {
+		return strings.TrimSpace(status), nil
+	}
+	return "", fmt.Errorf("pop3: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+}
+
+// list returns the numbers of the messages in the mailbox.
+func (c *pop3Conn) list() ([]int, error) {
+	if _, err := c.cmd("LIST"); err != nil {
+		return nil, err
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]int, 0, len(lines))
+	for _, line := range lines {
+		field, _, _ := strings.Cut(line, " ")
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, errors.New("pop3: malformed LIST response")
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+func (c *pop3Conn) retrieve(number int) ([]byte, error) {
+	if _, err := c.cmd("RETR %d", number); err != nil {
+		return nil, err
+	}
+	return c.text.ReadDotBytes()
+}
+
+func (c *pop3Conn) delete(number int) error {
+	_, err := c.cmd("DELE %d", number)
+	return err
+}
+
+// quit ends the session; the server only removes deleted messages on QUIT.
+func (c *pop3Conn) quit() error {
+	_, err := c.cmd("QUIT")
+	c.text.Close()
+	return err
+}
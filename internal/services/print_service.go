@@ -0,0 +1,292 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"online-judge/internal/auth"
+	"online-judge/internal/clock"
+	"online-judge/internal/store"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrPrintJobNotFound    = errors.New("print job not found")
+	ErrPrintQuotaExceeded  = errors.New("print quota exceeded")
+	ErrPrintTooLarge       = errors.New("print job is too large")
+	ErrPrintJobClaimed     = errors.New("print job is already claimed")
+	ErrPrintJobNotClaimed  = errors.New("print job is not claimed by you")
+	ErrPrintingUnavailable = errors.New("printing is only available while the contest is running")
+)
+
+type PrintStatus string
+
+const (
+	PrintQueued   PrintStatus = "queued"
+	PrintClaimed  PrintStatus = "claimed"
+	PrintFinished PrintStatus = "done"
+)
+
+// PrintJob is source or text a team sent to the onsite printers.
+type PrintJob struct {
+	ID        string      `json:"id"`
+	ContestID string      `json:"contestId"`
+	UserID    string      `json:"userId"`
+	Filename  string      `json:"filename,omitempty"`
+	Content   string      `json:"content"`
+	Status    PrintStatus `json:"status"`
+	ClaimedBy string      `json:"claimedBy,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+	DoneAt    *time.Time  `json:"doneAt,omitempty"`
+}
+
+type PrintConfig struct {
+	// QuotaPerTeam is the number of jobs a team may send per contest.
+	QuotaPerTeam int
+	MaxBytes     int
+}
+
+// PrintConfigFromEnv reads PRINT_QUOTA_PER_TEAM and PRINT_MAX_BYTES,
+// defaulting to 10 jobs of at most 64 KiB.
+func PrintConfigFromEnv() PrintConfig {
+	return PrintConfig{
+		QuotaPerTeam: intFromEnv("PRINT_QUOTA_PER_TEAM", 10),
+		MaxBytes:     intFromEnv("PRINT_MAX_BYTES", 64*1024),
+	}
+}
+
+func intFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+const (
+	printJobPrefix   = "print:job:"
+	printIDKey       = "counter:print"
+	printQuotaPrefix = "print:quota:"
+	printClaimPrefix = "print:claim:"
+)
+
+// PrintService queues print jobs for onsite contests. Teams submit jobs;
+// staff list them, claim one so no two staff print it, and mark it done.
+type PrintService struct {
+	store          store.Store
+	contestService *ContestService
+	config         PrintConfig
+	clock          clock.Clock
+}
+
+func NewPrintService(st store.Store, contestService *ContestService, config PrintConfig) *PrintService {
+	return &PrintService{store: st, contestService: contestService, config: config, clock: clock.System}
+}
+
+// SetClock replaces the wall clock, e.g. with a clock.Manual in tests.
+func (s *PrintService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Submit queues a job for a registered team of a running contest.
+func (s *PrintService) Submit(principal auth.Principal, contestID, filename, content string) (PrintJob, error) {
+	if len(content) > s.config.MaxBytes {
+		return PrintJob{}, ErrPrintTooLarge
+	}
+	contest, err := s.contestService.Get(contestID)
+	if err != nil {
+		return PrintJob{}, err
+	}
+	if !principal.IsAdmin() {
+		if !contest.AcceptsSubmissions() {
+			return PrintJob{}, ErrPrintingUnavailable
+		}
+		registered, err := s.contestService.IsRegistered(contestID, principal.UserID)
+		if err != nil {
+			return PrintJob{}, err
+		}
+		if !registered {
+			return PrintJob{}, ErrNotRegistered
+		}
+	}
+
+	quotaKey := printQuotaPrefix + contestID + ":" + principal.UserID
+	used, err := s.store.Incr(quotaKey)
+	if err != nil {
+		return PrintJob{}, err
+	}
+	if used > int64(s.config.QuotaPerTeam) {
+		s.store.Decr(quotaKey)
+		return PrintJob{}, ErrPrintQuotaExceeded
+	}
+
+	id, err := s.store.Incr(printIDKey)
+	if err != nil {
+		return PrintJob{}, err
+	}
+	job := PrintJob{
+		ID:        strconv.FormatInt(id, 10),
+		ContestID: contestID,
+		UserID:    principal.UserID,
+		Filename:  filename,
+		Content:   content,
+		Status:    PrintQueued,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.save(job); err != nil {
+		return PrintJob{}, err
+	}
+	return job, nil
+}
+
+// List returns the contest's jobs, oldest first, optionally filtered by
+// status.
+func (s *PrintService) List(contestID string, status PrintStatus) ([]PrintJob, error) {
+	all, err := listJSON[PrintJob](s.store, printJobPrefix+contestID+":")
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]PrintJob, 0, len(all))
+	for _, job := range all {
+		if status == "" || job.Status == status {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+func (s *PrintService) Get(contestID, id string) (PrintJob, error) {
+	var job PrintJob
+	if err := getJSON(s.store, printJobKey(contestID, id), &job); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return PrintJob{}, ErrPrintJobNotFound
+		}
+		return PrintJob{}, err
+	}
+	return job, nil
+}
+
+// Claim assigns a queued job to a staff member. The claim key makes this
+// atomic across replicas.
+func (s *PrintService) Claim(contestID, id, staffID string) (PrintJob, error) {
+	job, err := s.Get(contestID, id)
+	if err != nil {
+		return PrintJob{}, err
+	}
+	ok, err := s.store.SetNX(printClaimPrefix+id, []byte(staffID), 0)
+	if err != nil {
+		return PrintJob{}, err
+	}
+	if !ok {
+		return PrintJob{}, ErrPrintJobClaimed
+	}
+
+	job.Status = PrintClaimed
+	job.ClaimedBy = staffID
+	if err := s.save(job); err != nil {
+		return PrintJob{}, err
+	}
+	return job, nil
+}
+
+// Done marks a job printed. Only the staff member holding the claim, or an
+// admin, may finish it.
+func (s *PrintService) Done(principal auth.Principal, contestID, id string) (PrintJob, error) {
+	job, err := s.Get(contestID, id)
+	if err != nil {
+		return PrintJob{}, err
+	}
+	if job.Status != PrintClaimed || (job.ClaimedBy != principal.UserID && !principal.IsAdmin()) {
+		return PrintJob{}, ErrPrintJobNotClaimed
+	}
+
+	now := s.clock.Now()
+	job.Status = PrintFinished
+	job.DoneAt = &now
+	if err := s.save(job); err != nil {
+		return PrintJob{}, err
+	}
+	return job, nil
+}
+
+func (s *PrintService) save(job PrintJob) error {
+	return setJSON(s.store, printJobKey(job.ContestID, job.ID), job, 0)
+}
+
+func printJobKey(contestID, id string) string {
+	return printJobPrefix + contestID + ":" + id
+}
+
+func (s *PrintService) SnapshotName() string {
+	return "printJobs"
+}
+
+func (s *PrintService) ExportSnapshot() (json.RawMessage, error) {
+	jobs, err := listJSON[PrintJob](s.store, printJobPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jobs)
+}
+
+// ImportSnapshot restores the jobs and derives what is stored beside them:
+// the claims of claimed and printed jobs, each team's quota use and the
+// ID counter.
+func (s *PrintService) ImportSnapshot(data json.RawMessage) error {
+	var jobs []PrintJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return err
+	}
+	if err := replaceJSON(s.store, printJobPrefix, jobs, func(job PrintJob) string { return job.ContestID + ":" + job.ID }); err != nil {
+		return err
+	}
+
+	for _, prefix := range []string{printClaimPrefix, printQuotaPrefix} {
+		keys, err := s.store.Keys(prefix)
+		if err != nil {
+			return err
+		}
+		if err := s.store.Delete(keys...); err != nil {
+			return err
+		}
+	}
+	used := make(map[string]int)
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		used[printQuotaPrefix+job.ContestID+":"+job.UserID]++
+		ids[i] = job.ID
+		if job.ClaimedBy != "" {
+			if err := s.store.Set(printClaimPrefix+job.ID, []byte(job.ClaimedBy), 0); err != nil {
+				return err
+			}
+		}
+	}
+	for key, n := range used {
+		if err := s.store.Set(key, []byte(strconv.Itoa(n)), 0); err != nil {
+			return err
+		}
+	}
+	return restoreCounter(s.store, printIDKey, ids)
+}
+
+// JobUserData returns the privacy section holding the user's print jobs,
+// which carry their code and are deleted.
+func (s *PrintService) JobUserData() UserDataSection {
+	return userFieldSection{store: s.store, name: "printJobs", prefix: printJobPrefix, erase: true}
+}
+
+// QuotaUserData returns the privacy section holding the user's print
+// counts per contest.
+func (s *PrintService) QuotaUserData() UserDataSection {
+	return userKeySection{store: s.store, name: "printQuotas", prefixes: []string{printQuotaPrefix}, marker: ":", erase: true}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidBulkEdit = errors.New("bulk edit must name problems and change something")
+
+// ProblemClone says how to copy a problem, e.g. last semester's assignment
+// as the start of this one's.
+type ProblemClone struct {
+	// Title names the copy; empty appends " (copy)" to the original's.
+	Title string `json:"title"`
+	// WithTests copies the tests and unit test suite too. Without them the
+	// copy keeps the statement, limits and judging setup only.
+	WithTests bool `json:"withTests"`
+}
+
+// Clone copies the problem under a new ID, with its judge script and,
+// if asked, its tests.
+func (s *ProblemService) Clone(id string, clone ProblemClone) (Problem, error) {
+	problem, err := s.Get(id)
+	if err != nil {
+		return Problem{}, err
+	}
+	if clone.Title != "" {
+		problem.Title = clone.Title
+	} else {
+		problem.Title += " (copy)"
+	}
+	copied, err := s.Create(problem)
+	if err != nil {
+		return Problem{}, err
+	}
+
+	if script, err := s.JudgeScript(id); err == nil {
+		script.ProblemID = copied.ID
+		if err := setJSON(s.store, judgeScriptKeyPrefix+copied.ID, script, 0); err != nil {
+			return Problem{}, err
+		}
+	} else if !errors.Is(err, ErrJudgeScriptNotFound) {
+		return Problem{}, err
+	}
+	if !clone.WithTests {
+		return copied, nil
+	}
+
+	// tests keep their metadata, authors and dates included
+	tests, err := s.Tests(id)
+	if err != nil {
+		return Problem{}, err
+	}
+	if tests != nil {
+		if err := setJSON(s.store, problemTestsKeyPrefix+copied.ID, tests, 0); err != nil {
+			return Problem{}, err
+		}
+	}
+	if suite, err := s.UnitTests(id); err == nil {
+		suite.ProblemID = copied.ID
+		if err := setJSON(s.store, unitTestsKeyPrefix+copied.ID, suite, 0); err != nil {
+			return Problem{}, err
+		}
+	} else if !errors.Is(err, ErrUnitTestsNotFound) {
+		return Problem{}, err
+	}
+	return copied, nil
+}
+
+// ProblemBulkEdit changes the same settings on many problems at once, e.g.
+// the memory limit of every assignment after a language upgrade. Nil
+// fields are left as they are.
+type ProblemBulkEdit struct {
+	ProblemIDs  []string `json:"problemIds"`
+	TimeLimit   *float64 `json:"timeLimit"`
+	MemoryLimit *int     `json:"memoryLimit"`
+	OutputLimit *int     `json:"outputLimit"`
+	AddTags     []string `json:"addTags"`
+	RemoveTags  []string `json:"removeTags"`
+}
+
+func (e ProblemBulkEdit) empty() bool {
+	return e.TimeLimit == nil && e.MemoryLimit == nil && e.OutputLimit == nil &&
+		len(e.AddTags) == 0 && len(e.RemoveTags) == 0
+}
+
+// BulkEdit applies the edit to every problem it names. Either all of them
+// are changed or, when one is missing or would become invalid, none is.
+func (s *ProblemService) BulkEdit(edit ProblemBulkEdit) ([]Problem, error) {
+	if len(edit.ProblemIDs) == 0 || edit.empty() {
+		return nil, ErrInvalidBulkEdit
+	}
+	remove := normalizeTags(edit.RemoveTags)
+	problems := make([]Problem, 0, len(edit.ProblemIDs))
+	for _, id := range edit.ProblemIDs {
+		problem, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if edit.TimeLimit != nil {
+			problem.TimeLimit = *edit.TimeLimit
+		}
+		if edit.MemoryLimit != nil {
+			problem.MemoryLimit = *edit.MemoryLimit
+		}
+		if edit.OutputLimit != nil {
+			problem.OutputLimit = *edit.OutputLimit
+		}
+		problem.Tags = slices.DeleteFunc(append(problem.Tags, edit.AddTags...), func(tag string) bool {
+			return slices.Contains(remove, strings.TrimSpace(tag))
+		})
+		problem.applyDefaults()
+		if err := s.validate(problem); err != nil {
+			return nil, err
+		}
+		problems = append(problems, problem)
+	}
+	for _, problem := range problems {
+		if err := setJSON(s.store, problemKeyPrefix+problem.ID, problem, 0); err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
+
+// normalizeTags trims tags and drops empty and repeated ones, keeping
+// their order.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"online-judge/internal/judge"
+	"online-judge/internal/objectstore"
+	"online-judge/internal/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrProblemNotFound      = errors.New("problem not found")
+	ErrInvalidLimits        = errors.New("time and memory limits must be positive")
+	ErrInvalidScoringPolicy = errors.New("invalid scoring policy")
+	ErrInvalidFeedback      = errors.New("invalid feedback policy")
+	ErrInvalidOptimization  = errors.New("optimization direction must be minimize or maximize")
+	ErrTestNotFound         = errors.New("test not found")
+)
+
+type Problem struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Statement string `json:"statement"`
+	// Tags group problems, e.g. by course and semester, for listing and
+	// bulk edits.
+	Tags []string `json:"tags,omitempty"`
+	// Difficulty is the setter's rating of the problem; zero means unrated.
+	// EstimatedDifficulty is computed from submissions; see
+	// difficulty_estimation.go.
+	Difficulty          int                 `json:"difficulty,omitempty"`
+	EstimatedDifficulty *DifficultyEstimate `json:"estimatedDifficulty,omitempty"`
+	TimeLimit           float64             `json:"timeLimit"`   // seconds
+	MemoryLimit         int                 `json:"memoryLimit"` // kilobytes
+	// OutputLimit caps the program's output in kilobytes; zero leaves the
+	// judge's default.
+	OutputLimit int `json:"outputLimit,omitempty"`
+	// NetworkEnabled lets programs use the network, e.g. to teach HTTP
+	// clients against a server on localhost. Only admins set problems up.
+	NetworkEnabled bool `json:"networkEnabled,omitempty"`
+	// Env is exposed to the program inside the sandbox. Names must be on the
+	// judge's allowlist.
+	Env map[string]string `json:"env,omitempty"`
+	// RandomizeTestOrder runs tests in a per-submission shuffled order so
+	// solutions cannot rely on the order tests were leaked in.
+	RandomizeTestOrder bool `json:"randomizeTestOrder"`
+	// Checker scores each test; without one, output must match exactly.
+	Checker       *Checker      `json:"checker,omitempty"`
+	ScoringPolicy ScoringPolicy `json:"scoringPolicy,omitempty"`
+	MaxScore      float64       `json:"maxScore"`
+	// Optimization marks problems ranked by the checker's objective value.
+	Optimization *Optimization `json:"optimization,omitempty"`
+	// QueueWeight is the problem's share of grading capacity relative to
+	// other problems with queued submissions; zero means the default of 1.
+	QueueWeight int `json:"queueWeight,omitempty"`
+	// Feedback limits what contestants see of failed tests.
+	Feedback FeedbackPolicy `json:"feedback,omitempty"`
+	// SolutionVisibility decides who may read other users' accepted
+	// sources.
+	SolutionVisibility SolutionVisibility `json:"solutionVisibility,omitempty"`
+	// Templates are the starter code per language name.
+	Templates map[string]StarterTemplate `json:"templates,omitempty"`
+	// Harnesses are the hidden programs per language name that function-only
+	// submissions are spliced into; see harness.go.
+	Harnesses map[string]StarterTemplate `json:"harnesses,omitempty"`
+	// SQL makes the problem a database exercise; see sql_problems.go.
+	SQL *SQLSettings `json:"sql,omitempty"`
+	// Notebook picks the cells judged of notebook submissions.
+	Notebook *NotebookSettings `json:"notebook,omitempty"`
+}
+
+// TestCase is one input/expected-output pair. Sample tests are shown to
+// contestants with the statement. Final tests are held back from contest
+// submissions until system testing; the remaining tests form the
+// provisional set judged during the contest.
+type TestCase struct {
+	Input    string        `json:"input"`
+	Output   string        `json:"output"`
+	Sample   bool          `json:"sample"`
+	Final    bool          `json:"final,omitempty"`
+	Metadata *TestMetadata `json:"metadata,omitempty"`
+	// Data points at input and output kept in object storage, for tests
+	// too large for the store. Input and Output are then empty.
+	Data *TestData `json:"data,omitempty"`
+}
+
+// TestData locates a test's files in object storage.
+type TestData struct {
+	InputKey   string `json:"inputKey"`
+	OutputKey  string `json:"outputKey"`
+	InputSize  int64  `json:"inputSize"`
+	OutputSize int64  `json:"outputSize"`
+}
+
+// identity tells tests apart by their content.
+func (t TestCase) identity() string {
+	if t.Data != nil {
+		return "data:" + t.Data.InputKey + "\x00" + t.Data.OutputKey
+	}
+	return t.Input + "\x00" + t.Output
+}
+
+// TestMetadata records what a test is for and where it came from, for
+// setters only.
+type TestMetadata struct {
+	Description string         `json:"description,omitempty"`
+	Generator   *TestGenerator `json:"generator,omitempty"`
+	Author      string         `json:"author,omitempty"`
+	AddedAt     time.Time      `json:"addedAt"`
+}
+
+// TestGenerator is how to regenerate a test's input.
+type TestGenerator struct {
+	Command string `json:"command" binding:"required"`
+	Seed    string `json:"seed,omitempty"`
+}
+
+// Public returns the test as shown to contestants.
+func (t TestCase) Public() TestCase {
+	return TestCase{Input: t.Input, Output: t.Output, Sample: t.Sample}
+}
+
+func (p *Problem) applyDefaults() {
+	if p.ScoringPolicy == "" {
+		p.ScoringPolicy = ScoringBinary
+	}
+	if p.Feedback == "" {
+		p.Feedback = FeedbackFull
+	}
+	if p.SolutionVisibility == "" {
+		p.SolutionVisibility = SolutionsOwner
+	}
+	if p.MaxScore == 0 {
+		p.MaxScore = 100
+	}
+	p.Tags = normalizeTags(p.Tags)
+}
+
+// Public returns the problem as shown to contestants, without setter-only
+// fields.
+func (p Problem) Public() Problem {
+	p.Env = nil
+	p.RandomizeTestOrder = false
+	p.Checker = nil
+	p.QueueWeight = 0
+	p.Harnesses = nil
+	return p
+}
+
+const (
+	problemKeyPrefix      = "problem:"
+	problemIDKey          = "counter:problem"
+	problemTestsKeyPrefix = "tests:problem:"
+)
+
+type ProblemService struct {
+	store        store.Store
+	envAllowlist *judge.EnvAllowlist
+	objects      *objectstore.Client
+}
+
+func NewProblemService(st store.Store, envAllowlist *judge.EnvAllowlist) *ProblemService {
+	return &ProblemService{store: st, envAllowlist: envAllowlist}
+}
+
+// SetObjectStore enables tests whose data lives in object storage. It must
+// be called before tests are graded.
+func (s *ProblemService) SetObjectStore(objects *objectstore.Client) {
+	s.objects = objects
+}
+
+func (s *ProblemService) Create(problem Problem) (Problem, error) {
+	problem.EstimatedDifficulty = nil
+	problem.applyDefaults()
+	if err := s.validate(problem); err != nil {
+		return Problem{}, err
+	}
+
+	id, err := s.store.Incr(problemIDKey)
+	if err != nil {
+		return Problem{}, err
+	}
+	problem.ID = strconv.FormatInt(id, 10)
+	if err := setJSON(s.store, problemKeyPrefix+problem.ID, problem, 0); err != nil {
+		return Problem{}, err
+	}
+	return problem, nil
+}
+
+func (s *ProblemService) Update(problem Problem) (Problem, error) {
+	existing, err := s.Get(problem.ID)
+	if err != nil {
+		return Problem{}, err
+	}
+	problem.EstimatedDifficulty = existing.EstimatedDifficulty
+	problem.applyDefaults()
+	if err := s.validate(problem); err != nil {
+		return Problem{}, err
+	}
+	if err := setJSON(s.store, problemKeyPrefix+problem.ID, problem, 0); err != nil {
+		return Problem{}, err
+	}
+	return problem, nil
+}
+
+func (s *ProblemService) Get(id string) (Problem, error) {
+	var problem Problem
+	if err := getJSON(s.store, problemKeyPrefix+id, &problem); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return Problem{}, ErrProblemNotFound
+		}
+		return Problem{}, err
+	}
+	return problem, nil
+}
+
+func (s *ProblemService) List() ([]Problem, error) {
+	return listJSON[Problem](s.store, problemKeyPrefix)
+}
+
+// SetTests replaces the problem's test set. Every test gets metadata
+// naming author as its author unless it names one itself. Tests that were
+// already present with the same input and output keep their added date.
+func (s *ProblemService) SetTests(problemID string, tests []TestCase, author string) error {
+	if _, err := s.Get(problemID); err != nil {
+		return err
+	}
+	previous, err := s.Tests(problemID)
+	if err != nil {
+		return err
+	}
+	addedAt := make(map[string]time.Time, len(previous))
+	for _, test := range previous {
+		if test.Metadata != nil {
+			addedAt[test.identity()] = test.Metadata.AddedAt
+		}
+	}
+
+	now := time.Now()
+	for i := range tests {
+		metadata := TestMetadata{}
+		if tests[i].Metadata != nil {
+			metadata = *tests[i].Metadata
+		}
+		if metadata.Author == "" {
+			metadata.Author = author
+		}
+		if metadata.AddedAt.IsZero() {
+			if at, ok := addedAt[tests[i].identity()]; ok {
+				metadata.AddedAt = at
+			} else {
+				metadata.AddedAt = now
+			}
+		}
+		tests[i].Metadata = &metadata
+	}
+	return setJSON(s.store, problemTestsKeyPrefix+problemID, tests, 0)
+}
+
+// SetTestMetadata replaces the metadata of one test, identified by its
+// 1-based index. The added date is kept unless metadata sets one.
+func (s *ProblemService) SetTestMetadata(problemID string, test int, metadata TestMetadata) (TestCase, error) {
+	if _, err := s.Get(problemID); err != nil {
+		return TestCase{}, err
+	}
+	tests, err := s.Tests(problemID)
+	if err != nil {
+		return TestCase{}, err
+	}
+	if test < 1 || test > len(tests) {
+		return TestCase{}, ErrTestNotFound
+	}
+
+	current := tests[test-1].Metadata
+	if metadata.AddedAt.IsZero() && current != nil {
+		metadata.AddedAt = current.AddedAt
+	}
+	tests[test-1].Metadata = &metadata
+	if err := setJSON(s.store, problemTestsKeyPrefix+problemID, tests, 0); err != nil {
+		return TestCase{}, err
+	}
+	return tests[test-1], nil
+}
+
+// AddTest appends a test to the problem's test set.
+func (s *ProblemService) AddTest(problemID string, test TestCase, author string) (TestCase, error) {
+	tests, err := s.Tests(problemID)
+	if err != nil {
+		return TestCase{}, err
+	}
+	tests = append(tests, test)
+	if err := s.SetTests(problemID, tests, author); err != nil {
+		return TestCase{}, err
+	}
+	return tests[len(tests)-1], nil
+}
+
+// LoadTestData fills in the output, and with withInput also the input, of a
+// test kept in object storage. Other tests are returned unchanged. Judge
+// workers fetch inputs themselves through InputRef, so the input is only
+// needed here when a checker reads it.
+func (s *ProblemService) LoadTestData(ctx context.Context, test TestCase, withInput bool) (TestCase, error) {
+	if test.Data == nil {
+		return test, nil
+	}
+	if s.objects == nil {
+		return TestCase{}, ErrObjectStorageDisabled
+	}
+	var err error
+	if withInput {
+		if test.Input, err = s.readObject(ctx, test.Data.InputKey); err != nil {
+			return TestCase{}, err
+		}
+	}
+	if test.Output, err = s.readObject(ctx, test.Data.OutputKey); err != nil {
+		return TestCase{}, err
+	}
+	return test, nil
+}
+
+// TestDataVersion identifies the object-stored data of a test set. Judge
+// workers cache test data under it.
+func TestDataVersion(tests []TestCase) string {
+	hash := sha256.New()
+	for _, test := range tests {
+		if test.Data != nil {
+			fmt.Fprintf(hash, "%s\x00%s\x00", test.Data.InputKey, test.Data.OutputKey)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// InputRef returns a reference a judge worker downloads the test's input
+// from. The download link stays valid for an hour.
+func (s *ProblemService) InputRef(version string, test TestCase) (*judge.DataRef, error) {
+	if s.objects == nil {
+		return nil, ErrObjectStorageDisabled
+	}
+	url, err := s.objects.PresignGet(test.Data.InputKey, time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	name := sha256.Sum256([]byte(test.Data.InputKey))
+	return &judge.DataRef{
+		Version: version,
+		Name:    hex.EncodeToString(name[:8]) + ".in",
+		URL:     url,
+		Size:    test.Data.InputSize,
+	}, nil
+}
+
+func (s *ProblemService) readObject(ctx context.Context, key string) (string, error) {
+	body, err := s.objects.Open(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
+
+func (s *ProblemService) Tests(problemID string) ([]TestCase, error) {
+	var tests []TestCase
+	err := getJSON(s.store, problemTestsKeyPrefix+problemID, &tests)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	return tests, err
+}
+
+func (s *ProblemService) SampleTests(problemID string) ([]TestCase, error) {
+	tests, err := s.Tests(problemID)
+	if err != nil {
+		return nil, err
+	}
+	var samples []TestCase
+	for _, test := range tests {
+		if test.Sample {
+			samples = append(samples, test)
+		}
+	}
+	return samples, nil
+}
+
+func (s *ProblemService) validate(problem Problem) error {
+	if problem.TimeLimit <= 0 || problem.MemoryLimit <= 0 || problem.OutputLimit < 0 || problem.MaxScore < 0 || problem.QueueWeight < 0 || problem.Difficulty < 0 {
+		return ErrInvalidLimits
+	}
+	if !problem.ScoringPolicy.valid() {
+		return ErrInvalidScoringPolicy
+	}
+	if !problem.Feedback.valid() {
+		return ErrInvalidFeedback
+	}
+	if !problem.SolutionVisibility.valid() {
+		return ErrInvalidSolutionVisibility
+	}
+	if problem.Optimization != nil && !problem.Optimization.Direction.valid() {
+		return ErrInvalidOptimization
+	}
+	if err := validateTemplates(problem.Templates); err != nil {
+		return err
+	}
+	if err := validateHarnesses(problem.Harnesses); err != nil {
+		return err
+	}
+	return s.envAllowlist.Validate(problem.Env)
+}
+
+func (s *ProblemService) SnapshotName() string {
+	return "problems"
+}
+
+func (s *ProblemService) ExportSnapshot() (json.RawMessage, error) {
+	problems, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(problems)
+}
+
+func (s *ProblemService) ImportSnapshot(data json.RawMessage) error {
+	var problems []Problem
+	if err := json.Unmarshal(data, &problems); err != nil {
+		return err
+	}
+
+	maxID := 0
+	for _, problem := range problems {
+		if id, err := strconv.Atoi(problem.ID); err == nil && id > maxID {
+			maxID = id
+		}
+	}
+
+	if err := replaceJSON(s.store, problemKeyPrefix, problems, func(p Problem) string { return p.ID }); err != nil {
+		return err
+	}
+	return s.store.Set(problemIDKey, []byte(strconv.Itoa(maxID)), 0)
+}
+
+// TestsSnapshot returns the backup section holding every problem's tests.
+func (s *ProblemService) TestsSnapshot() SnapshotSection {
+	return problemTestsSnapshot{store: s.store}
+}
+
+type problemTestsSnapshot struct {
+	store store.Store
+}
+
+func (p problemTestsSnapshot) SnapshotName() string {
+	return "problemTests"
+}
+
+func (p problemTestsSnapshot) ExportSnapshot() (json.RawMessage, error) {
+	keys, err := p.store.Keys(problemTestsKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	tests := make(map[string][]TestCase, len(keys))
+	for _, key := range keys {
+		var problemTests []TestCase
+		if err := getJSON(p.store, key, &problemTests); err != nil {
+			return nil, err
+		}
+		tests[strings.TrimPrefix(key, problemTestsKeyPrefix)] = problemTests
+	}
+	return json.Marshal(tests)
+}
+
+func (p problemTestsSnapshot) ImportSnapshot(data json.RawMessage) error {
+	var tests map[string][]TestCase
+	if err := json.Unmarshal(data, &tests); err != nil {
+		return err
+	}
+
+	keys, err := p.store.Keys(problemTestsKeyPrefix)
+	if err != nil {
+		return err
+	}
+	if err := p.store.Delete(keys...); err != nil {
+		return err
+	}
+	for problemID, problemTests := range tests {
+		if err := setJSON(p.store, problemTestsKeyPrefix+problemID, problemTests, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"online-judge/internal/store"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProblemStats summarizes the judged submissions to a problem.
+// AvgAttemptsToAccept counts, for each user who solved the problem, the
+// submissions up to and including their first accepted one.
+type ProblemStats struct {
+	ProblemID           string                     `json:"problemId"`
+	Attempts            int64                      `json:"attempts"`
+	Accepted            int64                      `json:"accepted"`
+	AcceptanceRate      float64                    `json:"acceptanceRate"`
+	Users               int                        `json:"users"`
+	Solvers             int                        `json:"solvers"`
+	AvgAttemptsToAccept float64                    `json:"avgAttemptsToAccept"`
+	Verdicts            map[Verdict]int64          `json:"verdicts"`
+	Fastest             map[string]FastestSolution `json:"fastest"`
+}
+
+// FastestSolution is the accepted submission with the lowest peak CPU time
+// in a language, ties going to lower peak memory.
+type FastestSolution struct {
+	SubmissionID string    `json:"submissionId,omitempty"`
+	UserID       string    `json:"userId"`
+	Time         float64   `json:"time"`   // seconds, slowest test
+	Memory       int       `json:"memory"` // kilobytes, largest test
+	SubmittedAt  time.Time `json:"submittedAt"`
+}
+
+func (f FastestSolution) faster(other FastestSolution) bool {
+	if f.Time != other.Time {
+		return f.Time < other.Time
+	}
+	return f.Memory < other.Memory
+}
+
+// problemStatsUser is one user's progress on a problem.
+type problemStatsUser struct {
+	Attempts int `json:"attempts"`
+	// SolvedAfter is the attempt that was first accepted; zero while
+	// unsolved.
+	SolvedAfter int `json:"solvedAfter,omitempty"`
+}
+
+const problemStatsPrefix = "stats:problem:"
+
+// ProblemStatsService keeps per-problem statistics up to date as
+// submissions are judged, so reads do not scan every submission. Verdict
+// counts are store counters; each counted submission remembers the
+// verdict it was counted under so a rejudge moves it to its new verdict.
+type ProblemStatsService struct {
+	store          store.Store
+	problemService *ProblemService
+}
+
+func NewProblemStatsService(st store.Store, problemService *ProblemService) *ProblemStatsService {
+	return &ProblemStatsService{store: st, problemService: problemService}
+}
+
+// Record is a GradedListener. Virtual and practice submissions count like
+// any other; internal errors are not attempts.
+func (s *ProblemStatsService) Record(submission Submission, problem Problem) error {
+	if submission.Status != SubmissionJudged || submission.Verdict == VerdictInternalError {
+		return nil
+	}
+	prefix := problemStatsPrefix + problem.ID + ":"
+
+	countedKey := prefix + "submission:" + submission.ID
+	previous, err := s.store.Get(countedKey)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	first := errors.Is(err, store.ErrNotFound)
+	if !first && Verdict(previous) == submission.Verdict {
+		return nil
+	}
+	if err := s.store.Set(countedKey, []byte(submission.Verdict), 0); err != nil {
+		return err
+	}
+	if !first {
+		if _, err := s.store.Decr(prefix + "verdict:" + string(previous)); err != nil {
+			return err
+		}
+	}
+	if _, err := s.store.Incr(prefix + "verdict:" + string(submission.Verdict)); err != nil {
+		return err
+	}
+
+	if err := s.recordUser(prefix+"user:"+submission.UserID, submission.Verdict, first); err != nil {
+		return err
+	}
+	if submission.Verdict == VerdictAccepted {
+		return s.recordFastest(prefix+"fastest:"+submission.Language, submission)
+	}
+	return nil
+}
+
+func (s *ProblemStatsService) recordUser(key string, verdict Verdict, first bool) error {
+	var user problemStatsUser
+	if err := getJSON(s.store, key, &user); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if first {
+		user.Attempts++
+	}
+	if verdict == VerdictAccepted && user.SolvedAfter == 0 {
+		user.SolvedAfter = user.Attempts
+	}
+	return setJSON(s.store, key, user, 0)
+}
+
+func (s *ProblemStatsService) recordFastest(key string, submission Submission) error {
+	candidate := fastestSolution(submission)
+
+	var current FastestSolution
+	err := getJSON(s.store, key, &current)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if err == nil && current.SubmissionID != submission.ID && !candidate.faster(current) {
+		return nil
+	}
+	return setJSON(s.store, key, candidate, 0)
+}
+
+func fastestSolution(submission Submission) FastestSolution {
+	solution := FastestSolution{
+		SubmissionID: submission.ID,
+		UserID:       submission.UserID,
+		SubmittedAt:  submission.CreatedAt,
+	}
+	solution.Time, solution.Memory = peakUsage(submission.Results)
+	return solution
+}
+
+// peakUsage returns the highest CPU time and memory among the results.
+func peakUsage(results []TestResult) (float64, int) {
+	cpu, memory := 0.0, 0
+	for _, result := range results {
+		cpu = max(cpu, result.Time)
+		memory = max(memory, result.Memory)
+	}
+	return cpu, memory
+}
+
+// Stats returns the problem's statistics as recorded so far.
+func (s *ProblemStatsService) Stats(problemID string) (ProblemStats, error) {
+	if _, err := s.problemService.Get(problemID); err != nil {
+		return ProblemStats{}, err
+	}
+	prefix := problemStatsPrefix + problemID + ":"
+	stats := ProblemStats{
+		ProblemID: problemID,
+		Verdicts:  make(map[Verdict]int64),
+		Fastest:   make(map[string]FastestSolution),
+	}
+
+	keys, err := s.store.Keys(prefix + "verdict:")
+	if err != nil {
+		return ProblemStats{}, err
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := s.store.Get(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return ProblemStats{}, err
+		}
+		n, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			log.Printf("Invalid problem stats counter %s: %v", key, err)
+			continue
+		}
+		if n <= 0 {
+			continue
+		}
+		verdict := Verdict(strings.TrimPrefix(key, prefix+"verdict:"))
+		stats.Verdicts[verdict] = n
+		stats.Attempts += n
+	}
+	stats.Accepted = stats.Verdicts[VerdictAccepted]
+	if stats.Attempts > 0 {
+		stats.AcceptanceRate = float64(stats.Accepted) / float64(stats.Attempts)
+	}
+
+	users, err := listJSON[problemStatsUser](s.store, prefix+"user:")
+	if err != nil {
+		return ProblemStats{}, err
+	}
+	stats.Users = len(users)
+	attemptsToAccept := 0
+	for _, user := range users {
+		if user.SolvedAfter > 0 {
+			stats.Solvers++
+			attemptsToAccept += user.SolvedAfter
+		}
+	}
+	if stats.Solvers > 0 {
+		stats.AvgAttemptsToAccept = float64(attemptsToAccept) / float64(stats.Solvers)
+	}
+
+	keys, err = s.store.Keys(prefix + "fastest:")
+	if err != nil {
+		return ProblemStats{}, err
+	}
+	for _, key := range keys {
+		var fastest FastestSolution
+		if err := getJSON(s.store, key, &fastest); errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return ProblemStats{}, err
+		}
+		stats.Fastest[strings.TrimPrefix(key, prefix+"fastest:")] = fastest
+	}
+	return stats, nil
+}
+
+// Recomputer returns the rejudge recomputer that rebuilds the statistics of
+// every problem with a changed verdict from its stored submissions, since
+// the counters cannot take back an attempt's first accept.
+func (s *ProblemStatsService) Recomputer(submissionService *SubmissionService) Recomputer {
+	return NewRecomputer("problem statistics", func(_ []string, changes []VerdictChange) error {
+		seen := make(map[string]bool)
+		for _, change := range changes {
+			if seen[change.ProblemID] {
+				continue
+			}
+			seen[change.ProblemID] = true
+			submissions, err := submissionService.List(SubmissionFilter{ProblemID: change.ProblemID})
+			if err != nil {
+				return err
+			}
+			if err := s.rebuild(change.ProblemID, submissions); err != nil {
+				return fmt.Errorf("problem %s: %w", change.ProblemID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// rebuild drops the problem's statistics and records its submissions
+// again, oldest first.
+func (s *ProblemStatsService) rebuild(problemID string, submissions []Submission) error {
+	problem, err := s.problemService.Get(problemID)
+	if err != nil {
+		return err
+	}
+	keys, err := s.store.Keys(problemStatsPrefix + problemID + ":")
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if err := s.store.Delete(keys...); err != nil {
+			return err
+		}
+	}
+	sort.SliceStable(submissions, func(i, j int) bool {
+		return submissions[i].CreatedAt.Before(submissions[j].CreatedAt)
+	})
+	for _, submission := range submissions {
+		if err := s.Record(submission, problem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserData returns the privacy section holding the user's attempts per
+// problem, which are moved to the pseudonym so solver counts stay.
+func (s *ProblemStatsService) UserData() UserDataSection {
+	return userKeySection{store: s.store, name: "problemStats", prefixes: []string{problemStatsPrefix}, marker: ":user:"}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"online-judge/internal/store"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PublicConfig configures the unauthenticated read-only API.
+type PublicConfig struct {
+	// RateLimit is how many requests one client IP may make per minute;
+	// zero or less disables the limit.
+	RateLimit int
+	// Salt keys the pseudonyms that replace user IDs. When empty, a random
+	// salt is kept in the store so every replica derives the same ones.
+	Salt string
+}
+
+// PublicConfigFromEnv reads PUBLIC_API_RATE_LIMIT and PUBLIC_API_SALT.
+func PublicConfigFromEnv() PublicConfig {
+	return PublicConfig{
+		RateLimit: intFromEnv("PUBLIC_API_RATE_LIMIT", 60),
+		Salt:      strings.TrimSpace(os.Getenv("PUBLIC_API_SALT")),
+	}
+}
+
+// PublicStandingsRow is a standings row with the user replaced by a stable
+// pseudonym and without submission IDs.
+type PublicStandingsRow struct {
+	Rank        int                `json:"rank"`
+	Participant string             `json:"participant"`
+	Total       float64            `json:"total"`
+	Problems    map[string]float64 `json:"problems"`
+}
+
+type PublicStandings struct {
+	ContestID string               `json:"contestId"`
+	Phase     StandingsPhase       `json:"phase"`
+	Rows      []PublicStandingsRow `json:"rows"`
+}
+
+// SubmissionStats counts judged submissions without naming anyone.
+// Virtual participations count like practice submissions; submissions to
+// a contest count once it has finished and any freeze has been resolved.
+type SubmissionStats struct {
+	Submissions  int             `json:"submissions"`
+	Participants int             `json:"participants"`
+	ByVerdict    map[Verdict]int `json:"byVerdict"`
+	ByLanguage   map[string]int  `json:"byLanguage"`
+	ComputedAt   time.Time       `json:"computedAt"`
+}
+
+const publicSaltKey = "public:salt"
+
+// PublicService serves the read-only data of the public API: problems as
+// contestants see them, contest standings under pseudonyms and aggregate
+// submission statistics.
+type PublicService struct {
+	store             store.Store
+	problemService    *ProblemService
+	contestService    *ContestService
+	submissionService *SubmissionService
+	scoreboardService *ScoreboardService
+	statsService      *ProblemStatsService
+	config            PublicConfig
+}
+
+func NewPublicService(st store.Store, problemService *ProblemService, contestService *ContestService, submissionService *SubmissionService, scoreboardService *ScoreboardService, statsService *ProblemStatsService, config PublicConfig) *PublicService {
+	return &PublicService{
+		store:             st,
+		problemService:    problemService,
+		contestService:    contestService,
+		submissionService: submissionService,
+		scoreboardService: scoreboardService,
+		statsService:      statsService,
+		config:            config,
+	}
+}
+
+func (s *PublicService) RateLimit() int {
+	return s.config.RateLimit
+}
+
+func (s *PublicService) Problems() ([]Problem, error) {
+	problems, err := s.problemService.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range problems {
+		problems[i] = problems[i].Public()
+	}
+	return problems, nil
+}
+
+// Problem returns the problem and its sample tests as contestants see them.
+func (s *PublicService) Problem(id string) (Problem, []TestCase, error) {
+	problem, err := s.problemService.Get(id)
+	if err != nil {
+		return Problem{}, nil, err
+	}
+	samples, err := s.problemService.SampleTests(id)
+	if err != nil {
+		return Problem{}, nil, err
+	}
+	public := make([]TestCase, len(samples))
+	for i, sample := range samples {
+		public[i] = sample.Public()
+	}
+	return problem.Public(), public, nil
+}
+
+// Standings returns the contest's standings with every user replaced by a
+// pseudonym that is the same across contests but cannot be turned back
+// into the user ID without the salt.
+func (s *PublicService) Standings(contestID string, phase StandingsPhase) (PublicStandings, error) {
+	standings, err := s.scoreboardService.Standings(contestID, phase, false)
+	if err != nil {
+		return PublicStandings{}, err
+	}
+	salt, err := s.salt()
+	if err != nil {
+		return PublicStandings{}, err
+	}
+
+	rows := make([]PublicStandingsRow, 0, len(standings.Rows))
+	for _, row := range standings.Rows {
+		problems := make(map[string]float64, len(row.Problems))
+		for problemID, problem := range row.Problems {
+			problems[problemID] = problem.Score
+		}
+		rows = append(rows, PublicStandingsRow{
+			Rank:        row.Rank,
+			Participant: pseudonym(salt, row.UserID),
+			Total:       row.Total,
+			Problems:    problems,
+		})
+	}
+	// Rows with the same rank would otherwise keep the order of user IDs
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Rank != rows[j].Rank {
+			return rows[i].Rank < rows[j].Rank
+		}
+		return rows[i].Participant < rows[j].Participant
+	})
+	return PublicStandings{ContestID: standings.ContestID, Phase: standings.Phase, Rows: rows}, nil
+}
+
+// ProblemStats returns the problem's statistics with the authors of the
+// fastest solutions replaced by pseudonyms and their submission IDs left
+// out.
+func (s *PublicService) ProblemStats(problemID string) (ProblemStats, error) {
+	stats, err := s.statsService.Stats(problemID)
+	if err != nil {
+		return ProblemStats{}, err
+	}
+	salt, err := s.salt()
+	if err != nil {
+		return ProblemStats{}, err
+	}
+	for language, fastest := range stats.Fastest {
+		fastest.UserID = pseudonym(salt, fastest.UserID)
+		fastest.SubmissionID = ""
+		stats.Fastest[language] = fastest
+	}
+	return stats, nil
+}
+
+// Stats counts every judged submission by verdict and language, leaving
+// out contests whose results are not public yet.
+func (s *PublicService) Stats() (SubmissionStats, error) {
+	submissions, err := s.submissionService.List(SubmissionFilter{})
+	if err != nil {
+		return SubmissionStats{}, err
+	}
+	contests, err := s.contestService.List()
+	if err != nil {
+		return SubmissionStats{}, err
+	}
+	public := make(map[string]bool, len(contests))
+	for _, contest := range contests {
+		public[contest.ID] = contest.resultsPublic()
+	}
+
+	stats := SubmissionStats{
+		ByVerdict:  make(map[Verdict]int),
+		ByLanguage: make(map[string]int),
+		ComputedAt: time.Now().UTC(),
+	}
+	users := make(map[string]bool)
+	for _, submission := range submissions {
+		if submission.Status != SubmissionJudged {
+			continue
+		}
+		if submission.ContestID != "" && !submission.Virtual && !public[submission.ContestID] {
+			continue
+		}
+		stats.Submissions++
+		stats.ByVerdict[submission.Verdict]++
+		stats.ByLanguage[submission.Language]++
+		users[submission.UserID] = true
+	}
+	stats.Participants = len(users)
+	return stats, nil
+}
+
+// salt returns the configured salt or the one shared through the store,
+// creating it on first use.
+func (s *PublicService) salt() (string, error) {
+	if s.config.Salt != "" {
+		return s.config.Salt, nil
+	}
+	if salt, err := s.store.Get(publicSaltKey); err == nil {
+		return string(salt), nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return "", err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	// Another replica may have won the race; use whichever salt is stored
+	if _, err := s.store.SetNX(publicSaltKey, []byte(hex.EncodeToString(random)), 0); err != nil {
+		return "", err
+	}
+	salt, err := s.store.Get(publicSaltKey)
+	return string(salt), err
+}
+
+func pseudonym(salt, userID string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(userID))
+	return "anon-" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
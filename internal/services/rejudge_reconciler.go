@@ -0,0 +1,310 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"online-judge/internal/store"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRejudgeNotFound        = errors.New("rejudge not found")
+	ErrNoSubmissionsToRejudge = errors.New("no submissions to rejudge")
+)
+
+// VerdictChange records a submission whose verdict differs after a rejudge.
+type VerdictChange struct {
+	SubmissionID string `json:"submissionId"`
+	UserID       string `json:"userId"`
+	ContestID    string `json:"contestId,omitempty"`
+	ProblemID    string `json:"problemId"`
+	OldVerdict   string `json:"oldVerdict"`
+	NewVerdict   string `json:"newVerdict"`
+}
+
+// Recomputer rebuilds derived state (a scoreboard, ratings, assignment grades,
+// ...) for the contests touched by a rejudge.
+type Recomputer interface {
+	Name() string
+	Recompute(contestIDs []string, changes []VerdictChange) error
+}
+
+// recomputerFunc is a Recomputer made of a function.
+type recomputerFunc struct {
+	name string
+	fn   func(contestIDs []string, changes []VerdictChange) error
+}
+
+// NewRecomputer returns a Recomputer that calls fn.
+func NewRecomputer(name string, fn func(contestIDs []string, changes []VerdictChange) error) Recomputer {
+	return recomputerFunc{name: name, fn: fn}
+}
+
+func (r recomputerFunc) Name() string { return r.name }
+
+func (r recomputerFunc) Recompute(contestIDs []string, changes []VerdictChange) error {
+	return r.fn(contestIDs, changes)
+}
+
+// Rejudge is one batch of submissions graded again. Previous holds each
+// submission's verdict before the rejudge; once every submission has a
+// new one, the batch is reconciled against the stored verdicts.
+type Rejudge struct {
+	ID            string             `json:"id"`
+	SubmissionIDs []string           `json:"submissionIds"`
+	Previous      map[string]Verdict `json:"previous"`
+	RequestedBy   string             `json:"requestedBy"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	// Changes are the verdict changes found once the batch finished.
+	Changes      []VerdictChange `json:"changes,omitempty"`
+	ReconciledAt *time.Time      `json:"reconciledAt,omitempty"`
+}
+
+const (
+	rejudgeKeyPrefix        = "rejudge:"
+	rejudgeIDKey            = "counter:rejudge"
+	rejudgePendingPrefix    = "rejudge-pending:"
+	rejudgeSubmissionPrefix = "rejudge-submission:"
+	rejudgeGradedPrefix     = "rejudge-graded:"
+)
+
+// RejudgeReconciler rejudges submissions and propagates the verdict changes
+// to every registered recomputer, telling affected users which submissions
+// changed. Batches are kept in the store, so whichever replica grades the
+// last submission of a batch reconciles it.
+type RejudgeReconciler struct {
+	store               store.Store
+	submissionService   *SubmissionService
+	notificationService *NotificationService
+	recomputers         []Recomputer
+}
+
+func NewRejudgeReconciler(st store.Store, submissionService *SubmissionService, notificationService *NotificationService) *RejudgeReconciler {
+	return &RejudgeReconciler{store: st, submissionService: submissionService, notificationService: notificationService}
+}
+
+// Register adds a recomputer. Recomputers run in registration order, so
+// scoreboards should be registered before ratings that depend on them.
+func (r *RejudgeReconciler) Register(recomputer Recomputer) {
+	r.recomputers = append(r.recomputers, recomputer)
+}
+
+// Rejudge queues the submissions to be graded again, remembering their
+// current verdicts. Every submission must be judged or failed and still
+// have its source.
+func (r *RejudgeReconciler) Rejudge(judgeID string, submissionIDs []string) (Rejudge, error) {
+	rejudge := Rejudge{Previous: make(map[string]Verdict), RequestedBy: judgeID, CreatedAt: time.Now()}
+	for _, id := range submissionIDs {
+		if _, ok := rejudge.Previous[id]; ok {
+			continue
+		}
+		submission, err := r.submissionService.getRecord(id)
+		if err != nil {
+			return Rejudge{}, err
+		}
+		if !submission.rejudgeable() {
+			return Rejudge{}, fmt.Errorf("submission %s: %w", id, ErrNotRejudgeable)
+		}
+		rejudge.SubmissionIDs = append(rejudge.SubmissionIDs, id)
+		rejudge.Previous[id] = submission.Verdict
+	}
+	if len(rejudge.SubmissionIDs) == 0 {
+		return Rejudge{}, ErrNoSubmissionsToRejudge
+	}
+
+	id, err := r.store.Incr(rejudgeIDKey)
+	if err != nil {
+		return Rejudge{}, err
+	}
+	rejudge.ID = strconv.FormatInt(id, 10)
+	if err := setJSON(r.store, rejudgeKeyPrefix+rejudge.ID, rejudge, 0); err != nil {
+		return Rejudge{}, err
+	}
+	pending := []byte(strconv.Itoa(len(rejudge.SubmissionIDs)))
+	if err := r.store.Set(rejudgePendingPrefix+rejudge.ID, pending, 0); err != nil {
+		return Rejudge{}, err
+	}
+	for i, submissionID := range rejudge.SubmissionIDs {
+		err := r.store.Set(rejudgeSubmissionPrefix+submissionID, []byte(rejudge.ID), 0)
+		if err == nil {
+			_, err = r.submissionService.Rejudge(submissionID)
+		}
+		if err != nil {
+			// What was not queued counts as done, so the queued part of the
+			// batch is still reconciled
+			for _, rest := range rejudge.SubmissionIDs[i:] {
+				r.graded(rejudge.ID, rest)
+			}
+			return rejudge, fmt.Errorf("submission %s: %w", submissionID, err)
+		}
+	}
+	return rejudge, nil
+}
+
+// Get returns a rejudge batch.
+func (r *RejudgeReconciler) Get(id string) (Rejudge, error) {
+	var rejudge Rejudge
+	if err := getJSON(r.store, rejudgeKeyPrefix+id, &rejudge); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return Rejudge{}, ErrRejudgeNotFound
+		}
+		return Rejudge{}, err
+	}
+	return rejudge, nil
+}
+
+// Record is a GradedListener. When the submission belongs to a rejudge
+// batch and was the last of it to be graded, the batch is reconciled in
+// the background.
+func (r *RejudgeReconciler) Record(submission Submission, _ Problem) {
+	batchID, err := r.store.Get(rejudgeSubmissionPrefix + submission.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up the rejudge of submission %s: %v", submission.ID, err)
+		return
+	}
+	r.graded(string(batchID), submission.ID)
+}
+
+// graded counts the submission off its batch, once even if it is graded
+// twice, and reconciles the batch after its last submission.
+func (r *RejudgeReconciler) graded(batchID, submissionID string) {
+	first, err := r.store.SetNX(rejudgeGradedPrefix+batchID+":"+submissionID, []byte("1"), 0)
+	if err != nil || !first {
+		if err != nil {
+			log.Printf("Error recording rejudged submission %s: %v", submissionID, err)
+		}
+		return
+	}
+	left, err := r.store.Decr(rejudgePendingPrefix + batchID)
+	if err != nil {
+		log.Printf("Error counting rejudge %s: %v", batchID, err)
+		return
+	}
+	if left == 0 {
+		go func() {
+			if err := r.finish(batchID); err != nil {
+				log.Printf("Error reconciling rejudge %s: %v", batchID, err)
+			}
+		}()
+	}
+}
+
+// finish compares the stored verdicts with those from before the rejudge,
+// reconciles the changes and drops the batch's bookkeeping keys.
+func (r *RejudgeReconciler) finish(batchID string) error {
+	rejudge, err := r.Get(batchID)
+	if err != nil {
+		return err
+	}
+	for _, id := range rejudge.SubmissionIDs {
+		submission, err := r.submissionService.getRecord(id)
+		if errors.Is(err, ErrSubmissionNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if previous := rejudge.Previous[id]; previous != submission.Verdict {
+			rejudge.Changes = append(rejudge.Changes, VerdictChange{
+				SubmissionID: id,
+				UserID:       submission.UserID,
+				ContestID:    submission.ContestID,
+				ProblemID:    submission.ProblemID,
+				OldVerdict:   string(previous),
+				NewVerdict:   string(submission.Verdict),
+			})
+		}
+	}
+	reconcileErr := r.Reconcile(rejudge.Changes)
+
+	now := time.Now()
+	rejudge.ReconciledAt = &now
+	if err := setJSON(r.store, rejudgeKeyPrefix+rejudge.ID, rejudge, 0); err != nil {
+		return err
+	}
+	keys := []string{rejudgePendingPrefix + rejudge.ID}
+	for _, id := range rejudge.SubmissionIDs {
+		keys = append(keys, rejudgeSubmissionPrefix+id, rejudgeGradedPrefix+rejudge.ID+":"+id)
+	}
+	if err := r.store.Delete(keys...); err != nil {
+		return err
+	}
+	return reconcileErr
+}
+
+// Reconcile handles the outcome of one rejudge or override. Entries whose verdict did not
+// change are ignored. Recompute errors are collected so one failing
+// recomputer does not block the others or the notifications.
+func (r *RejudgeReconciler) Reconcile(changes []VerdictChange) error {
+	changed := make([]VerdictChange, 0, len(changes))
+	for _, change := range changes {
+		if change.OldVerdict != change.NewVerdict {
+			changed = append(changed, change)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	contestIDs := affectedContests(changed)
+
+	var failed []string
+	for _, recomputer := range r.recomputers {
+		if err := recomputer.Recompute(contestIDs, changed); err != nil {
+			log.Printf("Error recomputing %s after rejudge: %v", recomputer.Name(), err)
+			failed = append(failed, recomputer.Name())
+		}
+	}
+
+	r.notifyUsers(changed)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("recompute failed for: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (r *RejudgeReconciler) notifyUsers(changes []VerdictChange) {
+	if r.notificationService == nil {
+		return
+	}
+
+	byUser := make(map[string][]VerdictChange)
+	for _, change := range changes {
+		byUser[change.UserID] = append(byUser[change.UserID], change)
+	}
+
+	for userID, userChanges := range byUser {
+		lines := make([]string, 0, len(userChanges))
+		for _, change := range userChanges {
+			lines = append(lines, fmt.Sprintf("Submission %s (problem %s): %s -> %s",
+				change.SubmissionID, change.ProblemID, change.OldVerdict, change.NewVerdict))
+		}
+
+		r.notificationService.Notify([]string{userID}, Notification{
+			Event: EventVerdictChanged,
+			Title: fmt.Sprintf("%d of your submissions changed verdict", len(userChanges)),
+			Body:  strings.Join(lines, "\n"),
+		})
+	}
+}
+
+func affectedContests(changes []VerdictChange) []string {
+	seen := make(map[string]bool)
+	var contestIDs []string
+	for _, change := range changes {
+		if change.ContestID != "" && !seen[change.ContestID] {
+			seen[change.ContestID] = true
+			contestIDs = append(contestIDs, change.ContestID)
+		}
+	}
+	sort.Strings(contestIDs)
+	return contestIDs
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"online-judge/internal/judge"
+	"strconv"
+)
+
+// clicsContest, clicsProblem, clicsTeam and the others below follow the
+// Contest API objects of the same name, as far as the ICPC resolver reads
+// them.
+type clicsContest struct {
+	ID                       string `json:"id"`
+	Name                     string `json:"name"`
+	FormalName               string `json:"formal_name"`
+	StartTime                string `json:"start_time"`
+	Duration                 string `json:"duration"`
+	ScoreboardFreezeDuration string `json:"scoreboard_freeze_duration,omitempty"`
+	ScoreboardType           string `json:"scoreboard_type"`
+	PenaltyTime              int    `json:"penalty_time"`
+}
+
+type clicsJudgementType struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Penalty bool   `json:"penalty"`
+	Solved  bool   `json:"solved"`
+}
+
+type clicsLanguage struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type clicsProblem struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Name    string `json:"name"`
+	Ordinal int    `json:"ordinal"`
+}
+
+type clicsTeam struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+type clicsState struct {
+	Started      string  `json:"started"`
+	Frozen       *string `json:"frozen"`
+	Ended        string  `json:"ended"`
+	Thawed       *string `json:"thawed"`
+	Finalized    string  `json:"finalized"`
+	EndOfUpdates string  `json:"end_of_updates"`
+}
+
+// resolverJudgementTypes are the judgement types clicsJudgementTypes
+// produces. Compile errors and judge errors cost no penalty.
+var resolverJudgementTypes = []clicsJudgementType{
+	{ID: "AC", Name: "Accepted", Solved: true},
+	{ID: "WA", Name: "Wrong Answer", Penalty: true},
+	{ID: "TLE", Name: "Time Limit Exceeded", Penalty: true},
+	{ID: "MLE", Name: "Memory Limit Exceeded", Penalty: true},
+	{ID: "OLE", Name: "Output Limit Exceeded", Penalty: true},
+	{ID: "RTE", Name: "Run-Time Error", Penalty: true},
+	{ID: "CE", Name: "Compile Error"},
+	{ID: "CTL", Name: "Compile Time Limit Exceeded"},
+	{ID: "JE", Name: "Judging Error"},
+}
+
+// ResolverExporter writes a finished contest as a Contest API event feed,
+// the format the ICPC resolver replays at award ceremonies. The feed holds
+// every judgement, including those of the frozen period the resolver
+// reveals.
+type ResolverExporter struct {
+	contestService    *ContestService
+	problemService    *ProblemService
+	submissionService *SubmissionService
+}
+
+func NewResolverExporter(contestService *ContestService, problemService *ProblemService, submissionService *SubmissionService) *ResolverExporter {
+	return &ResolverExporter{
+		contestService:    contestService,
+		problemService:    problemService,
+		submissionService: submissionService,
+	}
+}
+
+// Export returns the contest's event feed in order: the contest, its
+// judgement types, languages, problems and teams, then each submission
+// with its judgement, and the final state. Only official submissions are
+// included; teams are the registered users and anyone who submitted.
+func (e *ResolverExporter) Export(contestID string) ([]WebhookEvent, error) {
+	contest, err := e.contestService.Get(contestID)
+	if err != nil {
+		return nil, err
+	}
+	if contest.Status != ContestFinished {
+		return nil, ErrContestNotFinished
+	}
+	submissions, err := e.submissionService.ListByContest(contestID)
+	if err != nil {
+		return nil, err
+	}
+	registrants, err := e.contestService.Registrants(contestID)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []WebhookEvent
+	add := func(kind string, data any) {
+		events = append(events, WebhookEvent{ID: strconv.Itoa(len(events) + 1), Type: kind, Op: "create", Data: data})
+	}
+
+	info := clicsContest{
+		ID:             contest.ID,
+		Name:           contest.Title,
+		FormalName:     contest.Title,
+		StartTime:      clicsTime(contest.StartTime),
+		Duration:       clicsContestTime(contest.EndTime.Sub(contest.StartTime)),
+		ScoreboardType: "score",
+	}
+	if contest.FreezeTime != nil {
+		info.ScoreboardFreezeDuration = clicsContestTime(contest.EndTime.Sub(*contest.FreezeTime))
+	}
+	add("contests", info)
+	for _, judgementType := range resolverJudgementTypes {
+		add("judgement-types", judgementType)
+	}
+	for _, name := range judge.LanguageNames() {
+		add("languages", clicsLanguage{ID: name, Name: name})
+	}
+	for i, problemID := range contest.Problems {
+		problem, err := e.problemService.Get(problemID)
+		if err != nil {
+			return nil, err
+		}
+		add("problems", clicsProblem{ID: problem.ID, Label: problemLabel(i), Name: problem.Title, Ordinal: i})
+	}
+
+	teams := make(map[string]bool)
+	addTeam := func(userID string) {
+		if !teams[userID] {
+			teams[userID] = true
+			add("teams", clicsTeam{ID: userID, Name: userID, DisplayName: userID})
+		}
+	}
+	for _, userID := range registrants {
+		addTeam(userID)
+	}
+	var judged []Submission
+	for _, submission := range submissions {
+		if submission.Virtual {
+			continue
+		}
+		addTeam(submission.UserID)
+		if submission.Status == SubmissionJudged && submission.JudgedAt != nil {
+			judged = append(judged, submission)
+		}
+	}
+	for _, submission := range judged {
+		add("submissions", clicsSubmissionOf(submission, contest))
+		add("judgements", clicsJudgementOf(submission, contest))
+	}
+
+	ended := clicsTime(contest.EndTime)
+	state := clicsState{Started: clicsTime(contest.StartTime), Ended: ended, Finalized: ended, EndOfUpdates: ended}
+	if contest.FreezeTime != nil {
+		frozen := clicsTime(*contest.FreezeTime)
+		state.Frozen = &frozen
+	}
+	add("state", state)
+	return events, nil
+}
+
+// problemLabel is A for the first problem, Z for the 26th and AA after.
+func problemLabel(i int) string {
+	label := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		label = string(rune('A'+(i-1)%26)) + label
+	}
+	return label
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ScalingConfig holds the targets the advisor sizes the judge fleet for.
+type ScalingConfig struct {
+	// TargetWait is the longest a submission should wait to be graded.
+	TargetWait time.Duration
+	// SlotsPerWorker is how many submissions one worker grades at once.
+	SlotsPerWorker int
+	MinWorkers     int
+	MaxWorkers     int
+	// ContestLead is how long before a contest its participants count
+	// towards the desired size, so workers are up before the first
+	// submissions arrive.
+	ContestLead time.Duration
+	// ParticipantsPerWorker is how many contest participants one worker
+	// keeps up with.
+	ParticipantsPerWorker int
+	// Token, if set, is the static bearer token autoscalers use to read
+	// the advice instead of an admin login.
+	Token string
+}
+
+// ScalingConfigFromEnv reads SCALING_TARGET_WAIT_SECONDS,
+// SCALING_SLOTS_PER_WORKER, SCALING_MIN_WORKERS, SCALING_MAX_WORKERS,
+// SCALING_CONTEST_LEAD_MINUTES, SCALING_PARTICIPANTS_PER_WORKER and
+// SCALING_TOKEN.
+func ScalingConfigFromEnv() ScalingConfig {
+	return ScalingConfig{
+		TargetWait:            time.Duration(intFromEnv("SCALING_TARGET_WAIT_SECONDS", 30)) * time.Second,
+		SlotsPerWorker:        intFromEnv("SCALING_SLOTS_PER_WORKER", 1),
+		MinWorkers:            intFromEnv("SCALING_MIN_WORKERS", 1),
+		MaxWorkers:            intFromEnv("SCALING_MAX_WORKERS", 20),
+		ContestLead:           time.Duration(intFromEnv("SCALING_CONTEST_LEAD_MINUTES", 15)) * time.Minute,
+		ParticipantsPerWorker: intFromEnv("SCALING_PARTICIPANTS_PER_WORKER", 50),
+		Token:                 strings.TrimSpace(os.Getenv("SCALING_TOKEN")),
+	}
+}
+
+// ScalingAdvice is the desired judge worker count and what it is based on.
+// DesiredWorkers is the larger of what the current backlog needs to be
+// graded within the target wait and what running or imminent contests
+// need, within the configured bounds.
+type ScalingAdvice struct {
+	DesiredWorkers  int       `json:"desiredWorkers"`
+	BacklogWorkers  int       `json:"backlogWorkers"`
+	ContestWorkers  int       `json:"contestWorkers"`
+	Queued          int64     `json:"queued"`
+	InProgress      int       `json:"inProgress"`
+	AvgGradeSeconds float64   `json:"avgGradeSeconds"`
+	Participants    int       `json:"participants"`
+	ComputedAt      time.Time `json:"computedAt"`
+}
+
+const (
+	// scalingSampleSize is how many recent gradings the average is over.
+	scalingSampleSize = 50
+	// defaultGradeSeconds is assumed before anything was graded.
+	defaultGradeSeconds = 5.0
+)
+
+// ScalingAdvisor tells an autoscaler how many judge workers to run.
+type ScalingAdvisor struct {
+	submissionService *SubmissionService
+	contestService    *ContestService
+	config            ScalingConfig
+}
+
+func NewScalingAdvisor(submissionService *SubmissionService, contestService *ContestService, config ScalingConfig) *ScalingAdvisor {
+	return &ScalingAdvisor{submissionService: submissionService, contestService: contestService, config: config}
+}
+
+func (a *ScalingAdvisor) Token() string {
+	return a.config.Token
+}
+
+func (a *ScalingAdvisor) Advise(now time.Time) (ScalingAdvice, error) {
+	advice := ScalingAdvice{ComputedAt: now}
+	queued, err := a.submissionService.QueueLength()
+	if err != nil {
+		return ScalingAdvice{}, err
+	}
+	advice.Queued = queued
+
+	submissions, err := a.submissionService.List(SubmissionFilter{})
+	if err != nil {
+		return ScalingAdvice{}, err
+	}
+	var graded []Submission
+	for _, submission := range submissions {
+		if submission.Status.InProgress() {
+			advice.InProgress++
+		}
+		if submission.JudgedAt != nil && submission.Timing != nil {
+			graded = append(graded, submission)
+		}
+	}
+	sort.Slice(graded, func(i, j int) bool { return graded[i].JudgedAt.After(*graded[j].JudgedAt) })
+	advice.AvgGradeSeconds = defaultGradeSeconds
+	if n := min(len(graded), scalingSampleSize); n > 0 {
+		total := 0.0
+		for _, submission := range graded[:n] {
+			total += submission.Timing.Total - submission.Timing.QueueWait
+		}
+		advice.AvgGradeSeconds = total / float64(n)
+	}
+
+	// Work that must finish within the target wait, spread over slots
+	work := float64(advice.Queued+int64(advice.InProgress)) * advice.AvgGradeSeconds
+	slots := work / a.config.TargetWait.Seconds()
+	advice.BacklogWorkers = int(math.Ceil(slots / float64(max(a.config.SlotsPerWorker, 1))))
+
+	contests, err := a.contestService.List()
+	if err != nil {
+		return ScalingAdvice{}, err
+	}
+	for _, contest := range contests {
+		if now.Before(contest.StartTime.Add(-a.config.ContestLead)) || !now.Before(contest.EndTime) {
+			continue
+		}
+		participants, err := a.contestService.RegistrationCount(contest.ID)
+		if err != nil {
+			return ScalingAdvice{}, err
+		}
+		advice.Participants += participants
+	}
+	advice.ContestWorkers = int(math.Ceil(float64(advice.Participants) / float64(max(a.config.ParticipantsPerWorker, 1))))
+
+	advice.DesiredWorkers = max(advice.BacklogWorkers, advice.ContestWorkers, a.config.MinWorkers)
+	if a.config.MaxWorkers > 0 {
+		advice.DesiredWorkers = min(advice.DesiredWorkers, a.config.MaxWorkers)
+	}
+	return advice, nil
+}
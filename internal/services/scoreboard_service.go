@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrInvalidStandingsPhase  = errors.New("standings phase must be provisional or final")
+	ErrFinalStandingsNotReady = errors.New("final standings are available once system testing is done")
+)
+
+type StandingsPhase string
+
+const (
+	StandingsProvisional StandingsPhase = "provisional"
+	StandingsFinal       StandingsPhase = "final"
+	// StandingsPractice labels gym standings of virtual participants.
+	StandingsPractice StandingsPhase = "practice"
+)
+
+func (p StandingsPhase) valid() bool {
+	return p == StandingsProvisional || p == StandingsFinal
+}
+
+type ProblemStanding struct {
+	SubmissionID string  `json:"submissionId,omitempty"`
+	Score        float64 `json:"score"`
+	// Pending counts submissions whose results the freeze hides.
+	Pending int `json:"pending,omitempty"`
+	final   bool
+}
+
+type StandingsRow struct {
+	Rank     int                        `json:"rank"`
+	UserID   string                     `json:"userId"`
+	Total    float64                    `json:"total"`
+	Problems map[string]ProblemStanding `json:"problems"`
+}
+
+type Standings struct {
+	ContestID string         `json:"contestId"`
+	Phase     StandingsPhase `json:"phase"`
+	// Frozen marks standings that hide the results of submissions made
+	// after the contest's freeze.
+	Frozen bool           `json:"frozen,omitempty"`
+	Rows   []StandingsRow `json:"rows"`
+}
+
+// ScoreboardService computes contest standings from judged submissions.
+// Standings are derived on every read, so rejudges and system testing are
+// reflected without a separate recompute step.
+type ScoreboardService struct {
+	contestService    *ContestService
+	submissionService *SubmissionService
+}
+
+func NewScoreboardService(contestService *ContestService, submissionService *SubmissionService) *ScoreboardService {
+	return &ScoreboardService{contestService: contestService, submissionService: submissionService}
+}
+
+// Standings ranks a contest's users by total score. Provisional standings
+// take each user's best provisional score per problem. Final standings use
+// the final-test score of the submission that was system tested and fall
+// back to the best score on problems without final tests. Until a contest
+// with a freeze is resolved, submissions made after the freeze only count
+// as pending, unless live is set for staff.
+func (s *ScoreboardService) Standings(contestID string, phase StandingsPhase, live bool) (Standings, error) {
+	if !phase.valid() {
+		return Standings{}, ErrInvalidStandingsPhase
+	}
+	contest, err := s.contestService.Get(contestID)
+	if err != nil {
+		return Standings{}, err
+	}
+	if phase == StandingsFinal && contest.SystemTest != SystemTestDone {
+		return Standings{}, ErrFinalStandingsNotReady
+	}
+
+	submissions, err := s.submissionService.ListByContest(contestID)
+	if err != nil {
+		return Standings{}, err
+	}
+	official := func(submission Submission) bool { return !submission.Virtual }
+	standings := Standings{ContestID: contestID, Phase: phase}
+	var hidden func(Submission) bool
+	if cutoff, frozen := contest.freezeCutoff(); frozen && !live {
+		standings.Frozen = true
+		hidden = func(submission Submission) bool { return !submission.CreatedAt.Before(cutoff) }
+	}
+	standings.Rows = standingsRows(submissions, official, hidden, phase)
+	return standings, nil
+}
+
+// PracticeStandings ranks the virtual participants of a finished contest by
+// their best score per problem. Official participants are not included.
+func (s *ScoreboardService) PracticeStandings(contestID string) (Standings, error) {
+	if _, err := s.contestService.Get(contestID); err != nil {
+		return Standings{}, err
+	}
+	submissions, err := s.submissionService.ListByContest(contestID)
+	if err != nil {
+		return Standings{}, err
+	}
+	virtual := func(submission Submission) bool { return submission.Virtual }
+	return Standings{ContestID: contestID, Phase: StandingsPractice, Rows: standingsRows(submissions, virtual, nil, StandingsProvisional)}, nil
+}
+
+// standingsRows builds ranked rows from the judged submissions that pass
+// include. Those hidden, if hidden is set, only count as pending.
+func standingsRows(submissions []Submission, include func(Submission) bool, hidden func(Submission) bool, phase StandingsPhase) []StandingsRow {
+	rows := make(map[string]*StandingsRow)
+	for _, submission := range submissions {
+		if submission.Status != SubmissionJudged || !include(submission) {
+			continue
+		}
+		row, ok := rows[submission.UserID]
+		if !ok {
+			row = &StandingsRow{UserID: submission.UserID, Problems: make(map[string]ProblemStanding)}
+			rows[submission.UserID] = row
+		}
+		if hidden != nil && hidden(submission) {
+			standing := row.Problems[submission.ProblemID]
+			standing.Pending++
+			row.Problems[submission.ProblemID] = standing
+			continue
+		}
+		row.Problems[submission.ProblemID] = bestStanding(row.Problems[submission.ProblemID], submission, phase)
+	}
+
+	ranked := make([]StandingsRow, 0, len(rows))
+	for _, row := range rows {
+		for _, problem := range row.Problems {
+			row.Total += problem.Score
+		}
+		ranked = append(ranked, *row)
+	}
+	rankStandings(ranked)
+	return ranked
+}
+
+// bestStanding folds one submission into a user's standing on a problem.
+// Submissions arrive oldest first, so a final result comes from the
+// system-tested submission and is never replaced by a provisional score.
+func bestStanding(current ProblemStanding, submission Submission, phase StandingsPhase) ProblemStanding {
+	if phase == StandingsFinal && submission.Final != nil {
+		return ProblemStanding{SubmissionID: submission.ID, Score: submission.Final.Score, final: true}
+	}
+	candidate := ProblemStanding{SubmissionID: submission.ID, Score: submission.Score}
+	if current.SubmissionID == "" || (!current.final && candidate.Score > current.Score) {
+		return candidate
+	}
+	return current
+}
+
+// rankStandings sorts rows by total and assigns competition ranks, so equal
+// totals share a rank.
+func rankStandings(rows []StandingsRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Total != rows[j].Total {
+			return rows[i].Total > rows[j].Total
+		}
+		return rows[i].UserID < rows[j].UserID
+	})
+	for i := range rows {
+		if i > 0 && rows[i].Total == rows[i-1].Total {
+			rows[i].Rank = rows[i-1].Rank
+		} else {
+			rows[i].Rank = i + 1
+		}
+	}
+}
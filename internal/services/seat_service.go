@@ -0,0 +1,237 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"online-judge/internal/auth"
+	"online-judge/internal/store"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSeatNotFound    = errors.New("seat not found")
+	ErrInvalidSeatIP   = errors.New("seat IP address or prefix is invalid")
+	ErrSeatIPMismatch  = errors.New("submissions for this team are only accepted from its seat")
+	ErrSeatIPAssigned  = errors.New("IP address overlaps the one bound to another seat")
+	ErrSeatUnavailable = errors.New("seat is already assigned to another team")
+)
+
+// Seat places a team at a location for an onsite contest. When IP is set,
+// the team's contest submissions are only accepted from that address. IP
+// may also be a prefix such as 2001:db8:0:1::/64, for IPv6 hosts that
+// rotate temporary addresses within their network.
+type Seat struct {
+	ContestID string `json:"contestId"`
+	UserID    string `json:"userId"`
+	Room      string `json:"room" binding:"required"`
+	Seat      string `json:"seat" binding:"required"`
+	IP        string `json:"ip,omitempty"`
+}
+
+// Credential is one team's row on the printed credential sheets.
+type Credential struct {
+	Seat
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+const seatKeyPrefix = "seat:contest:"
+
+// credentialGrace keeps credential tokens valid for a while after the
+// contest ends, e.g. for clarifications and printing results.
+const credentialGrace = time.Hour
+
+type SeatService struct {
+	store          store.Store
+	contestService *ContestService
+	authenticator  *auth.Authenticator
+}
+
+func NewSeatService(st store.Store, contestService *ContestService, authenticator *auth.Authenticator) *SeatService {
+	return &SeatService{store: st, contestService: contestService, authenticator: authenticator}
+}
+
+// Assign places a team at a seat and registers it for the contest. A seat
+// and an IP address each belong to at most one team; seat prefixes may not
+// overlap.
+func (s *SeatService) Assign(seat Seat) (Seat, error) {
+	var prefix netip.Prefix
+	if seat.IP != "" {
+		var err error
+		if prefix, err = parseSeatIP(seat.IP); err != nil {
+			return Seat{}, ErrInvalidSeatIP
+		}
+		seat.IP = seatIPString(prefix)
+	}
+
+	seats, err := s.List(seat.ContestID)
+	if err != nil {
+		return Seat{}, err
+	}
+	for _, other := range seats {
+		if other.UserID == seat.UserID {
+			continue
+		}
+		if other.Room == seat.Room && other.Seat == seat.Seat {
+			return Seat{}, ErrSeatUnavailable
+		}
+		if seat.IP != "" && other.IP != "" {
+			if otherPrefix, err := parseSeatIP(other.IP); err == nil && otherPrefix.Overlaps(prefix) {
+				return Seat{}, ErrSeatIPAssigned
+			}
+		}
+	}
+
+	if err := s.contestService.Register(seat.ContestID, seat.UserID); err != nil {
+		return Seat{}, err
+	}
+	if err := setJSON(s.store, seatKey(seat.ContestID, seat.UserID), seat, 0); err != nil {
+		return Seat{}, err
+	}
+	return seat, nil
+}
+
+func (s *SeatService) Get(contestID, userID string) (Seat, error) {
+	var seat Seat
+	if err := getJSON(s.store, seatKey(contestID, userID), &seat); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return Seat{}, ErrSeatNotFound
+		}
+		return Seat{}, err
+	}
+	return seat, nil
+}
+
+func (s *SeatService) Unassign(contestID, userID string) error {
+	return s.store.Delete(seatKey(contestID, userID))
+}
+
+// List returns the contest's seats ordered by room and seat.
+func (s *SeatService) List(contestID string) ([]Seat, error) {
+	if _, err := s.contestService.Get(contestID); err != nil {
+		return nil, err
+	}
+	seats, err := listJSON[Seat](s.store, seatKeyPrefix+contestID+":")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(seats, func(i, j int) bool {
+		if seats[i].Room != seats[j].Room {
+			return seats[i].Room < seats[j].Room
+		}
+		return seats[i].Seat < seats[j].Seat
+	})
+	return seats, nil
+}
+
+// Credentials returns the data for the credential sheets handed out at the
+// seats: each team's location and a token valid until shortly after the
+// contest ends.
+func (s *SeatService) Credentials(contestID string) ([]Credential, error) {
+	contest, err := s.contestService.Get(contestID)
+	if err != nil {
+		return nil, err
+	}
+	seats, err := s.List(contestID)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := contest.EndTime.Add(credentialGrace)
+	credentials := make([]Credential, 0, len(seats))
+	for _, seat := range seats {
+		token, err := s.authenticator.Issue(auth.Principal{UserID: seat.UserID, Role: auth.RoleUser}, time.Until(expiresAt))
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, Credential{Seat: seat, Token: token, ExpiresAt: expiresAt})
+	}
+	return credentials, nil
+}
+
+// CheckIP enforces the contest IP restriction: a team bound to a seat IP may
+// only act from that address. Teams without a seat IP are unrestricted.
+func (s *SeatService) CheckIP(contestID, userID, clientIP string) error {
+	seat, err := s.Get(contestID, userID)
+	if errors.Is(err, ErrSeatNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if seat.IP == "" {
+		return nil
+	}
+	prefix, err := parseSeatIP(seat.IP)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil || !prefix.Contains(addr.Unmap().WithZone("")) {
+		return ErrSeatIPMismatch
+	}
+	return nil
+}
+
+// parseSeatIP reads a seat's address or prefix. IPv4-mapped IPv6
+// addresses and zones are normalized away, so a seat matches however the
+// connection reports the client.
+func parseSeatIP(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// seatIPString formats a single address without a prefix length.
+func seatIPString(prefix netip.Prefix) string {
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+func seatKey(contestID, userID string) string {
+	return seatKeyPrefix + contestID + ":" + userID
+}
+
+func (s *SeatService) SnapshotName() string {
+	return "seats"
+}
+
+func (s *SeatService) ExportSnapshot() (json.RawMessage, error) {
+	seats, err := listJSON[Seat](s.store, seatKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(seats)
+}
+
+func (s *SeatService) ImportSnapshot(data json.RawMessage) error {
+	var seats []Seat
+	if err := json.Unmarshal(data, &seats); err != nil {
+		return err
+	}
+	return replaceJSON(s.store, seatKeyPrefix, seats, func(seat Seat) string { return seat.ContestID + ":" + seat.UserID })
+}
+
+// UserData returns the privacy section holding the user's seats and their
+// IP addresses.
+func (s *SeatService) UserData() UserDataSection {
+	return userKeySection{store: s.store, name: "seats", prefixes: []string{seatKeyPrefix}, marker: ":", erase: true}
+}
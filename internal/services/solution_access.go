@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"online-judge/internal/auth"
+	"sort"
+	"time"
+)
+
+var ErrInvalidSolutionVisibility = errors.New("solution visibility must be owner or after_accept")
+
+// SolutionVisibility decides who besides its author and judges may read an
+// accepted submission's source.
+type SolutionVisibility string
+
+const (
+	// SolutionsOwner keeps every source to its author.
+	SolutionsOwner SolutionVisibility = "owner"
+	// SolutionsAfterAccept shows accepted sources to users who have an
+	// accepted submission to the problem themselves.
+	SolutionsAfterAccept SolutionVisibility = "after_accept"
+)
+
+func (v SolutionVisibility) valid() bool {
+	return v == "" || v == SolutionsOwner || v == SolutionsAfterAccept
+}
+
+// SolutionSummary lists an accepted submission whose source the viewer may
+// read.
+type SolutionSummary struct {
+	SubmissionID string    `json:"submissionId"`
+	UserID       string    `json:"userId"`
+	Language     string    `json:"language"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// SourceAccess is the one place that decides whether a principal may read a
+// submission's source. Authors and judges always may. Others may read
+// accepted sources when the problem's solution visibility allows it, but
+// never those of a contest that has not finished.
+type SourceAccess struct {
+	submissionService *SubmissionService
+	problemService    *ProblemService
+	contestService    *ContestService
+}
+
+func NewSourceAccess(submissionService *SubmissionService, problemService *ProblemService, contestService *ContestService) *SourceAccess {
+	return &SourceAccess{submissionService: submissionService, problemService: problemService, contestService: contestService}
+}
+
+// Source returns the submission with its source if the principal may read
+// it, and ErrSubmissionNotFound otherwise so hidden submissions cannot be
+// told apart from missing ones.
+func (a *SourceAccess) Source(principal auth.Principal, id string) (Submission, error) {
+	submission, err := a.submissionService.Get(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	if submission.UserID == principal.UserID || principal.HasRole(auth.RoleJudge) {
+		return submission, nil
+	}
+
+	if submission.Verdict != VerdictAccepted {
+		return Submission{}, ErrSubmissionNotFound
+	}
+	allowed, err := a.unlocked(principal, submission.ProblemID)
+	if err != nil {
+		return Submission{}, err
+	}
+	if !allowed {
+		return Submission{}, ErrSubmissionNotFound
+	}
+	if over, err := contestOver(a.contestService, submission); err != nil {
+		return Submission{}, err
+	} else if !over {
+		return Submission{}, ErrSubmissionNotFound
+	}
+	return submission, nil
+}
+
+// Solutions lists the problem's accepted submissions by other users whose
+// sources the principal may read, oldest first.
+func (a *SourceAccess) Solutions(principal auth.Principal, problemID string) ([]SolutionSummary, error) {
+	if _, err := a.problemService.Get(problemID); err != nil {
+		return nil, err
+	}
+	allowed, err := a.unlocked(principal, problemID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed && !principal.HasRole(auth.RoleJudge) {
+		return []SolutionSummary{}, nil
+	}
+
+	submissions, err := a.submissionService.List(SubmissionFilter{ProblemID: problemID})
+	if err != nil {
+		return nil, err
+	}
+	solutions := make([]SolutionSummary, 0)
+	for _, submission := range submissions {
+		if submission.Verdict != VerdictAccepted || submission.UserID == principal.UserID {
+			continue
+		}
+		if over, err := contestOver(a.contestService, submission); err != nil {
+			return nil, err
+		} else if !over && !principal.HasRole(auth.RoleJudge) {
+			continue
+		}
+		solutions = append(solutions, SolutionSummary{
+			SubmissionID: submission.ID,
+			UserID:       submission.UserID,
+			Language:     submission.Language,
+			CreatedAt:    submission.CreatedAt,
+		})
+	}
+	sort.SliceStable(solutions, func(i, j int) bool { return solutions[i].CreatedAt.Before(solutions[j].CreatedAt) })
+	return solutions, nil
+}
+
+// unlocked reports whether the problem's policy lets the principal read
+// other users' accepted sources.
+func (a *SourceAccess) unlocked(principal auth.Principal, problemID string) (bool, error) {
+	problem, err := a.problemService.Get(problemID)
+	if errors.Is(err, ErrProblemNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if problem.SolutionVisibility != SolutionsAfterAccept {
+		return false, nil
+	}
+
+	own, err := a.submissionService.List(SubmissionFilter{UserID: principal.UserID, ProblemID: problemID})
+	if err != nil {
+		return false, err
+	}
+	for _, submission := range own {
+		if submission.Status == SubmissionJudged && submission.Verdict == VerdictAccepted {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// contestOver reports whether the submission was made outside a contest or
+// in one that has finished, so showing its source spoils nothing.
+func contestOver(contestService *ContestService, submission Submission) (bool, error) {
+	if submission.ContestID == "" {
+		return true, nil
+	}
+	contest, err := contestService.Get(submission.ContestID)
+	if errors.Is(err, ErrContestNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return contest.Status == ContestFinished, nil
+}
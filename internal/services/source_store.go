@@ -0,0 +1,111 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"online-judge/internal/store"
+	"time"
+)
+
+var ErrSourceBusy = errors.New("source is being updated, try again")
+
+const (
+	sourceBlobPrefix = "source:blob:"
+	sourceRefsPrefix = "source:refs:"
+	sourceLockPrefix = "source:lock:"
+)
+
+// SourceStore keeps submission sources content-addressed by SHA-256 with a
+// reference count, so identical sources (e.g. template-based classroom
+// submissions) are stored once.
+type SourceStore struct {
+	store store.Store
+}
+
+func NewSourceStore(st store.Store) *SourceStore {
+	return &SourceStore{store: st}
+}
+
+func sourceHash(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores source, or adds a reference to an identical stored source, and
+// returns its hash.
+func (s *SourceStore) Put(source string) (string, error) {
+	hash := sourceHash(source)
+	unlock, err := s.lock(hash)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if _, err := s.store.Incr(sourceRefsPrefix + hash); err != nil {
+		return "", err
+	}
+	if _, err := s.store.SetNX(sourceBlobPrefix+hash, []byte(source), 0); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+func (s *SourceStore) Get(hash string) (string, error) {
+	data, err := s.store.Get(sourceBlobPrefix + hash)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Release drops one reference and deletes the source with its last one.
+func (s *SourceStore) Release(hash string) error {
+	unlock, err := s.lock(hash)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	refs, err := s.store.Decr(sourceRefsPrefix + hash)
+	if err != nil {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+	return s.store.Delete(sourceRefsPrefix+hash, sourceBlobPrefix+hash)
+}
+
+// reset deletes every stored source. It is used when restoring
+// submissions, which add their sources back.
+func (s *SourceStore) reset() error {
+	var keys []string
+	for _, prefix := range []string{sourceBlobPrefix, sourceRefsPrefix} {
+		found, err := s.store.Keys(prefix)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, found...)
+	}
+	return s.store.Delete(keys...)
+}
+
+// lock serializes Put and Release of one hash across replicas, so a source
+// is never deleted while another submission is adding a reference to it.
+// Both hold the lock only for a few store round trips, so a short wait
+// suffices.
+func (s *SourceStore) lock(hash string) (func(), error) {
+	key := sourceLockPrefix + hash
+	for attempt := 0; attempt < 50; attempt++ {
+		ok, err := s.store.SetNX(key, []byte("1"), 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() { s.store.Delete(key) }, nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return nil, ErrSourceBusy
+}
@@ -0,0 +1,31 @@
+package services
+
+import "online-judge/pkg/compare"
+
+// SQLSettings make a problem a database exercise for the sql language:
+// every run starts from an in-memory database built from Seed and then the
+// test's input, and the query's result sets are compared with the
+// expected output, which holds them as printed by the sql language.
+type SQLSettings struct {
+	// Seed is SQL creating and filling the tables shared by all tests.
+	Seed string `json:"seed"`
+	// Ordered makes the order of rows count, for queries with ORDER BY.
+	Ordered bool `json:"ordered,omitempty"`
+}
+
+// runFiles are the files every run of the problem's submissions gets.
+func (p Problem) runFiles() map[string]string {
+	if p.SQL == nil {
+		return nil
+	}
+	return map[string]string{"seed.sql": p.SQL.Seed}
+}
+
+// outputsMatch compares a run's output with a test's expected output the
+// way the problem asks for.
+func (p Problem) outputsMatch(expected, actual string) bool {
+	if p.SQL != nil {
+		return compare.ResultSetsMatch(expected, actual, p.SQL.Ordered)
+	}
+	return compare.OutputsMatch(expected, actual)
+}
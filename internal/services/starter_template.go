@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"online-judge/internal/judge"
+	"strings"
+)
+
+var (
+	ErrInvalidTemplate = errors.New("invalid starter template")
+	ErrNoTemplate      = errors.New("problem has no template with a solution region for this language")
+)
+
+// Markers delimit a template's solution region. Each sits on a line of its
+// own, usually inside a comment of the template's language, e.g.
+// "// BEGIN SOLUTION".
+const (
+	templateRegionBegin = "BEGIN SOLUTION"
+	templateRegionEnd   = "END SOLUTION"
+)
+
+// StarterTemplate is the code contestants start from in one language:
+// function signatures, input/output scaffolding and the like. A template
+// may mark a solution region, in which case submissions can send just the
+// region's code and the rest of the template is filled in around it.
+type StarterTemplate struct {
+	Code string `json:"code"`
+}
+
+// region returns the line indexes of the template's markers, and whether
+// it has a region at all.
+func (t StarterTemplate) region() (begin, end int, ok bool, err error) {
+	begin, end = -1, -1
+	for i, line := range strings.Split(t.Code, "\n") {
+		switch {
+		case strings.Contains(line, templateRegionBegin):
+			if begin >= 0 {
+				return 0, 0, false, fmt.Errorf("%w: more than one %s", ErrInvalidTemplate, templateRegionBegin)
+			}
+			begin = i
+		case strings.Contains(line, templateRegionEnd):
+			if begin < 0 || end >= 0 {
+				return 0, 0, false, fmt.Errorf("%w: %s without a matching %s", ErrInvalidTemplate, templateRegionEnd, templateRegionBegin)
+			}
+			end = i
+		}
+	}
+	if begin >= 0 && end < 0 {
+		return 0, 0, false, fmt.Errorf("%w: %s without a matching %s", ErrInvalidTemplate, templateRegionBegin, templateRegionEnd)
+	}
+	return begin, end, begin >= 0, nil
+}
+
+// fill returns the template with the code between its markers replaced by
+// code, and the number of template lines before code. The markers
+// themselves are kept so line numbers in compiler messages stay close to
+// what the contestant sees.
+func (t StarterTemplate) fill(code string) (string, int, error) {
+	begin, end, ok, err := t.region()
+	if err != nil {
+		return "", 0, err
+	}
+	if !ok {
+		return "", 0, ErrNoTemplate
+	}
+	lines := strings.Split(t.Code, "\n")
+	filled := append([]string{}, lines[:begin+1]...)
+	filled = append(filled, strings.TrimSuffix(code, "\n"))
+	filled = append(filled, lines[end:]...)
+	return strings.Join(filled, "\n"), begin + 1, nil
+}
+
+func validateTemplates(templates map[string]StarterTemplate) error {
+	for language, template := range templates {
+		if _, ok := judge.LookupLanguage(language); !ok {
+			return fmt.Errorf("%w: unknown language %q", ErrInvalidTemplate, language)
+		}
+		if _, _, _, err := template.region(); err != nil {
+			return fmt.Errorf("%s: %w", language, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"online-judge/internal/store"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func getJSON(st store.Store, key string, v any) error {
+	data, err := st.Get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func setJSON(st store.Store, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return st.Set(key, data, ttl)
+}
+
+// listJSON decodes every value stored under prefix. Keys that disappear
+// between listing and reading are skipped.
+func listJSON[T any](st store.Store, prefix string) ([]T, error) {
+	keys, err := st.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	values := make([]T, 0, len(keys))
+	for _, key := range keys {
+		var v T
+		if err := getJSON(st, key, &v); err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// replaceJSON deletes every key under prefix and stores values, keyed by
+// prefix + keyOf(value). It is used when restoring a snapshot.
+func replaceJSON[T any](st store.Store, prefix string, values []T, keyOf func(T) string) error {
+	keys, err := st.Keys(prefix)
+	if err != nil {
+		return err
+	}
+	if err := st.Delete(keys...); err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := setJSON(st, prefix+keyOf(v), v, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreCounter sets the ID counter at key to the highest numeric ID, so
+// IDs handed out after a restore do not collide with restored ones.
+func restoreCounter(st store.Store, key string, ids []string) error {
+	maxID := 0
+	for _, id := range ids {
+		if n, err := strconv.Atoi(id); err == nil && n > maxID {
+			maxID = n
+		}
+	}
+	return st.Set(key, []byte(strconv.Itoa(maxID)), 0)
+}
+
+// putBack requeues an undelivered batch ahead of the values queued at key
+// since it was popped, and returns cause. Values queued while it runs may
+// still land first; receivers restore the order from increasing IDs.
+func putBack(st store.Store, key string, batch []json.RawMessage, cause error) error {
+	for {
+		value, err := st.Pop(key)
+		if errors.Is(err, store.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return errors.Join(cause, err)
+		}
+		batch = append(batch, value)
+	}
+	for _, value := range batch {
+		if err := st.Push(key, value); err != nil {
+			return errors.Join(cause, err)
+		}
+	}
+	return cause
+}
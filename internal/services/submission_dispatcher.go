@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// SubmissionDispatcher runs a fixed number of workers that take queued
+// submissions and grade them. Submissions of users already at their
+// concurrent judging limit, or for which the fleet has no judging slot
+// left, go back to the end of the queue; withdrawn and cancelled ones are
+// dropped. Nothing is taken from the queue while maintenance mode pauses
+// judging.
+type SubmissionDispatcher struct {
+	submissionService *SubmissionService
+	gradingService    *GradingService
+	limiter           *JudgingLimiter
+	capacity          *CapacityPool
+	maintenance       *MaintenanceService
+	workers           int
+	pollInterval      time.Duration
+}
+
+func NewSubmissionDispatcher(submissionService *SubmissionService, gradingService *GradingService, limiter *JudgingLimiter, capacity *CapacityPool, maintenance *MaintenanceService, workers int) *SubmissionDispatcher {
+	return &SubmissionDispatcher{
+		submissionService: submissionService,
+		gradingService:    gradingService,
+		limiter:           limiter,
+		capacity:          capacity,
+		maintenance:       maintenance,
+		workers:           workers,
+		pollInterval:      500 * time.Millisecond,
+	}
+}
+
+// Run blocks until ctx is cancelled and all workers have returned.
+func (d *SubmissionDispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *SubmissionDispatcher) work(ctx context.Context) {
+	// deferred counts submissions put back in a row; once it covers the
+	// whole queue every queued user is at their limit, so the worker waits.
+	deferred := int64(0)
+	for ctx.Err() == nil {
+		if paused, err := d.maintenance.Paused(); paused || err != nil {
+			if err != nil {
+				log.Printf("Error reading maintenance mode: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(d.pollInterval):
+			}
+			continue
+		}
+		submission, err := d.submissionService.NextQueued()
+		if errors.Is(err, ErrSubmissionNotFound) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(d.pollInterval):
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("Error taking queued submission: %v", err)
+			time.Sleep(d.pollInterval)
+			continue
+		}
+		if submission.Status != SubmissionQueued {
+			continue
+		}
+
+		release, ok := d.acquire(submission)
+		if !ok {
+			if err := d.submissionService.Requeue(submission); err != nil {
+				log.Printf("Error requeueing submission %s: %v", submission.ID, err)
+			}
+			deferred++
+			if queued, _ := d.submissionService.QueueLength(); deferred >= queued {
+				deferred = 0
+				select {
+				case <-ctx.Done():
+				case <-time.After(d.pollInterval):
+				}
+			}
+			continue
+		}
+		deferred = 0
+
+		claimed, ok, err := d.submissionService.Claim(submission.ID)
+		if err != nil {
+			log.Printf("Error claiming submission %s: %v", submission.ID, err)
+			if err := d.submissionService.Requeue(submission); err != nil {
+				log.Printf("Error requeueing submission %s: %v", submission.ID, err)
+			}
+		}
+		if !ok {
+			release()
+			continue
+		}
+		submission = claimed
+		if _, err := d.gradingService.Grade(ctx, submission); err != nil {
+			log.Printf("Error storing result of submission %s: %v", submission.ID, err)
+		}
+		release()
+	}
+}
+
+// acquire takes one of the user's judging slots and one of the fleet's.
+func (d *SubmissionDispatcher) acquire(submission Submission) (func(), bool) {
+	releaseUser, ok, err := d.limiter.Acquire(submission.UserID)
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("Error acquiring judging slot for user %s: %v", submission.UserID, err)
+		}
+		return nil, false
+	}
+	releaseSlot, ok, err := d.capacity.Acquire(submission)
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("Error acquiring judging slot for submission %s: %v", submission.ID, err)
+		}
+		releaseUser()
+		return nil, false
+	}
+	return func() {
+		releaseSlot()
+		releaseUser()
+	}, true
+}
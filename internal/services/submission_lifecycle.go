@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidTransition = errors.New("invalid submission status transition")
+
+// StatusTransition is one recorded change of a submission's status.
+type StatusTransition struct {
+	From SubmissionStatus `json:"from,omitempty"`
+	To   SubmissionStatus `json:"to"`
+	At   time.Time        `json:"at"`
+}
+
+// submissionTransitions lists the statuses each status may move to.
+// Judged and failed submissions go back to queued when rejudged, as do
+// stuck ones taken back by the watchdog, and a failed one becomes judged
+// when a judge overrides its verdict. Withdrawn and cancelled submissions
+// never move again.
+var submissionTransitions = map[SubmissionStatus][]SubmissionStatus{
+	SubmissionReceived:  {SubmissionQueued, SubmissionFailed},
+	SubmissionQueued:    {SubmissionCompiling, SubmissionWithdrawn, SubmissionCancelled, SubmissionFailed},
+	SubmissionCompiling: {SubmissionRunning, SubmissionJudged, SubmissionFailed, SubmissionQueued},
+	SubmissionRunning:   {SubmissionJudged, SubmissionFailed, SubmissionQueued},
+	SubmissionJudging:   {SubmissionJudged, SubmissionFailed, SubmissionQueued},
+	SubmissionJudged:    {SubmissionQueued},
+	SubmissionFailed:    {SubmissionQueued, SubmissionJudged},
+}
+
+// CanTransition reports whether a submission may move from s to status.
+func (s SubmissionStatus) CanTransition(status SubmissionStatus) bool {
+	for _, next := range submissionTransitions[s] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// InProgress reports whether the submission is being graded.
+func (s SubmissionStatus) InProgress() bool {
+	return s == SubmissionCompiling || s == SubmissionRunning || s == SubmissionJudging
+}
+
+// Done reports whether the submission will not be graded unless rejudged:
+// it was judged, failed, withdrawn or cancelled.
+func (s SubmissionStatus) Done() bool {
+	switch s {
+	case SubmissionJudged, SubmissionFailed, SubmissionWithdrawn, SubmissionCancelled:
+		return true
+	}
+	return false
+}
+
+// transition moves the submission to status and records the change.
+// Moving to the current status does nothing.
+func (s *Submission) transition(status SubmissionStatus, at time.Time) error {
+	if s.Status == status {
+		return nil
+	}
+	if !s.Status.CanTransition(status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, s.Status, status)
+	}
+	s.Transitions = append(s.Transitions, StatusTransition{From: s.Status, To: status, At: at})
+	s.Status = status
+	return nil
+}
+
+// Attempts counts how often grading of the submission started.
+func (s Submission) Attempts() int {
+	attempts := 0
+	for _, t := range s.Transitions {
+		if t.To == SubmissionCompiling {
+			attempts++
+		}
+	}
+	return attempts
+}
+
+// StatusSince returns when the submission entered its current status.
+// Submissions stored before transitions were recorded report CreatedAt.
+func (s Submission) StatusSince() time.Time {
+	if len(s.Transitions) == 0 {
+		return s.CreatedAt
+	}
+	return s.Transitions[len(s.Transitions)-1].At
+}
@@ -0,0 +1,755 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"online-judge/internal/auth"
+	"online-judge/internal/clock"
+	"online-judge/internal/store"
+	"online-judge/pkg/verdict"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrSubmissionNotFound  = errors.New("submission not found")
+	ErrSubmissionForged    = errors.New("submission user does not match the authenticated user")
+	ErrContestNotRunning   = errors.New("contest is not accepting submissions")
+	ErrNotRegistered       = errors.New("user is not registered for the contest")
+	ErrProblemNotInContest = errors.New("problem is not part of the contest")
+	ErrEmptySource         = errors.New("source code is empty")
+	ErrSubmissionNotQueued = errors.New("submission is no longer queued")
+	ErrSubmissionMoved     = errors.New("submission status changed while it was being graded")
+	ErrNotRejudgeable      = errors.New("only judged or failed submissions with a source can be rejudged")
+	ErrSubmissionsPending  = errors.New("submissions still queued or being graded")
+)
+
+type SubmissionStatus string
+
+// Submission statuses; submission_lifecycle.go lists the allowed moves
+// between them.
+const (
+	// SubmissionReceived submissions are stored but not yet queued.
+	SubmissionReceived  SubmissionStatus = "received"
+	SubmissionQueued    SubmissionStatus = "queued"
+	SubmissionCompiling SubmissionStatus = "compiling"
+	SubmissionRunning   SubmissionStatus = "running"
+	SubmissionJudged    SubmissionStatus = "judged"
+	SubmissionFailed    SubmissionStatus = "failed"
+	// SubmissionWithdrawn submissions were taken back by their owner before
+	// judging, and SubmissionCancelled ones by an admin; they are never
+	// graded and count for nothing.
+	SubmissionWithdrawn SubmissionStatus = "withdrawn"
+	SubmissionCancelled SubmissionStatus = "cancelled"
+	// SubmissionJudging is the status of records graded before compiling
+	// and running were told apart.
+	SubmissionJudging SubmissionStatus = "judging"
+)
+
+// Verdict and its values are defined in pkg/verdict, for embedders.
+type Verdict = verdict.Verdict
+
+const (
+	VerdictAccepted                 = verdict.Accepted
+	VerdictPartial                  = verdict.Partial
+	VerdictWrongAnswer              = verdict.WrongAnswer
+	VerdictTimeLimitExceeded        = verdict.TimeLimitExceeded
+	VerdictMemoryLimitExceeded      = verdict.MemoryLimitExceeded
+	VerdictOutputLimitExceeded      = verdict.OutputLimitExceeded
+	VerdictDiskQuotaExceeded        = verdict.DiskQuotaExceeded
+	VerdictRuntimeError             = verdict.RuntimeError
+	VerdictCompileError             = verdict.CompileError
+	VerdictCompileTimeLimitExceeded = verdict.CompileTimeLimitExceeded
+	VerdictInternalError            = verdict.InternalError
+	VerdictSecurityViolation        = verdict.SecurityViolation
+)
+
+// TestResult is the outcome of one test. Test is the 1-based index of the
+// test in the problem's test set, independent of execution order.
+type TestResult struct {
+	Test int `json:"test"`
+	// Name identifies unit test cases, which have no number of their own.
+	Name string `json:"name,omitempty"`
+	// ErrorLocation is the notebook cell a runtime error happened in.
+	ErrorLocation string  `json:"errorLocation,omitempty"`
+	Verdict       Verdict `json:"verdict"`
+	// Score is the fraction of the test awarded, between 0 and 1.
+	Score          float64 `json:"score"`
+	CheckerMessage string  `json:"checkerMessage,omitempty"`
+	// Objective is the raw value reported by an optimization checker.
+	Objective *float64 `json:"objective,omitempty"`
+	Time      float64  `json:"time"`
+	// Memory is the test's peak memory use in kilobytes, so the test that
+	// exceeds the budget can be told apart from the rest.
+	Memory int `json:"memory"`
+	// WallTime and CheckerTime are seconds spent running the program and
+	// the checker.
+	WallTime    float64 `json:"wallTime"`
+	CheckerTime float64 `json:"checkerTime,omitempty"`
+	Sample      bool    `json:"sample,omitempty"`
+	// Feedback is kept for sample tests only, as their data is public.
+	Feedback *TestFeedback `json:"feedback,omitempty"`
+}
+
+// Timing breaks down where a submission's latency went, in seconds. The
+// judge compiles once per test, so Compile sums every compilation.
+type Timing struct {
+	QueueWait float64 `json:"queueWait"`
+	Compile   float64 `json:"compile"`
+	Run       float64 `json:"run"`
+	Checker   float64 `json:"checker"`
+	// Total runs from submission to verdict.
+	Total float64 `json:"total"`
+}
+
+type Submission struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	ContestID string `json:"contestId,omitempty"`
+	// Virtual marks submissions made during a virtual participation in a
+	// finished contest. They count only towards practice standings.
+	Virtual   bool   `json:"virtual,omitempty"`
+	ProblemID string `json:"problemId"`
+	Language  string `json:"language"`
+	Source    string `json:"source,omitempty"`
+	// Notebook marks sources extracted from a Jupyter notebook, whose
+	// runtime errors are located by cell.
+	Notebook bool `json:"notebook,omitempty"`
+	// JudgeRegion is the region of the coordinator that accepted the
+	// submission, the one nearest its author; grading runs there unless
+	// the region is down.
+	JudgeRegion string `json:"judgeRegion,omitempty"`
+	// SourceHash addresses the source in the SourceStore. Stored records
+	// omit Source when it is set; records from before content addressing
+	// keep their source inline.
+	SourceHash    string           `json:"sourceHash,omitempty"`
+	Status        SubmissionStatus `json:"status"`
+	Verdict       Verdict          `json:"verdict,omitempty"`
+	Score         float64          `json:"score"`
+	CompileOutput string           `json:"compileOutput,omitempty"`
+	// Hint explains the first compile or runtime error for beginners.
+	Hint    string       `json:"hint,omitempty"`
+	Results []TestResult `json:"results,omitempty"`
+	// FirstFailedTest is the first test that was not accepted, or zero.
+	// It is filled in for contestants by ForContestant.
+	FirstFailedTest int `json:"firstFailedTest,omitempty"`
+	// TestOrderSeed is recorded when the problem randomizes test order, so
+	// a rejudge runs the tests in the same order.
+	TestOrderSeed *int64     `json:"testOrderSeed,omitempty"`
+	Timing        *Timing    `json:"timing,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	JudgedAt      *time.Time `json:"judgedAt,omitempty"`
+	// Final is the outcome on the full test set, recorded by system testing
+	// for contest problems with final tests. Verdict, Score and Results
+	// above then hold the provisional outcome.
+	Final *FinalResult `json:"final,omitempty"`
+	// Transitions records every status change, oldest first.
+	Transitions []StatusTransition `json:"transitions,omitempty"`
+	// Overrides is the audit trail of manual verdicts; the latest one
+	// decides Verdict and Score. ManualVerdict is set once there is one.
+	Overrides     []VerdictOverride `json:"overrides,omitempty"`
+	ManualVerdict bool              `json:"manualVerdict,omitempty"`
+}
+
+type FinalResult struct {
+	Verdict  Verdict      `json:"verdict"`
+	Score    float64      `json:"score"`
+	Results  []TestResult `json:"results,omitempty"`
+	JudgedAt time.Time    `json:"judgedAt"`
+	// FirstFailedTest is filled in for contestants by ForContestant.
+	FirstFailedTest int `json:"firstFailedTest,omitempty"`
+}
+
+// SubmissionRequest is what a client sends. UserID is optional and, when
+// present, must match the authenticated principal.
+type SubmissionRequest struct {
+	UserID    string `json:"userId"`
+	ContestID string `json:"contestId"`
+	ProblemID string `json:"problemId" binding:"required"`
+	Language  string `json:"language" binding:"required"`
+	Source    string `json:"source" binding:"required"`
+	// Region marks a source holding only the code for the solution region
+	// of the problem's template in Language; it is judged inside the
+	// template.
+	Region bool `json:"region"`
+	// Format is SubmissionFormatNotebook for Jupyter notebooks, whose code
+	// cells are extracted into a Python script; empty for source code.
+	Format string `json:"format"`
+	// ClientIP is set by the controller from the connection, never from
+	// the body. Seat IP restrictions are checked against it.
+	ClientIP string `json:"-"`
+}
+
+const (
+	submissionKeyPrefix = "submission:"
+	submissionIDKey     = "counter:submission"
+	// submissionQueueKey is the single queue used before per-problem
+	// queues; it is drained first so nothing queued before an upgrade is lost.
+	submissionQueueKey     = "queue:submissions"
+	submissionQueuePrefix  = "queue:submissions:"
+	submissionQueueTurnKey = "counter:submission-queue-turn"
+	// submissionClaimPrefix keys a short claim taken both by the worker
+	// about to grade a submission and by its owner withdrawing it, so
+	// exactly one of them wins.
+	submissionClaimPrefix = "claim:submission:"
+	submissionClaimTTL    = time.Minute
+)
+
+// SubmissionService stores submissions and queues them for grading. The
+// queue is fair across problems: each problem has its own sub-queue, so a
+// flood of submissions to one problem does not delay verdicts on the others.
+type SubmissionService struct {
+	store          store.Store
+	sources        *SourceStore
+	queue          *FairQueue
+	contestService *ContestService
+	problemService *ProblemService
+	seatService    *SeatService
+	clock          clock.Clock
+	listeners      []SavedListener
+	judgeRegion    string
+}
+
+// SavedListener is called with a submission every time it is stored.
+type SavedListener func(submission Submission)
+
+func NewSubmissionService(st store.Store, contestService *ContestService, problemService *ProblemService, seatService *SeatService) *SubmissionService {
+	return &SubmissionService{
+		store:          st,
+		sources:        NewSourceStore(st),
+		queue:          NewFairQueue(st, submissionQueuePrefix, submissionQueueTurnKey),
+		contestService: contestService,
+		problemService: problemService,
+		seatService:    seatService,
+		clock:          clock.System,
+	}
+}
+
+// SetClock replaces the wall clock, e.g. with a clock.Manual in tests.
+func (s *SubmissionService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetJudgeRegion sets the judge region recorded on new submissions, the
+// region this coordinator runs in.
+func (s *SubmissionService) SetJudgeRegion(region string) {
+	s.judgeRegion = region
+}
+
+// OnSaved registers a listener. It must be called before submissions are
+// accepted.
+func (s *SubmissionService) OnSaved(listener SavedListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// SetQueueWeights sets each problem's share of grading capacity relative to
+// other problems with queued submissions.
+func (s *SubmissionService) SetQueueWeights(weight func(problemID string) int) {
+	s.queue.SetWeights(weight)
+}
+
+// Create validates a submission against the authenticated principal and
+// stores it. The owner is always taken from the principal, never from the
+// request body, so a client cannot submit on someone else's behalf.
+func (s *SubmissionService) Create(principal auth.Principal, req SubmissionRequest) (Submission, error) {
+	virtual, err := s.authorize(principal, req)
+	if err != nil {
+		return Submission{}, err
+	}
+	problem, err := s.problemService.Get(req.ProblemID)
+	if err != nil {
+		return Submission{}, err
+	}
+	if req.Format == SubmissionFormatNotebook {
+		if req.Language != "python" {
+			return Submission{}, fmt.Errorf("%w: notebooks are judged as python", ErrInvalidNotebook)
+		}
+		if req.Source, err = extractNotebook(req.Source, problem.Notebook); err != nil {
+			return Submission{}, err
+		}
+	} else if req.Format != "" {
+		return Submission{}, fmt.Errorf("%w: unknown format %q", ErrInvalidNotebook, req.Format)
+	}
+	if err := checkSplice(problem, req.Language, req.Source); err != nil {
+		return Submission{}, err
+	}
+	if req.Region {
+		if req.Source, err = fillTemplate(problem, req); err != nil {
+			return Submission{}, err
+		}
+	}
+
+	id, err := s.store.Incr(submissionIDKey)
+	if err != nil {
+		return Submission{}, err
+	}
+	hash, err := s.sources.Put(req.Source)
+	if err != nil {
+		return Submission{}, err
+	}
+	submission := Submission{
+		ID:          strconv.FormatInt(id, 10),
+		UserID:      principal.UserID,
+		ContestID:   req.ContestID,
+		Virtual:     virtual,
+		ProblemID:   req.ProblemID,
+		Language:    req.Language,
+		Source:      req.Source,
+		Notebook:    req.Format == SubmissionFormatNotebook,
+		SourceHash:  hash,
+		JudgeRegion: s.judgeRegion,
+		Status:      SubmissionReceived,
+		CreatedAt:   s.clock.Now(),
+	}
+	submission.Transitions = []StatusTransition{{To: SubmissionReceived, At: submission.CreatedAt}}
+	if err := s.save(submission); err != nil {
+		s.sources.Release(hash)
+		return Submission{}, err
+	}
+	// The record is queued before it is pushed, so a worker never pops a
+	// submission that still looks received.
+	submission.transition(SubmissionQueued, s.clock.Now())
+	if err := s.save(submission); err != nil {
+		return Submission{}, err
+	}
+	if err := s.queue.Push(submission.ProblemID, []byte(submission.ID)); err != nil {
+		submission.transition(SubmissionFailed, s.clock.Now())
+		s.save(submission)
+		return Submission{}, err
+	}
+	return submission, nil
+}
+
+// authorize checks that the declared user/contest/problem tuple is one the
+// principal is allowed to submit to. It reports whether the submission
+// belongs to a virtual participation in a finished contest.
+func (s *SubmissionService) authorize(principal auth.Principal, req SubmissionRequest) (bool, error) {
+	if req.UserID != "" && req.UserID != principal.UserID {
+		return false, ErrSubmissionForged
+	}
+	if req.Source == "" {
+		return false, ErrEmptySource
+	}
+	if req.ContestID == "" {
+		return false, nil
+	}
+
+	contest, err := s.contestService.Get(req.ContestID)
+	if err != nil {
+		return false, err
+	}
+	if !contest.HasProblem(req.ProblemID) {
+		return false, ErrProblemNotInContest
+	}
+	if principal.IsAdmin() {
+		return false, nil
+	}
+	if !contest.AcceptsSubmissions() {
+		virtual, ok, err := s.contestService.Virtual(contest.ID, principal.UserID)
+		if err != nil {
+			return false, err
+		}
+		if ok && contest.Status == ContestFinished && virtual.ActiveAt(s.clock.Now()) {
+			return true, nil
+		}
+		return false, ErrContestNotRunning
+	}
+
+	registered, err := s.contestService.IsRegistered(contest.ID, principal.UserID)
+	if err != nil {
+		return false, err
+	}
+	if !registered {
+		return false, ErrNotRegistered
+	}
+	return false, s.seatService.CheckIP(contest.ID, principal.UserID, req.ClientIP)
+}
+
+// fillTemplate returns the full program for a submission of just a
+// template's solution region.
+func fillTemplate(problem Problem, req SubmissionRequest) (string, error) {
+	template, ok := problem.Templates[req.Language]
+	if !ok {
+		return "", ErrNoTemplate
+	}
+	source, _, err := template.fill(req.Source)
+	return source, err
+}
+
+// Get returns the submission with its source.
+func (s *SubmissionService) Get(id string) (Submission, error) {
+	submission, err := s.getRecord(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	if submission.SourceHash != "" {
+		if submission.Source, err = s.sources.Get(submission.SourceHash); err != nil {
+			return Submission{}, err
+		}
+	}
+	return submission, nil
+}
+
+func (s *SubmissionService) getRecord(id string) (Submission, error) {
+	var submission Submission
+	if err := getJSON(s.store, submissionKeyPrefix+id, &submission); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return Submission{}, ErrSubmissionNotFound
+		}
+		return Submission{}, err
+	}
+	return submission, nil
+}
+
+// Delete removes a submission and its reference to the stored source.
+func (s *SubmissionService) Delete(id string) error {
+	submission, err := s.getRecord(id)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Delete(submissionKeyPrefix + id); err != nil {
+		return err
+	}
+	if submission.SourceHash != "" {
+		return s.sources.Release(submission.SourceHash)
+	}
+	return nil
+}
+
+func (s *SubmissionService) UserDataName() string {
+	return "submissions"
+}
+
+// ExportUserData returns the user's submissions with their sources.
+func (s *SubmissionService) ExportUserData(userID string) (json.RawMessage, error) {
+	records, err := s.List(SubmissionFilter{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	var submissions []Submission
+	for _, record := range records {
+		submission, err := s.Get(record.ID)
+		if errors.Is(err, ErrSubmissionNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, submission)
+	}
+	return json.Marshal(submissions)
+}
+
+// AnonymizeUserData keeps the user's submissions, which scoreboards and
+// problem statistics are built from, under the pseudonym and drops their
+// sources. Listeners are not told, as nothing was judged. Submissions
+// still queued or being graded are left for a later attempt, as a worker
+// would store them back; a worker whose copy predates the change has its
+// write rejected by UpdateUnlessMoved.
+func (s *SubmissionService) AnonymizeUserData(userID, pseudonym string) error {
+	submissions, err := s.List(SubmissionFilter{UserID: userID})
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, submission := range submissions {
+		if !submission.Status.Done() {
+			pending++
+			continue
+		}
+		if submission.SourceHash != "" {
+			if err := s.sources.Release(submission.SourceHash); err != nil {
+				return err
+			}
+		}
+		submission.UserID = pseudonym
+		submission.Source = ""
+		submission.SourceHash = ""
+		if err := setJSON(s.store, submissionKeyPrefix+submission.ID, submission, 0); err != nil {
+			return err
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d", ErrSubmissionsPending, pending)
+	}
+	return nil
+}
+
+// Withdraw takes back the principal's own submission while it is still
+// queued. The queue entry stays behind and is skipped by the workers.
+func (s *SubmissionService) Withdraw(principal auth.Principal, id string) (Submission, error) {
+	submission, err := s.getRecord(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	if submission.UserID != principal.UserID {
+		return Submission{}, ErrSubmissionNotFound
+	}
+	return s.takeBack(id, SubmissionWithdrawn)
+}
+
+// Cancel is an admin taking back a queued submission.
+func (s *SubmissionService) Cancel(id string) (Submission, error) {
+	return s.takeBack(id, SubmissionCancelled)
+}
+
+// takeBack moves a queued submission to status, racing the workers for
+// its claim.
+func (s *SubmissionService) takeBack(id string, status SubmissionStatus) (Submission, error) {
+	submission, err := s.getRecord(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	if submission.Status != SubmissionQueued {
+		return Submission{}, ErrSubmissionNotQueued
+	}
+
+	ok, err := s.store.SetNX(submissionClaimPrefix+id, []byte(status), submissionClaimTTL)
+	if err != nil {
+		return Submission{}, err
+	}
+	if !ok {
+		return Submission{}, ErrSubmissionNotQueued
+	}
+	if submission, err = s.getRecord(id); err != nil {
+		return Submission{}, err
+	}
+	if submission.Status != SubmissionQueued {
+		return Submission{}, ErrSubmissionNotQueued
+	}
+	if err := submission.transition(status, s.clock.Now()); err != nil {
+		return Submission{}, err
+	}
+	if err := s.save(submission); err != nil {
+		return Submission{}, err
+	}
+	return submission, nil
+}
+
+// Claim reserves a queued submission for grading and returns it as
+// currently stored. It reports false if the submission was withdrawn or
+// is already being graded.
+func (s *SubmissionService) Claim(id string) (Submission, bool, error) {
+	ok, err := s.store.SetNX(submissionClaimPrefix+id, []byte("grade"), submissionClaimTTL)
+	if err != nil || !ok {
+		return Submission{}, false, err
+	}
+	submission, err := s.Get(id)
+	if err != nil {
+		return Submission{}, false, err
+	}
+	return submission, submission.Status == SubmissionQueued, nil
+}
+
+// SubmissionFilter narrows List; empty fields match every submission.
+type SubmissionFilter struct {
+	UserID    string
+	ContestID string
+	ProblemID string
+}
+
+// List returns the submissions matching filter without their sources.
+func (s *SubmissionService) List(filter SubmissionFilter) ([]Submission, error) {
+	all, err := listJSON[Submission](s.store, submissionKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var submissions []Submission
+	for _, submission := range all {
+		if (filter.UserID == "" || submission.UserID == filter.UserID) &&
+			(filter.ContestID == "" || submission.ContestID == filter.ContestID) &&
+			(filter.ProblemID == "" || submission.ProblemID == filter.ProblemID) {
+			submissions = append(submissions, submission)
+		}
+	}
+	return submissions, nil
+}
+
+// ListByContest returns the contest's submissions, oldest first. Sources
+// are not loaded; use Get for a submission's source.
+func (s *SubmissionService) ListByContest(contestID string) ([]Submission, error) {
+	all, err := listJSON[Submission](s.store, submissionKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var submissions []Submission
+	for _, submission := range all {
+		if submission.ContestID == contestID {
+			submissions = append(submissions, submission)
+		}
+	}
+	sort.SliceStable(submissions, func(i, j int) bool {
+		return submissions[i].CreatedAt.Before(submissions[j].CreatedAt)
+	})
+	return submissions, nil
+}
+
+// Update stores the submission as given.
+func (s *SubmissionService) Update(submission Submission) error {
+	return s.save(submission)
+}
+
+// UpdateUnlessMoved stores the submission unless the stored one has made a
+// status transition that the given one has not, e.g. because the watchdog
+// took it back from a stuck worker, or has been anonymized since. It then
+// returns ErrSubmissionMoved.
+func (s *SubmissionService) UpdateUnlessMoved(submission Submission) error {
+	stored, err := s.getRecord(submission.ID)
+	if err != nil {
+		return err
+	}
+	if len(stored.Transitions) > len(submission.Transitions) || stored.UserID != submission.UserID {
+		return ErrSubmissionMoved
+	}
+	for i, t := range stored.Transitions {
+		ours := submission.Transitions[i]
+		if t.From != ours.From || t.To != ours.To || !t.At.Equal(ours.At) {
+			return ErrSubmissionMoved
+		}
+	}
+	return s.save(submission)
+}
+
+// NextQueued pops the next queued submission, taking turns between
+// problems. It returns ErrSubmissionNotFound when the queue is empty.
+func (s *SubmissionService) NextQueued() (Submission, error) {
+	id, err := s.store.Pop(submissionQueueKey)
+	if errors.Is(err, store.ErrNotFound) {
+		id, err = s.queue.Pop()
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return Submission{}, ErrSubmissionNotFound
+	}
+	if err != nil {
+		return Submission{}, err
+	}
+	return s.Get(string(id))
+}
+
+// Rejudge puts a judged or failed submission back in its problem's queue
+// to be graded again. It keeps its verdict until the new one is stored.
+func (s *SubmissionService) Rejudge(id string) (Submission, error) {
+	submission, err := s.getRecord(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	if !submission.rejudgeable() {
+		return Submission{}, ErrNotRejudgeable
+	}
+	if err := submission.transition(SubmissionQueued, s.clock.Now()); err != nil {
+		return Submission{}, err
+	}
+	if err := s.save(submission); err != nil {
+		return Submission{}, err
+	}
+	// The claim of the last grading may not have expired yet
+	if err := s.store.Delete(submissionClaimPrefix + id); err != nil {
+		return Submission{}, err
+	}
+	return submission, s.queue.Push(submission.ProblemID, []byte(submission.ID))
+}
+
+// rejudgeable reports whether the submission is done grading and still has
+// its source, which anonymization removes.
+func (s Submission) rejudgeable() bool {
+	done := s.Status == SubmissionJudged || s.Status == SubmissionFailed
+	return done && (s.Source != "" || s.SourceHash != "")
+}
+
+// Requeue puts a submission back at the end of its problem's queue.
+func (s *SubmissionService) Requeue(submission Submission) error {
+	return s.queue.Push(submission.ProblemID, []byte(submission.ID))
+}
+
+// QueueLength returns the number of queued submissions.
+func (s *SubmissionService) QueueLength() (int64, error) {
+	legacy, err := s.store.Len(submissionQueueKey)
+	if err != nil {
+		return 0, err
+	}
+	queued, err := s.queue.Len()
+	return legacy + queued, err
+}
+
+func (s *SubmissionService) SnapshotName() string {
+	return "submissions"
+}
+
+// ExportSnapshot exports every submission with its source, so a snapshot
+// restores without the SourceStore it was taken from.
+func (s *SubmissionService) ExportSnapshot() (json.RawMessage, error) {
+	submissions, err := listJSON[Submission](s.store, submissionKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for i, submission := range submissions {
+		if submission.SourceHash == "" {
+			continue
+		}
+		source, err := s.sources.Get(submission.SourceHash)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		submissions[i].Source = source
+	}
+	return json.Marshal(submissions)
+}
+
+// ImportSnapshot restores the records, their sources and the ID counter,
+// and puts queued submissions back in their problems' queues. Grading only
+// claims queued submissions, so one still in a queue from before is not
+// graded twice; ones that were being graded are picked up by the watchdog.
+func (s *SubmissionService) ImportSnapshot(data json.RawMessage) error {
+	var submissions []Submission
+	if err := json.Unmarshal(data, &submissions); err != nil {
+		return err
+	}
+
+	if err := s.sources.reset(); err != nil {
+		return err
+	}
+	ids := make([]string, len(submissions))
+	for i, submission := range submissions {
+		ids[i] = submission.ID
+		if submission.Source == "" {
+			// anonymized, or a source lost before the export
+			submissions[i].SourceHash = ""
+			continue
+		}
+		hash, err := s.sources.Put(submission.Source)
+		if err != nil {
+			return err
+		}
+		submissions[i].SourceHash = hash
+		submissions[i].Source = ""
+	}
+	if err := replaceJSON(s.store, submissionKeyPrefix, submissions, func(sub Submission) string { return sub.ID }); err != nil {
+		return err
+	}
+
+	for _, submission := range submissions {
+		if submission.Status == SubmissionQueued {
+			if err := s.Requeue(submission); err != nil {
+				return err
+			}
+		}
+	}
+	return restoreCounter(s.store, submissionIDKey, ids)
+}
+
+func (s *SubmissionService) save(submission Submission) error {
+	if submission.SourceHash != "" {
+		submission.Source = ""
+	}
+	if err := setJSON(s.store, submissionKeyPrefix+submission.ID, submission, 0); err != nil {
+		return err
+	}
+	for _, listener := range s.listeners {
+		listener(submission)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"online-judge/internal/judge"
+	"online-judge/internal/store"
+	"strconv"
+	"time"
+)
+
+const (
+	watchdogLeaseKey = "lease:submission-watchdog"
+	// Counters for alerting: stuck submissions found, and how many of
+	// them were requeued or failed.
+	watchdogStuckKey    = "metric:watchdog:stuck"
+	watchdogRequeuedKey = "metric:watchdog:requeued"
+	watchdogFailedKey   = "metric:watchdog:failed"
+)
+
+type WatchdogConfig struct {
+	// Margin is added to the time the limits allow before a submission
+	// counts as stuck.
+	Margin time.Duration
+	// CompileAllowance is how long a compilation may take. It should not
+	// be below the judge workers' compile wall time limit.
+	CompileAllowance time.Duration
+	// MaxAttempts is how often a submission is graded before a stuck one
+	// fails instead of going back to the queue.
+	MaxAttempts int
+	Interval    time.Duration
+}
+
+// WatchdogConfigFromEnv reads WATCHDOG_MARGIN_SECONDS,
+// WATCHDOG_COMPILE_SECONDS and WATCHDOG_MAX_ATTEMPTS.
+func WatchdogConfigFromEnv() WatchdogConfig {
+	return WatchdogConfig{
+		Margin:           time.Duration(intFromEnv("WATCHDOG_MARGIN_SECONDS", 60)) * time.Second,
+		CompileAllowance: time.Duration(intFromEnv("WATCHDOG_COMPILE_SECONDS", 60)) * time.Second,
+		MaxAttempts:      intFromEnv("WATCHDOG_MAX_ATTEMPTS", 2),
+		Interval:         30 * time.Second,
+	}
+}
+
+// WatchdogStats are the watchdog's counters since the store was created.
+type WatchdogStats struct {
+	Stuck    int64 `json:"stuck"`
+	Requeued int64 `json:"requeued"`
+	Failed   int64 `json:"failed"`
+}
+
+// SubmissionWatchdog finds submissions that have been compiling or running
+// for longer than their problem's limits allow, for instance because the
+// replica grading them died. It kills their runs on the judge and requeues
+// them, or fails them with an internal error once they have used up their
+// attempts. Every replica runs it; a short lease lets one check per tick.
+type SubmissionWatchdog struct {
+	store             store.Store
+	submissionService *SubmissionService
+	problemService    *ProblemService
+	judgeClient       *judge.Client
+	config            WatchdogConfig
+}
+
+func NewSubmissionWatchdog(st store.Store, submissionService *SubmissionService, problemService *ProblemService, judgeClient *judge.Client, config WatchdogConfig) *SubmissionWatchdog {
+	return &SubmissionWatchdog{
+		store:             st,
+		submissionService: submissionService,
+		problemService:    problemService,
+		judgeClient:       judgeClient,
+		config:            config,
+	}
+}
+
+// Run checks for stuck submissions until ctx is cancelled.
+func (w *SubmissionWatchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := w.store.SetNX(watchdogLeaseKey, []byte("1"), w.config.Interval/2)
+		if err != nil {
+			log.Printf("Error acquiring watchdog lease: %v", err)
+			continue
+		}
+		if ok {
+			w.Check(ctx, time.Now())
+		}
+	}
+}
+
+// Check handles every submission stuck at now.
+func (w *SubmissionWatchdog) Check(ctx context.Context, now time.Time) {
+	submissions, err := w.submissionService.List(SubmissionFilter{})
+	if err != nil {
+		log.Printf("Error listing submissions for the watchdog: %v", err)
+		return
+	}
+	for _, submission := range submissions {
+		if !submission.Status.InProgress() {
+			continue
+		}
+		deadline, err := w.deadline(submission)
+		if err != nil {
+			log.Printf("Error computing deadline of submission %s: %v", submission.ID, err)
+			continue
+		}
+		if now.Sub(submission.StatusSince()) > deadline {
+			w.recover(ctx, submission, now)
+		}
+	}
+}
+
+// deadline is how long the submission may stay in its status: the first
+// compilation and test while compiling, every test while running.
+func (w *SubmissionWatchdog) deadline(submission Submission) (time.Duration, error) {
+	problem, err := w.problemService.Get(submission.ProblemID)
+	if err != nil {
+		return 0, err
+	}
+	tests, err := w.problemService.Tests(submission.ProblemID)
+	if err != nil {
+		return 0, err
+	}
+	// A checker runs under the same limits after every test
+	perTest := time.Duration(judge.WallTimeLimit(problem.TimeLimit) * float64(time.Second))
+	if problem.Checker != nil {
+		perTest *= 2
+	}
+	if script, err := w.problemService.JudgeScript(submission.ProblemID); err == nil {
+		timeLimit, _ := script.limits()
+		return w.config.CompileAllowance + time.Duration(judge.WallTimeLimit(timeLimit)*float64(time.Second)) + w.config.Margin, nil
+	} else if !errors.Is(err, ErrJudgeScriptNotFound) {
+		return 0, err
+	}
+	if submission.Status == SubmissionCompiling {
+		return w.config.CompileAllowance + perTest + w.config.Margin, nil
+	}
+	// The judge compiles before every test, so each one may take as long
+	// as the compile allowance
+	return time.Duration(max(len(tests), 1))*(w.config.CompileAllowance+perTest) + w.config.Margin, nil
+}
+
+func (w *SubmissionWatchdog) recover(ctx context.Context, submission Submission, now time.Time) {
+	w.count(watchdogStuckKey)
+	if killed, err := w.judgeClient.Kill(ctx, submission.ID); err != nil {
+		log.Printf("Error killing runs of stuck submission %s: %v", submission.ID, err)
+	} else if killed > 0 {
+		log.Printf("Killed %d runs of stuck submission %s", killed, submission.ID)
+	}
+
+	requeue := submission.Attempts() < w.config.MaxAttempts
+	if requeue {
+		submission.transition(SubmissionQueued, now)
+	} else {
+		submission.transition(SubmissionFailed, now)
+		submission.Verdict = VerdictInternalError
+		submission.JudgedAt = &now
+	}
+	if err := w.submissionService.UpdateUnlessMoved(submission); err != nil {
+		if !errors.Is(err, ErrSubmissionMoved) {
+			log.Printf("Error recovering stuck submission %s: %v", submission.ID, err)
+		}
+		return
+	}
+
+	if !requeue {
+		w.count(watchdogFailedKey)
+		log.Printf("Stuck submission %s failed after %d attempts", submission.ID, submission.Attempts())
+		return
+	}
+	w.count(watchdogRequeuedKey)
+	if err := w.submissionService.Requeue(submission); err != nil {
+		log.Printf("Error requeueing stuck submission %s: %v", submission.ID, err)
+		return
+	}
+	log.Printf("Requeued stuck submission %s", submission.ID)
+}
+
+func (w *SubmissionWatchdog) count(key string) {
+	if _, err := w.store.Incr(key); err != nil {
+		log.Printf("Error counting %s: %v", key, err)
+	}
+}
+
+// Stats returns the watchdog's counters.
+func (w *SubmissionWatchdog) Stats() (WatchdogStats, error) {
+	var stats WatchdogStats
+	for key, value := range map[string]*int64{
+		watchdogStuckKey:    &stats.Stuck,
+		watchdogRequeuedKey: &stats.Requeued,
+		watchdogFailedKey:   &stats.Failed,
+	} {
+		data, err := w.store.Get(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return WatchdogStats{}, err
+		}
+		if *value, err = strconv.ParseInt(string(data), 10, 64); err != nil {
+			return WatchdogStats{}, err
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"log"
+	"online-judge/internal/clock"
+	"online-judge/internal/judge"
+	"online-judge/internal/store"
+	"time"
+)
+
+const prefetchKeyPrefix = "prefetch:contest:"
+
+// TestDataPrefetcher fills the judge workers' test data caches shortly
+// before a contest starts, so the first submission to each problem does
+// not wait for a download. A store claim per contest makes every replica
+// agree on a single prefetch.
+type TestDataPrefetcher struct {
+	store          store.Store
+	contestService *ContestService
+	problemService *ProblemService
+	judgeClient    *judge.Client
+	lead           time.Duration
+	interval       time.Duration
+	clock          clock.Clock
+}
+
+func NewTestDataPrefetcher(st store.Store, contestService *ContestService, problemService *ProblemService, judgeClient *judge.Client, lead time.Duration) *TestDataPrefetcher {
+	return &TestDataPrefetcher{
+		store:          st,
+		contestService: contestService,
+		problemService: problemService,
+		judgeClient:    judgeClient,
+		lead:           lead,
+		interval:       30 * time.Second,
+		clock:          clock.System,
+	}
+}
+
+// SetClock replaces the wall clock, e.g. with a clock.Manual in tests.
+func (p *TestDataPrefetcher) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// PrefetchLeadFromEnv reads JUDGE_PREFETCH_LEAD_MINUTES, how long before a
+// contest starts its test data is prefetched, defaulting to 10 minutes.
+func PrefetchLeadFromEnv() time.Duration {
+	return time.Duration(intFromEnv("JUDGE_PREFETCH_LEAD_MINUTES", 10)) * time.Minute
+}
+
+// Run checks for contests about to start until ctx is cancelled.
+func (p *TestDataPrefetcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.prefetchDue(ctx, p.clock.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TestDataPrefetcher) prefetchDue(ctx context.Context, now time.Time) {
+	contests, err := p.contestService.List()
+	if err != nil {
+		log.Printf("Error listing contests to prefetch: %v", err)
+		return
+	}
+	for _, contest := range contests {
+		if contest.StatusAt(now) != ContestUpcoming || contest.StartTime.Sub(now) > p.lead {
+			continue
+		}
+		key := prefetchKeyPrefix + contest.ID
+		claimed, err := p.store.SetNX(key, []byte("1"), contest.EndTime.Sub(now))
+		if err != nil {
+			log.Printf("Error claiming prefetch of contest %s: %v", contest.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err := p.Prefetch(ctx, contest); err != nil {
+			log.Printf("Error prefetching test data of contest %s: %v", contest.ID, err)
+			// let the next check try again
+			p.store.Delete(key)
+		}
+	}
+}
+
+// Prefetch asks the judge workers to download the object-stored test
+// inputs of the contest's problems.
+func (p *TestDataPrefetcher) Prefetch(ctx context.Context, contest Contest) error {
+	refs, err := contestInputRefs(p.problemService, contest)
+	if err != nil || len(refs) == 0 {
+		return err
+	}
+	return p.judgeClient.Prefetch(ctx, refs)
+}
+
+// contestInputRefs lists the object-stored test inputs of a contest.
+func contestInputRefs(problemService *ProblemService, contest Contest) ([]judge.DataRef, error) {
+	var refs []judge.DataRef
+	for _, problemID := range contest.Problems {
+		tests, err := problemService.Tests(problemID)
+		if err != nil {
+			return nil, err
+		}
+		version := TestDataVersion(tests)
+		for _, test := range tests {
+			if test.Data == nil {
+				continue
+			}
+			ref, err := problemService.InputRef(version, test)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, *ref)
+		}
+	}
+	return refs, nil
+}
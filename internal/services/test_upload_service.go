@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"online-judge/internal/objectstore"
+	"online-judge/internal/store"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrObjectStorageDisabled = errors.New("object storage is not configured")
+	ErrTestUploadNotFound    = errors.New("test upload not found")
+	ErrTestUploadIncomplete  = errors.New("test input and output must both be uploaded before finalizing")
+	ErrTestDataTooLarge      = errors.New("test data exceeds the size limit")
+)
+
+const (
+	testUploadKeyPrefix = "upload:test:"
+	testUploadIDKey     = "counter:test-upload"
+	// testUploadTTL is how long the presigned URLs stay valid. The upload
+	// record lives a little longer so a slow finalize still finds it.
+	testUploadTTL   = time.Hour
+	testUploadGrace = 15 * time.Minute
+)
+
+// TestUpload is a pending test whose files a setter puts straight into
+// object storage through the presigned URLs.
+type TestUpload struct {
+	ID        string    `json:"id"`
+	ProblemID string    `json:"problemId"`
+	InputKey  string    `json:"inputKey"`
+	OutputKey string    `json:"outputKey"`
+	InputURL  string    `json:"inputUrl"`
+	OutputURL string    `json:"outputUrl"`
+	Sample    bool      `json:"sample"`
+	Final     bool      `json:"final,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type TestUploadRequest struct {
+	Sample bool `json:"sample"`
+	Final  bool `json:"final"`
+}
+
+// TestUploadService registers large tests without streaming their data
+// through the API server: Create hands out presigned upload URLs and
+// Finalize checks the uploaded objects and adds the test to the problem.
+type TestUploadService struct {
+	store          store.Store
+	problemService *ProblemService
+	objects        *objectstore.Client
+	maxBytes       int64
+}
+
+// NewTestUploadService creates the service. objects may be nil when object
+// storage is not configured; every call then fails with
+// ErrObjectStorageDisabled.
+func NewTestUploadService(st store.Store, problemService *ProblemService, objects *objectstore.Client, maxBytes int64) *TestUploadService {
+	return &TestUploadService{store: st, problemService: problemService, objects: objects, maxBytes: maxBytes}
+}
+
+// TestDataMaxBytesFromEnv reads TEST_DATA_MAX_BYTES, the size limit of each
+// uploaded test file, defaulting to 4 GiB.
+func TestDataMaxBytesFromEnv() int64 {
+	return int64(intFromEnv("TEST_DATA_MAX_BYTES", 4<<30))
+}
+
+func (s *TestUploadService) Create(problemID string, req TestUploadRequest) (TestUpload, error) {
+	if s.objects == nil {
+		return TestUpload{}, ErrObjectStorageDisabled
+	}
+	if _, err := s.problemService.Get(problemID); err != nil {
+		return TestUpload{}, err
+	}
+	id, err := s.store.Incr(testUploadIDKey)
+	if err != nil {
+		return TestUpload{}, err
+	}
+
+	upload := TestUpload{
+		ID:        strconv.FormatInt(id, 10),
+		ProblemID: problemID,
+		Sample:    req.Sample,
+		Final:     req.Final,
+		ExpiresAt: time.Now().Add(testUploadTTL),
+	}
+	upload.InputKey = fmt.Sprintf("tests/%s/%s/input", problemID, upload.ID)
+	upload.OutputKey = fmt.Sprintf("tests/%s/%s/output", problemID, upload.ID)
+	if upload.InputURL, err = s.objects.PresignPut(upload.InputKey, testUploadTTL); err != nil {
+		return TestUpload{}, err
+	}
+	if upload.OutputURL, err = s.objects.PresignPut(upload.OutputKey, testUploadTTL); err != nil {
+		return TestUpload{}, err
+	}
+	if err := setJSON(s.store, testUploadKeyPrefix+upload.ID, upload, testUploadTTL+testUploadGrace); err != nil {
+		return TestUpload{}, err
+	}
+	return upload, nil
+}
+
+// Finalize checks that both files of an upload are in object storage and
+// within the size limit, then appends the test to the problem. Oversized
+// files are deleted.
+func (s *TestUploadService) Finalize(ctx context.Context, problemID, uploadID, author string) (TestCase, error) {
+	if s.objects == nil {
+		return TestCase{}, ErrObjectStorageDisabled
+	}
+	var upload TestUpload
+	if err := getJSON(s.store, testUploadKeyPrefix+uploadID, &upload); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return TestCase{}, ErrTestUploadNotFound
+		}
+		return TestCase{}, err
+	}
+	if upload.ProblemID != problemID {
+		return TestCase{}, ErrTestUploadNotFound
+	}
+
+	data := TestData{InputKey: upload.InputKey, OutputKey: upload.OutputKey}
+	var err error
+	if data.InputSize, err = s.objectSize(ctx, upload.InputKey); err != nil {
+		return TestCase{}, err
+	}
+	if data.OutputSize, err = s.objectSize(ctx, upload.OutputKey); err != nil {
+		return TestCase{}, err
+	}
+	if data.InputSize > s.maxBytes || data.OutputSize > s.maxBytes {
+		s.discard(ctx, upload)
+		return TestCase{}, ErrTestDataTooLarge
+	}
+
+	test, err := s.problemService.AddTest(problemID, TestCase{Sample: upload.Sample, Final: upload.Final, Data: &data}, author)
+	if err != nil {
+		return TestCase{}, err
+	}
+	if err := s.store.Delete(testUploadKeyPrefix + uploadID); err != nil {
+		log.Printf("Error removing finalized test upload %s: %v", uploadID, err)
+	}
+	return test, nil
+}
+
+func (s *TestUploadService) objectSize(ctx context.Context, key string) (int64, error) {
+	size, err := s.objects.Size(ctx, key)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		return 0, ErrTestUploadIncomplete
+	}
+	return size, err
+}
+
+func (s *TestUploadService) discard(ctx context.Context, upload TestUpload) {
+	for _, key := range []string{upload.InputKey, upload.OutputKey} {
+		if err := s.objects.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting oversized test data %s: %v", key, err)
+		}
+	}
+	if err := s.store.Delete(testUploadKeyPrefix + upload.ID); err != nil {
+		log.Printf("Error removing test upload %s: %v", upload.ID, err)
+	}
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"online-judge/internal/judge"
+	"online-judge/internal/store"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRolloutNotFound   = errors.New("toolchain rollout not found")
+	ErrRolloutInProgress = errors.New("another toolchain rollout is in progress")
+)
+
+type RolloutStatus string
+
+const (
+	RolloutRunning    RolloutStatus = "running"
+	RolloutCompleted  RolloutStatus = "completed"
+	RolloutRolledBack RolloutStatus = "rolled_back"
+)
+
+// Steps a worker goes through during a rollout.
+const (
+	RolloutPending    = "pending"
+	RolloutDraining   = "draining"
+	RolloutSwapping   = "swapping"
+	RolloutSelfTest   = "self_test"
+	RolloutCanary     = "canary"
+	RolloutDone       = "done"
+	RolloutReverted   = "reverted"
+	RolloutRevertFail = "revert_failed"
+)
+
+// ToolchainRollout moves the judge workers to another runtime directory
+// built by cmd/imagebuild, one worker at a time: drain, swap, self-test,
+// re-enable, then re-run canary submissions and compare verdicts. If a
+// step fails on any worker, every worker moved so far goes back to its
+// previous runtime.
+type ToolchainRollout struct {
+	ID         string `json:"id"`
+	RuntimeDir string `json:"runtimeDir" binding:"required"`
+	// Canaries is how many recently judged submissions are re-run on each
+	// upgraded worker.
+	Canaries   int             `json:"canaries"`
+	Status     RolloutStatus   `json:"status"`
+	Workers    []WorkerRollout `json:"workers"`
+	Error      string          `json:"error,omitempty"`
+	StartedBy  string          `json:"startedBy"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+type WorkerRollout struct {
+	URL         string `json:"url"`
+	Step        string `json:"step"`
+	PreviousDir string `json:"previousDir,omitempty"`
+	Detail      string `json:"detail,omitempty"`
+}
+
+const (
+	rolloutKeyPrefix = "rollout:toolchain:"
+	rolloutIDKey     = "counter:toolchain-rollout"
+	rolloutLockKey   = "lock:toolchain-rollout"
+	// rolloutLockTTL outlives any rollout; the lock is released when the
+	// rollout ends.
+	rolloutLockTTL = 24 * time.Hour
+	// canaryTestsPerSubmission caps the tests re-run per canary.
+	canaryTestsPerSubmission = 3
+	defaultCanaries          = 5
+)
+
+// ToolchainRolloutService runs rollouts in the background on the replica
+// that started them. A rollout interrupted by a replica crash leaves its
+// lock until it expires; workers it drained must then be enabled by hand.
+type ToolchainRolloutService struct {
+	store             store.Store
+	submissionService *SubmissionService
+	problemService    *ProblemService
+	workers           []string
+	// drainTimeout bounds the wait for a worker's running executions.
+	drainTimeout time.Duration
+}
+
+func NewToolchainRolloutService(st store.Store, submissionService *SubmissionService, problemService *ProblemService, workers []string) *ToolchainRolloutService {
+	return &ToolchainRolloutService{
+		store:             st,
+		submissionService: submissionService,
+		problemService:    problemService,
+		workers:           workers,
+		drainTimeout:      10 * time.Minute,
+	}
+}
+
+// Start begins a rollout to runtimeDir and returns it while it runs.
+func (s *ToolchainRolloutService) Start(rollout ToolchainRollout) (ToolchainRollout, error) {
+	ok, err := s.store.SetNX(rolloutLockKey, []byte("1"), rolloutLockTTL)
+	if err != nil {
+		return ToolchainRollout{}, err
+	}
+	if !ok {
+		return ToolchainRollout{}, ErrRolloutInProgress
+	}
+
+	id, err := s.store.Incr(rolloutIDKey)
+	if err != nil {
+		s.store.Delete(rolloutLockKey)
+		return ToolchainRollout{}, err
+	}
+	rollout.ID = strconv.FormatInt(id, 10)
+	rollout.Status = RolloutRunning
+	rollout.Error, rollout.FinishedAt = "", nil
+	rollout.CreatedAt = time.Now()
+	if rollout.Canaries <= 0 {
+		rollout.Canaries = defaultCanaries
+	}
+	rollout.Workers = make([]WorkerRollout, len(s.workers))
+	for i, url := range s.workers {
+		rollout.Workers[i] = WorkerRollout{URL: url, Step: RolloutPending}
+	}
+	if err := s.save(rollout); err != nil {
+		s.store.Delete(rolloutLockKey)
+		return ToolchainRollout{}, err
+	}
+
+	go s.run(context.Background(), rollout)
+	return rollout, nil
+}
+
+func (s *ToolchainRolloutService) Get(id string) (ToolchainRollout, error) {
+	var rollout ToolchainRollout
+	if err := getJSON(s.store, rolloutKeyPrefix+id, &rollout); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ToolchainRollout{}, ErrRolloutNotFound
+		}
+		return ToolchainRollout{}, err
+	}
+	return rollout, nil
+}
+
+// List returns every rollout, newest first.
+func (s *ToolchainRolloutService) List() ([]ToolchainRollout, error) {
+	rollouts, err := listJSON[ToolchainRollout](s.store, rolloutKeyPrefix)
+	sort.Slice(rollouts, func(i, j int) bool { return rollouts[i].CreatedAt.After(rollouts[j].CreatedAt) })
+	return rollouts, err
+}
+
+func (s *ToolchainRolloutService) save(rollout ToolchainRollout) error {
+	return setJSON(s.store, rolloutKeyPrefix+rollout.ID, rollout, 0)
+}
+
+func (s *ToolchainRolloutService) run(ctx context.Context, rollout ToolchainRollout) {
+	defer s.store.Delete(rolloutLockKey)
+
+	canaries, err := s.canaries(rollout.Canaries)
+	if err != nil {
+		log.Printf("Error selecting canaries for rollout %s: %v", rollout.ID, err)
+	}
+
+	var failure error
+	for i := range rollout.Workers {
+		if failure = s.upgrade(ctx, &rollout, i, canaries); failure != nil {
+			rollout.Workers[i].Detail = failure.Error()
+			failure = fmt.Errorf("worker %s: %w", rollout.Workers[i].URL, failure)
+			break
+		}
+	}
+
+	if failure != nil {
+		rollout.Error = failure.Error()
+		rollout.Status = RolloutRolledBack
+		for i := len(rollout.Workers) - 1; i >= 0; i-- {
+			if rollout.Workers[i].Step != RolloutPending {
+				s.revert(ctx, &rollout, i)
+			}
+		}
+	} else {
+		rollout.Status = RolloutCompleted
+	}
+	now := time.Now()
+	rollout.FinishedAt = &now
+	if err := s.save(rollout); err != nil {
+		log.Printf("Error saving rollout %s: %v", rollout.ID, err)
+	}
+	log.Printf("Toolchain rollout %s to %s %s", rollout.ID, rollout.RuntimeDir, rollout.Status)
+}
+
+// step records the worker's progress so the rollout can be followed.
+func (s *ToolchainRolloutService) step(rollout *ToolchainRollout, i int, step string) {
+	rollout.Workers[i].Step = step
+	if err := s.save(*rollout); err != nil {
+		log.Printf("Error saving rollout %s: %v", rollout.ID, err)
+	}
+}
+
+func (s *ToolchainRolloutService) upgrade(ctx context.Context, rollout *ToolchainRollout, i int, canaries []Submission) error {
+	client := judge.NewClient(rollout.Workers[i].URL)
+	status, err := client.Maintenance(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Runtime != nil {
+		rollout.Workers[i].PreviousDir = status.Runtime.Dir
+	}
+	// Languages the worker could not run before are not held against the
+	// new runtime
+	before, err := client.SelfTest(ctx)
+	if err != nil {
+		return err
+	}
+	worked := make(map[string]bool, len(before))
+	for _, result := range before {
+		worked[result.Language] = result.OK
+	}
+
+	s.step(rollout, i, RolloutDraining)
+	if err := s.drain(ctx, client); err != nil {
+		return err
+	}
+	s.step(rollout, i, RolloutSwapping)
+	if _, err := client.SwapRuntime(ctx, rollout.RuntimeDir); err != nil {
+		return err
+	}
+	s.step(rollout, i, RolloutSelfTest)
+	results, err := client.SelfTest(ctx)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if worked[result.Language] && !result.OK {
+			return fmt.Errorf("self-test failed for %s: %s", result.Language, result.Detail)
+		}
+	}
+	if err := client.Enable(ctx); err != nil {
+		return err
+	}
+	s.step(rollout, i, RolloutCanary)
+	if err := s.compareCanaries(ctx, client, canaries); err != nil {
+		return err
+	}
+	s.step(rollout, i, RolloutDone)
+	return nil
+}
+
+// revert puts the worker back on its previous runtime and enables it.
+func (s *ToolchainRolloutService) revert(ctx context.Context, rollout *ToolchainRollout, i int) {
+	client := judge.NewClient(rollout.Workers[i].URL)
+	err := s.drain(ctx, client)
+	if err == nil {
+		_, err = client.SwapRuntime(ctx, rollout.Workers[i].PreviousDir)
+	}
+	// A worker is never left drained, even on its new runtime
+	if enableErr := client.Enable(ctx); err == nil {
+		err = enableErr
+	}
+	if err != nil {
+		log.Printf("Error reverting worker %s in rollout %s: %v", rollout.Workers[i].URL, rollout.ID, err)
+		rollout.Workers[i].Detail = strings.TrimSpace(rollout.Workers[i].Detail + "; revert: " + err.Error())
+		s.step(rollout, i, RolloutRevertFail)
+		return
+	}
+	s.step(rollout, i, RolloutReverted)
+}
+
+// drain stops the worker taking executions and waits for it to go idle.
+func (s *ToolchainRolloutService) drain(ctx context.Context, client *judge.Client) error {
+	if err := client.Drain(ctx); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(s.drainTimeout)
+	for {
+		status, err := client.Maintenance(ctx)
+		if err != nil {
+			return err
+		}
+		if status.Active == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("worker still has %d executions after %s", status.Active, s.drainTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// canaries picks the most recently judged submissions whose tests can be
+// compared on their own: no checker, no judge script, no tests in object
+// storage.
+func (s *ToolchainRolloutService) canaries(n int) ([]Submission, error) {
+	submissions, err := s.submissionService.List(SubmissionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(submissions, func(i, j int) bool { return submissions[i].CreatedAt.After(submissions[j].CreatedAt) })
+
+	var canaries []Submission
+	for _, submission := range submissions {
+		if len(canaries) == n {
+			break
+		}
+		if submission.Status != SubmissionJudged || len(submission.Results) == 0 || len(submission.Overrides) > 0 {
+			continue
+		}
+		problem, err := s.problemService.Get(submission.ProblemID)
+		if err != nil || problem.Checker != nil {
+			continue
+		}
+		if _, err := s.problemService.JudgeScript(problem.ID); !errors.Is(err, ErrJudgeScriptNotFound) {
+			continue
+		}
+		full, err := s.submissionService.Get(submission.ID)
+		if err != nil {
+			return nil, err
+		}
+		canaries = append(canaries, full)
+	}
+	return canaries, nil
+}
+
+// compareCanaries re-runs the first tests of each canary on the worker and
+// fails on any verdict that differs from the stored one.
+func (s *ToolchainRolloutService) compareCanaries(ctx context.Context, client *judge.Client, canaries []Submission) error {
+	for _, submission := range canaries {
+		problem, err := s.problemService.Get(submission.ProblemID)
+		if err != nil {
+			return err
+		}
+		tests, err := s.problemService.Tests(submission.ProblemID)
+		if err != nil {
+			return err
+		}
+		source, err := judgedSource(problem, submission)
+		if err != nil {
+			return err
+		}
+		compared := 0
+		for _, stored := range submission.Results {
+			if compared == canaryTestsPerSubmission {
+				break
+			}
+			if stored.Test < 1 || stored.Test > len(tests) || tests[stored.Test-1].Data != nil {
+				continue
+			}
+			test := tests[stored.Test-1]
+			result, err := client.Execute(ctx, judge.Submission{
+				Language:       submission.Language,
+				Code:           source.Code,
+				Input:          test.Input,
+				TimeLimit:      problem.TimeLimit,
+				MemoryLimit:    problem.MemoryLimit,
+				OutputLimit:    problem.OutputLimit,
+				Env:            problem.Env,
+				NetworkEnabled: problem.NetworkEnabled,
+				Files:          problem.runFiles(),
+			})
+			if err != nil {
+				return err
+			}
+			if verdict := verdictFor(problem, result, test); verdict != stored.Verdict {
+				return fmt.Errorf("canary submission %s test %d: %s, was %s", submission.ID, stored.Test, verdict, stored.Verdict)
+			}
+			compared++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"online-judge/internal/judge"
+	"online-judge/internal/store"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnitTestsNotFound = errors.New("unit test suite not found")
+	ErrUnitTestsFailed   = errors.New("unit test run failed")
+	ErrInvalidUnitTests  = errors.New("invalid unit test suite")
+)
+
+// UnitTestFramework picks how a suite is run.
+type UnitTestFramework string
+
+const (
+	// UnitTestsPytest runs the suite's files with pytest.
+	UnitTestsPytest UnitTestFramework = "pytest"
+	// UnitTestsCustom runs the suite's own Runner, which must print a
+	// JUnit XML report on stdout: e.g. a Java Main launching JUnit, or a
+	// script piping go test through go-junit-report.
+	UnitTestsCustom UnitTestFramework = "custom"
+)
+
+// UnitTestSuite grades a problem's submissions with hidden unit tests in
+// place of its tests and checker, for assignments where students write a
+// module rather than a program. The suite's files and the submission,
+// saved as SubmissionFile, run once per submission in the judge sandbox,
+// and every test case of the framework's JUnit XML report becomes one test
+// result.
+type UnitTestSuite struct {
+	ProblemID string            `json:"problemId"`
+	Framework UnitTestFramework `json:"framework" binding:"required"`
+	// Language and Runner are the runner program of a custom suite; other
+	// frameworks bring their own.
+	Language string `json:"language,omitempty"`
+	Runner   string `json:"runner,omitempty"`
+	// SubmissionFile is the name the tests import the submission by, e.g.
+	// solution.py.
+	SubmissionFile string            `json:"submissionFile" binding:"required"`
+	Files          map[string]string `json:"files" binding:"required"`
+	// Weights are the points of test cases by name, "classname.name" as in
+	// the report; unlisted cases are worth 1.
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// TimeLimit and MemoryLimit bound the whole run; zero means the
+	// defaults.
+	TimeLimit   float64   `json:"timeLimit,omitempty"`
+	MemoryLimit int       `json:"memoryLimit,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Limits for unit test runs, which include the framework's start-up.
+const (
+	unitTestTimeLimit    = 30.0
+	unitTestMaxTimeLimit = 300.0
+	unitTestMemoryLimit  = 512 * 1024
+	unitTestProcesses    = 16
+)
+
+// pytestRunner keeps pytest's own output off stdout, which carries only
+// the report.
+const pytestRunner = `import sys
+import pytest
+
+stdout, sys.stdout = sys.stdout, sys.stderr
+pytest.main(["-q", "-p", "no:cacheprovider", "--junitxml=report.xml"])
+with open("report.xml") as report:
+    stdout.write(report.read())
+`
+
+// runner returns the language and source of the program that runs the
+// suite.
+func (u UnitTestSuite) runner() (string, string) {
+	if u.Framework == UnitTestsPytest {
+		return "python", pytestRunner
+	}
+	return u.Language, u.Runner
+}
+
+func (u UnitTestSuite) limits() (float64, int) {
+	timeLimit, memoryLimit := u.TimeLimit, u.MemoryLimit
+	if timeLimit == 0 {
+		timeLimit = unitTestTimeLimit
+	}
+	if memoryLimit == 0 {
+		memoryLimit = unitTestMemoryLimit
+	}
+	return timeLimit, memoryLimit
+}
+
+func (u UnitTestSuite) validate() error {
+	switch u.Framework {
+	case UnitTestsPytest:
+	case UnitTestsCustom:
+		if _, ok := judge.LookupLanguage(u.Language); !ok || u.Runner == "" {
+			return fmt.Errorf("%w: a custom suite needs a runner in a supported language", ErrInvalidUnitTests)
+		}
+	default:
+		return fmt.Errorf("%w: unknown framework %q", ErrInvalidUnitTests, u.Framework)
+	}
+	if u.TimeLimit < 0 || u.TimeLimit > unitTestMaxTimeLimit || u.MemoryLimit < 0 {
+		return fmt.Errorf("%w: limits out of range", ErrInvalidUnitTests)
+	}
+	language, _ := u.runner()
+	lang, _ := judge.LookupLanguage(language)
+	for _, name := range append([]string{u.SubmissionFile}, mapKeys(u.Files)...) {
+		if name == "" || name != filepath.Base(name) || name == "." || name == ".." || name == lang.SourceFile || name == "meta" {
+			return fmt.Errorf("%w: invalid file name %q", ErrInvalidUnitTests, name)
+		}
+	}
+	if _, ok := u.Files[u.SubmissionFile]; ok {
+		return fmt.Errorf("%w: %s is also a suite file", ErrInvalidUnitTests, u.SubmissionFile)
+	}
+	return nil
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+const unitTestsKeyPrefix = "unittests:problem:"
+
+// SetUnitTests attaches a unit test suite to the problem, replacing any
+// previous one. Submissions graded from then on are evaluated by it.
+func (s *ProblemService) SetUnitTests(suite UnitTestSuite) (UnitTestSuite, error) {
+	if _, err := s.Get(suite.ProblemID); err != nil {
+		return UnitTestSuite{}, err
+	}
+	if err := suite.validate(); err != nil {
+		return UnitTestSuite{}, err
+	}
+	suite.UpdatedAt = time.Now()
+	if err := setJSON(s.store, unitTestsKeyPrefix+suite.ProblemID, suite, 0); err != nil {
+		return UnitTestSuite{}, err
+	}
+	return suite, nil
+}
+
+// UnitTests returns the problem's unit test suite, or ErrUnitTestsNotFound
+// if it has none.
+func (s *ProblemService) UnitTests(problemID string) (UnitTestSuite, error) {
+	var suite UnitTestSuite
+	if err := getJSON(s.store, unitTestsKeyPrefix+problemID, &suite); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return UnitTestSuite{}, ErrUnitTestsNotFound
+		}
+		return UnitTestSuite{}, err
+	}
+	return suite, nil
+}
+
+func (s *ProblemService) DeleteUnitTests(problemID string) error {
+	if _, err := s.UnitTests(problemID); err != nil {
+		return err
+	}
+	return s.store.Delete(unitTestsKeyPrefix + problemID)
+}
+
+// UnitTestsSnapshot returns the backup section holding every problem's
+// unit test suite.
+func (s *ProblemService) UnitTestsSnapshot() SnapshotSection {
+	return unitTestsSnapshot{store: s.store}
+}
+
+type unitTestsSnapshot struct {
+	store store.Store
+}
+
+func (u unitTestsSnapshot) SnapshotName() string {
+	return "unitTests"
+}
+
+func (u unitTestsSnapshot) ExportSnapshot() (json.RawMessage, error) {
+	suites, err := listJSON[UnitTestSuite](u.store, unitTestsKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(suites)
+}
+
+func (u unitTestsSnapshot) ImportSnapshot(data json.RawMessage) error {
+	var suites []UnitTestSuite
+	if err := json.Unmarshal(data, &suites); err != nil {
+		return err
+	}
+	return replaceJSON(u.store, unitTestsKeyPrefix, suites, func(s UnitTestSuite) string { return s.ProblemID })
+}
+
+// gradeWithUnitTests runs the suite against source, the submission spliced
+// into any harness.
+func (s *GradingService) gradeWithUnitTests(ctx context.Context, submission Submission, source splicedSource, problem Problem, suite UnitTestSuite) (Submission, error) {
+	files := make(map[string]string, len(suite.Files)+1)
+	for name, content := range suite.Files {
+		files[name] = content
+	}
+	files[suite.SubmissionFile] = source.Code
+
+	if submission.Status == SubmissionCompiling {
+		if err := submission.transition(SubmissionRunning, time.Now()); err != nil {
+			return submission, err
+		}
+		if err := s.submissionService.UpdateUnlessMoved(submission); err != nil {
+			return submission, err
+		}
+	}
+	language, runner := suite.runner()
+	timeLimit, memoryLimit := suite.limits()
+	result, err := s.judgeClient.Execute(ctx, judge.Submission{
+		Language:       language,
+		Code:           runner,
+		TimeLimit:      timeLimit,
+		MemoryLimit:    memoryLimit,
+		MaxProcesses:   unitTestProcesses,
+		NetworkEnabled: problem.NetworkEnabled,
+		Files:          files,
+		RunID:          submission.ID,
+	})
+	if err != nil {
+		return submission, err
+	}
+	submission.Timing.Run = result.WallTime
+
+	switch result.Status {
+	case judge.StatusOK:
+	case judge.StatusTimeLimitExceeded:
+		submission.Verdict = VerdictTimeLimitExceeded
+		return submission, submission.transition(SubmissionJudged, time.Now())
+	case judge.StatusMemoryLimitExceeded:
+		submission.Verdict = VerdictMemoryLimitExceeded
+		return submission, submission.transition(SubmissionJudged, time.Now())
+	case judge.StatusOutputLimitExceeded:
+		submission.Verdict = VerdictOutputLimitExceeded
+		return submission, submission.transition(SubmissionJudged, time.Now())
+	case judge.StatusDiskQuotaExceeded:
+		submission.Verdict = VerdictDiskQuotaExceeded
+		return submission, submission.transition(SubmissionJudged, time.Now())
+	case judge.StatusCompileTimeLimitExceeded:
+		submission.Verdict = VerdictCompileTimeLimitExceeded
+		return submission, submission.transition(SubmissionJudged, time.Now())
+	case judge.StatusSecurityViolation:
+		submission.Verdict = VerdictSecurityViolation
+		return submission, submission.transition(SubmissionJudged, time.Now())
+	default:
+		return submission, fmt.Errorf("%w: %s %s%s", ErrUnitTestsFailed, result.Status, result.CompileOutput, snippet(result.Stderr))
+	}
+	cases, err := parseJUnitReport(strings.NewReader(result.Stdout))
+	if err != nil {
+		return submission, err
+	}
+
+	total, earned := 0.0, 0.0
+	submission.Verdict = ""
+	for i, testCase := range cases {
+		weight, ok := suite.Weights[testCase.fullName()]
+		if !ok {
+			weight = 1
+		}
+		total += weight
+		testResult := TestResult{
+			Test:    i + 1,
+			Name:    testCase.fullName(),
+			Verdict: testCase.verdict(),
+			Time:    testCase.Time,
+		}
+		if testResult.Verdict == VerdictAccepted {
+			testResult.Score = 1
+			earned += weight
+		} else {
+			testResult.CheckerMessage = snippet(testCase.message())
+			if submission.Verdict == "" {
+				submission.Verdict = testResult.Verdict
+			}
+		}
+		submission.Results = append(submission.Results, testResult)
+	}
+
+	fraction := 0.0
+	if total > 0 {
+		fraction = earned / total
+	}
+	submission.Score = fraction * problem.MaxScore
+	switch {
+	case submission.Verdict == "":
+		submission.Verdict = VerdictAccepted
+	case fraction > 0 && problem.ScoringPolicy != ScoringBinary:
+		submission.Verdict = VerdictPartial
+	case problem.ScoringPolicy == ScoringBinary:
+		submission.Score = 0
+	}
+	return submission, submission.transition(SubmissionJudged, time.Now())
+}
+
+// junitCase is one <testcase> of a JUnit XML report.
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitProblem `xml:"skipped"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (c junitCase) fullName() string {
+	if c.ClassName == "" {
+		return c.Name
+	}
+	return c.ClassName + "." + c.Name
+}
+
+// verdict counts skipped cases as failed, or a submission could skip the
+// cases it cannot pass.
+func (c junitCase) verdict() Verdict {
+	switch {
+	case c.Error != nil:
+		return VerdictRuntimeError
+	case c.Failure != nil, c.Skipped != nil:
+		return VerdictWrongAnswer
+	}
+	return VerdictAccepted
+}
+
+func (c junitCase) message() string {
+	for _, problem := range []*junitProblem{c.Error, c.Failure, c.Skipped} {
+		if problem == nil {
+			continue
+		}
+		if problem.Message != "" {
+			return problem.Message
+		}
+		return strings.TrimSpace(problem.Text)
+	}
+	return ""
+}
+
+// parseJUnitReport returns every test case of a report, however the
+// framework nests its <testsuites> and <testsuite> elements.
+func parseJUnitReport(r io.Reader) ([]junitCase, error) {
+	decoder := xml.NewDecoder(r)
+	var cases []junitCase
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: report is not JUnit XML: %v", ErrUnitTestsFailed, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "testcase" {
+			continue
+		}
+		var testCase junitCase
+		if err := decoder.DecodeElement(&testCase, &start); err != nil {
+			return nil, fmt.Errorf("%w: report is not JUnit XML: %v", ErrUnitTestsFailed, err)
+		}
+		cases = append(cases, testCase)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%w: report has no test cases", ErrUnitTestsFailed)
+	}
+	return cases, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"online-judge/internal/judge"
+	"online-judge/internal/store"
+	"time"
+)
+
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+// UserPreferences are a user's editor settings, kept on the server so they
+// follow the user across devices. Clients prefill submissions and the
+// editor from them; the judge does not read them.
+type UserPreferences struct {
+	UserID string `json:"userId"`
+	// Language is the language new submissions start in.
+	Language string `json:"language,omitempty"`
+	// Templates are the user's own starting code per language name, used
+	// where a problem has no starter template.
+	Templates map[string]string `json:"templates,omitempty"`
+	TabWidth  int               `json:"tabWidth,omitempty"`
+	// Theme is the client's editor theme name.
+	Theme     string    `json:"theme,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+const (
+	preferencesKeyPrefix = "preferences:user:"
+	// maxPreferenceTemplate bounds each template, which every page load
+	// sends back.
+	maxPreferenceTemplate = 16 * 1024
+	maxTabWidth           = 16
+	maxThemeLength        = 64
+)
+
+func (p UserPreferences) validate() error {
+	if p.Language != "" {
+		if _, ok := judge.LookupLanguage(p.Language); !ok {
+			return ErrInvalidPreferences
+		}
+	}
+	for language, template := range p.Templates {
+		if _, ok := judge.LookupLanguage(language); !ok || len(template) > maxPreferenceTemplate {
+			return ErrInvalidPreferences
+		}
+	}
+	if p.TabWidth < 0 || p.TabWidth > maxTabWidth || len(p.Theme) > maxThemeLength {
+		return ErrInvalidPreferences
+	}
+	return nil
+}
+
+type PreferencesService struct {
+	store store.Store
+}
+
+func NewPreferencesService(st store.Store) *PreferencesService {
+	return &PreferencesService{store: st}
+}
+
+// Get returns the user's preferences, empty ones if they saved none.
+func (s *PreferencesService) Get(userID string) (UserPreferences, error) {
+	var preferences UserPreferences
+	if err := getJSON(s.store, preferencesKeyPrefix+userID, &preferences); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return UserPreferences{UserID: userID}, nil
+		}
+		return UserPreferences{}, err
+	}
+	return preferences, nil
+}
+
+// Set replaces the user's preferences.
+func (s *PreferencesService) Set(preferences UserPreferences) (UserPreferences, error) {
+	if err := preferences.validate(); err != nil {
+		return UserPreferences{}, err
+	}
+	preferences.UpdatedAt = time.Now()
+	if err := setJSON(s.store, preferencesKeyPrefix+preferences.UserID, preferences, 0); err != nil {
+		return UserPreferences{}, err
+	}
+	return preferences, nil
+}
+
+// Reset drops the user's preferences, returning them to the defaults.
+func (s *PreferencesService) Reset(userID string) error {
+	return s.store.Delete(preferencesKeyPrefix + userID)
+}
+
+func (s *PreferencesService) SnapshotName() string {
+	return "userPreferences"
+}
+
+func (s *PreferencesService) ExportSnapshot() (json.RawMessage, error) {
+	preferences, err := listJSON[UserPreferences](s.store, preferencesKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(preferences)
+}
+
+func (s *PreferencesService) ImportSnapshot(data json.RawMessage) error {
+	var preferences []UserPreferences
+	if err := json.Unmarshal(data, &preferences); err != nil {
+		return err
+	}
+	return replaceJSON(s.store, preferencesKeyPrefix, preferences,
+		func(p UserPreferences) string { return p.UserID })
+}
+
+// UserData returns the privacy section holding the user's preferences.
+func (s *PreferencesService) UserData() UserDataSection {
+	return userKeySection{store: s.store, name: "preferences", prefixes: []string{preferencesKeyPrefix}, marker: ":", erase: true}
+}
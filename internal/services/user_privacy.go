@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"online-judge/internal/store"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDeletionNotFound = errors.New("no account deletion requested")
+	// ErrDeletionStarted is returned when cancelling a deletion that has
+	// already been carried out.
+	ErrDeletionStarted = errors.New("account deletion already carried out")
+)
+
+const (
+	deletionKeyPrefix = "deletion:user:"
+	deletionLeaseKey  = "lease:account-deletion"
+	// deletedUserPrefix starts the pseudonyms that replace a deleted
+	// user's ID in the records kept.
+	deletedUserPrefix = "deleted-"
+)
+
+// UserDataSection is implemented by every service holding data about a
+// user. ExportUserData returns JSON null when it holds nothing for the
+// user; AnonymizeUserData erases the user's personal data, replacing their
+// ID with pseudonym where a record must stay, such as a contest
+// submission, and must leave ExportUserData returning null.
+type UserDataSection interface {
+	UserDataName() string
+	ExportUserData(userID string) (json.RawMessage, error)
+	AnonymizeUserData(userID, pseudonym string) error
+}
+
+// UserDataExport is everything stored about a user.
+type UserDataExport struct {
+	UserID     string                     `json:"userId"`
+	ExportedAt time.Time                  `json:"exportedAt"`
+	Sections   map[string]json.RawMessage `json:"sections"`
+}
+
+type DeletionStatus string
+
+const (
+	DeletionPending   DeletionStatus = "pending"
+	DeletionCompleted DeletionStatus = "completed"
+	// DeletionFailed is a deletion whose verification found data left;
+	// it is retried every run.
+	DeletionFailed DeletionStatus = "failed"
+)
+
+// DeletionRequest is a user's request to delete their account. It is
+// carried out once DeleteAfter has passed, so it can be cancelled until
+// then.
+type DeletionRequest struct {
+	UserID      string         `json:"userId"`
+	Status      DeletionStatus `json:"status"`
+	RequestedAt time.Time      `json:"requestedAt"`
+	DeleteAfter time.Time      `json:"deleteAfter"`
+	CompletedAt *time.Time     `json:"completedAt,omitempty"`
+	// Remaining names the sections still holding data after the last
+	// attempt.
+	Remaining []string `json:"remaining,omitempty"`
+	// Pseudonym replaces the user's ID. It is chosen on the first attempt
+	// and reused by retries, so one user's records share one pseudonym,
+	// and dropped once the deletion completes.
+	Pseudonym string `json:"pseudonym,omitempty"`
+}
+
+type PrivacyConfig struct {
+	// GracePeriod is how long a deletion request can be cancelled.
+	GracePeriod time.Duration
+	Interval    time.Duration
+}
+
+// PrivacyConfigFromEnv reads ACCOUNT_DELETION_GRACE_DAYS.
+func PrivacyConfigFromEnv() PrivacyConfig {
+	return PrivacyConfig{
+		GracePeriod: time.Duration(max(intFromEnv("ACCOUNT_DELETION_GRACE_DAYS", 7), 0)) * 24 * time.Hour,
+		Interval:    time.Hour,
+	}
+}
+
+// PrivacyService exports a user's data and carries out account deletion.
+// Deletion anonymizes rather than removes what contests need: submissions
+// stay on scoreboards under a pseudonym, without their sources. The event
+// log and integrity reviews are kept as they are; both are audit records.
+// Every replica runs the deletion job; a lease lets one run per tick.
+type PrivacyService struct {
+	store    store.Store
+	config   PrivacyConfig
+	sections []UserDataSection
+}
+
+func NewPrivacyService(st store.Store, config PrivacyConfig, sections ...UserDataSection) *PrivacyService {
+	return &PrivacyService{store: st, config: config, sections: sections}
+}
+
+// Export returns everything stored about the user.
+func (s *PrivacyService) Export(userID string) (*UserDataExport, error) {
+	export := &UserDataExport{
+		UserID:     userID,
+		ExportedAt: time.Now().UTC(),
+		Sections:   make(map[string]json.RawMessage, len(s.sections)),
+	}
+	for _, section := range s.sections {
+		data, err := section.ExportUserData(userID)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", section.UserDataName(), err)
+		}
+		export.Sections[section.UserDataName()] = data
+	}
+	return export, nil
+}
+
+// RequestDeletion schedules the user's account for deletion after the
+// grace period. Requesting again keeps the pending request.
+func (s *PrivacyService) RequestDeletion(userID string) (DeletionRequest, error) {
+	if request, err := s.Deletion(userID); err == nil && request.Status != DeletionCompleted {
+		return request, nil
+	} else if err != nil && !errors.Is(err, ErrDeletionNotFound) {
+		return DeletionRequest{}, err
+	}
+	now := time.Now().UTC()
+	request := DeletionRequest{
+		UserID:      userID,
+		Status:      DeletionPending,
+		RequestedAt: now,
+		DeleteAfter: now.Add(s.config.GracePeriod),
+	}
+	if err := setJSON(s.store, deletionKeyPrefix+userID, request, 0); err != nil {
+		return DeletionRequest{}, err
+	}
+	return request, nil
+}
+
+// CancelDeletion withdraws a deletion that has not been carried out.
+func (s *PrivacyService) CancelDeletion(userID string) error {
+	request, err := s.Deletion(userID)
+	if err != nil {
+		return err
+	}
+	if request.Status == DeletionCompleted {
+		return ErrDeletionStarted
+	}
+	return s.store.Delete(deletionKeyPrefix + userID)
+}
+
+func (s *PrivacyService) Deletion(userID string) (DeletionRequest, error) {
+	var request DeletionRequest
+	if err := getJSON(s.store, deletionKeyPrefix+userID, &request); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return DeletionRequest{}, ErrDeletionNotFound
+		}
+		return DeletionRequest{}, err
+	}
+	return request, nil
+}
+
+// Run carries out due deletions every interval until ctx is cancelled.
+func (s *PrivacyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := s.store.SetNX(deletionLeaseKey, []byte("1"), s.config.Interval/2)
+		if err != nil {
+			log.Printf("Error acquiring account deletion lease: %v", err)
+			continue
+		}
+		if ok {
+			s.ProcessDeletions(time.Now())
+		}
+	}
+}
+
+// ProcessDeletions carries out the deletions due at now and those that
+// failed verification before.
+func (s *PrivacyService) ProcessDeletions(now time.Time) {
+	requests, err := listJSON[DeletionRequest](s.store, deletionKeyPrefix)
+	if err != nil {
+		log.Printf("Error listing account deletions: %v", err)
+		return
+	}
+	for _, request := range requests {
+		if request.Status == DeletionCompleted || now.Before(request.DeleteAfter) {
+			continue
+		}
+		if err := s.delete(request, now); err != nil {
+			log.Printf("Error deleting account %s: %v", request.UserID, err)
+		}
+	}
+}
+
+// delete anonymizes every section, then verifies that none still exports
+// anything for the user before marking the request completed.
+func (s *PrivacyService) delete(request DeletionRequest, now time.Time) error {
+	if request.Pseudonym == "" {
+		pseudonym, err := newPseudonym()
+		if err != nil {
+			return err
+		}
+		request.Pseudonym = pseudonym
+		// Stored before any section uses it, for the retries
+		if err := setJSON(s.store, deletionKeyPrefix+request.UserID, request, 0); err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, section := range s.sections {
+		if err := section.AnonymizeUserData(request.UserID, request.Pseudonym); err != nil {
+			errs = append(errs, fmt.Errorf("anonymize %s: %w", section.UserDataName(), err))
+		}
+	}
+
+	request.Remaining = nil
+	for _, section := range s.sections {
+		data, err := section.ExportUserData(request.UserID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("verify %s: %w", section.UserDataName(), err))
+			request.Remaining = append(request.Remaining, section.UserDataName())
+		} else if string(data) != "null" {
+			request.Remaining = append(request.Remaining, section.UserDataName())
+		}
+	}
+	request.Status = DeletionCompleted
+	if len(request.Remaining) > 0 {
+		request.Status = DeletionFailed
+		errs = append(errs, fmt.Errorf("data left in %s", strings.Join(request.Remaining, ", ")))
+	} else {
+		completed := now.UTC()
+		request.CompletedAt = &completed
+		request.Pseudonym = ""
+		log.Printf("Deleted account %s", request.UserID)
+	}
+	if err := setJSON(s.store, deletionKeyPrefix+request.UserID, request, 0); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func newPseudonym() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return deletedUserPrefix + hex.EncodeToString(b), nil
+}
+
+// userDataEntry is one exported record: its store key and value.
+type userDataEntry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// exportEntries reads keys, skipping those deleted meanwhile; it returns
+// null when there are none.
+func exportEntries(st store.Store, keys []string) (json.RawMessage, error) {
+	var entries []userDataEntry
+	for _, key := range keys {
+		value, err := st.Get(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(value) {
+			value, _ = json.Marshal(string(value))
+		}
+		entries = append(entries, userDataEntry{Key: key, Value: value})
+	}
+	return json.Marshal(entries)
+}
+
+// replaceUserID sets a JSON object's userId to pseudonym. Other values
+// are returned unchanged.
+func replaceUserID(value []byte, pseudonym string) []byte {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(value, &record); err != nil {
+		return value
+	}
+	if _, ok := record["userId"]; !ok {
+		return value
+	}
+	record["userId"], _ = json.Marshal(pseudonym)
+	data, err := json.Marshal(record)
+	if err != nil {
+		return value
+	}
+	return data
+}
+
+// userKeySection holds the records whose keys end in marker followed by
+// the user's ID under any of prefixes, such as registration:contest:<id>:
+// followed by the user. Anonymizing moves them to the pseudonym, so counts
+// and rankings stay, or deletes them when erase is set.
+type userKeySection struct {
+	store    store.Store
+	name     string
+	prefixes []string
+	marker   string
+	erase    bool
+}
+
+func (s userKeySection) UserDataName() string {
+	return s.name
+}
+
+func (s userKeySection) keys(userID string) ([]string, error) {
+	var keys []string
+	for _, prefix := range s.prefixes {
+		found, err := s.store.Keys(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range found {
+			if strings.HasSuffix(key, s.marker+userID) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s userKeySection) ExportUserData(userID string) (json.RawMessage, error) {
+	keys, err := s.keys(userID)
+	if err != nil {
+		return nil, err
+	}
+	return exportEntries(s.store, keys)
+}
+
+func (s userKeySection) AnonymizeUserData(userID, pseudonym string) error {
+	keys, err := s.keys(userID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !s.erase {
+			value, err := s.store.Get(key)
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			moved := strings.TrimSuffix(key, userID) + pseudonym
+			if err := s.store.Set(moved, replaceUserID(value, pseudonym), 0); err != nil {
+				return err
+			}
+		}
+		if err := s.store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userFieldSection holds the records under prefix whose userId is the
+// user's. Anonymizing replaces the ID with the pseudonym, or deletes the
+// records when erase is set.
+type userFieldSection struct {
+	store  store.Store
+	name   string
+	prefix string
+	erase  bool
+}
+
+func (s userFieldSection) UserDataName() string {
+	return s.name
+}
+
+func (s userFieldSection) keys(userID string) ([]string, error) {
+	found, err := s.store.Keys(s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(found)
+	var keys []string
+	for _, key := range found {
+		var record struct {
+			UserID string `json:"userId"`
+		}
+		if err := getJSON(s.store, key, &record); errors.Is(err, store.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if record.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s userFieldSection) ExportUserData(userID string) (json.RawMessage, error) {
+	keys, err := s.keys(userID)
+	if err != nil {
+		return nil, err
+	}
+	return exportEntries(s.store, keys)
+}
+
+func (s userFieldSection) AnonymizeUserData(userID, pseudonym string) error {
+	keys, err := s.keys(userID)
+	if err != nil {
+		return err
+	}
+	if s.erase {
+		return s.store.Delete(keys...)
+	}
+	for _, key := range keys {
+		value, err := s.store.Get(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.store.Set(key, replaceUserID(value, pseudonym), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
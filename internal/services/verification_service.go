@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	mathrand "math/rand"
+	"online-judge/internal/judge"
+	"online-judge/internal/store"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var ErrVerificationNotFound = errors.New("verification not found")
+
+type VerificationStatus string
+
+const (
+	VerificationOpen     VerificationStatus = "open"
+	VerificationResolved VerificationStatus = "resolved"
+)
+
+// Reasons a double-judged submission is flagged for review.
+const (
+	ReasonVerdictMismatch       = "verdict_mismatch"
+	ReasonScoreMismatch         = "score_mismatch"
+	ReasonNearTimeLimit         = "near_time_limit"
+	ReasonCheckerNondeterminism = "checker_nondeterminism"
+)
+
+// nearTimeLimit is the fraction of the time limit above which a run is
+// considered too close to call.
+const nearTimeLimit = 0.9
+
+type JudgementSummary struct {
+	Verdict Verdict `json:"verdict"`
+	Score   float64 `json:"score"`
+}
+
+// TestDiscrepancy is one test on which the two judgements disagree.
+type TestDiscrepancy struct {
+	Test      int         `json:"test"`
+	Primary   *TestResult `json:"primary,omitempty"`
+	Secondary *TestResult `json:"secondary,omitempty"`
+}
+
+// Verification is a double-judged submission whose two judgements differ,
+// kept for manual review.
+type Verification struct {
+	SubmissionID string             `json:"submissionId"`
+	UserID       string             `json:"userId"`
+	ContestID    string             `json:"contestId,omitempty"`
+	ProblemID    string             `json:"problemId"`
+	Primary      JudgementSummary   `json:"primary"`
+	Secondary    JudgementSummary   `json:"secondary"`
+	Reasons      []string           `json:"reasons"`
+	Tests        []TestDiscrepancy  `json:"tests,omitempty"`
+	Status       VerificationStatus `json:"status"`
+	Resolution   string             `json:"resolution,omitempty"`
+	ResolvedBy   string             `json:"resolvedBy,omitempty"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	ResolvedAt   *time.Time         `json:"resolvedAt,omitempty"`
+}
+
+type VerificationConfig struct {
+	// SampleRate is the fraction of judged submissions judged again.
+	SampleRate float64
+	// JudgeURL is the independent judge worker used for the second run.
+	JudgeURL string
+}
+
+// VerificationConfigFromEnv reads VERIFY_SAMPLE_RATE and JUDGE_VERIFY_URL.
+// The returned bool is false unless both are set.
+func VerificationConfigFromEnv() (VerificationConfig, bool) {
+	config := VerificationConfig{JudgeURL: os.Getenv("JUDGE_VERIFY_URL")}
+	if value := os.Getenv("VERIFY_SAMPLE_RATE"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			log.Printf("Invalid VERIFY_SAMPLE_RATE %q, double judging disabled", value)
+			return config, false
+		}
+		config.SampleRate = rate
+	}
+	return config, config.JudgeURL != "" && config.SampleRate > 0
+}
+
+const verificationKeyPrefix = "verification:"
+
+type verificationJob struct {
+	submission Submission
+	problem    Problem
+}
+
+// VerificationService judges a sample of graded submissions a second time
+// on an independent judge worker and records any disagreement for review.
+// Second runs are queued in process and run one at a time, so verification
+// never takes more than one judge slot.
+type VerificationService struct {
+	store      store.Store
+	secondary  *GradingService
+	sampleRate float64
+	queue      chan verificationJob
+}
+
+func NewVerificationService(st store.Store, problemService *ProblemService, submissionService *SubmissionService, config VerificationConfig) *VerificationService {
+	s := &VerificationService{
+		store:      st,
+		secondary:  NewGradingService(problemService, submissionService, judge.NewClient(config.JudgeURL)),
+		sampleRate: config.SampleRate,
+		queue:      make(chan verificationJob, 256),
+	}
+	go s.run()
+	return s
+}
+
+// Sample is a GradedListener that queues a random sample of judged
+// submissions for a second judgement.
+func (s *VerificationService) Sample(submission Submission, problem Problem) {
+	if submission.Status != SubmissionJudged || mathrand.Float64() >= s.sampleRate {
+		return
+	}
+	select {
+	case s.queue <- verificationJob{submission: submission, problem: problem}:
+	default:
+		log.Printf("Verification queue full, skipping submission %s", submission.ID)
+	}
+}
+
+func (s *VerificationService) run() {
+	for job := range s.queue {
+		if err := s.verify(job.submission, job.problem); err != nil {
+			log.Printf("Error double judging submission %s: %v", job.submission.ID, err)
+		}
+	}
+}
+
+// verify judges the submission again, reusing its test order seed, and
+// stores a verification when the judgements differ.
+func (s *VerificationService) verify(primary Submission, problem Problem) error {
+	secondary, err := s.secondary.grade(context.Background(), primary, false)
+	if err != nil {
+		return err
+	}
+
+	verification, differs := compareJudgements(primary, secondary, problem)
+	if !differs {
+		return nil
+	}
+	log.Printf("Submission %s judged differently on the verification worker: %v", primary.ID, verification.Reasons)
+	return setJSON(s.store, verificationKeyPrefix+primary.ID, verification, 0)
+}
+
+// compareJudgements reports whether two judgements of the same submission
+// disagree and, if so, why.
+func compareJudgements(primary, secondary Submission, problem Problem) (Verification, bool) {
+	verification := Verification{
+		SubmissionID: primary.ID,
+		UserID:       primary.UserID,
+		ContestID:    primary.ContestID,
+		ProblemID:    primary.ProblemID,
+		Primary:      JudgementSummary{Verdict: primary.Verdict, Score: primary.Score},
+		Secondary:    JudgementSummary{Verdict: secondary.Verdict, Score: secondary.Score},
+		Status:       VerificationOpen,
+		CreatedAt:    time.Now(),
+	}
+
+	reasons := make(map[string]bool)
+	if primary.Verdict != secondary.Verdict {
+		reasons[ReasonVerdictMismatch] = true
+	}
+	if primary.Score != secondary.Score {
+		reasons[ReasonScoreMismatch] = true
+	}
+
+	primaryTests := resultsByTest(primary.Results)
+	secondaryTests := resultsByTest(secondary.Results)
+	for test := range mergeKeys(primaryTests, secondaryTests) {
+		a, b := primaryTests[test], secondaryTests[test]
+		if a != nil && b != nil && a.Verdict == b.Verdict && a.Score == b.Score {
+			continue
+		}
+		verification.Tests = append(verification.Tests, TestDiscrepancy{Test: test, Primary: a, Secondary: b})
+		if a == nil || b == nil {
+			continue
+		}
+		if a.Time >= nearTimeLimit*problem.TimeLimit || b.Time >= nearTimeLimit*problem.TimeLimit {
+			reasons[ReasonNearTimeLimit] = true
+		}
+		// both runs finished but the checker scored them differently
+		if a.Score != b.Score && a.Verdict != VerdictTimeLimitExceeded && b.Verdict != VerdictTimeLimitExceeded &&
+			a.Verdict != VerdictRuntimeError && b.Verdict != VerdictRuntimeError && problem.Checker != nil {
+			reasons[ReasonCheckerNondeterminism] = true
+		}
+	}
+	sort.Slice(verification.Tests, func(i, j int) bool { return verification.Tests[i].Test < verification.Tests[j].Test })
+
+	for reason := range reasons {
+		verification.Reasons = append(verification.Reasons, reason)
+	}
+	sort.Strings(verification.Reasons)
+	return verification, len(verification.Reasons) > 0 || len(verification.Tests) > 0
+}
+
+func resultsByTest(results []TestResult) map[int]*TestResult {
+	byTest := make(map[int]*TestResult, len(results))
+	for i := range results {
+		byTest[results[i].Test] = &results[i]
+	}
+	return byTest
+}
+
+func mergeKeys(a, b map[int]*TestResult) map[int]bool {
+	keys := make(map[int]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
+
+// List returns verifications, oldest first, optionally filtered by status.
+func (s *VerificationService) List(status VerificationStatus) ([]Verification, error) {
+	all, err := listJSON[Verification](s.store, verificationKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	verifications := make([]Verification, 0, len(all))
+	for _, v := range all {
+		if status == "" || v.Status == status {
+			verifications = append(verifications, v)
+		}
+	}
+	sort.Slice(verifications, func(i, j int) bool {
+		return verifications[i].CreatedAt.Before(verifications[j].CreatedAt)
+	})
+	return verifications, nil
+}
+
+func (s *VerificationService) Get(submissionID string) (Verification, error) {
+	var verification Verification
+	if err := getJSON(s.store, verificationKeyPrefix+submissionID, &verification); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return Verification{}, ErrVerificationNotFound
+		}
+		return Verification{}, err
+	}
+	return verification, nil
+}
+
+// Resolve closes a verification after manual review.
+func (s *VerificationService) Resolve(submissionID, judgeID, resolution string) (Verification, error) {
+	verification, err := s.Get(submissionID)
+	if err != nil {
+		return Verification{}, err
+	}
+	now := time.Now()
+	verification.Status = VerificationResolved
+	verification.Resolution = resolution
+	verification.ResolvedBy = judgeID
+	verification.ResolvedAt = &now
+	if err := setJSON(s.store, verificationKeyPrefix+submissionID, verification, 0); err != nil {
+		return Verification{}, err
+	}
+	return verification, nil
+}
+
+// UserData returns the privacy section holding double-judging reviews of
+// the user's submissions, which are kept under the pseudonym.
+func (s *VerificationService) UserData() UserDataSection {
+	return userFieldSection{store: s.store, name: "verifications", prefix: verificationKeyPrefix}
+}
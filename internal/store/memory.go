@@ -0,0 +1,177 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	lists   map[string][][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		lists:   make(map[string][][]byte),
+	}
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookupLocked(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = newMemoryEntry(value, ttl)
+	return nil
+}
+
+func (s *MemoryStore) Delete(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.entries, key)
+		delete(s.lists, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lookupLocked(key); ok {
+		return false, nil
+	}
+	s.entries[key] = newMemoryEntry(value, ttl)
+	return true, nil
+}
+
+func (s *MemoryStore) Expire(key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookupLocked(key)
+	if !ok {
+		return ErrNotFound
+	}
+	s.entries[key] = newMemoryEntry(e.value, ttl)
+	return nil
+}
+
+func (s *MemoryStore) Incr(key string) (int64, error) {
+	return s.incrBy(key, 1)
+}
+
+func (s *MemoryStore) Decr(key string) (int64, error) {
+	return s.incrBy(key, -1)
+}
+
+func (s *MemoryStore) incrBy(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	e, ok := s.lookupLocked(key)
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(string(e.value), 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	n += delta
+	e.value = []byte(strconv.FormatInt(n, 10))
+	s.entries[key] = e
+	return n, nil
+}
+
+func (s *MemoryStore) Keys(prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var keys []string
+	for key, e := range s.entries {
+		if strings.HasPrefix(key, prefix) && !e.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	// like Redis, non-empty lists are keys too
+	for key := range s.lists {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) Push(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lists[key] = append(s.lists[key], append([]byte(nil), value...))
+	return nil
+}
+
+func (s *MemoryStore) Pop(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.lists[key]
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	value := list[0]
+	if len(list) == 1 {
+		delete(s.lists, key)
+	} else {
+		s.lists[key] = list[1:]
+	}
+	return value, nil
+}
+
+func (s *MemoryStore) Len(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.lists[key])), nil
+}
+
+func (s *MemoryStore) lookupLocked(key string) (memoryEntry, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if e.expired(time.Now()) {
+		delete(s.entries, key)
+		return memoryEntry{}, false
+	}
+	return e, true
+}
+
+func newMemoryEntry(value []byte, ttl time.Duration) memoryEntry {
+	e := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+	return e
+}
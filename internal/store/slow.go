@@ -0,0 +1,79 @@
+package store
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SlowStore delays every call to the store it wraps by a random time up to
+// a maximum, to test timeouts and races against a slow database in chaos
+// mode.
+type SlowStore struct {
+	Store
+	maxDelay time.Duration
+}
+
+func NewSlowStore(st Store, maxDelay time.Duration) *SlowStore {
+	return &SlowStore{Store: st, maxDelay: maxDelay}
+}
+
+func (s *SlowStore) wait() {
+	if s.maxDelay > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(s.maxDelay))))
+	}
+}
+
+func (s *SlowStore) Get(key string) ([]byte, error) {
+	s.wait()
+	return s.Store.Get(key)
+}
+
+func (s *SlowStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.wait()
+	return s.Store.Set(key, value, ttl)
+}
+
+func (s *SlowStore) Delete(keys ...string) error {
+	s.wait()
+	return s.Store.Delete(keys...)
+}
+
+func (s *SlowStore) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.wait()
+	return s.Store.SetNX(key, value, ttl)
+}
+
+func (s *SlowStore) Expire(key string, ttl time.Duration) error {
+	s.wait()
+	return s.Store.Expire(key, ttl)
+}
+
+func (s *SlowStore) Incr(key string) (int64, error) {
+	s.wait()
+	return s.Store.Incr(key)
+}
+
+func (s *SlowStore) Decr(key string) (int64, error) {
+	s.wait()
+	return s.Store.Decr(key)
+}
+
+func (s *SlowStore) Keys(prefix string) ([]string, error) {
+	s.wait()
+	return s.Store.Keys(prefix)
+}
+
+func (s *SlowStore) Push(key string, value []byte) error {
+	s.wait()
+	return s.Store.Push(key, value)
+}
+
+func (s *SlowStore) Pop(key string) ([]byte, error) {
+	s.wait()
+	return s.Store.Pop(key)
+}
+
+func (s *SlowStore) Len(key string) (int64, error) {
+	s.wait()
+	return s.Store.Len(key)
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"strconv"
+	"time"
+)
+
+// sqliteMigrations create and evolve the schema, in order. The database's
+// user_version is how many have been applied; append new ones, never edit
+// applied ones.
+var sqliteMigrations = []string{
+	`CREATE TABLE kv (
+		key TEXT PRIMARY KEY,
+		value BLOB NOT NULL,
+		expires_at INTEGER -- Unix milliseconds, NULL for never
+	);
+	CREATE INDEX kv_expires_at ON kv (expires_at) WHERE expires_at IS NOT NULL;
+	CREATE TABLE list (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key TEXT NOT NULL,
+		value BLOB NOT NULL
+	);
+	CREATE INDEX list_key ON list (key, id);`,
+}
+
+// SQLiteStore keeps the state in a SQLite database file, so a single API
+// instance needs no Redis and keeps its state across restarts. It uses one
+// connection, which makes read-modify-write calls such as Incr atomic, so
+// the file must not be shared by several API instances.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// migrate applies the migrations the database lacks, each in its own
+// transaction.
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database is at version %d, newer than this build's %d", version, len(sqliteMigrations))
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA takes no parameters
+		if _, err := tx.Exec(`PRAGMA user_version = ` + strconv.Itoa(i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// sqliteNow is the current time as stored in expires_at.
+func sqliteNow() int64 {
+	return time.Now().UnixMilli()
+}
+
+// sqliteExpiry is expires_at for ttl, NULL for none.
+func sqliteExpiry(ttl time.Duration) any {
+	if ttl <= 0 {
+		return nil
+	}
+	return time.Now().Add(ttl).UnixMilli()
+}
+
+func (s *SQLiteStore) Get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM kv WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)`, key, sqliteNow()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *SQLiteStore) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := s.db.Exec(`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, value, sqliteExpiry(ttl))
+	return err
+}
+
+func (s *SQLiteStore) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, key := range keys {
+		if _, err := tx.Exec(`DELETE FROM kv WHERE key = ?`, key); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM list WHERE key = ?`, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	// an expired key is replaced as if it did not exist
+	result, err := s.db.Exec(`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at
+		WHERE kv.expires_at IS NOT NULL AND kv.expires_at <= ?`,
+		key, value, sqliteExpiry(ttl), sqliteNow())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *SQLiteStore) Expire(key string, ttl time.Duration) error {
+	result, err := s.db.Exec(`UPDATE kv SET expires_at = ? WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)`,
+		sqliteExpiry(ttl), key, sqliteNow())
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Incr(key string) (int64, error) {
+	return s.incrBy(key, 1)
+}
+
+func (s *SQLiteStore) Decr(key string) (int64, error) {
+	return s.incrBy(key, -1)
+}
+
+// incrBy keeps the key's expiry, as Redis does.
+func (s *SQLiteStore) incrBy(key string, delta int64) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var n int64
+	var value []byte
+	var expiresAt sql.NullInt64
+	err = tx.QueryRow(`SELECT value, expires_at FROM kv WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)`, key, sqliteNow()).Scan(&value, &expiresAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return 0, err
+	default:
+		if n, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	n += delta
+	if _, err := tx.Exec(`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, []byte(strconv.FormatInt(n, 10)), expiresAt); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// Keys also removes expired keys, which nothing else reads again.
+func (s *SQLiteStore) Keys(prefix string) ([]string, error) {
+	now := sqliteNow()
+	if _, err := s.db.Exec(`DELETE FROM kv WHERE expires_at <= ?`, now); err != nil {
+		return nil, err
+	}
+	// like Redis, non-empty lists are keys too
+	rows, err := s.db.Query(`SELECT key FROM kv WHERE substr(key, 1, length(?1)) = ?1
+		UNION SELECT DISTINCT key FROM list WHERE substr(key, 1, length(?1)) = ?1`, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLiteStore) Push(key string, value []byte) error {
+	_, err := s.db.Exec(`INSERT INTO list (key, value) VALUES (?, ?)`, key, value)
+	return err
+}
+
+func (s *SQLiteStore) Pop(key string) ([]byte, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var value []byte
+	err = tx.QueryRow(`SELECT id, value FROM list WHERE key = ? ORDER BY id LIMIT 1`, key).Scan(&id, &value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM list WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return value, tx.Commit()
+}
+
+func (s *SQLiteStore) Len(key string) (int64, error) {
+	var n int64
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM list WHERE key = ?`, key).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// Store is the shared key/value state used by services that must behave the
+// same on every API replica. Values are opaque bytes; services encode them as
+// JSON. A zero ttl means the key never expires.
+type Store interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(keys ...string) error
+	// SetNX sets key only if it does not exist and reports whether it did.
+	SetNX(key string, value []byte, ttl time.Duration) (bool, error)
+	// Expire resets the ttl of an existing key.
+	Expire(key string, ttl time.Duration) error
+	Incr(key string) (int64, error)
+	Decr(key string) (int64, error)
+	// Keys returns every key that starts with prefix.
+	Keys(prefix string) ([]string, error)
+
+	// Push appends to the list at key; Pop removes from its head and returns
+	// ErrNotFound when the list is empty.
+	Push(key string, value []byte) error
+	Pop(key string) ([]byte, error)
+	Len(key string) (int64, error)
+}
+
+// FromEnv returns a Redis-backed store when REDIS_URL is set, a SQLite
+// database at SQLITE_PATH when that is set, and an in-memory store
+// otherwise. The SQLite and in-memory stores are only correct for a single
+// API instance.
+func FromEnv() (Store, error) {
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		return NewRedisStore(url)
+	}
+	if path := os.Getenv("SQLITE_PATH"); path != "" {
+		return NewSQLiteStore(path)
+	}
+	return NewMemoryStore(), nil
+}
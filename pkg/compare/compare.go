@@ -0,0 +1,80 @@
+// Package compare decides whether a program's output answers a test.
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Comparator reports whether actual output matches the expected answer.
+// OutputsMatch is the default.
+type Comparator func(expected, actual string) bool
+
+// OutputsMatch compares program output with the expected answer, ignoring
+// trailing whitespace on each line and trailing blank lines.
+func OutputsMatch(expected, actual string) bool {
+	return normalizeOutput(expected) == normalizeOutput(actual)
+}
+
+func normalizeOutput(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// ResultSetsMatch compares query results printed as JSON arrays of rows,
+// one per statement, as by the sql language. Rows compare by column name
+// and value; unless ordered, each statement's rows may come in any order.
+// Output that is not such JSON falls back to OutputsMatch.
+func ResultSetsMatch(expected, actual string, ordered bool) bool {
+	want, err := parseResultSets(expected, ordered)
+	if err != nil {
+		return OutputsMatch(expected, actual)
+	}
+	got, err := parseResultSets(actual, ordered)
+	if err != nil || len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if strings.Join(want[i], "\n") != strings.Join(got[i], "\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// parseResultSets returns each statement's rows in a canonical form,
+// sorted unless ordered.
+func parseResultSets(output string, ordered bool) ([][]string, error) {
+	decoder := json.NewDecoder(strings.NewReader(output))
+	decoder.UseNumber()
+	var sets [][]string
+	for {
+		var rows []map[string]any
+		err := decoder.Decode(&rows)
+		if errors.Is(err, io.EOF) {
+			return sets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		set := make([]string, 0, len(rows))
+		for _, row := range rows {
+			// maps marshal with sorted keys, so column order does not matter
+			canonical, err := json.Marshal(row)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, string(canonical))
+		}
+		if !ordered {
+			sort.Strings(set)
+		}
+		sets = append(sets, set)
+	}
+}
@@ -0,0 +1,169 @@
+// Package languages is the registry of languages programs are written in.
+package languages
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrInvalidLanguage is returned by Register for a language missing what
+// every language needs.
+var ErrInvalidLanguage = errors.New("invalid language")
+
+// Language describes how to build and run a submission. CompileCmd and
+// RunCmd both run inside a sandbox box, where the work directory contents
+// are copied; the compiler is looked up on PATH, RunCmd[0] must be absolute.
+type Language struct {
+	Name       string   `json:"name"`
+	SourceFile string   `json:"sourceFile"`
+	CompileCmd []string `json:"-"`
+	RunCmd     []string `json:"-"`
+	// PrecompiledHeaders are built once at startup with PrecompileCmd, whose
+	// flags must match CompileCmd or the compiler ignores the result.
+	PrecompiledHeaders []string `json:"-"`
+	PrecompileCmd      []string `json:"-"`
+	// WarmupCmd runs once at startup to fill the toolchain's own caches.
+	WarmupCmd []string `json:"-"`
+	// Files are written next to the source unless the submission brings
+	// files of the same name.
+	Files map[string]string `json:"-"`
+	// Tools, when set, are the only commands the program finds in /usr/bin
+	// and /bin, on backends that mount the host's toolchain.
+	Tools []string `json:"-"`
+	// Env is set for every run of the language, before the problem's own
+	// variables, which win on a clash. It is the operator's to set and
+	// skips the allowlist.
+	Env map[string]string `json:"-"`
+	// MaxProcesses is the process limit for runs that set none; zero means
+	// the judge's default of one.
+	MaxProcesses int `json:"-"`
+	// Mounts are host directories the toolchain needs beyond the box's
+	// default mounts, shown read-only at the same path where they exist.
+	// Docker images bring their own runtime and ignore them.
+	Mounts []string `json:"-"`
+	// Module is the WebAssembly module the program builds to, which the
+	// wasm backend runs with deterministic limits; RunCmd runs it without
+	// them on other backends.
+	Module string `json:"-"`
+}
+
+var (
+	mu        sync.RWMutex
+	languages = map[string]Language{
+		"bash": {
+			Name:       "bash",
+			SourceFile: "main.sh",
+			RunCmd:     []string{"/bin/bash", "main.sh"},
+			Env:        map[string]string{"LANG": "C.UTF-8"},
+			// scripting courses get the text tools and little else
+			Tools: []string{"bash", "sh", "cat", "cut", "echo", "printf", "grep", "sed", "awk",
+				"sort", "uniq", "wc", "head", "tail", "tr", "paste", "seq", "expr", "rev",
+				"tee", "xargs", "basename", "dirname", "ls", "mkdir", "rm", "cp", "mv", "touch",
+				"true", "false", "test", "date", "sleep"},
+			MaxProcesses: 8,
+		},
+		"c": {
+			Name:       "c",
+			SourceFile: "main.c",
+			CompileCmd: []string{"gcc", "-O2", "-std=c17", "-o", "main", "main.c", "-lm"},
+			RunCmd:     []string{"./main"},
+		},
+		"cpp": {
+			Name:       "cpp",
+			SourceFile: "main.cpp",
+			CompileCmd: []string{"g++", "-O2", "-std=c++17", "-o", "main", "main.cpp"},
+			RunCmd:     []string{"./main"},
+
+			PrecompiledHeaders: []string{"bits/stdc++.h"},
+			PrecompileCmd:      []string{"g++", "-O2", "-std=c++17", "-x", "c++-header"},
+		},
+		"java": {
+			Name:       "java",
+			SourceFile: "Main.java",
+			CompileCmd: []string{"javac", "Main.java"},
+			RunCmd:     []string{"/usr/bin/java", "-Xss64m", "Main"},
+			// read and print UTF-8 whatever the host's locale
+			Env: map[string]string{"LANG": "C.UTF-8"},
+			// the JVM starts its compiler and garbage collector threads, which
+			// count as processes
+			MaxProcesses: 64,
+			// java and javac are links through the alternatives system, which
+			// lives under /etc
+			Mounts: []string{"/etc/alternatives"},
+			// regenerate the JDK's class data sharing archive to speed up startup
+			WarmupCmd: []string{"/usr/bin/java", "-Xshare:dump"},
+		},
+		"python": {
+			Name:       "python",
+			SourceFile: "main.py",
+			RunCmd:     []string{"/usr/bin/python3", "main.py"},
+			// a fixed hash seed keeps set and dict iteration order the same
+			// from run to run
+			Env: map[string]string{"PYTHONHASHSEED": "0", "LANG": "C.UTF-8"},
+			// byte-compile the standard library so imports skip compilation
+			WarmupCmd: []string{"/usr/bin/python3", "-c",
+				"import compileall, sysconfig; compileall.compile_dir(sysconfig.get_paths()['stdlib'], quiet=1)"},
+		},
+		"sql": {
+			Name:       "sql",
+			SourceFile: "query.sql",
+			// an in-memory SQLite database is seeded from seed.sql, then from
+			// the input, before the query runs; every statement's rows are
+			// printed as a JSON array
+			RunCmd: []string{"/usr/bin/sqlite3", "-batch", "-bail", ":memory:",
+				".read seed.sql", ".read /dev/stdin", ".mode json", ".read query.sql"},
+			Files: map[string]string{"seed.sql": ""},
+		},
+		// C built with the WASI SDK
+		"c-wasm": {
+			Name:       "c-wasm",
+			SourceFile: "main.c",
+			CompileCmd: []string{"/opt/wasi-sdk/bin/clang", "--target=wasm32-wasip1", "-O2", "-o", "main.wasm", "main.c", "-lm"},
+			RunCmd:     []string{"/usr/local/bin/wasmtime", "run", "--dir", ".", "--", "main.wasm"},
+			Mounts:     []string{"/opt/wasi-sdk"},
+			Module:     "main.wasm",
+		},
+		// the WebAssembly text format, which wasmtime runs as is
+		"wat": {
+			Name:       "wat",
+			SourceFile: "main.wat",
+			RunCmd:     []string{"/usr/local/bin/wasmtime", "run", "--dir", ".", "--", "main.wat"},
+			Module:     "main.wat",
+		},
+	}
+)
+
+// Lookup returns the language registered as name.
+func Lookup(name string) (Language, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	lang, ok := languages[name]
+	return lang, ok
+}
+
+// Names returns the names of every registered language, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register adds a language, or replaces the one of the same name, e.g. to
+// pin another compiler version. It must be called before programs in the
+// language are judged.
+func Register(lang Language) error {
+	if lang.Name == "" || lang.SourceFile == "" || len(lang.RunCmd) == 0 {
+		return fmt.Errorf("%w: name, source file and run command are required", ErrInvalidLanguage)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	languages[lang.Name] = lang
+	return nil
+}
@@ -0,0 +1,196 @@
+// Package runner embeds judging in other Go programs: it compiles and runs
+// submissions in a sandbox on the local machine and grades them against
+// tests, without the API server or a judge worker.
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"online-judge/internal/judge"
+	"online-judge/pkg/compare"
+	"online-judge/pkg/verdict"
+	"os"
+	"path/filepath"
+)
+
+var ErrInvalidConfig = errors.New("invalid runner configuration")
+
+// ErrUnsupportedLanguage is returned for submissions in a language missing
+// from pkg/languages.
+var ErrUnsupportedLanguage = judge.ErrUnsupportedLanguage
+
+type Config struct {
+	// WorkDir holds the per-execution directories and the build cache; it
+	// is created if missing.
+	WorkDir string
+	// Sandbox names the backend as JUDGE_SANDBOX does; empty is isolate on
+	// Linux and the process sandbox, which contains nothing, elsewhere.
+	// Backend settings such as JUDGE_ISOLATE_CGROUPS are read from the
+	// environment.
+	Sandbox string
+	// FirstBox is the first sandbox box ID the runner uses and Boxes how
+	// many executions run at once, 4 when zero. The IDs must not overlap
+	// with other judges on the machine.
+	FirstBox int
+	Boxes    int
+	// Env are the environment variables submissions may set.
+	Env []string
+	// CompileTimeLimit, in seconds of CPU time, and CompileMemoryLimit, in
+	// kilobytes, bound compilers; zero means 10 seconds and 1 GiB.
+	CompileTimeLimit   float64
+	CompileMemoryLimit int
+}
+
+// Submission is a program to run. Zero limits take the judge's defaults:
+// 2 seconds of CPU time, 256 MiB of memory and 64 MiB of output.
+type Submission struct {
+	Language    string
+	Code        string
+	TimeLimit   float64 // seconds of CPU time
+	MemoryLimit int     // kilobytes
+	// OutputLimit caps what the program writes to stdout, to stderr and to
+	// each file, in kilobytes.
+	OutputLimit int
+	Env         map[string]string
+	// Files are extra files placed next to the program.
+	Files map[string]string
+}
+
+// Result is how one run of a submission went.
+type Result struct {
+	Status        verdict.Status
+	Stdout        string
+	Stderr        string
+	CompileOutput string
+	CompileTime   float64 // seconds of wall time
+	Time          float64 // seconds of CPU time
+	WallTime      float64
+	Memory        int // peak, in kilobytes
+	ExitCode      int
+	Message       string
+}
+
+// Test is an input and the answer expected for it.
+type Test struct {
+	Input  string
+	Output string
+}
+
+type TestResult struct {
+	Verdict verdict.Verdict
+	Result  Result
+}
+
+// Report is the outcome of judging a submission: the verdict of the first
+// test that failed, or accepted, and the tests run up to it.
+type Report struct {
+	Verdict verdict.Verdict
+	Tests   []TestResult
+}
+
+// Runner judges submissions on this machine. It is safe for concurrent
+// use.
+type Runner struct {
+	judge *judge.Judge
+}
+
+// New sets up the sandbox and checks the toolchain of every language.
+func New(config Config) (*Runner, error) {
+	if config.WorkDir == "" {
+		return nil, fmt.Errorf("%w: a work directory is required", ErrInvalidConfig)
+	}
+	if config.Boxes == 0 {
+		config.Boxes = 4
+	}
+	if config.FirstBox < 0 || config.Boxes < 0 {
+		return nil, fmt.Errorf("%w: box IDs and counts must not be negative", ErrInvalidConfig)
+	}
+	limits := judge.CompileLimits{TimeLimit: config.CompileTimeLimit, MemoryLimit: config.CompileMemoryLimit}
+	if limits.TimeLimit == 0 {
+		limits.TimeLimit = 10
+	}
+	if limits.MemoryLimit == 0 {
+		limits.MemoryLimit = 1 << 20
+	}
+	if limits.TimeLimit < 0 || limits.MemoryLimit < 0 {
+		return nil, fmt.Errorf("%w: compile limits must be positive", ErrInvalidConfig)
+	}
+
+	if err := os.MkdirAll(config.WorkDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create work directory: %w", err)
+	}
+	binaryCache, err := judge.NewBinaryCache(filepath.Join(config.WorkDir, "binaries"))
+	if err != nil {
+		return nil, err
+	}
+	sandbox, err := judge.NewSandbox(config.Sandbox, config.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := judge.CheckSandboxCapabilities(sandbox); err != nil {
+		return nil, err
+	}
+	boxes, err := judge.NewBoxPool(config.FirstBox, config.FirstBox+config.Boxes-1)
+	if err != nil {
+		return nil, err
+	}
+	j := judge.New(config.WorkDir, judge.NewEnvAllowlist(config.Env), judge.PinToolchains(), nil, nil, binaryCache, sandbox, boxes, limits)
+	return &Runner{judge: j}, nil
+}
+
+// Run compiles the submission if its language needs it and runs it once
+// on input. Programs that do not compile or fail are reported in the
+// result's status; the error is for submissions that could not be run.
+func (r *Runner) Run(submission Submission, input string) (Result, error) {
+	result, err := r.judge.Execute(judge.Submission{
+		Language:    submission.Language,
+		Code:        submission.Code,
+		Input:       input,
+		TimeLimit:   submission.TimeLimit,
+		MemoryLimit: submission.MemoryLimit,
+		OutputLimit: submission.OutputLimit,
+		Env:         submission.Env,
+		Files:       submission.Files,
+		// built once for all of Judge's tests
+		CacheBinary: true,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Status:        result.Status,
+		Stdout:        result.Stdout,
+		Stderr:        result.Stderr,
+		CompileOutput: result.CompileOutput,
+		CompileTime:   result.CompileTime,
+		Time:          result.Time,
+		WallTime:      result.WallTime,
+		Memory:        result.Memory,
+		ExitCode:      result.ExitCode,
+		Message:       result.Message,
+	}, nil
+}
+
+// Judge runs the submission on each test in order until one fails.
+// Outputs are compared with match, compare.OutputsMatch when nil.
+func (r *Runner) Judge(submission Submission, tests []Test, match compare.Comparator) (Report, error) {
+	if match == nil {
+		match = compare.OutputsMatch
+	}
+	report := Report{Verdict: verdict.Accepted}
+	for _, test := range tests {
+		result, err := r.Run(submission, test.Input)
+		if err != nil {
+			return Report{}, err
+		}
+		v := verdict.For(result.Status, func() bool {
+			return match(test.Output, result.Stdout)
+		})
+		report.Tests = append(report.Tests, TestResult{Verdict: v, Result: result})
+		if v != verdict.Accepted {
+			report.Verdict = v
+			break
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,107 @@
+// Package verdict turns the outcome of running a program on a test into
+// the verdict shown to contestants.
+package verdict
+
+// Status is how a single execution of a program ended, as reported by the
+// sandbox.
+type Status string
+
+const (
+	StatusOK           Status = "ok"
+	StatusCompileError Status = "compile_error"
+	// StatusCompileTimeLimitExceeded is a compiler stopped by the compile
+	// limits, e.g. on template-heavy code.
+	StatusCompileTimeLimitExceeded Status = "compile_time_limit_exceeded"
+	StatusRuntimeError             Status = "runtime_error"
+	StatusTimeLimitExceeded        Status = "time_limit_exceeded"
+	StatusMemoryLimitExceeded      Status = "memory_limit_exceeded"
+	StatusOutputLimitExceeded      Status = "output_limit_exceeded"
+	StatusDiskQuotaExceeded        Status = "disk_quota_exceeded"
+	StatusInternalError            Status = "internal_error"
+	// StatusSecurityViolation is a program killed for a system call its
+	// language's syscall policy forbids.
+	StatusSecurityViolation Status = "security_violation"
+)
+
+// CompileFailed reports whether the program did not build, so it was
+// never run.
+func (s Status) CompileFailed() bool {
+	return s == StatusCompileError || s == StatusCompileTimeLimitExceeded
+}
+
+type Verdict string
+
+const (
+	Accepted            Verdict = "accepted"
+	Partial             Verdict = "partial"
+	WrongAnswer         Verdict = "wrong_answer"
+	TimeLimitExceeded   Verdict = "time_limit_exceeded"
+	MemoryLimitExceeded Verdict = "memory_limit_exceeded"
+	OutputLimitExceeded Verdict = "output_limit_exceeded"
+	DiskQuotaExceeded   Verdict = "disk_quota_exceeded"
+	RuntimeError        Verdict = "runtime_error"
+	CompileError        Verdict = "compile_error"
+	// CompileTimeLimitExceeded is a compiler that ran out of time.
+	CompileTimeLimitExceeded Verdict = "compile_time_limit_exceeded"
+	InternalError            Verdict = "internal_error"
+	// SecurityViolation is a program that made a system call its language
+	// may not use.
+	SecurityViolation Verdict = "security_violation"
+)
+
+var descriptions = map[Verdict]string{
+	Accepted:                 "The program passed every test.",
+	Partial:                  "The program earned part of the score.",
+	WrongAnswer:              "The program printed a wrong answer.",
+	TimeLimitExceeded:        "The program ran longer than the time limit.",
+	MemoryLimitExceeded:      "The program used more memory than the memory limit.",
+	OutputLimitExceeded:      "The program wrote more output than the output limit.",
+	DiskQuotaExceeded:        "The program wrote more files than the disk quota allows.",
+	RuntimeError:             "The program crashed or exited with an error.",
+	CompileError:             "The program did not compile.",
+	CompileTimeLimitExceeded: "The compiler ran longer than the compile time limit.",
+	InternalError:            "The judge failed to grade the program; it will be looked at.",
+	SecurityViolation:        "The program made a system call that is not allowed.",
+}
+
+// Description explains the verdict in English.
+func (v Verdict) Description() string {
+	return descriptions[v]
+}
+
+// Valid reports whether v is one of the verdicts above.
+func (v Verdict) Valid() bool {
+	_, ok := descriptions[v]
+	return ok
+}
+
+// For is the verdict of a test whose run ended with status. matches
+// compares the program's output with the expected answer; it is only
+// called for programs that ran to the end.
+func For(status Status, matches func() bool) Verdict {
+	switch status {
+	case StatusOK:
+		if matches() {
+			return Accepted
+		}
+		return WrongAnswer
+	case StatusCompileError:
+		return CompileError
+	case StatusCompileTimeLimitExceeded:
+		return CompileTimeLimitExceeded
+	case StatusTimeLimitExceeded:
+		return TimeLimitExceeded
+	case StatusMemoryLimitExceeded:
+		return MemoryLimitExceeded
+	case StatusOutputLimitExceeded:
+		return OutputLimitExceeded
+	case StatusDiskQuotaExceeded:
+		return DiskQuotaExceeded
+	case StatusRuntimeError:
+		return RuntimeError
+	case StatusSecurityViolation:
+		return SecurityViolation
+	default:
+		return InternalError
+	}
+}